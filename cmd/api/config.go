@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"go-starter-template/internal/bootstrap"
+	"go-starter-template/internal/infrastructure/config"
+)
+
+const configUsage = `usage: %[1]s config <command>
+
+commands:
+  check    load the configuration and build everything derived from it
+  print    print the settings and where they come from, secrets redacted
+`
+
+func runConfig(sources config.Sources, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, configUsage, filepath.Base(os.Args[0]))
+		return 2
+	}
+
+	switch args[0] {
+	case "check":
+		// every invalid setting is fatal on the way, like on startup
+		app := bootstrap.CheckConfig(sources)
+		defer app.DB.Close()
+
+		app.GetLogger().Info("configuration is valid")
+
+	case "print":
+		app := bootstrap.LoadConfig(sources)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+		for _, setting := range app.Config.Settings() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+		}
+		w.Flush()
+
+	default:
+		fmt.Fprintf(os.Stderr, configUsage, filepath.Base(os.Args[0]))
+		return 2
+	}
+
+	return 0
+}
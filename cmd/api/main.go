@@ -2,19 +2,93 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"go-starter-template/internal/bootstrap"
+	"go-starter-template/internal/infrastructure/config"
 )
 
+const usage = `usage: %[1]s [options] [command] [arguments]
+
+commands:
+  serve             run the web server, the default
+  migrate           apply or roll back database migrations, see %[1]s migrate help
+  create-user       add a user with a role, see %[1]s create-user -h
+  reset-password    set the password of a user and sign them out everywhere
+  purge-sessions    delete the expired sessions, or revoke those of one user
+  routes            print the routes registered on the router
+  config check      validate the configuration
+  config print      print the settings and where they come from, secrets redacted
+
+options:
+  -config FILE      YAML or TOML config file, defaults to CONFIG_FILE
+  -env-file FILE    dotenv file, defaults to .env when it exists
+  -set KEY=VALUE    override a setting, can be repeated
+
+settings are read from the defaults, the env file, the config file, the
+environment and -set, each overriding the ones before it. KEY_FILE reads the
+value of KEY from a file.
+`
+
 func main() {
+	sources := config.Sources{Flags: make(map[string]string)}
+	flag.StringVar(&sources.File, "config", "", "")
+	flag.StringVar(&sources.EnvFile, "env-file", "", "")
+	flag.Func("set", "", func(value string) error {
+		key, value, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("expected KEY=VALUE")
+		}
+		sources.Flags[key] = value
+		return nil
+	})
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, filepath.Base(os.Args[0]))
+	}
+	flag.Parse()
+
+	command, args := "serve", flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
 	ctx := context.Background()
-	app := bootstrap.Init(ctx)
+	switch command {
+	case "serve":
+		serve(ctx, sources)
+	case "migrate":
+		os.Exit(runMigrate(ctx, sources, args))
+	case "create-user":
+		os.Exit(runCreateUser(ctx, sources, args))
+	case "reset-password":
+		os.Exit(runResetPassword(ctx, sources, args))
+	case "purge-sessions":
+		os.Exit(runPurgeSessions(ctx, sources, args))
+	case "routes":
+		os.Exit(runRoutes(sources, args))
+	case "config":
+		os.Exit(runConfig(sources, args))
+	case "help":
+		fmt.Printf(usage, filepath.Base(os.Args[0]))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		fmt.Fprintf(os.Stderr, usage, filepath.Base(os.Args[0]))
+		os.Exit(2)
+	}
+}
+
+func serve(ctx context.Context, sources config.Sources) {
+	app := bootstrap.Init(ctx, sources)
 	log := app.GetLogger()
 
 	go func() {
 		if err := app.Serve(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("failed to start application: %v", err)
+			log.Fatal("failed to start application", "error", err)
 		}
 	}()
 
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-starter-template/internal/bootstrap"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/db/postgres"
+	"go-starter-template/internal/infrastructure/factories"
+	"go-starter-template/pkg/migrate"
+)
+
+const migrateUsage = `usage: %[1]s migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down           roll back the latest migration
+  redo           roll back the latest migration and apply it again
+  to VERSION     migrate up or down to VERSION, 0 rolls back everything
+  status         list the migrations and whether they are applied
+  version        print the current schema version
+  create NAME    add an empty migration to internal/migrations
+`
+
+// migrationsDir is where create writes new migrations, they are embedded
+// into the binary on the next build
+const migrationsDir = "internal/migrations"
+
+func runMigrate(ctx context.Context, sources config.Sources, args []string) int {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintf(os.Stderr, migrateUsage, filepath.Base(os.Args[0]))
+		return 2
+	}
+
+	// create only writes a file, it should work without a database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, migrateUsage, filepath.Base(os.Args[0]))
+			return 2
+		}
+		file, err := createMigration(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("created", file)
+		return 0
+	}
+
+	app := bootstrap.InitBase(ctx, sources)
+	log := app.GetLogger()
+	defer app.DB.Close()
+
+	// DDL may take longer than the statement timeout meant for requests
+	ctx = postgres.WithStatementTimeout(ctx, 0)
+
+	migrator, err := factories.NewMigrator(app.DB)
+	if err != nil {
+		log.Error("failed to load migrations", "error", err)
+		return 1
+	}
+
+	logApplied := func(direction string, migrations ...migrate.Migration) {
+		for _, migration := range migrations {
+			log.Info("migrated "+direction, "version", migration.Version, "name", migration.Name)
+		}
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		applied, err := migrator.Up(ctx)
+		logApplied("up", applied...)
+		if err != nil {
+			log.Error("failed to migrate up", "error", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			log.Info("no migrations to apply")
+		}
+
+	case args[0] == "down" && len(args) == 1:
+		migration, err := migrator.Down(ctx)
+		if errors.Is(err, migrate.ErrNoMigration) {
+			log.Info("no migrations to roll back")
+			return 0
+		}
+		if err != nil {
+			log.Error("failed to migrate down", "error", err)
+			return 1
+		}
+		logApplied("down", *migration)
+
+	case args[0] == "redo" && len(args) == 1:
+		migration, err := migrator.Redo(ctx)
+		if err != nil {
+			log.Error("failed to redo the latest migration", "error", err)
+			return 1
+		}
+		logApplied("down and up", *migration)
+
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", args[1])
+			return 2
+		}
+		migrated, direction, err := migrator.To(ctx, version)
+		logApplied(string(direction), migrated...)
+		if err != nil {
+			log.Error("failed to migrate", "to", version, "error", err)
+			return 1
+		}
+
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Error("failed to read the migration status", "error", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", appliedAt, status.File)
+		}
+		w.Flush()
+
+	case args[0] == "version" && len(args) == 1:
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			log.Error("failed to read the schema version", "error", err)
+			return 1
+		}
+		fmt.Println(version)
+
+	default:
+		fmt.Fprintf(os.Stderr, migrateUsage, filepath.Base(os.Args[0]))
+		return 2
+	}
+
+	return 0
+}
+
+func createMigration(name string) (string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	for _, char := range name {
+		if (char < 'a' || char > 'z') && (char < '0' || char > '9') && char != '_' {
+			return "", fmt.Errorf("invalid migration name %q, use letters, digits and underscores", name)
+		}
+	}
+
+	file := filepath.Join(migrationsDir, time.Now().UTC().Format("20060102150405")+"_"+name+".sql")
+	content := "-- +goose Up\n\n-- +goose Down\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("failed to create migration: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"go-starter-template/internal/bootstrap"
+	"go-starter-template/internal/infrastructure/config"
+)
+
+// runRoutes prints the routes registered by the controllers, routes serving
+// every method are listed as ANY
+func runRoutes(sources config.Sources, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: routes")
+		return 2
+	}
+
+	app := bootstrap.InitRoutes(sources)
+	defer app.DB.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATTERN")
+	for _, endpoint := range app.Router.Routes() {
+		method := endpoint.Method
+		if method == "*" {
+			method = "ANY"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", method, endpoint.Pattern)
+	}
+	w.Flush()
+	return 0
+}
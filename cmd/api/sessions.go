@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"go-starter-template/internal/bootstrap"
+	"go-starter-template/internal/infrastructure/config"
+)
+
+// runPurgeSessions deletes the expired sessions like the reaper of the
+// server, or signs a single user out everywhere with -email
+func runPurgeSessions(ctx context.Context, sources config.Sources, args []string) int {
+	flags := flag.NewFlagSet("purge-sessions", flag.ContinueOnError)
+	email := flags.String("email", "", "revoke every session of this user instead")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: purge-sessions [-email EMAIL]")
+		return 2
+	}
+
+	app := bootstrap.InitOperator(ctx, sources)
+	log := app.GetLogger()
+	defer app.DB.Close()
+
+	operatorService := app.OperatorService()
+
+	if *email != "" {
+		// revoking works for every store, the server checks the cutoff stored
+		// on the user
+		user, err := operatorService.RevokeSessions(ctx, *email)
+		if err != nil {
+			log.Error("failed to revoke sessions", "email", *email, "error", err)
+			return 1
+		}
+		log.Info("sessions revoked", "id", user.User.ID, "email", user.User.Email)
+		return 0
+	}
+
+	// the memory store lives inside the server process, out of reach of
+	// this one
+	if app.Config.SessionConfig.Store == config.SessionStoreMemory {
+		log.Error("sessions in the memory store end with the server process", "store", app.Config.SessionConfig.Store)
+		return 1
+	}
+
+	purged, err := operatorService.PurgeExpiredSessions(ctx)
+	if err != nil {
+		log.Error("failed to purge expired sessions", "error", err)
+		return 1
+	}
+	log.Info("purged expired sessions", "count", purged)
+	return 0
+}
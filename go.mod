@@ -3,12 +3,17 @@ module go-starter-template
 go 1.23.1
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/a-h/templ v0.3.833
 	github.com/google/uuid v1.3.0
 	github.com/gorilla/csrf v1.7.2
+	github.com/gorilla/securecookie v1.1.2
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	golang.org/x/crypto v0.0.0-20220517005047-85d78b3ac167
+	golang.org/x/term v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/gorilla/securecookie v1.1.2 // indirect
+require golang.org/x/sys v0.28.0 // indirect
@@ -0,0 +1,23 @@
+package command
+
+// CurrentSessionID on ChangePasswordCommand is kept signed in, every other
+// session of the user is revoked
+type ChangePasswordCommand struct {
+	UserID           int
+	CurrentSessionID string
+	CurrentPassword  string
+	NewPassword      string
+}
+
+// CurrentSessionID on ChangeEmailCommand is kept signed in as well
+type ChangeEmailCommand struct {
+	UserID           int
+	CurrentSessionID string
+	Password         string
+	Email            string
+}
+
+type DeleteAccountCommand struct {
+	UserID   int
+	Password string
+}
@@ -0,0 +1,21 @@
+package command
+
+// ActorID on the admin commands is the user performing the action, the
+// services check its permissions
+
+type ChangeUserRoleCommand struct {
+	ActorID int
+	UserID  int
+	Role    string
+}
+
+type SetUserDisabledCommand struct {
+	ActorID  int
+	UserID   int
+	Disabled bool
+}
+
+type ForceLogoutCommand struct {
+	ActorID int
+	UserID  int
+}
@@ -0,0 +1,30 @@
+package command
+
+import (
+	"go-starter-template/internal/application/result"
+	"go-starter-template/internal/domain/entities"
+)
+
+type CreateAPITokenCommand struct {
+	UserID        int
+	Name          string
+	ExpiresInDays int
+}
+
+type CreateAPITokenCommandResult struct {
+	Token *result.APITokenResult
+	// PlainText is only available right after creation, only the hash is stored
+	PlainText string
+}
+
+func NewCreateAPITokenCommandResult(token *entities.APIToken, plainText string) *CreateAPITokenCommandResult {
+	return &CreateAPITokenCommandResult{
+		Token:     result.NewAPITokenResult(token),
+		PlainText: plainText,
+	}
+}
+
+type RevokeAPITokenCommand struct {
+	ID     int
+	UserID int
+}
@@ -0,0 +1,11 @@
+package command
+
+import "go-starter-template/internal/domain/entities"
+
+type SendVerificationCommand struct {
+	User *entities.User
+}
+
+type ResendVerificationCommand struct {
+	Email string
+}
@@ -0,0 +1,44 @@
+package command
+
+type BeginIdentityLoginCommand struct {
+	Provider string
+}
+
+// BeginIdentityLoginCommandResult carries the values the callback is checked
+// against. They have to be kept by the client until the provider redirects
+// back, AuthURL is where the user is sent to log in.
+type BeginIdentityLoginCommandResult struct {
+	AuthURL  string
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// IdentityCallbackCommand is the provider redirect together with the values
+// stored by BeginIdentityLogin
+type IdentityCallbackCommand struct {
+	Provider string
+	Code     string
+	// State is the value the provider sent back, ExpectedState the one
+	// stored when the flow began
+	State         string
+	ExpectedState string
+	Nonce         string
+	Verifier      string
+}
+
+type CompleteIdentityLoginCommand struct {
+	Callback  *IdentityCallbackCommand
+	UserAgent string
+	IPAddress string
+}
+
+type LinkIdentityCommand struct {
+	UserID   int
+	Callback *IdentityCallbackCommand
+}
+
+type UnlinkIdentityCommand struct {
+	UserID     int
+	IdentityID int
+}
@@ -0,0 +1,8 @@
+package command
+
+type LoginAttemptCommand struct {
+	Email     string
+	IPAddress string
+	// UserID is set when the email belongs to an existing user
+	UserID *int
+}
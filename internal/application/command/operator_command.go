@@ -0,0 +1,18 @@
+package command
+
+// The operator commands come from the command line, whoever runs it already
+// has access to the configuration and the database so there is no actor
+
+type CreateUserCommand struct {
+	Email    string
+	Password string
+	Role     string
+	// Verified skips the email verification, the operator vouches for the
+	// address
+	Verified bool
+}
+
+type SetUserPasswordCommand struct {
+	Email    string
+	Password string
+}
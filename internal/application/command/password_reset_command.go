@@ -0,0 +1,10 @@
+package command
+
+type RequestPasswordResetCommand struct {
+	Email string
+}
+
+type ResetPasswordCommand struct {
+	Token    string
+	Password string
+}
@@ -6,8 +6,10 @@ import (
 )
 
 type CreateSessionCommand struct {
-	User         *entities.User
-	ExtendByHour int
+	User      *entities.User
+	Remember  bool
+	UserAgent string
+	IPAddress string
 }
 
 type CreateSessionCommandResult struct {
@@ -19,3 +21,27 @@ func NewCreateSessionCommandResult(session *entities.Session) *CreateSessionComm
 		Session: result.NewSessionResult(session),
 	}
 }
+
+type TouchSessionCommandResult struct {
+	Session *result.SessionResult
+	// Renewed is set when the session was saved again, because the expiry slid
+	// forward or the user snapshot was refreshed, and the cookie needs refreshing
+	Renewed bool
+}
+
+func NewTouchSessionCommandResult(session *entities.Session, renewed bool) *TouchSessionCommandResult {
+	return &TouchSessionCommandResult{
+		Session: result.NewSessionResult(session),
+		Renewed: renewed,
+	}
+}
+
+type RevokeSessionCommand struct {
+	UserID    int
+	SessionID string
+}
+
+type RevokeOtherSessionsCommand struct {
+	UserID           int
+	CurrentSessionID string
+}
@@ -6,6 +6,7 @@ import (
 )
 
 type CreateTodoCommand struct {
+	UserID      int
 	Title       string
 	Description string
 }
@@ -22,6 +23,7 @@ func NewCreateTodoCommandResult(todo *entities.Todo) *CreateTodoCommandResult {
 
 type UpdateTodoCommand struct {
 	ID          int
+	UserID      int
 	Title       string
 	Description string
 }
@@ -37,5 +39,6 @@ func NewUpdateTodoCommandResult(todo *entities.Todo) *UpdateTodoCommandResult {
 }
 
 type DeleteTodoCommand struct {
-	ID int
+	ID     int
+	UserID int
 }
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"go-starter-template/internal/application/result"
+	"go-starter-template/internal/domain/entities"
+)
+
+type BeginTwoFactorEnrollmentCommand struct {
+	UserID int
+	// Email labels the account in the authenticator app
+	Email string
+}
+
+type BeginTwoFactorEnrollmentCommandResult struct {
+	// Secret is the base32 secret for manual entry, URI carries the same
+	// secret in the otpauth format used for QR codes
+	Secret string
+	URI    string
+}
+
+// TwoFactorCodeCommand proves possession of the second factor, Code is
+// either a TOTP code or a recovery code
+type TwoFactorCodeCommand struct {
+	UserID int
+	Code   string
+}
+
+type RecoveryCodesCommandResult struct {
+	// RecoveryCodes are only available in plain text right after generation
+	RecoveryCodes []string
+}
+
+type StartTwoFactorChallengeCommand struct {
+	User     *entities.User
+	Remember bool
+	// DeviceToken is the trusted device cookie of the client, if any
+	DeviceToken string
+}
+
+type CompleteTwoFactorLoginCommand struct {
+	Token          string
+	Code           string
+	RememberDevice bool
+	UserAgent      string
+	IPAddress      string
+}
+
+type TrustDeviceCommand struct {
+	UserID    int
+	UserAgent string
+}
+
+type TrustDeviceCommandResult struct {
+	Device *result.TrustedDeviceResult
+}
+
+func NewTrustDeviceCommandResult(device *entities.TrustedDevice, plainText string) *TrustDeviceCommandResult {
+	return &TrustDeviceCommandResult{
+		Device: result.NewTrustedDeviceResult(device, plainText),
+	}
+}
@@ -21,14 +21,24 @@ func NewSignupUserCommandResult(user *entities.User) *CreateSignupCommandResult
 }
 
 type CreateLoginCommand struct {
-	Email    string
-	Password string
-	Remember bool
+	Email     string
+	Password  string
+	Remember  bool
+	UserAgent string
+	IPAddress string
+	// DeviceToken is the trusted device cookie of the client, if any
+	DeviceToken string
 }
 
 type CreateLoginCommandResult struct {
 	Session *result.SessionResult
 	User    *result.UserResult
+	// TwoFactorToken is set instead of Session when the user still has to
+	// enter a second factor
+	TwoFactorToken string
+	// TrustedDevice is set when the user asked to skip the second factor on
+	// this device from now on
+	TrustedDevice *result.TrustedDeviceResult
 }
 
 func NewLoginUserCommandResult(user *entities.User) *CreateLoginCommandResult {
@@ -0,0 +1,22 @@
+package query
+
+import (
+	"go-starter-template/internal/application/result"
+	"go-starter-template/internal/domain/entities"
+)
+
+type GetAPITokenListQuery struct {
+	Tokens []*result.APITokenResult
+}
+
+func NewGetAPITokenListQuery(tokens []*entities.APIToken) *GetAPITokenListQuery {
+	tokenResults := make([]*result.APITokenResult, 0, len(tokens))
+
+	for _, token := range tokens {
+		tokenResults = append(tokenResults, result.NewAPITokenResult(token))
+	}
+
+	return &GetAPITokenListQuery{
+		Tokens: tokenResults,
+	}
+}
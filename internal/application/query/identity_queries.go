@@ -0,0 +1,23 @@
+package query
+
+import (
+	"go-starter-template/internal/application/result"
+	"go-starter-template/internal/domain/entities"
+)
+
+type GetIdentityListQuery struct {
+	Identities []*result.IdentityResult
+}
+
+// NewGetIdentityListQuery resolves display names through providerName
+func NewGetIdentityListQuery(identities []*entities.Identity, providerName func(provider string) string) *GetIdentityListQuery {
+	identityResults := make([]*result.IdentityResult, 0, len(identities))
+
+	for _, identity := range identities {
+		identityResults = append(identityResults, result.NewIdentityResult(identity, providerName(identity.Provider)))
+	}
+
+	return &GetIdentityListQuery{
+		Identities: identityResults,
+	}
+}
@@ -14,3 +14,19 @@ func NewGetSessionQuery(session *entities.Session) *GetSessionQuery {
 		Session: result.NewSessionResult(session),
 	}
 }
+
+type GetSessionListQuery struct {
+	Sessions []*result.SessionResult
+}
+
+func NewGetSessionListQuery(sessions []*entities.Session) *GetSessionListQuery {
+	sessionResults := make([]*result.SessionResult, 0, len(sessions))
+
+	for _, session := range sessions {
+		sessionResults = append(sessionResults, result.NewSessionResult(session))
+	}
+
+	return &GetSessionListQuery{
+		Sessions: sessionResults,
+	}
+}
@@ -20,7 +20,7 @@ func NewGetTodoQuery(todo *entities.Todo) *GetTodoQuery {
 }
 
 func NewGetTodoListQuery(todos []*entities.Todo) *GetTodoListQuery {
-	var todoResults []*result.TodoResult
+	todoResults := make([]*result.TodoResult, 0, len(todos))
 
 	for _, todo := range todos {
 		todoResults = append(todoResults, result.NewTodoResult(todo))
@@ -0,0 +1,16 @@
+package query
+
+import "go-starter-template/internal/application/result"
+
+type GetTwoFactorStatusQuery struct {
+	Status *result.TwoFactorStatusResult
+}
+
+func NewGetTwoFactorStatusQuery(enabled bool, recoveryCodesLeft int) *GetTwoFactorStatusQuery {
+	return &GetTwoFactorStatusQuery{
+		Status: &result.TwoFactorStatusResult{
+			Enabled:           enabled,
+			RecoveryCodesLeft: recoveryCodesLeft,
+		},
+	}
+}
@@ -14,3 +14,27 @@ func NewGetUserQuery(user *entities.User) *GetUserQuery {
 		User: result.NewUserResult(user),
 	}
 }
+
+type GetUserListQuery struct {
+	Users     []*result.UserResult
+	Search    string
+	Page      int
+	PageCount int
+	Total     int
+}
+
+func NewGetUserListQuery(users []*entities.User, search string, page, pageSize, total int) *GetUserListQuery {
+	userResults := make([]*result.UserResult, 0, len(users))
+
+	for _, user := range users {
+		userResults = append(userResults, result.NewUserResult(user))
+	}
+
+	return &GetUserListQuery{
+		Users:     userResults,
+		Search:    search,
+		Page:      page,
+		PageCount: (total + pageSize - 1) / pageSize,
+		Total:     total,
+	}
+}
@@ -0,0 +1,28 @@
+package result
+
+import "go-starter-template/internal/domain/entities"
+
+type APITokenResult struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Prefix     string `json:"prefix"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+func NewAPITokenResult(token *entities.APIToken) *APITokenResult {
+	res := &APITokenResult{
+		ID:        token.ID,
+		Name:      token.Name,
+		Prefix:    token.Prefix,
+		CreatedAt: token.CreatedAt.ToString(),
+	}
+	if token.LastUsedAt != nil {
+		res.LastUsedAt = token.LastUsedAt.ToString()
+	}
+	if token.ExpiresAt != nil {
+		res.ExpiresAt = token.ExpiresAt.ToString()
+	}
+	return res
+}
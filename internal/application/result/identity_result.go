@@ -0,0 +1,28 @@
+package result
+
+import "go-starter-template/internal/domain/entities"
+
+type IdentityProviderResult struct {
+	Name        string
+	DisplayName string
+}
+
+type IdentityResult struct {
+	ID       int
+	Provider string
+	// ProviderName is the display name of the provider, or the provider key
+	// when it is no longer configured
+	ProviderName string
+	Email        string
+	CreatedAt    string
+}
+
+func NewIdentityResult(identity *entities.Identity, providerName string) *IdentityResult {
+	return &IdentityResult{
+		ID:           identity.ID,
+		Provider:     identity.Provider,
+		ProviderName: providerName,
+		Email:        identity.Email,
+		CreatedAt:    identity.CreatedAt.ToString(),
+	}
+}
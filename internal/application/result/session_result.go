@@ -10,6 +10,9 @@ import (
 
 type SessionResult struct {
 	ID        uuid.UUID
+	Token     string
+	UserAgent string
+	IPAddress string
 	ExpiresAt time.Time
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -21,11 +24,17 @@ func (s *SessionResult) Expired() bool {
 }
 
 func NewSessionResult(session *entities.Session) *SessionResult {
-	return &SessionResult{
+	res := &SessionResult{
 		ID:        session.ID,
+		Token:     session.Token,
+		UserAgent: session.UserAgent,
+		IPAddress: session.IPAddress,
 		ExpiresAt: session.ExpiresAt.ToTime(),
 		CreatedAt: session.CreatedAt.ToTime(),
 		UpdatedAt: session.UpdatedAt.ToTime(),
-		User:      NewUserResult(session.User),
 	}
+	if session.User != nil {
+		res.User = NewUserResult(session.User)
+	}
+	return res
 }
@@ -3,11 +3,11 @@ package result
 import "go-starter-template/internal/domain/entities"
 
 type TodoResult struct {
-	ID          int
-	Title       string
-	Description string
-	CreatedAt   string
-	UpdatedAt   string
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
 }
 
 func NewTodoResult(todo *entities.Todo) *TodoResult {
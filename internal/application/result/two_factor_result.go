@@ -0,0 +1,26 @@
+package result
+
+import (
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type TwoFactorStatusResult struct {
+	Enabled           bool
+	RecoveryCodesLeft int
+}
+
+type TrustedDeviceResult struct {
+	// Token is the plain text value for the device cookie, only the hash is
+	// stored
+	Token     string
+	ExpiresAt time.Time
+}
+
+func NewTrustedDeviceResult(device *entities.TrustedDevice, plainText string) *TrustedDeviceResult {
+	return &TrustedDeviceResult{
+		Token:     plainText,
+		ExpiresAt: device.ExpiresAt.ToTime(),
+	}
+}
@@ -5,6 +5,9 @@ import "go-starter-template/internal/domain/entities"
 type UserResult struct {
 	ID        int
 	Email     string
+	Role      string
+	Verified  bool
+	Disabled  bool
 	CreatedAt string
 	UpdatedAt string
 }
@@ -13,7 +16,16 @@ func NewUserResult(user *entities.User) *UserResult {
 	return &UserResult{
 		ID:        user.ID,
 		Email:     user.Email.ToString(),
+		Role:      string(user.Role),
+		Verified:  user.Verified(),
+		Disabled:  user.Disabled(),
 		CreatedAt: user.CreatedAt.ToString(),
 		UpdatedAt: user.UpdatedAt.ToString(),
 	}
 }
+
+// Can reports what the role held when the result was created allows, use
+// IAuthorizationService for decisions that have to reflect the current role
+func (u *UserResult) Can(permission entities.Permission) bool {
+	return u != nil && !u.Disabled && entities.Role(u.Role).Can(permission)
+}
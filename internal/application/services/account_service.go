@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/query"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/pkg/security"
+)
+
+type IAccountService interface {
+	ChangePassword(ctx context.Context, passwordCommand *command.ChangePasswordCommand) error
+	ChangeEmail(ctx context.Context, emailCommand *command.ChangeEmailCommand) (*query.GetUserQuery, error)
+	DeleteAccount(ctx context.Context, deleteCommand *command.DeleteAccountCommand) error
+}
+
+// AccountService lets users manage their own account. Every change asks for
+// the current password, so a session left open somewhere is not enough to
+// take the account over. Users who signed up through an identity provider
+// set a password with a password reset first.
+type AccountService struct {
+	userRepository               repositories.IUserRepository
+	passwordResetTokenRepository repositories.IPasswordResetTokenRepository
+	passwordHasher               security.IPasswordHasher
+	passwordPolicy               valueobject.PasswordPolicy
+	sessionService               ISessionService
+	emailVerificationService     IEmailVerificationService
+}
+
+func NewAccountService(
+	userRepository repositories.IUserRepository,
+	passwordResetTokenRepository repositories.IPasswordResetTokenRepository,
+	passwordHasher security.IPasswordHasher,
+	passwordPolicy valueobject.PasswordPolicy,
+	sessionService ISessionService,
+	emailVerificationService IEmailVerificationService,
+) IAccountService {
+	return &AccountService{
+		userRepository:               userRepository,
+		passwordResetTokenRepository: passwordResetTokenRepository,
+		passwordHasher:               passwordHasher,
+		passwordPolicy:               passwordPolicy,
+		sessionService:               sessionService,
+		emailVerificationService:     emailVerificationService,
+	}
+}
+
+// ChangePassword signs the user out of every other session and voids the
+// password reset links sent before
+func (s *AccountService) ChangePassword(ctx context.Context, passwordCommand *command.ChangePasswordCommand) error {
+	if _, err := valueobject.NewPassword(passwordCommand.NewPassword, s.passwordPolicy); err != nil {
+		return err
+	}
+
+	user, err := s.authenticate(ctx, passwordCommand.UserID, passwordCommand.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.passwordHasher.GenerateFromPassword(passwordCommand.NewPassword)
+	if err != nil {
+		return err
+	}
+	// a concurrent change of the password the user just proved wins, this
+	// one fails as if the current password had been wrong
+	previousHash := user.Password.ToString()
+	if err := user.SetPassword(hashedPassword); err != nil {
+		return err
+	}
+	if err := s.userRepository.UpdatePassword(ctx, user, previousHash); err != nil {
+		if err == repositories.ErrNoRows {
+			return entities.ErrPasswordIncorrect
+		}
+		return err
+	}
+
+	return s.signOutElsewhere(ctx, user.ID, passwordCommand.CurrentSessionID)
+}
+
+// ChangeEmail marks the user unverified again and mails a link to the new
+// address. Like ChangePassword it signs the user out of every other session
+// and voids the password reset links, which went to the old address.
+func (s *AccountService) ChangeEmail(ctx context.Context, emailCommand *command.ChangeEmailCommand) (*query.GetUserQuery, error) {
+	user, err := s.authenticate(ctx, emailCommand.UserID, emailCommand.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.ChangeEmail(emailCommand.Email); err != nil {
+		return nil, err
+	}
+
+	existingUser, err := s.userRepository.GetByEmail(ctx, user.Email.ToString())
+	if err != nil && err != repositories.ErrNoRows {
+		return nil, err
+	}
+	if existingUser != nil {
+		return nil, entities.ErrUserAlreadyExists
+	}
+
+	err = s.userRepository.UpdateEmail(ctx, user)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return nil, entities.ErrUserAlreadyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.signOutElsewhere(ctx, user.ID, emailCommand.CurrentSessionID); err != nil {
+		return nil, err
+	}
+
+	// the address is changed at this point, a failed email is recovered by
+	// asking for a new link
+	s.emailVerificationService.SendVerification(ctx, &command.SendVerificationCommand{User: user})
+
+	return query.NewGetUserQuery(user), nil
+}
+
+// DeleteAccount removes the user with its sessions, todos and everything else
+// it owns
+func (s *AccountService) DeleteAccount(ctx context.Context, deleteCommand *command.DeleteAccountCommand) error {
+	user, err := s.authenticate(ctx, deleteCommand.UserID, deleteCommand.Password)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepository.Delete(ctx, user)
+}
+
+// signOutElsewhere is called once the credentials changed, so whoever had
+// the old ones is locked out
+func (s *AccountService) signOutElsewhere(ctx context.Context, userID int, currentSessionID string) error {
+	if err := s.passwordResetTokenRepository.DeleteForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.sessionService.RevokeOtherSessions(ctx, &command.RevokeOtherSessionsCommand{
+		UserID:           userID,
+		CurrentSessionID: currentSessionID,
+	})
+}
+
+func (s *AccountService) authenticate(ctx context.Context, userID int, password string) (*entities.User, error) {
+	user, err := s.userRepository.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.passwordHasher.CompareHashAndPassword(user.Password.ToString(), password); err != nil {
+		return nil, entities.ErrPasswordIncorrect
+	}
+	return user, nil
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+func newTestAccountService() (*AccountService, *fakePasswordResetTokenRepository, *fakeSessionService, *fakeEmailVerificationService) {
+	resetTokens := &fakePasswordResetTokenRepository{}
+	sessions := &fakeSessionService{}
+	verification := &fakeEmailVerificationService{}
+	service := NewAccountService(
+		newFakeUserRepository(
+			newTestUser(1, "ada@example.com", "correct horse"),
+			newTestUser(2, "grace@example.com", "battery staple"),
+		),
+		resetTokens,
+		plainHasher{},
+		valueobject.DefaultPasswordPolicy(),
+		sessions,
+		verification,
+	).(*AccountService)
+	return service, resetTokens, sessions, verification
+}
+
+func TestChangeEmailSignsOutElsewhere(t *testing.T) {
+	service, resetTokens, sessions, verification := newTestAccountService()
+
+	res, err := service.ChangeEmail(context.Background(), &command.ChangeEmailCommand{
+		UserID:           1,
+		CurrentSessionID: "current",
+		Password:         "correct horse",
+		Email:            "ada@example.org",
+	})
+	if err != nil {
+		t.Fatalf("ChangeEmail() error = %v", err)
+	}
+	if res.User.Email != "ada@example.org" {
+		t.Errorf("email = %q, want %q", res.User.Email, "ada@example.org")
+	}
+
+	if !slices.Equal(resetTokens.deletedFor, []int{1}) {
+		t.Errorf("reset tokens deleted for %v, want [1]", resetTokens.deletedFor)
+	}
+	if len(sessions.revoked) != 1 || sessions.revoked[0].UserID != 1 || sessions.revoked[0].CurrentSessionID != "current" {
+		t.Errorf("revoked sessions %+v, want those of user 1 but the current one", sessions.revoked)
+	}
+	if !slices.Equal(verification.sent, []string{"ada@example.org"}) {
+		t.Errorf("verification sent to %v, want the new address", verification.sent)
+	}
+}
+
+func TestChangeEmailRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		want     error
+	}{
+		{name: "wrong password", password: "wrong", email: "ada@example.org", want: entities.ErrPasswordIncorrect},
+		{name: "taken address", password: "correct horse", email: "grace@example.com", want: entities.ErrUserAlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, resetTokens, sessions, _ := newTestAccountService()
+
+			_, err := service.ChangeEmail(context.Background(), &command.ChangeEmailCommand{
+				UserID:   1,
+				Password: tt.password,
+				Email:    tt.email,
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ChangeEmail() error = %v, want %v", err, tt.want)
+			}
+			if len(resetTokens.deletedFor) != 0 || len(sessions.revoked) != 0 {
+				t.Error("a rejected change signed the user out")
+			}
+		})
+	}
+}
+
+func TestChangePasswordSignsOutElsewhere(t *testing.T) {
+	service, resetTokens, sessions, _ := newTestAccountService()
+
+	err := service.ChangePassword(context.Background(), &command.ChangePasswordCommand{
+		UserID:           1,
+		CurrentSessionID: "current",
+		CurrentPassword:  "correct horse",
+		NewPassword:      "Tr0ub4dor&3-but-longer",
+	})
+	if err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+	if !slices.Equal(resetTokens.deletedFor, []int{1}) {
+		t.Errorf("reset tokens deleted for %v, want [1]", resetTokens.deletedFor)
+	}
+	if len(sessions.revoked) != 1 || sessions.revoked[0].CurrentSessionID != "current" {
+		t.Errorf("revoked sessions %+v, want all but the current one", sessions.revoked)
+	}
+}
+
+// racingHasher runs race once the password was checked, after the service
+// read the user, standing in for a request changing the user meanwhile
+type racingHasher struct {
+	plainHasher
+	race func()
+}
+
+func (h racingHasher) CompareHashAndPassword(hashedPassword, password string) error {
+	h.race()
+	return h.plainHasher.CompareHashAndPassword(hashedPassword, password)
+}
+
+func TestAccountChangesKeepConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	users := newFakeUserRepository(newTestUser(1, "ada@example.com", "correct horse"))
+	race := func() {}
+	service := NewAccountService(users, &fakePasswordResetTokenRepository{}, racingHasher{race: func() { race() }}, valueobject.DefaultPasswordPolicy(), &fakeSessionService{}, &fakeEmailVerificationService{})
+
+	// an admin disables the user while the email changes
+	race = func() { users.users[1].Disable() }
+	_, err := service.ChangeEmail(ctx, &command.ChangeEmailCommand{UserID: 1, Password: "correct horse", Email: "ada@example.org"})
+	if err != nil {
+		t.Fatalf("ChangeEmail() error = %v", err)
+	}
+	if stored := users.users[1]; stored.Email.ToString() != "ada@example.org" || !stored.Disabled() {
+		t.Errorf("stored user %s disabled = %v, want ada@example.org disabled", stored.Email.ToString(), stored.Disabled())
+	}
+
+	// a password reset lands while the password changes, the reset wins
+	race = func() { users.users[1].Password = valueobject.NewPasswordHash("hash:reset") }
+	err = service.ChangePassword(ctx, &command.ChangePasswordCommand{UserID: 1, CurrentPassword: "correct horse", NewPassword: "Tr0ub4dor&3-but-longer"})
+	if !errors.Is(err, entities.ErrPasswordIncorrect) {
+		t.Errorf("ChangePassword() error = %v, want ErrPasswordIncorrect", err)
+	}
+	if got := users.users[1].Password.ToString(); got != "hash:reset" {
+		t.Errorf("stored password = %q, want the reset one", got)
+	}
+}
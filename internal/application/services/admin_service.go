@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/query"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+)
+
+type IAdminService interface {
+	ListUsers(ctx context.Context, actorID int, search string, page int) (*query.GetUserListQuery, error)
+	ChangeRole(ctx context.Context, roleCommand *command.ChangeUserRoleCommand) (*query.GetUserQuery, error)
+	SetDisabled(ctx context.Context, disabledCommand *command.SetUserDisabledCommand) (*query.GetUserQuery, error)
+	ForceLogout(ctx context.Context, logoutCommand *command.ForceLogoutCommand) (*query.GetUserQuery, error)
+}
+
+const adminUserPageSize = 25
+
+// AdminService manages other users. Every method checks the permissions of
+// the actor itself, so it stays safe to call from places other than the admin
+// routes.
+type AdminService struct {
+	userRepository       repositories.IUserRepository
+	authorizationService IAuthorizationService
+	sessionService       ISessionService
+}
+
+func NewAdminService(
+	userRepository repositories.IUserRepository,
+	authorizationService IAuthorizationService,
+	sessionService ISessionService,
+) IAdminService {
+	return &AdminService{
+		userRepository:       userRepository,
+		authorizationService: authorizationService,
+		sessionService:       sessionService,
+	}
+}
+
+// ListUsers pages start at 1
+func (s *AdminService) ListUsers(ctx context.Context, actorID int, search string, page int) (*query.GetUserListQuery, error) {
+	if _, err := s.authorizationService.Authorize(ctx, actorID, entities.PermissionUsersRead); err != nil {
+		return nil, err
+	}
+
+	search = strings.TrimSpace(search)
+	if page < 1 {
+		page = 1
+	}
+
+	total, err := s.userRepository.Count(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.userRepository.List(ctx, search, adminUserPageSize, (page-1)*adminUserPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.NewGetUserListQuery(users, search, page, adminUserPageSize, total), nil
+}
+
+func (s *AdminService) ChangeRole(ctx context.Context, roleCommand *command.ChangeUserRoleCommand) (*query.GetUserQuery, error) {
+	user, err := s.managedUser(ctx, roleCommand.ActorID, roleCommand.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.SetRole(roleCommand.Role); err != nil {
+		return nil, err
+	}
+	if err := s.userRepository.UpdateRole(ctx, user); err != nil {
+		return nil, err
+	}
+	return query.NewGetUserQuery(user), nil
+}
+
+// SetDisabled locks or unlocks the account. Disabling also ends the sessions
+// of the user.
+func (s *AdminService) SetDisabled(ctx context.Context, disabledCommand *command.SetUserDisabledCommand) (*query.GetUserQuery, error) {
+	user, err := s.managedUser(ctx, disabledCommand.ActorID, disabledCommand.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if disabledCommand.Disabled {
+		err = user.Disable()
+	} else {
+		err = user.Enable()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepository.UpdateDisabled(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if disabledCommand.Disabled {
+		if err := s.sessionService.RevokeAllSessions(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return query.NewGetUserQuery(user), nil
+}
+
+// ForceLogout signs the user out of every session
+func (s *AdminService) ForceLogout(ctx context.Context, logoutCommand *command.ForceLogoutCommand) (*query.GetUserQuery, error) {
+	if _, err := s.authorizationService.Authorize(ctx, logoutCommand.ActorID, entities.PermissionUsersManage); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepository.Get(ctx, logoutCommand.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionService.RevokeAllSessions(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	return query.NewGetUserQuery(user), nil
+}
+
+// managedUser authorizes the actor and loads the target, admins cannot lock
+// themselves out by changing their own role or account
+func (s *AdminService) managedUser(ctx context.Context, actorID, userID int) (*entities.User, error) {
+	if _, err := s.authorizationService.Authorize(ctx, actorID, entities.PermissionUsersManage); err != nil {
+		return nil, err
+	}
+
+	if actorID == userID {
+		return nil, entities.ErrCannotModifySelf
+	}
+
+	return s.userRepository.Get(ctx, userID)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/query"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/pkg/security"
+)
+
+const (
+	apiTokenPrefix        = "gst_"
+	apiTokenEntropyBytes  = 32
+	apiTokenDisplayLength = len(apiTokenPrefix) + 6
+)
+
+type IAPITokenService interface {
+	CreateToken(ctx context.Context, tokenCommand *command.CreateAPITokenCommand) (*command.CreateAPITokenCommandResult, error)
+	ListTokens(ctx context.Context, userID int) (*query.GetAPITokenListQuery, error)
+	RevokeToken(ctx context.Context, tokenCommand *command.RevokeAPITokenCommand) error
+	Authenticate(ctx context.Context, plainText string) (*query.GetUserQuery, error)
+}
+
+type APITokenService struct {
+	apiTokenRepository repositories.IAPITokenRepository
+}
+
+func NewAPITokenService(apiTokenRepository repositories.IAPITokenRepository) IAPITokenService {
+	return &APITokenService{
+		apiTokenRepository: apiTokenRepository,
+	}
+}
+
+func (s *APITokenService) CreateToken(ctx context.Context, tokenCommand *command.CreateAPITokenCommand) (*command.CreateAPITokenCommandResult, error) {
+	plainText, err := security.GenerateToken(apiTokenPrefix, apiTokenEntropyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *valueobject.Time
+	if tokenCommand.ExpiresInDays > 0 {
+		t := valueobject.NewCurrentTime().ExtendByHour(tokenCommand.ExpiresInDays * 24)
+		expiresAt = &t
+	}
+
+	token, err := entities.NewAPIToken(
+		tokenCommand.UserID,
+		tokenCommand.Name,
+		plainText[:apiTokenDisplayLength],
+		security.HashToken(plainText),
+		expiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	createdToken, err := s.apiTokenRepository.Create(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCreateAPITokenCommandResult(createdToken, plainText), nil
+}
+
+func (s *APITokenService) ListTokens(ctx context.Context, userID int) (*query.GetAPITokenListQuery, error) {
+	tokens, err := s.apiTokenRepository.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return query.NewGetAPITokenListQuery(tokens), nil
+}
+
+func (s *APITokenService) RevokeToken(ctx context.Context, tokenCommand *command.RevokeAPITokenCommand) error {
+	token, err := s.apiTokenRepository.Get(ctx, tokenCommand.UserID, tokenCommand.ID)
+	if errors.Is(err, repositories.ErrNoRows) {
+		return entities.ErrAPITokenNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.apiTokenRepository.Delete(ctx, token)
+}
+
+func (s *APITokenService) Authenticate(ctx context.Context, plainText string) (*query.GetUserQuery, error) {
+	token, err := s.apiTokenRepository.GetByHashWithUser(ctx, security.HashToken(plainText))
+	if err != nil {
+		if errors.Is(err, repositories.ErrNoRows) {
+			return nil, entities.ErrAPITokenInvalid
+		}
+		return nil, err
+	}
+
+	// tokens of disabled users stay in place and work again once re-enabled
+	if token.Expired() || token.User.Disabled() {
+		return nil, entities.ErrAPITokenInvalid
+	}
+
+	if err := s.apiTokenRepository.MarkUsed(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return query.NewGetUserQuery(token.User), nil
+}
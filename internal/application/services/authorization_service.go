@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+
+	"go-starter-template/internal/application/query"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+)
+
+type IAuthorizationService interface {
+	Authorize(ctx context.Context, userID int, permission entities.Permission) (*query.GetUserQuery, error)
+}
+
+type AuthorizationService struct {
+	userRepository repositories.IUserRepository
+}
+
+func NewAuthorizationService(userRepository repositories.IUserRepository) IAuthorizationService {
+	return &AuthorizationService{
+		userRepository: userRepository,
+	}
+}
+
+// Authorize checks the permission against the current role of the user
+// rather than the copy held by the session, so role changes and disabled
+// accounts take effect on the next request with every session store. It
+// returns entities.ErrUserDisabled or entities.ErrPermissionDenied, and the
+// fresh user on success.
+func (s *AuthorizationService) Authorize(ctx context.Context, userID int, permission entities.Permission) (*query.GetUserQuery, error) {
+	user, err := s.authorize(ctx, userID, permission)
+	if err != nil {
+		return nil, err
+	}
+	return query.NewGetUserQuery(user), nil
+}
+
+func (s *AuthorizationService) authorize(ctx context.Context, userID int, permission entities.Permission) (*entities.User, error) {
+	if userID == 0 {
+		return nil, entities.ErrPermissionDenied
+	}
+
+	user, err := s.userRepository.Get(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return nil, entities.ErrPermissionDenied
+		}
+		return nil, err
+	}
+
+	if user.Disabled() {
+		return nil, entities.ErrUserDisabled
+	}
+	if !user.Can(permission) {
+		return nil, entities.ErrPermissionDenied
+	}
+	return user, nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/pkg/mailer"
+	"go-starter-template/pkg/security"
+)
+
+type IEmailVerificationService interface {
+	SendVerification(ctx context.Context, verificationCommand *command.SendVerificationCommand) error
+	ResendVerification(ctx context.Context, verificationCommand *command.ResendVerificationCommand) error
+	Verify(ctx context.Context, token string) error
+	IsVerified(ctx context.Context, userID int) (bool, error)
+	BlocksLogin() bool
+	RestrictsFeatures() bool
+	PurgeExpiredTokens(ctx context.Context) (int64, error)
+}
+
+// EmailVerificationOptions configures the verification emails and what an
+// unverified user may do. VerifyURL is the absolute address of the verify
+// endpoint, the token is appended as a query parameter.
+type EmailVerificationOptions struct {
+	TokenTTL         time.Duration
+	ResendInterval   time.Duration
+	VerifyURL        string
+	MailFrom         string
+	BlockLogin       bool
+	RestrictFeatures bool
+}
+
+type EmailVerificationService struct {
+	emailVerificationTokenRepository repositories.IEmailVerificationTokenRepository
+	userRepository                   repositories.IUserRepository
+	mailer                           mailer.IMailer
+	options                          EmailVerificationOptions
+}
+
+func NewEmailVerificationService(
+	emailVerificationTokenRepository repositories.IEmailVerificationTokenRepository,
+	userRepository repositories.IUserRepository,
+	mail mailer.IMailer,
+	options EmailVerificationOptions,
+) IEmailVerificationService {
+	return &EmailVerificationService{
+		emailVerificationTokenRepository: emailVerificationTokenRepository,
+		userRepository:                   userRepository,
+		mailer:                           mail,
+		options:                          options,
+	}
+}
+
+// SendVerification replaces any outstanding link of the user with a new one
+// and mails it
+func (s *EmailVerificationService) SendVerification(ctx context.Context, verificationCommand *command.SendVerificationCommand) error {
+	user := verificationCommand.User
+	if user == nil {
+		return entities.ErrUserIsRequired
+	}
+
+	plainText, err := security.GenerateToken("", 32)
+	if err != nil {
+		return err
+	}
+
+	token, err := entities.NewEmailVerificationToken(user.ID, security.HashToken(plainText), s.options.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	if err := s.emailVerificationTokenRepository.DeleteForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if _, err := s.emailVerificationTokenRepository.Create(ctx, token); err != nil {
+		return err
+	}
+
+	link := s.options.VerifyURL + "?token=" + url.QueryEscape(plainText)
+	return s.mailer.Send(ctx, &mailer.Message{
+		From:    s.options.MailFrom,
+		To:      user.Email.ToString(),
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Please confirm this is your email address by opening the link below, it expires in %s:\n\n%s\n\n"+
+				"If you didn't sign up or change your email address, you can ignore this email.\n",
+			s.options.TokenTTL,
+			link,
+		),
+	})
+}
+
+// ResendVerification mails a new link unless one was sent within the resend
+// interval. Unknown and already verified emails succeed silently.
+func (s *EmailVerificationService) ResendVerification(ctx context.Context, verificationCommand *command.ResendVerificationCommand) error {
+	user, err := s.userRepository.GetByEmail(ctx, verificationCommand.Email)
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if user.Verified() {
+		return nil
+	}
+
+	latest, err := s.emailVerificationTokenRepository.GetLatestForUser(ctx, user.ID)
+	if err != nil && err != repositories.ErrNoRows {
+		return err
+	}
+	if latest != nil && latest.SentWithin(s.options.ResendInterval) {
+		return entities.ErrVerificationEmailRateLimited
+	}
+
+	return s.SendVerification(ctx, &command.SendVerificationCommand{User: user})
+}
+
+func (s *EmailVerificationService) Verify(ctx context.Context, plainText string) error {
+	if plainText == "" {
+		return entities.ErrEmailVerificationTokenInvalid
+	}
+
+	token, err := s.emailVerificationTokenRepository.GetByHashWithUser(ctx, security.HashToken(plainText))
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return entities.ErrEmailVerificationTokenInvalid
+		}
+		return err
+	}
+	if token.Expired() {
+		return entities.ErrEmailVerificationTokenInvalid
+	}
+
+	user := token.User
+	if !user.Verified() {
+		if err := user.MarkVerified(); err != nil {
+			return err
+		}
+		if err := s.userRepository.UpdateVerified(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	return s.emailVerificationTokenRepository.DeleteForUser(ctx, user.ID)
+}
+
+// IsVerified looks the user up again, so a verification made after the
+// session was created is picked up by stores that cache the user
+func (s *EmailVerificationService) IsVerified(ctx context.Context, userID int) (bool, error) {
+	user, err := s.userRepository.Get(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.Verified(), nil
+}
+
+func (s *EmailVerificationService) BlocksLogin() bool {
+	return s.options.BlockLogin
+}
+
+func (s *EmailVerificationService) RestrictsFeatures() bool {
+	return s.options.RestrictFeatures
+}
+
+func (s *EmailVerificationService) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	return s.emailVerificationTokenRepository.DeleteExpired(ctx)
+}
@@ -0,0 +1,475 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/result"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+// the fakes embed the interface they stand in for, so a test calling a
+// method it did not expect panics instead of passing silently
+
+type fakeUserRepository struct {
+	repositories.IUserRepository
+	users  map[int]*entities.User
+	nextID int
+	// gets counts the calls of Get
+	gets int
+}
+
+func newFakeUserRepository(users ...*entities.User) *fakeUserRepository {
+	r := &fakeUserRepository{users: make(map[int]*entities.User), nextID: 1}
+	for _, user := range users {
+		r.Create(context.Background(), user)
+	}
+	return r
+}
+
+func (r *fakeUserRepository) Create(ctx context.Context, user *entities.User) (*entities.User, error) {
+	if existing, _ := r.GetByEmail(ctx, user.Email.ToString()); existing != nil {
+		return nil, repositories.ErrDuplicate
+	}
+	if user.ID == 0 {
+		user.ID = r.nextID
+	}
+	r.nextID = max(r.nextID, user.ID) + 1
+	r.users[user.ID] = copyUser(user)
+	return user, nil
+}
+
+func (r *fakeUserRepository) Get(ctx context.Context, id int) (*entities.User, error) {
+	r.gets++
+	if user, ok := r.users[id]; ok {
+		return copyUser(user), nil
+	}
+	return nil, repositories.ErrNoRows
+}
+
+func (r *fakeUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	for _, user := range r.users {
+		if user.Email.ToString() == email {
+			return copyUser(user), nil
+		}
+	}
+	return nil, repositories.ErrNoRows
+}
+
+// the updates only copy the fields they are named after, like the postgres
+// repository
+
+func (r *fakeUserRepository) UpdatePassword(ctx context.Context, user *entities.User, previousHash string) error {
+	stored, ok := r.users[user.ID]
+	if !ok || stored.Password.ToString() != previousHash {
+		return repositories.ErrNoRows
+	}
+	stored.Password = user.Password
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateEmail(ctx context.Context, user *entities.User) error {
+	return r.update(user.ID, func(stored *entities.User) {
+		stored.Email = user.Email
+		stored.VerifiedAt = user.VerifiedAt
+	})
+}
+
+func (r *fakeUserRepository) UpdateVerified(ctx context.Context, user *entities.User) error {
+	return r.update(user.ID, func(stored *entities.User) { stored.VerifiedAt = user.VerifiedAt })
+}
+
+func (r *fakeUserRepository) UpdateRole(ctx context.Context, user *entities.User) error {
+	return r.update(user.ID, func(stored *entities.User) { stored.Role = user.Role })
+}
+
+func (r *fakeUserRepository) UpdateDisabled(ctx context.Context, user *entities.User) error {
+	return r.update(user.ID, func(stored *entities.User) { stored.DisabledAt = user.DisabledAt })
+}
+
+func (r *fakeUserRepository) RevokeSessions(ctx context.Context, user *entities.User) error {
+	return r.update(user.ID, func(stored *entities.User) {
+		stored.SessionsValidAfter = user.SessionsValidAfter
+		stored.KeptSessionID = user.KeptSessionID
+	})
+}
+
+func (r *fakeUserRepository) update(id int, change func(stored *entities.User)) error {
+	stored, ok := r.users[id]
+	if !ok {
+		return repositories.ErrNoRows
+	}
+	change(stored)
+	return nil
+}
+
+func (r *fakeUserRepository) Delete(ctx context.Context, user *entities.User) error {
+	if _, ok := r.users[user.ID]; !ok {
+		return repositories.ErrNoRows
+	}
+	delete(r.users, user.ID)
+	return nil
+}
+
+// copyUser hands out copies like a database would, so changes only stick
+// once they are saved
+func copyUser(user *entities.User) *entities.User {
+	copied := *user
+	return &copied
+}
+
+// fakeSessionRevocationRepository keeps the revocation list of the cookie
+// store in memory
+type fakeSessionRevocationRepository struct {
+	revoked map[uuid.UUID]int
+}
+
+func newFakeSessionRevocationRepository() *fakeSessionRevocationRepository {
+	return &fakeSessionRevocationRepository{revoked: make(map[uuid.UUID]int)}
+}
+
+func (r *fakeSessionRevocationRepository) Revoke(ctx context.Context, sessionID uuid.UUID, userID int, until time.Time) error {
+	r.revoked[sessionID] = userID
+	return nil
+}
+
+func (r *fakeSessionRevocationRepository) Revoked(ctx context.Context, sessionID uuid.UUID, userID int) (bool, error) {
+	owner, ok := r.revoked[sessionID]
+	return ok && owner == userID, nil
+}
+
+func (r *fakeSessionRevocationRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+type fakePasswordResetTokenRepository struct {
+	repositories.IPasswordResetTokenRepository
+	deletedFor []int
+}
+
+func (r *fakePasswordResetTokenRepository) DeleteForUser(ctx context.Context, userID int) error {
+	r.deletedFor = append(r.deletedFor, userID)
+	return nil
+}
+
+type fakeSessionService struct {
+	ISessionService
+	revoked []*command.RevokeOtherSessionsCommand
+	created []int
+}
+
+func (s *fakeSessionService) CreateSession(ctx context.Context, sessionCommand *command.CreateSessionCommand) (*command.CreateSessionCommandResult, error) {
+	s.created = append(s.created, sessionCommand.User.ID)
+	return &command.CreateSessionCommandResult{Session: &result.SessionResult{ID: uuid.New()}}, nil
+}
+
+func (s *fakeSessionService) RevokeOtherSessions(ctx context.Context, sessionCommand *command.RevokeOtherSessionsCommand) error {
+	s.revoked = append(s.revoked, sessionCommand)
+	return nil
+}
+
+type fakeEmailVerificationService struct {
+	IEmailVerificationService
+	sent        []string
+	blocksLogin bool
+}
+
+func (s *fakeEmailVerificationService) BlocksLogin() bool {
+	return s.blocksLogin
+}
+
+func (s *fakeEmailVerificationService) SendVerification(ctx context.Context, verificationCommand *command.SendVerificationCommand) error {
+	s.sent = append(s.sent, verificationCommand.User.Email.ToString())
+	return nil
+}
+
+type fakeIdentityRepository struct {
+	repositories.IIdentityRepository
+	users      *fakeUserRepository
+	identities []*entities.Identity
+}
+
+func (r *fakeIdentityRepository) Create(ctx context.Context, identity *entities.Identity) (*entities.Identity, error) {
+	identity.ID = len(r.identities) + 1
+	r.identities = append(r.identities, identity)
+	return identity, nil
+}
+
+func (r *fakeIdentityRepository) GetByProviderSubjectWithUser(ctx context.Context, provider, subject string) (*entities.Identity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			user, err := r.users.Get(ctx, identity.UserID)
+			if err != nil {
+				return nil, err
+			}
+			joined := *identity
+			joined.User = user
+			return &joined, nil
+		}
+	}
+	return nil, repositories.ErrNoRows
+}
+
+func (r *fakeIdentityRepository) ListByUser(ctx context.Context, userID int) ([]*entities.Identity, error) {
+	var identities []*entities.Identity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+// fakeTwoFactorService behaves as if no user had two-factor authentication
+type fakeTwoFactorService struct {
+	ITwoFactorService
+}
+
+func (fakeTwoFactorService) StartChallenge(ctx context.Context, challengeCommand *command.StartTwoFactorChallengeCommand) (string, error) {
+	return "", nil
+}
+
+// plainHasher "hashes" by prefixing, so tests can build users with known
+// passwords without paying for bcrypt
+type plainHasher struct{}
+
+func (plainHasher) GenerateFromPassword(password string) (string, error) {
+	return "hash:" + password, nil
+}
+
+func (plainHasher) CompareHashAndPassword(hashedPassword, password string) error {
+	if hashedPassword != "hash:"+password {
+		return errors.New("password mismatch")
+	}
+	return nil
+}
+
+func (plainHasher) NeedsRehash(hashedPassword string) bool {
+	return false
+}
+
+// newTestUser returns a verified user with the password "hash:" + password
+func newTestUser(id int, email, password string) *entities.User {
+	emailValid, err := valueobject.NewEmail(email)
+	if err != nil {
+		panic(err)
+	}
+	now := valueobject.NewCurrentTime()
+	return &entities.User{
+		ID:         id,
+		Email:      emailValid,
+		Password:   valueobject.NewPasswordHash("hash:" + password),
+		Role:       entities.RoleUser,
+		VerifiedAt: &now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+type fakeLoginThrottleRepository struct {
+	repositories.ILoginThrottleRepository
+	throttles map[string]*entities.LoginThrottle
+	lockouts  []*entities.LoginLockout
+}
+
+func newFakeLoginThrottleRepository() *fakeLoginThrottleRepository {
+	return &fakeLoginThrottleRepository{throttles: make(map[string]*entities.LoginThrottle)}
+}
+
+func (r *fakeLoginThrottleRepository) Get(ctx context.Context, scope, key string) (*entities.LoginThrottle, error) {
+	if throttle, ok := r.throttles[scope+"/"+key]; ok {
+		copied := *throttle
+		return &copied, nil
+	}
+	return nil, repositories.ErrNoRows
+}
+
+func (r *fakeLoginThrottleRepository) RecordFailure(ctx context.Context, scope, key string, at time.Time, window time.Duration) (*entities.LoginThrottle, error) {
+	throttle, ok := r.throttles[scope+"/"+key]
+	if !ok || at.Sub(throttle.LastFailureAt.ToTime()) > window {
+		throttle = &entities.LoginThrottle{Scope: scope, Key: key}
+		r.throttles[scope+"/"+key] = throttle
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = valueobject.NewTime(at)
+	copied := *throttle
+	return &copied, nil
+}
+
+func (r *fakeLoginThrottleRepository) Delete(ctx context.Context, scope, key string) error {
+	delete(r.throttles, scope+"/"+key)
+	return nil
+}
+
+func (r *fakeLoginThrottleRepository) CreateLockout(ctx context.Context, lockout *entities.LoginLockout) (*entities.LoginLockout, error) {
+	lockout.ID = len(r.lockouts) + 1
+	r.lockouts = append(r.lockouts, lockout)
+	return lockout, nil
+}
+
+// fakeTwoFactorRepository keeps one secret per user and enforces the replay
+// and single use rules the postgres repository puts in its queries
+type fakeTwoFactorRepository struct {
+	repositories.ITwoFactorRepository
+	twoFactors    map[int]*entities.TwoFactor
+	recoveryCodes map[int]map[string]bool
+}
+
+func newFakeTwoFactorRepository() *fakeTwoFactorRepository {
+	return &fakeTwoFactorRepository{
+		twoFactors:    make(map[int]*entities.TwoFactor),
+		recoveryCodes: make(map[int]map[string]bool),
+	}
+}
+
+func (r *fakeTwoFactorRepository) Get(ctx context.Context, userID int) (*entities.TwoFactor, error) {
+	if twoFactor, ok := r.twoFactors[userID]; ok {
+		copied := *twoFactor
+		return &copied, nil
+	}
+	return nil, repositories.ErrNoRows
+}
+
+func (r *fakeTwoFactorRepository) Save(ctx context.Context, twoFactor *entities.TwoFactor) (*entities.TwoFactor, error) {
+	if existing, ok := r.twoFactors[twoFactor.UserID]; ok && existing.Enabled() {
+		return nil, repositories.ErrNoRows
+	}
+	copied := *twoFactor
+	r.twoFactors[twoFactor.UserID] = &copied
+	return twoFactor, nil
+}
+
+func (r *fakeTwoFactorRepository) Confirm(ctx context.Context, twoFactor *entities.TwoFactor, recoveryCodeHashes []string) error {
+	copied := *twoFactor
+	r.twoFactors[twoFactor.UserID] = &copied
+	return r.ReplaceRecoveryCodes(ctx, twoFactor.UserID, recoveryCodeHashes)
+}
+
+func (r *fakeTwoFactorRepository) MarkStepUsed(ctx context.Context, userID int, step int64) error {
+	twoFactor, ok := r.twoFactors[userID]
+	if !ok || twoFactor.LastUsedStep >= step {
+		return repositories.ErrNoRows
+	}
+	twoFactor.LastUsedStep = step
+	return nil
+}
+
+func (r *fakeTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error {
+	codes := make(map[string]bool, len(recoveryCodeHashes))
+	for _, hash := range recoveryCodeHashes {
+		codes[hash] = false
+	}
+	r.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (r *fakeTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	used, ok := r.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return repositories.ErrNoRows
+	}
+	r.recoveryCodes[userID][codeHash] = true
+	return nil
+}
+
+func (r *fakeTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	left := 0
+	for _, used := range r.recoveryCodes[userID] {
+		if !used {
+			left++
+		}
+	}
+	return left, nil
+}
+
+type fakeTwoFactorChallengeRepository struct {
+	repositories.ITwoFactorChallengeRepository
+	challenges map[string]*entities.TwoFactorChallenge
+}
+
+func newFakeTwoFactorChallengeRepository() *fakeTwoFactorChallengeRepository {
+	return &fakeTwoFactorChallengeRepository{challenges: make(map[string]*entities.TwoFactorChallenge)}
+}
+
+func (r *fakeTwoFactorChallengeRepository) Create(ctx context.Context, challenge *entities.TwoFactorChallenge) (*entities.TwoFactorChallenge, error) {
+	challenge.ID = len(r.challenges) + 1
+	r.challenges[challenge.TokenHash] = challenge
+	return challenge, nil
+}
+
+func (r *fakeTwoFactorChallengeRepository) GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.TwoFactorChallenge, error) {
+	if challenge, ok := r.challenges[tokenHash]; ok {
+		return challenge, nil
+	}
+	return nil, repositories.ErrNoRows
+}
+
+func (r *fakeTwoFactorChallengeRepository) RecordAttempt(ctx context.Context, challenge *entities.TwoFactorChallenge) (int, error) {
+	stored, ok := r.challenges[challenge.TokenHash]
+	if !ok {
+		return 0, repositories.ErrNoRows
+	}
+	stored.Attempts++
+	return stored.Attempts, nil
+}
+
+func (r *fakeTwoFactorChallengeRepository) Delete(ctx context.Context, challenge *entities.TwoFactorChallenge) error {
+	if _, ok := r.challenges[challenge.TokenHash]; !ok {
+		return repositories.ErrNoRows
+	}
+	delete(r.challenges, challenge.TokenHash)
+	return nil
+}
+
+type fakeTodoRepository struct {
+	repositories.ITodoRepository
+	todos map[int]*entities.Todo
+}
+
+func newFakeTodoRepository(todos ...*entities.Todo) *fakeTodoRepository {
+	r := &fakeTodoRepository{todos: make(map[int]*entities.Todo)}
+	for _, todo := range todos {
+		r.Create(context.Background(), todo)
+	}
+	return r
+}
+
+func (r *fakeTodoRepository) Get(ctx context.Context, userID int, id int) (*entities.Todo, error) {
+	if todo, ok := r.todos[id]; ok && todo.UserID == userID {
+		copied := *todo
+		return &copied, nil
+	}
+	return nil, repositories.ErrNoRows
+}
+
+func (r *fakeTodoRepository) Create(ctx context.Context, todo *entities.Todo) (*entities.Todo, error) {
+	if todo.ID == 0 {
+		todo.ID = len(r.todos) + 1
+	}
+	copied := *todo
+	r.todos[todo.ID] = &copied
+	return todo, nil
+}
+
+func (r *fakeTodoRepository) Update(ctx context.Context, todo *entities.Todo) (*entities.Todo, error) {
+	if _, err := r.Get(ctx, todo.UserID, todo.ID); err != nil {
+		return nil, err
+	}
+	copied := *todo
+	r.todos[todo.ID] = &copied
+	return todo, nil
+}
+
+func (r *fakeTodoRepository) Delete(ctx context.Context, todo *entities.Todo) error {
+	if _, err := r.Get(ctx, todo.UserID, todo.ID); err != nil {
+		return err
+	}
+	delete(r.todos, todo.ID)
+	return nil
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/query"
+	"go-starter-template/internal/application/result"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/pkg/security"
+)
+
+// IIdentityProvider is an external login such as an OpenID Connect provider.
+// Name is the stable key stored with linked identities and used in URLs.
+type IIdentityProvider interface {
+	Name() string
+	DisplayName() string
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Authenticate exchanges the callback code and returns the verified
+	// identity of the user
+	Authenticate(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error)
+}
+
+// ExternalIdentity is what a provider vouches for after a successful login
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type IIdentityService interface {
+	Providers() []*result.IdentityProviderResult
+	BeginLogin(ctx context.Context, beginCommand *command.BeginIdentityLoginCommand) (*command.BeginIdentityLoginCommandResult, error)
+	CompleteLogin(ctx context.Context, loginCommand *command.CompleteIdentityLoginCommand) (*command.CreateLoginCommandResult, error)
+	Link(ctx context.Context, linkCommand *command.LinkIdentityCommand) error
+	ListIdentities(ctx context.Context, userID int) (*query.GetIdentityListQuery, error)
+	Unlink(ctx context.Context, unlinkCommand *command.UnlinkIdentityCommand) error
+}
+
+type IdentityService struct {
+	identityRepository       repositories.IIdentityRepository
+	userRepository           repositories.IUserRepository
+	passwordHasher           security.IPasswordHasher
+	sessionService           ISessionService
+	emailVerificationService IEmailVerificationService
+	twoFactorService         ITwoFactorService
+	providers                []IIdentityProvider
+}
+
+func NewIdentityService(
+	identityRepository repositories.IIdentityRepository,
+	userRepository repositories.IUserRepository,
+	passwordHasher security.IPasswordHasher,
+	sessionService ISessionService,
+	emailVerificationService IEmailVerificationService,
+	twoFactorService ITwoFactorService,
+	providers []IIdentityProvider,
+) IIdentityService {
+	return &IdentityService{
+		identityRepository:       identityRepository,
+		userRepository:           userRepository,
+		passwordHasher:           passwordHasher,
+		sessionService:           sessionService,
+		emailVerificationService: emailVerificationService,
+		twoFactorService:         twoFactorService,
+		providers:                providers,
+	}
+}
+
+func (s *IdentityService) Providers() []*result.IdentityProviderResult {
+	providers := make([]*result.IdentityProviderResult, 0, len(s.providers))
+	for _, provider := range s.providers {
+		providers = append(providers, &result.IdentityProviderResult{
+			Name:        provider.Name(),
+			DisplayName: provider.DisplayName(),
+		})
+	}
+	return providers
+}
+
+// BeginLogin generates the per login secrets and the provider URL. It is used
+// for linking an identity to a logged in user as well.
+func (s *IdentityService) BeginLogin(ctx context.Context, beginCommand *command.BeginIdentityLoginCommand) (*command.BeginIdentityLoginCommandResult, error) {
+	provider, err := s.provider(beginCommand.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := security.GenerateToken("", 32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := security.GenerateToken("", 32)
+	if err != nil {
+		return nil, err
+	}
+	// 32 random bytes encode to a valid PKCE verifier, RFC 7636 section 4.1
+	verifier, err := security.GenerateToken("", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return &command.BeginIdentityLoginCommandResult{
+		AuthURL:  authURL,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+	}, nil
+}
+
+// CompleteLogin logs in the user linked to the external identity. Unknown
+// identities are linked to the account with the same email when both sides
+// verified the address, otherwise a new account is created. Linking to an
+// account whose email is not verified on either side is refused with
+// entities.ErrIdentityEmailInUse, as it would hand the account to whoever
+// registered the address first. The result follows the same rules as
+// IUserService.Login for unverified users and two-factor authentication.
+func (s *IdentityService) CompleteLogin(ctx context.Context, loginCommand *command.CompleteIdentityLoginCommand) (*command.CreateLoginCommandResult, error) {
+	result, err := s.completeLogin(ctx, loginCommand)
+	recordLogin(loginMethodOIDC, result, err)
+	return result, err
+}
+
+func (s *IdentityService) completeLogin(ctx context.Context, loginCommand *command.CompleteIdentityLoginCommand) (*command.CreateLoginCommandResult, error) {
+	provider, external, err := s.authenticate(ctx, loginCommand.Callback)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, provider, external)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Disabled() {
+		return nil, entities.ErrUserDisabled
+	}
+
+	if s.emailVerificationService.BlocksLogin() && !user.Verified() {
+		return nil, entities.ErrEmailNotVerified
+	}
+
+	challengeToken, err := s.twoFactorService.StartChallenge(ctx, &command.StartTwoFactorChallengeCommand{
+		User: user,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if challengeToken != "" {
+		result := command.NewLoginUserCommandResult(user)
+		result.TwoFactorToken = challengeToken
+		return result, nil
+	}
+
+	sessionResult, err := s.sessionService.CreateSession(ctx, &command.CreateSessionCommand{
+		User:      user,
+		UserAgent: loginCommand.UserAgent,
+		IPAddress: loginCommand.IPAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := command.NewLoginUserCommandResult(user)
+	result.Session = sessionResult.Session
+
+	return result, nil
+}
+
+func (s *IdentityService) resolveUser(ctx context.Context, provider IIdentityProvider, external *ExternalIdentity) (*entities.User, error) {
+	identity, err := s.identityRepository.GetByProviderSubjectWithUser(ctx, provider.Name(), external.Subject)
+	if err != nil && err != repositories.ErrNoRows {
+		return nil, err
+	}
+	if identity != nil {
+		return identity.User, nil
+	}
+
+	if external.Email == "" {
+		return nil, entities.ErrIdentityEmailMissing
+	}
+
+	user, err := s.userRepository.GetByEmail(ctx, external.Email)
+	if err != nil && err != repositories.ErrNoRows {
+		return nil, err
+	}
+
+	if user != nil {
+		if !external.EmailVerified || !user.Verified() {
+			return nil, entities.ErrIdentityEmailInUse
+		}
+	} else {
+		user, err = s.createUser(ctx, external)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newIdentity, err := entities.NewIdentity(user, provider.Name(), external.Subject, external.Email)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = s.identityRepository.Create(ctx, newIdentity); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *IdentityService) createUser(ctx context.Context, external *ExternalIdentity) (*entities.User, error) {
+	secret, err := security.GenerateToken("", 32)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := s.passwordHasher.GenerateFromPassword(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := entities.NewExternalUser(external.Email, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	if external.EmailVerified {
+		user.MarkVerified()
+	}
+
+	createdUser, err := s.userRepository.Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	signupsTotal.Inc(loginMethodOIDC)
+
+	if !createdUser.Verified() {
+		// same as Signup, a failed email is recovered by asking for a new link
+		s.emailVerificationService.SendVerification(ctx, &command.SendVerificationCommand{User: createdUser})
+	}
+
+	return createdUser, nil
+}
+
+// Link connects the external identity to a logged in user, regardless of the
+// email address the provider reports
+func (s *IdentityService) Link(ctx context.Context, linkCommand *command.LinkIdentityCommand) error {
+	provider, external, err := s.authenticate(ctx, linkCommand.Callback)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.identityRepository.GetByProviderSubjectWithUser(ctx, provider.Name(), external.Subject)
+	if err != nil && err != repositories.ErrNoRows {
+		return err
+	}
+	if existing != nil {
+		if existing.UserID == linkCommand.UserID {
+			return nil
+		}
+		return entities.ErrIdentityLinkedElsewhere
+	}
+
+	identities, err := s.identityRepository.ListByUser(ctx, linkCommand.UserID)
+	if err != nil {
+		return err
+	}
+	for _, identity := range identities {
+		if identity.Provider == provider.Name() {
+			return entities.ErrIdentityAlreadyLinked
+		}
+	}
+
+	identity, err := entities.NewIdentity(&entities.User{ID: linkCommand.UserID}, provider.Name(), external.Subject, external.Email)
+	if err != nil {
+		return err
+	}
+	_, err = s.identityRepository.Create(ctx, identity)
+	return err
+}
+
+func (s *IdentityService) ListIdentities(ctx context.Context, userID int) (*query.GetIdentityListQuery, error) {
+	identities, err := s.identityRepository.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.NewGetIdentityListQuery(identities, func(name string) string {
+		if provider, err := s.provider(name); err == nil {
+			return provider.DisplayName()
+		}
+		return name
+	}), nil
+}
+
+// Unlink returns repositories.ErrNoRows for identities of other users
+func (s *IdentityService) Unlink(ctx context.Context, unlinkCommand *command.UnlinkIdentityCommand) error {
+	return s.identityRepository.DeleteForUser(ctx, unlinkCommand.UserID, unlinkCommand.IdentityID)
+}
+
+// authenticate validates the callback against the stored flow values before
+// the code is exchanged. Provider errors are reported as
+// entities.ErrIdentityLoginFailed wrapping the cause.
+func (s *IdentityService) authenticate(ctx context.Context, callback *command.IdentityCallbackCommand) (IIdentityProvider, *ExternalIdentity, error) {
+	provider, err := s.provider(callback.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if callback.ExpectedState == "" || callback.Code == "" ||
+		subtle.ConstantTimeCompare([]byte(callback.State), []byte(callback.ExpectedState)) != 1 {
+		return nil, nil, entities.ErrIdentityLoginFailed
+	}
+
+	external, err := provider.Authenticate(ctx, callback.Code, callback.Verifier, callback.Nonce)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", entities.ErrIdentityLoginFailed, err)
+	}
+	if external.Subject == "" {
+		return nil, nil, entities.ErrIdentityLoginFailed
+	}
+
+	return provider, external, nil
+}
+
+func (s *IdentityService) provider(name string) (IIdentityProvider, error) {
+	for _, provider := range s.providers {
+		if provider.Name() == name {
+			return provider, nil
+		}
+	}
+	return nil, entities.ErrIdentityProviderUnknown
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/pkg/oidc"
+	"go-starter-template/pkg/oidc/oidctest"
+)
+
+// oidcProvider logs in through pkg/oidc the way the adapter in
+// infrastructure/identity does, which cannot be imported from here
+type oidcProvider struct {
+	client *oidc.Client
+}
+
+func (p *oidcProvider) Name() string        { return "test" }
+func (p *oidcProvider) DisplayName() string { return "Test" }
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return p.client.AuthCodeURL(ctx, state, nonce, verifier)
+}
+
+func (p *oidcProvider) Authenticate(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error) {
+	token, err := p.client.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := p.client.VerifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalIdentity{Subject: claims.Subject, Email: claims.Email, EmailVerified: bool(claims.EmailVerified)}, nil
+}
+
+type identityTest struct {
+	idp          *oidctest.IdP
+	service      *IdentityService
+	users        *fakeUserRepository
+	identities   *fakeIdentityRepository
+	sessions     *fakeSessionService
+	verification *fakeEmailVerificationService
+}
+
+// newIdentityTest knows ada, who verified her address, and grace, who did not
+func newIdentityTest(t *testing.T) *identityTest {
+	t.Helper()
+	idp := oidctest.NewIdP("app", "app-secret")
+	t.Cleanup(idp.Close)
+
+	grace := newTestUser(2, "grace@example.com", "battery staple")
+	grace.VerifiedAt = nil
+	users := newFakeUserRepository(newTestUser(1, "ada@example.com", "correct horse"), grace)
+
+	test := &identityTest{
+		idp:          idp,
+		users:        users,
+		identities:   &fakeIdentityRepository{users: users},
+		sessions:     &fakeSessionService{},
+		verification: &fakeEmailVerificationService{},
+	}
+	test.service = NewIdentityService(
+		test.identities,
+		users,
+		plainHasher{},
+		test.sessions,
+		test.verification,
+		fakeTwoFactorService{},
+		[]IIdentityProvider{&oidcProvider{client: oidc.NewClient(oidc.Config{
+			Issuer:       idp.Issuer(),
+			ClientID:     "app",
+			ClientSecret: "app-secret",
+			RedirectURL:  "https://app.example.com/auth/test/callback",
+		}, nil)}},
+	).(*IdentityService)
+	return test
+}
+
+// login begins a login, lets the provider log in its current user and
+// returns the callback the browser brings back
+func (test *identityTest) login(t *testing.T) *command.IdentityCallbackCommand {
+	t.Helper()
+	begin, err := test.service.BeginLogin(context.Background(), &command.BeginIdentityLoginCommand{Provider: "test"})
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := browser.Get(begin.AuthURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", begin.AuthURL, err)
+	}
+	res.Body.Close()
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("provider redirected to %q: %v", res.Header.Get("Location"), err)
+	}
+
+	return &command.IdentityCallbackCommand{
+		Provider:      "test",
+		Code:          location.Query().Get("code"),
+		State:         location.Query().Get("state"),
+		ExpectedState: begin.State,
+		Nonce:         begin.Nonce,
+		Verifier:      begin.Verifier,
+	}
+}
+
+func TestCompleteLogin(t *testing.T) {
+	tests := []struct {
+		name string
+		user oidctest.User
+		// linked is the user the subject is already linked to
+		linked     int
+		disabled   bool
+		want       int
+		wantErr    error
+		wantLinked bool
+		wantSent   []string
+	}{
+		{name: "linked identity", user: oidctest.User{Subject: "s", Email: "changed@example.com", EmailVerified: false}, linked: 2, want: 2},
+		{name: "verified on both sides", user: oidctest.User{Subject: "s", Email: "ada@example.com", EmailVerified: true}, want: 1, wantLinked: true},
+		// whoever controls the provider account must not get into an
+		// account for an address the provider never checked
+		{name: "unverified at the provider", user: oidctest.User{Subject: "s", Email: "ada@example.com", EmailVerified: false}, wantErr: entities.ErrIdentityEmailInUse},
+		{name: "unverified account", user: oidctest.User{Subject: "s", Email: "grace@example.com", EmailVerified: true}, wantErr: entities.ErrIdentityEmailInUse},
+		{name: "new verified user", user: oidctest.User{Subject: "s", Email: "alan@example.com", EmailVerified: true}, want: 3, wantLinked: true},
+		{name: "new unverified user", user: oidctest.User{Subject: "s", Email: "alan@example.com", EmailVerified: false}, want: 3, wantLinked: true, wantSent: []string{"alan@example.com"}},
+		{name: "no email", user: oidctest.User{Subject: "s"}, wantErr: entities.ErrIdentityEmailMissing},
+		{name: "disabled user", user: oidctest.User{Subject: "s"}, linked: 1, disabled: true, wantErr: entities.ErrUserDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newIdentityTest(t)
+			test.idp.SetUser(tt.user)
+			if tt.linked != 0 {
+				user, _ := test.users.Get(context.Background(), tt.linked)
+				identity, _ := entities.NewIdentity(user, "test", tt.user.Subject, user.Email.ToString())
+				test.identities.Create(context.Background(), identity)
+				if tt.disabled {
+					user.Disable()
+					test.users.UpdateDisabled(context.Background(), user)
+				}
+			}
+			identitiesBefore := len(test.identities.identities)
+
+			res, err := test.service.CompleteLogin(context.Background(), &command.CompleteIdentityLoginCommand{Callback: test.login(t)})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CompleteLogin() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(test.sessions.created) != 0 {
+					t.Errorf("sessions created for %v, want none", test.sessions.created)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("CompleteLogin() error = %v", err)
+				}
+				if res.Session == nil || !slices.Equal(test.sessions.created, []int{tt.want}) {
+					t.Errorf("sessions created for %v, want [%d]", test.sessions.created, tt.want)
+				}
+			}
+
+			linked := len(test.identities.identities) > identitiesBefore
+			if linked != tt.wantLinked {
+				t.Fatalf("identity linked = %v, want %v", linked, tt.wantLinked)
+			}
+			if linked {
+				identity := test.identities.identities[identitiesBefore]
+				if identity.UserID != tt.want || identity.Subject != tt.user.Subject {
+					t.Errorf("identity = %+v, want subject %q of user %d", identity, tt.user.Subject, tt.want)
+				}
+			}
+			if !slices.Equal(test.verification.sent, tt.wantSent) {
+				t.Errorf("verification sent to %v, want %v", test.verification.sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestCompleteLoginVerifiesNewUsers(t *testing.T) {
+	test := newIdentityTest(t)
+	test.idp.SetUser(oidctest.User{Subject: "s", Email: "alan@example.com", EmailVerified: true})
+
+	if _, err := test.service.CompleteLogin(context.Background(), &command.CompleteIdentityLoginCommand{Callback: test.login(t)}); err != nil {
+		t.Fatalf("CompleteLogin() error = %v", err)
+	}
+	user, err := test.users.GetByEmail(context.Background(), "alan@example.com")
+	if err != nil || !user.Verified() {
+		t.Errorf("user = %+v, %v, want a verified user", user, err)
+	}
+}
+
+func TestCompleteLoginRejectsCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(callback *command.IdentityCallbackCommand)
+		wantErr error
+	}{
+		{name: "state mismatch", tamper: func(c *command.IdentityCallbackCommand) { c.State = "forged" }, wantErr: entities.ErrIdentityLoginFailed},
+		{name: "no flow began", tamper: func(c *command.IdentityCallbackCommand) { c.State, c.ExpectedState = "", "" }, wantErr: entities.ErrIdentityLoginFailed},
+		{name: "nonce mismatch", tamper: func(c *command.IdentityCallbackCommand) { c.Nonce = "forged" }, wantErr: entities.ErrIdentityLoginFailed},
+		{name: "verifier mismatch", tamper: func(c *command.IdentityCallbackCommand) { c.Verifier = "forged" }, wantErr: entities.ErrIdentityLoginFailed},
+		{name: "no code", tamper: func(c *command.IdentityCallbackCommand) { c.Code = "" }, wantErr: entities.ErrIdentityLoginFailed},
+		{name: "unknown provider", tamper: func(c *command.IdentityCallbackCommand) { c.Provider = "other" }, wantErr: entities.ErrIdentityProviderUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newIdentityTest(t)
+			test.idp.SetUser(oidctest.User{Subject: "s", Email: "alan@example.com", EmailVerified: true})
+			callback := test.login(t)
+			tt.tamper(callback)
+
+			_, err := test.service.CompleteLogin(context.Background(), &command.CompleteIdentityLoginCommand{Callback: callback})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteLogin() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(test.identities.identities) != 0 || len(test.sessions.created) != 0 {
+				t.Errorf("identities %v and sessions %v, want none", test.identities.identities, test.sessions.created)
+			}
+		})
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    int
+		linked  int
+		wantErr error
+	}{
+		// linking ignores the address, the user proved both logins
+		{name: "any email", user: 2},
+		{name: "linked to the same user", user: 1, linked: 1},
+		{name: "linked to another user", user: 2, linked: 1, wantErr: entities.ErrIdentityLinkedElsewhere},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newIdentityTest(t)
+			test.idp.SetUser(oidctest.User{Subject: "s", Email: "someone@example.com", EmailVerified: false})
+			if tt.linked != 0 {
+				user, _ := test.users.Get(context.Background(), tt.linked)
+				identity, _ := entities.NewIdentity(user, "test", "s", "someone@example.com")
+				test.identities.Create(context.Background(), identity)
+			}
+
+			err := test.service.Link(context.Background(), &command.LinkIdentityCommand{UserID: tt.user, Callback: test.login(t)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Link() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				identities, _ := test.identities.ListByUser(context.Background(), tt.user)
+				if len(identities) != 1 || identities[0].Subject != "s" {
+					t.Errorf("identities of user %d = %+v, want the linked one", tt.user, identities)
+				}
+			}
+		})
+	}
+}
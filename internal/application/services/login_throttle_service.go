@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+)
+
+type ILoginThrottleService interface {
+	Check(ctx context.Context, attemptCommand *command.LoginAttemptCommand) error
+	RecordFailure(ctx context.Context, attemptCommand *command.LoginAttemptCommand) error
+	Reset(ctx context.Context, email string) error
+	PurgeStale(ctx context.Context) (int64, error)
+}
+
+type LoginThrottleService struct {
+	loginThrottleRepository repositories.ILoginThrottleRepository
+	policy                  entities.LoginThrottlePolicy
+}
+
+func NewLoginThrottleService(loginThrottleRepository repositories.ILoginThrottleRepository, policy entities.LoginThrottlePolicy) ILoginThrottleService {
+	return &LoginThrottleService{
+		loginThrottleRepository: loginThrottleRepository,
+		policy:                  policy,
+	}
+}
+
+// Check returns a *entities.LoginThrottledError when either the account or the
+// client IP has to wait before the next attempt
+func (s *LoginThrottleService) Check(ctx context.Context, attemptCommand *command.LoginAttemptCommand) error {
+	var retryAfter time.Duration
+	for _, scope := range s.scopes(attemptCommand) {
+		throttle, err := s.loginThrottleRepository.Get(ctx, scope.name, scope.key)
+		if err != nil {
+			if err == repositories.ErrNoRows {
+				continue
+			}
+			return err
+		}
+		retryAfter = max(retryAfter, throttle.RetryAfter(s.policy))
+	}
+
+	if retryAfter > 0 {
+		return &entities.LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login for the account and the client IP and
+// writes an audit record for every scope that gets locked by it
+func (s *LoginThrottleService) RecordFailure(ctx context.Context, attemptCommand *command.LoginAttemptCommand) error {
+	now := time.Now()
+	for _, scope := range s.scopes(attemptCommand) {
+		throttle, err := s.loginThrottleRepository.RecordFailure(ctx, scope.name, scope.key, now, s.policy.Window)
+		if err != nil {
+			return err
+		}
+		if !throttle.JustLocked(s.policy) {
+			continue
+		}
+
+		lockout := entities.NewLoginLockout(throttle, attemptCommand.UserID, attemptCommand.IPAddress, s.policy)
+		if _, err := s.loginThrottleRepository.CreateLockout(ctx, lockout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset clears the failures of an account after a successful login. The IP
+// count is kept so one valid account cannot be used to reset it.
+func (s *LoginThrottleService) Reset(ctx context.Context, email string) error {
+	return s.loginThrottleRepository.Delete(ctx, entities.LoginThrottleScopeAccount, normalizeEmail(email))
+}
+
+// PurgeStale removes throttles which neither block anything nor count
+// towards a lockout anymore
+func (s *LoginThrottleService) PurgeStale(ctx context.Context) (int64, error) {
+	keep := max(s.policy.Window, s.policy.LockoutDuration, s.policy.MaxDelay)
+	return s.loginThrottleRepository.DeleteStale(ctx, time.Now().Add(-keep))
+}
+
+type throttleScope struct {
+	name string
+	key  string
+}
+
+func (s *LoginThrottleService) scopes(attemptCommand *command.LoginAttemptCommand) []throttleScope {
+	scopes := []throttleScope{{entities.LoginThrottleScopeAccount, normalizeEmail(attemptCommand.Email)}}
+	if attemptCommand.IPAddress != "" {
+		scopes = append(scopes, throttleScope{entities.LoginThrottleScopeIP, attemptCommand.IPAddress})
+	}
+	return scopes
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
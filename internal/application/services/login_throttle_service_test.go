@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+)
+
+var testThrottlePolicy = entities.LoginThrottlePolicy{
+	MaxAccountFailures: 5,
+	MaxIPFailures:      8,
+	FreeFailures:       2,
+	BaseDelay:          time.Second,
+	MaxDelay:           4 * time.Second,
+	LockoutDuration:    15 * time.Minute,
+	Window:             15 * time.Minute,
+}
+
+// retryAfter returns the wait of a *entities.LoginThrottledError, zero for nil
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	if err == nil {
+		return 0
+	}
+	var throttled *entities.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("Check() error = %v, want a LoginThrottledError", err)
+	}
+	return throttled.RetryAfter
+}
+
+// near accepts a wait that started at most a second ago
+func near(got, want time.Duration) bool {
+	return got <= want && got > want-time.Second
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	ctx := context.Background()
+	service := NewLoginThrottleService(newFakeLoginThrottleRepository(), testThrottlePolicy)
+	attempt := &command.LoginAttemptCommand{Email: "ada@example.com", IPAddress: "203.0.113.7"}
+
+	// the wait after each failure: free, free, then doubling up to MaxDelay
+	// until the lockout
+	wants := []time.Duration{0, 0, time.Second, 2 * time.Second, testThrottlePolicy.LockoutDuration}
+	for i, want := range wants {
+		if err := service.RecordFailure(ctx, attempt); err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+		got := retryAfter(t, service.Check(ctx, attempt))
+		if !near(got, want) && !(want == 0 && got == 0) {
+			t.Errorf("after %d failures retry after %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	ctx := context.Background()
+	repository := newFakeLoginThrottleRepository()
+	service := NewLoginThrottleService(repository, testThrottlePolicy)
+	userID := 1
+	attempt := &command.LoginAttemptCommand{Email: "ada@example.com", IPAddress: "203.0.113.7", UserID: &userID}
+
+	for range testThrottlePolicy.MaxAccountFailures + 2 {
+		if err := service.RecordFailure(ctx, attempt); err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+	}
+
+	// the limit is crossed once, later failures do not lock again
+	if len(repository.lockouts) != 1 {
+		t.Fatalf("lockouts = %d, want 1", len(repository.lockouts))
+	}
+	lockout := repository.lockouts[0]
+	if lockout.Scope != entities.LoginThrottleScopeAccount || lockout.Key != "ada@example.com" || lockout.UserID == nil || *lockout.UserID != userID {
+		t.Errorf("lockout = %+v, want the account of user 1", lockout)
+	}
+	if lockout.Failures != testThrottlePolicy.MaxAccountFailures {
+		t.Errorf("lockout failures = %d, want %d", lockout.Failures, testThrottlePolicy.MaxAccountFailures)
+	}
+
+	// other emails from the same IP only wait for the backoff of the IP
+	other := &command.LoginAttemptCommand{Email: "grace@example.com", IPAddress: "203.0.113.7"}
+	if got := retryAfter(t, service.Check(ctx, other)); !near(got, testThrottlePolicy.MaxDelay) {
+		t.Errorf("other account retry after %v, want %v", got, testThrottlePolicy.MaxDelay)
+	}
+
+	// until the IP reaches its own limit
+	if err := service.RecordFailure(ctx, other); err != nil {
+		t.Fatalf("RecordFailure() error = %v", err)
+	}
+	if got := retryAfter(t, service.Check(ctx, other)); !near(got, testThrottlePolicy.LockoutDuration) {
+		t.Errorf("locked IP retry after %v, want %v", got, testThrottlePolicy.LockoutDuration)
+	}
+	if len(repository.lockouts) != 2 || repository.lockouts[1].Scope != entities.LoginThrottleScopeIP {
+		t.Errorf("lockouts = %+v, want the IP locked second", repository.lockouts)
+	}
+}
+
+func TestLoginThrottleReset(t *testing.T) {
+	ctx := context.Background()
+	repository := newFakeLoginThrottleRepository()
+	service := NewLoginThrottleService(repository, testThrottlePolicy)
+	attempt := &command.LoginAttemptCommand{Email: " Ada@Example.com ", IPAddress: "203.0.113.7"}
+
+	for range 3 {
+		if err := service.RecordFailure(ctx, attempt); err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+	}
+	if err := service.Reset(ctx, "ada@example.com"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+
+	if _, err := repository.Get(ctx, entities.LoginThrottleScopeAccount, "ada@example.com"); err == nil {
+		t.Error("the account throttle survived the reset")
+	}
+	// a successful login cannot be used to clear the failures of its IP
+	ip, err := repository.Get(ctx, entities.LoginThrottleScopeIP, "203.0.113.7")
+	if err != nil || ip.Failures != 3 {
+		t.Errorf("IP throttle = %+v, %v, want 3 failures", ip, err)
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/pkg/metrics"
+)
+
+const (
+	loginMethodPassword = "password"
+	loginMethodOIDC     = "oidc"
+)
+
+var (
+	loginsTotal = metrics.MustRegister(metrics.NewCounter(
+		"app_logins_total",
+		"Login attempts by method and result. A login with two-factor authentication counts as two_factor and then as success or failed.",
+		"method", "result",
+	))
+	signupsTotal = metrics.MustRegister(metrics.NewCounter(
+		"app_signups_total",
+		"Accounts created by method.",
+		"method",
+	))
+	todosCreatedTotal = metrics.MustRegister(metrics.NewCounter(
+		"app_todos_created_total",
+		"Todos created.",
+	))
+)
+
+// recordLogin counts the outcome of a login attempt
+func recordLogin(method string, res *command.CreateLoginCommandResult, err error) {
+	loginsTotal.Inc(method, loginResult(res, err))
+}
+
+func loginResult(res *command.CreateLoginCommandResult, err error) string {
+	var throttled *entities.LoginThrottledError
+	switch {
+	case err == nil && res.TwoFactorToken != "":
+		return "two_factor"
+	case err == nil:
+		return "success"
+	case errors.As(err, &throttled):
+		return "throttled"
+	case errors.Is(err, entities.ErrInvalidCredentials), errors.Is(err, entities.ErrTwoFactorCodeInvalid),
+		errors.Is(err, entities.ErrIdentityLoginFailed):
+		return "failed"
+	case errors.Is(err, entities.ErrTwoFactorChallengeInvalid):
+		return "expired"
+	case errors.Is(err, entities.ErrUserDisabled):
+		return "disabled"
+	case errors.Is(err, entities.ErrEmailNotVerified):
+		return "unverified"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/query"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/pkg/security"
+	"go-starter-template/pkg/tracing"
+)
+
+type IOperatorService interface {
+	CreateUser(ctx context.Context, userCommand *command.CreateUserCommand) (*query.GetUserQuery, error)
+	SetPassword(ctx context.Context, passwordCommand *command.SetUserPasswordCommand) (*query.GetUserQuery, error)
+	RevokeSessions(ctx context.Context, email string) (*query.GetUserQuery, error)
+	PurgeExpiredSessions(ctx context.Context) (int64, error)
+}
+
+// OperatorService runs the maintenance tasks of the command line. Unlike
+// AdminService it does not check any permissions, so it must never be
+// reachable from a route.
+type OperatorService struct {
+	userRepository               repositories.IUserRepository
+	passwordResetTokenRepository repositories.IPasswordResetTokenRepository
+	sessionService               ISessionService
+	passwordHasher               security.IPasswordHasher
+	passwordPolicy               valueobject.PasswordPolicy
+}
+
+func NewOperatorService(
+	userRepository repositories.IUserRepository,
+	passwordResetTokenRepository repositories.IPasswordResetTokenRepository,
+	sessionService ISessionService,
+	passwordHasher security.IPasswordHasher,
+	passwordPolicy valueobject.PasswordPolicy,
+) IOperatorService {
+	return &OperatorService{
+		userRepository:               userRepository,
+		passwordResetTokenRepository: passwordResetTokenRepository,
+		sessionService:               sessionService,
+		passwordHasher:               passwordHasher,
+		passwordPolicy:               passwordPolicy,
+	}
+}
+
+// CreateUser applies the password policy like signup does, an operator
+// should not be able to create the weakest account of the app
+func (s *OperatorService) CreateUser(ctx context.Context, userCommand *command.CreateUserCommand) (*query.GetUserQuery, error) {
+	ctx, span := tracing.Start(ctx, "OperatorService.CreateUser", tracing.String("user.role", userCommand.Role))
+	defer span.End()
+
+	user, err := entities.NewUser(userCommand.Email, userCommand.Password, s.passwordPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if err := user.SetRole(userCommand.Role); err != nil {
+		return nil, err
+	}
+	if userCommand.Verified {
+		if err := user.MarkVerified(); err != nil {
+			return nil, err
+		}
+	}
+
+	hashedPassword, err := s.passwordHasher.GenerateFromPassword(userCommand.Password)
+	if err != nil {
+		return nil, err
+	}
+	if err := user.SetPassword(hashedPassword); err != nil {
+		return nil, err
+	}
+
+	createdUser, err := s.userRepository.Create(ctx, user)
+	if err != nil {
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return nil, entities.ErrUserAlreadyExists
+		}
+		return nil, err
+	}
+
+	return query.NewGetUserQuery(createdUser), nil
+}
+
+// SetPassword replaces the password, drops pending reset links and signs the
+// user out everywhere, as after a reset through email
+func (s *OperatorService) SetPassword(ctx context.Context, passwordCommand *command.SetUserPasswordCommand) (*query.GetUserQuery, error) {
+	ctx, span := tracing.Start(ctx, "OperatorService.SetPassword")
+	defer span.End()
+
+	if _, err := valueobject.NewPassword(passwordCommand.Password, s.passwordPolicy); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userByEmail(ctx, passwordCommand.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.passwordHasher.GenerateFromPassword(passwordCommand.Password)
+	if err != nil {
+		return nil, err
+	}
+	previousHash := user.Password.ToString()
+	if err := user.SetPassword(hashedPassword); err != nil {
+		return nil, err
+	}
+	if err := s.userRepository.UpdatePassword(ctx, user, previousHash); err != nil {
+		return nil, err
+	}
+
+	if err := s.passwordResetTokenRepository.DeleteForUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.sessionService.RevokeAllSessions(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	return query.NewGetUserQuery(user), nil
+}
+
+func (s *OperatorService) RevokeSessions(ctx context.Context, email string) (*query.GetUserQuery, error) {
+	ctx, span := tracing.Start(ctx, "OperatorService.RevokeSessions")
+	defer span.End()
+
+	user, err := s.userByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionService.RevokeAllSessions(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	return query.NewGetUserQuery(user), nil
+}
+
+func (s *OperatorService) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+	return s.sessionService.PurgeExpiredSessions(ctx)
+}
+
+func (s *OperatorService) userByEmail(ctx context.Context, email string) (*entities.User, error) {
+	user, err := s.userRepository.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNoRows) {
+			return nil, entities.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/pkg/mailer"
+	"go-starter-template/pkg/security"
+)
+
+type IPasswordResetService interface {
+	RequestReset(ctx context.Context, resetCommand *command.RequestPasswordResetCommand) error
+	ValidateToken(ctx context.Context, token string) error
+	ResetPassword(ctx context.Context, resetCommand *command.ResetPasswordCommand) error
+	PurgeExpiredTokens(ctx context.Context) (int64, error)
+}
+
+// PasswordResetOptions configures the reset emails. ResetURL is the absolute
+// address of the reset page, the token is appended as a query parameter.
+type PasswordResetOptions struct {
+	TokenTTL time.Duration
+	ResetURL string
+	MailFrom string
+}
+
+type PasswordResetService struct {
+	passwordResetTokenRepository repositories.IPasswordResetTokenRepository
+	userRepository               repositories.IUserRepository
+	sessionService               ISessionService
+	passwordHasher               security.IPasswordHasher
+	passwordPolicy               valueobject.PasswordPolicy
+	mailer                       mailer.IMailer
+	options                      PasswordResetOptions
+}
+
+func NewPasswordResetService(
+	passwordResetTokenRepository repositories.IPasswordResetTokenRepository,
+	userRepository repositories.IUserRepository,
+	sessionService ISessionService,
+	passwordHasher security.IPasswordHasher,
+	passwordPolicy valueobject.PasswordPolicy,
+	mail mailer.IMailer,
+	options PasswordResetOptions,
+) IPasswordResetService {
+	return &PasswordResetService{
+		passwordResetTokenRepository: passwordResetTokenRepository,
+		userRepository:               userRepository,
+		sessionService:               sessionService,
+		passwordHasher:               passwordHasher,
+		passwordPolicy:               passwordPolicy,
+		mailer:                       mail,
+		options:                      options,
+	}
+}
+
+// RequestReset mails a reset link to the user. Unknown emails succeed
+// silently so the form cannot be used to find out who has an account.
+func (s *PasswordResetService) RequestReset(ctx context.Context, resetCommand *command.RequestPasswordResetCommand) error {
+	user, err := s.userRepository.GetByEmail(ctx, resetCommand.Email)
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	plainText, err := security.GenerateToken("", 32)
+	if err != nil {
+		return err
+	}
+
+	token, err := entities.NewPasswordResetToken(user.ID, security.HashToken(plainText), s.options.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	// only the most recent link stays valid
+	if err := s.passwordResetTokenRepository.DeleteForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if _, err := s.passwordResetTokenRepository.Create(ctx, token); err != nil {
+		return err
+	}
+
+	link := s.options.ResetURL + "?token=" + url.QueryEscape(plainText)
+	return s.mailer.Send(ctx, &mailer.Message{
+		From:    s.options.MailFrom,
+		To:      user.Email.ToString(),
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your account.\n\n"+
+				"Open the link below to choose a new password, it expires in %s:\n\n%s\n\n"+
+				"If this wasn't you, you can ignore this email.\n",
+			s.options.TokenTTL,
+			link,
+		),
+	})
+}
+
+func (s *PasswordResetService) ValidateToken(ctx context.Context, token string) error {
+	_, err := s.getUsableToken(ctx, token)
+	return err
+}
+
+// ResetPassword redeems the token, sets the new password and signs the user
+// out everywhere
+func (s *PasswordResetService) ResetPassword(ctx context.Context, resetCommand *command.ResetPasswordCommand) error {
+	if _, err := valueobject.NewPassword(resetCommand.Password, s.passwordPolicy); err != nil {
+		return err
+	}
+
+	token, err := s.getUsableToken(ctx, resetCommand.Token)
+	if err != nil {
+		return err
+	}
+
+	if err := s.passwordResetTokenRepository.MarkUsed(ctx, token); err != nil {
+		if err == repositories.ErrNoRows {
+			return entities.ErrPasswordResetTokenInvalid
+		}
+		return err
+	}
+
+	hashedPassword, err := s.passwordHasher.GenerateFromPassword(resetCommand.Password)
+	if err != nil {
+		return err
+	}
+
+	// a password changed since the link was sent voids it, like the links
+	// AccountService.ChangePassword deletes
+	user := token.User
+	previousHash := user.Password.ToString()
+	if err := user.SetPassword(hashedPassword); err != nil {
+		return err
+	}
+	if err := s.userRepository.UpdatePassword(ctx, user, previousHash); err != nil {
+		if err == repositories.ErrNoRows {
+			return entities.ErrPasswordResetTokenInvalid
+		}
+		return err
+	}
+
+	if err := s.passwordResetTokenRepository.DeleteForUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	return s.sessionService.RevokeAllSessions(ctx, user.ID)
+}
+
+func (s *PasswordResetService) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	return s.passwordResetTokenRepository.DeleteExpired(ctx)
+}
+
+func (s *PasswordResetService) getUsableToken(ctx context.Context, plainText string) (*entities.PasswordResetToken, error) {
+	if plainText == "" {
+		return nil, entities.ErrPasswordResetTokenInvalid
+	}
+
+	token, err := s.passwordResetTokenRepository.GetByHashWithUser(ctx, security.HashToken(plainText))
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return nil, entities.ErrPasswordResetTokenInvalid
+		}
+		return nil, err
+	}
+
+	if !token.Usable() {
+		return nil, entities.ErrPasswordResetTokenInvalid
+	}
+	return token, nil
+}
@@ -2,34 +2,53 @@ package services
 
 import (
 	"context"
+	"errors"
+
+	"github.com/google/uuid"
 
 	"go-starter-template/internal/application/command"
 	"go-starter-template/internal/application/query"
 	"go-starter-template/internal/domain/entities"
 	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/pkg/tracing"
 )
 
 type ISessionService interface {
 	CreateSession(ctx context.Context, sessionCommand *command.CreateSessionCommand) (*command.CreateSessionCommandResult, error)
 	GetSession(ctx context.Context, sessionId string) (*query.GetSessionQuery, error)
+	TouchSession(ctx context.Context, sessionId string) (*command.TouchSessionCommandResult, error)
 	DeleteSession(ctx context.Context, sessionId string) error
+	ListSessions(ctx context.Context, userID int) (*query.GetSessionListQuery, error)
+	RevokeSession(ctx context.Context, sessionCommand *command.RevokeSessionCommand) error
+	RevokeOtherSessions(ctx context.Context, sessionCommand *command.RevokeOtherSessionsCommand) error
+	RevokeAllSessions(ctx context.Context, userID int) error
+	PurgeExpiredSessions(ctx context.Context) (int64, error)
 }
 
 type SessionService struct {
 	sessionRepository repositories.ISessionRepository
+	userRepository    repositories.IUserRepository
+	policy            entities.SessionPolicy
 }
 
-func NewSessionService(sessionRepository repositories.ISessionRepository) ISessionService {
+func NewSessionService(
+	sessionRepository repositories.ISessionRepository,
+	userRepository repositories.IUserRepository,
+	policy entities.SessionPolicy,
+) ISessionService {
 	return &SessionService{
 		sessionRepository: sessionRepository,
+		userRepository:    userRepository,
+		policy:            policy,
 	}
 }
 
 func (s *SessionService) CreateSession(ctx context.Context, sessionCommand *command.CreateSessionCommand) (*command.CreateSessionCommandResult, error) {
-	newSession := entities.NewSession(sessionCommand.User)
-	if sessionCommand.ExtendByHour > 0 {
-		newSession.ExpiresAt = newSession.ExpiresAt.ExtendByHour(sessionCommand.ExtendByHour)
-	}
+	ctx, span := tracing.Start(ctx, "SessionService.CreateSession")
+	defer span.End()
+
+	newSession := entities.NewSession(sessionCommand.User, sessionCommand.Remember, s.policy)
+	newSession.SetClient(sessionCommand.UserAgent, sessionCommand.IPAddress)
 	session, err := s.sessionRepository.Create(ctx, newSession)
 	if err != nil {
 		return nil, err
@@ -39,6 +58,9 @@ func (s *SessionService) CreateSession(ctx context.Context, sessionCommand *comm
 }
 
 func (s *SessionService) GetSession(ctx context.Context, sessionId string) (*query.GetSessionQuery, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.GetSession")
+	defer span.End()
+
 	session, err := s.sessionRepository.GetWithUser(ctx, sessionId)
 	if err != nil {
 		return nil, err
@@ -46,10 +68,166 @@ func (s *SessionService) GetSession(ctx context.Context, sessionId string) (*que
 	return query.NewGetSessionQuery(session), nil
 }
 
+// TouchSession validates the session for an incoming request. Expired and
+// revoked sessions and sessions of disabled or deleted users are removed,
+// sessions used past half of their lifetime get their expiry extended. Stores
+// keeping a snapshot of the user have it refreshed once per
+// UserRefreshInterval, until then the snapshot decides.
+func (s *SessionService) TouchSession(ctx context.Context, sessionId string) (*command.TouchSessionCommandResult, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.TouchSession")
+	defer span.End()
+
+	session, err := s.sessionRepository.GetWithUser(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshed := false
+	if s.snapshotsUser() && session.UserStale(s.policy) {
+		user, err := s.userRepository.Get(ctx, session.User.ID)
+		if errors.Is(err, repositories.ErrNoRows) {
+			// the account was deleted
+			if err := s.sessionRepository.Delete(ctx, session); err != nil {
+				return nil, err
+			}
+			return nil, entities.ErrSessionRevoked
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := session.RefreshUser(user); err != nil {
+			return nil, err
+		}
+		refreshed = true
+	}
+
+	if session.Expired() {
+		if err := s.sessionRepository.Delete(ctx, session); err != nil {
+			return nil, err
+		}
+		return nil, entities.ErrSessionExpired
+	}
+
+	if session.User.Disabled() {
+		if err := s.sessionRepository.Delete(ctx, session); err != nil {
+			return nil, err
+		}
+		return nil, entities.ErrUserDisabled
+	}
+
+	if session.User.SessionRevoked(session) {
+		if err := s.sessionRepository.Delete(ctx, session); err != nil {
+			return nil, err
+		}
+		return nil, entities.ErrSessionRevoked
+	}
+
+	renew := session.NeedsRenewal(s.policy)
+	if !renew && !refreshed {
+		return command.NewTouchSessionCommandResult(session, false), nil
+	}
+
+	if renew {
+		if err := session.Renew(s.policy); err != nil {
+			return nil, err
+		}
+	}
+	session, err = s.sessionRepository.Update(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewTouchSessionCommandResult(session, true), nil
+}
+
+// snapshotsUser reports whether the store returns the user as it was at sign
+// in instead of reading it with the session
+func (s *SessionService) snapshotsUser() bool {
+	store, ok := s.sessionRepository.(repositories.ISnapshotSessionRepository)
+	return ok && store.SnapshotsUser()
+}
+
 func (s *SessionService) DeleteSession(ctx context.Context, sessionId string) error {
+	ctx, span := tracing.Start(ctx, "SessionService.DeleteSession")
+	defer span.End()
+
 	session, err := s.sessionRepository.Get(ctx, sessionId)
 	if err != nil {
 		return err
 	}
 	return s.sessionRepository.Delete(ctx, session)
 }
+
+func (s *SessionService) ListSessions(ctx context.Context, userID int) (*query.GetSessionListQuery, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.ListSessions", tracing.Int("user.id", userID))
+	defer span.End()
+
+	sessions, err := s.sessionRepository.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return query.NewGetSessionListQuery(sessions), nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, sessionCommand *command.RevokeSessionCommand) error {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeSession", tracing.Int("user.id", sessionCommand.UserID))
+	defer span.End()
+
+	return s.sessionRepository.DeleteForUser(ctx, sessionCommand.UserID, sessionCommand.SessionID)
+}
+
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, sessionCommand *command.RevokeOtherSessionsCommand) error {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeOtherSessions", tracing.Int("user.id", sessionCommand.UserID))
+	defer span.End()
+
+	keep := uuid.Nil
+	if sessionCommand.CurrentSessionID != "" {
+		id, err := uuid.Parse(sessionCommand.CurrentSessionID)
+		if err != nil {
+			return err
+		}
+		keep = id
+	}
+	return s.revoke(ctx, sessionCommand.UserID, keep)
+}
+
+// RevokeAllSessions logs the user out everywhere
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID int) error {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeAllSessions", tracing.Int("user.id", userID))
+	defer span.End()
+
+	return s.revoke(ctx, userID, uuid.Nil)
+}
+
+// revoke stores the cutoff on the user, which TouchSession checks for every
+// store, and deletes the sessions from the stores that can
+func (s *SessionService) revoke(ctx context.Context, userID int, keep uuid.UUID) error {
+	user, err := s.userRepository.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := user.RevokeSessions(keep); err != nil {
+		return err
+	}
+	if err := s.userRepository.RevokeSessions(ctx, user); err != nil {
+		return err
+	}
+
+	exceptSessionId := ""
+	if keep != uuid.Nil {
+		exceptSessionId = keep.String()
+	}
+	err = s.sessionRepository.DeleteAllForUser(ctx, userID, exceptSessionId)
+	if errors.Is(err, repositories.ErrNotSupported) {
+		// the sessions of stateless stores are rejected by the cutoff
+		return nil
+	}
+	return err
+}
+
+func (s *SessionService) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.PurgeExpiredSessions")
+	defer span.End()
+
+	return s.sessionRepository.DeleteExpired(ctx)
+}
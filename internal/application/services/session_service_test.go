@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/result"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/infrastructure/db/cookie"
+	"go-starter-template/internal/infrastructure/db/memory"
+)
+
+var testSessionPolicy = entities.SessionPolicy{
+	IdleTimeout:         time.Hour,
+	RememberIdleTimeout: 24 * time.Hour,
+	AbsoluteTimeout:     7 * 24 * time.Hour,
+}
+
+// sessionStores are the stores keeping a snapshot of the user, revoking has
+// to work without deleting anything from them
+var sessionStores = map[string]func() repositories.ISessionRepository{
+	"cookie": func() repositories.ISessionRepository {
+		return cookie.NewCookieSessionRepository(
+			[]byte("0123456789abcdef0123456789abcdef"),
+			[]byte("0123456789abcdef"),
+			testSessionPolicy.AbsoluteTimeout,
+			newFakeSessionRevocationRepository(),
+		)
+	},
+	"memory": memory.NewMemorySessionRepository,
+}
+
+func TestSessionRevocation(t *testing.T) {
+	tests := []struct {
+		name string
+		// change runs after the current and the other session were created
+		change      func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error
+		wantCurrent error
+		wantOther   error
+	}{
+		{
+			name: "nothing changed",
+			change: func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error {
+				return nil
+			},
+		},
+		{
+			name: "password reset signs out everywhere",
+			change: func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error {
+				return service.RevokeAllSessions(ctx, 1)
+			},
+			wantCurrent: entities.ErrSessionRevoked,
+			wantOther:   entities.ErrSessionRevoked,
+		},
+		{
+			name: "password change keeps the current session",
+			change: func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error {
+				return service.RevokeOtherSessions(ctx, &command.RevokeOtherSessionsCommand{UserID: 1, CurrentSessionID: current.ID.String()})
+			},
+			wantOther: entities.ErrSessionRevoked,
+		},
+		{
+			name: "logout ends the current session",
+			change: func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error {
+				return service.DeleteSession(ctx, current.Token)
+			},
+			wantCurrent: entities.ErrSessionRevoked,
+		},
+		{
+			name: "single session revoked from the session list",
+			change: func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error {
+				return service.RevokeSession(ctx, &command.RevokeSessionCommand{UserID: 1, SessionID: other.ID.String()})
+			},
+			wantOther: entities.ErrSessionRevoked,
+		},
+		{
+			name: "session of another user cannot be revoked",
+			change: func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error {
+				err := service.RevokeSession(ctx, &command.RevokeSessionCommand{UserID: 2, SessionID: other.ID.String()})
+				if errors.Is(err, repositories.ErrNoRows) {
+					return nil
+				}
+				return err
+			},
+		},
+		{
+			name: "disabled by an admin",
+			change: func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error {
+				users.users[1].Disable()
+				return nil
+			},
+			wantCurrent: entities.ErrUserDisabled,
+			wantOther:   entities.ErrUserDisabled,
+		},
+		{
+			name: "account deleted",
+			change: func(ctx context.Context, service ISessionService, users *fakeUserRepository, current, other *result.SessionResult) error {
+				return users.Delete(ctx, users.users[1])
+			},
+			wantCurrent: entities.ErrSessionRevoked,
+			wantOther:   entities.ErrSessionRevoked,
+		},
+	}
+
+	for store, newStore := range sessionStores {
+		for _, tt := range tests {
+			t.Run(store+"/"+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				users := newFakeUserRepository(newTestUser(1, "ada@example.com", "correct horse"))
+				service := NewSessionService(newStore(), users, testSessionPolicy)
+
+				signIn := func() *result.SessionResult {
+					t.Helper()
+					user, _ := users.Get(ctx, 1)
+					res, err := service.CreateSession(ctx, &command.CreateSessionCommand{User: user})
+					if err != nil {
+						t.Fatalf("CreateSession() error = %v", err)
+					}
+					return res.Session
+				}
+				current, other := signIn(), signIn()
+
+				if err := tt.change(ctx, service, users, current, other); err != nil {
+					t.Fatalf("change error = %v", err)
+				}
+
+				if _, err := service.TouchSession(ctx, current.Token); !rejectedWith(err, tt.wantCurrent) {
+					t.Errorf("TouchSession(current) error = %v, want %v", err, tt.wantCurrent)
+				}
+				if _, err := service.TouchSession(ctx, other.Token); !rejectedWith(err, tt.wantOther) {
+					t.Errorf("TouchSession(other) error = %v, want %v", err, tt.wantOther)
+				}
+			})
+		}
+	}
+}
+
+// rejectedWith accepts ErrNoRows for revoked sessions, stores that can delete
+// sessions no longer find them
+func rejectedWith(err, want error) bool {
+	if errors.Is(want, entities.ErrSessionRevoked) && errors.Is(err, repositories.ErrNoRows) {
+		return true
+	}
+	return errors.Is(err, want)
+}
+
+func TestSignInAfterRevocation(t *testing.T) {
+	for store, newStore := range sessionStores {
+		t.Run(store, func(t *testing.T) {
+			ctx := context.Background()
+			users := newFakeUserRepository(newTestUser(1, "ada@example.com", "correct horse"))
+			service := NewSessionService(newStore(), users, testSessionPolicy)
+
+			if err := service.RevokeAllSessions(ctx, 1); err != nil {
+				t.Fatalf("RevokeAllSessions() error = %v", err)
+			}
+
+			user, _ := users.Get(ctx, 1)
+			res, err := service.CreateSession(ctx, &command.CreateSessionCommand{User: user})
+			if err != nil {
+				t.Fatalf("CreateSession() error = %v", err)
+			}
+			if _, err := service.TouchSession(ctx, res.Session.Token); err != nil {
+				t.Errorf("TouchSession() of a new session error = %v", err)
+			}
+		})
+	}
+}
+
+func TestTouchSessionUserSnapshot(t *testing.T) {
+	for store, newStore := range sessionStores {
+		t.Run(store, func(t *testing.T) {
+			ctx := context.Background()
+			users := newFakeUserRepository(newTestUser(1, "ada@example.com", "correct horse"))
+			policy := testSessionPolicy
+			policy.UserRefreshInterval = 50 * time.Millisecond
+			service := NewSessionService(newStore(), users, policy)
+
+			user, _ := users.Get(ctx, 1)
+			created, err := service.CreateSession(ctx, &command.CreateSessionCommand{User: user})
+			if err != nil {
+				t.Fatalf("CreateSession() error = %v", err)
+			}
+			token := created.Session.Token
+			users.gets = 0
+
+			// a fresh snapshot is trusted, even once the user has changed
+			users.users[1].Disable()
+			for range 2 {
+				res, err := service.TouchSession(ctx, token)
+				if err != nil {
+					t.Fatalf("TouchSession() error = %v", err)
+				}
+				if res.Renewed {
+					t.Error("TouchSession() renewed a session with a fresh snapshot")
+				}
+			}
+			if users.gets != 0 {
+				t.Fatalf("user read %d times within the refresh interval, want 0", users.gets)
+			}
+
+			// once stale the user is read again and decides
+			users.users[1].DisabledAt = nil
+			time.Sleep(policy.UserRefreshInterval)
+			res, err := service.TouchSession(ctx, token)
+			if err != nil {
+				t.Fatalf("TouchSession() of a stale snapshot error = %v", err)
+			}
+			if !res.Renewed || users.gets != 1 {
+				t.Fatalf("TouchSession() renewed = %v after %d reads, want a renewal after 1 read", res.Renewed, users.gets)
+			}
+
+			// the refreshed snapshot is saved with the session
+			if _, err := service.TouchSession(ctx, res.Session.Token); err != nil {
+				t.Fatalf("TouchSession() after the refresh error = %v", err)
+			}
+			if users.gets != 1 {
+				t.Errorf("user read %d times after the refresh, want 1", users.gets)
+			}
+
+			users.users[1].Disable()
+			time.Sleep(policy.UserRefreshInterval)
+			if _, err := service.TouchSession(ctx, res.Session.Token); !errors.Is(err, entities.ErrUserDisabled) {
+				t.Errorf("TouchSession() of a disabled user error = %v, want ErrUserDisabled", err)
+			}
+		})
+	}
+}
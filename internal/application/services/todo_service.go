@@ -2,33 +2,43 @@ package services
 
 import (
 	"context"
+	"errors"
 
 	"go-starter-template/internal/application/command"
 	"go-starter-template/internal/application/query"
 	"go-starter-template/internal/domain/entities"
 	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/pkg/tracing"
 )
 
 type ITodoService interface {
-	ListTodos(ctx context.Context) (*query.GetTodoListQuery, error)
-	GetTodo(ctx context.Context, id int) (*query.GetTodoQuery, error)
+	ListTodos(ctx context.Context, userID int) (*query.GetTodoListQuery, error)
+	GetTodo(ctx context.Context, userID int, id int) (*query.GetTodoQuery, error)
 	CreateTodo(ctx context.Context, todoCommand *command.CreateTodoCommand) (*command.CreateTodoCommandResult, error)
 	UpdateTodo(ctx context.Context, todoCommand *command.UpdateTodoCommand) (*command.UpdateTodoCommandResult, error)
 	DeleteTodo(ctx context.Context, todoCommand *command.DeleteTodoCommand) error
 }
 
+// TodoService scopes every todo to its owner. The methods that change todos
+// check the permission of the user themselves, like AdminService, so they
+// stay safe to call from places other than the todo routes.
 type TodoService struct {
-	todoRepository repositories.ITodoRepository
+	todoRepository       repositories.ITodoRepository
+	authorizationService IAuthorizationService
 }
 
-func NewTodoService(repo repositories.ITodoRepository) ITodoService {
+func NewTodoService(repo repositories.ITodoRepository, authorizationService IAuthorizationService) ITodoService {
 	return &TodoService{
-		todoRepository: repo,
+		todoRepository:       repo,
+		authorizationService: authorizationService,
 	}
 }
 
-func (s *TodoService) ListTodos(ctx context.Context) (*query.GetTodoListQuery, error) {
-	todos, err := s.todoRepository.List(ctx)
+func (s *TodoService) ListTodos(ctx context.Context, userID int) (*query.GetTodoListQuery, error) {
+	ctx, span := tracing.Start(ctx, "TodoService.ListTodos", tracing.Int("user.id", userID))
+	defer span.End()
+
+	todos, err := s.todoRepository.List(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -36,17 +46,27 @@ func (s *TodoService) ListTodos(ctx context.Context) (*query.GetTodoListQuery, e
 	return query.NewGetTodoListQuery(todos), nil
 }
 
-func (s *TodoService) GetTodo(ctx context.Context, id int) (*query.GetTodoQuery, error) {
-	todo, err := s.todoRepository.Get(ctx, id)
+func (s *TodoService) GetTodo(ctx context.Context, userID int, id int) (*query.GetTodoQuery, error) {
+	ctx, span := tracing.Start(ctx, "TodoService.GetTodo", tracing.Int("user.id", userID), tracing.Int("todo.id", id))
+	defer span.End()
+
+	todo, err := s.todoRepository.Get(ctx, userID, id)
 	if err != nil {
-		return nil, err
+		return nil, todoError(err)
 	}
 
 	return query.NewGetTodoQuery(todo), nil
 }
 
 func (s *TodoService) CreateTodo(ctx context.Context, todoCommand *command.CreateTodoCommand) (*command.CreateTodoCommandResult, error) {
-	todo, err := entities.NewTodo(todoCommand.Title, todoCommand.Description)
+	ctx, span := tracing.Start(ctx, "TodoService.CreateTodo", tracing.Int("user.id", todoCommand.UserID))
+	defer span.End()
+
+	if _, err := s.authorizationService.Authorize(ctx, todoCommand.UserID, entities.PermissionTodosWrite); err != nil {
+		return nil, err
+	}
+
+	todo, err := entities.NewTodo(todoCommand.UserID, todoCommand.Title, todoCommand.Description)
 	if err != nil {
 		return nil, err
 	}
@@ -55,28 +75,52 @@ func (s *TodoService) CreateTodo(ctx context.Context, todoCommand *command.Creat
 	if err != nil {
 		return nil, err
 	}
+	todosCreatedTotal.Inc()
 
 	return command.NewCreateTodoCommandResult(result), nil
 }
 
 func (s *TodoService) UpdateTodo(ctx context.Context, todoCommand *command.UpdateTodoCommand) (*command.UpdateTodoCommandResult, error) {
-	updatedTodo, err := entities.NewTodoWithID(todoCommand.ID, todoCommand.Title, todoCommand.Description)
+	ctx, span := tracing.Start(ctx, "TodoService.UpdateTodo", tracing.Int("user.id", todoCommand.UserID), tracing.Int("todo.id", todoCommand.ID))
+	defer span.End()
+
+	if _, err := s.authorizationService.Authorize(ctx, todoCommand.UserID, entities.PermissionTodosWrite); err != nil {
+		return nil, err
+	}
+
+	updatedTodo, err := entities.NewTodoWithID(todoCommand.ID, todoCommand.UserID, todoCommand.Title, todoCommand.Description)
 	if err != nil {
 		return nil, err
 	}
 
 	updatedTodo, err = s.todoRepository.Update(ctx, updatedTodo)
 	if err != nil {
-		return nil, err
+		return nil, todoError(err)
 	}
 
 	return command.NewUpdateTodoCommandResult(updatedTodo), nil
 }
 
 func (s *TodoService) DeleteTodo(ctx context.Context, todoCommand *command.DeleteTodoCommand) error {
-	todo, err := s.todoRepository.Get(ctx, todoCommand.ID)
-	if err != nil {
+	ctx, span := tracing.Start(ctx, "TodoService.DeleteTodo", tracing.Int("user.id", todoCommand.UserID), tracing.Int("todo.id", todoCommand.ID))
+	defer span.End()
+
+	if _, err := s.authorizationService.Authorize(ctx, todoCommand.UserID, entities.PermissionTodosDelete); err != nil {
 		return err
 	}
-	return s.todoRepository.Delete(ctx, todo)
+
+	todo, err := s.todoRepository.Get(ctx, todoCommand.UserID, todoCommand.ID)
+	if err != nil {
+		return todoError(err)
+	}
+	return todoError(s.todoRepository.Delete(ctx, todo))
+}
+
+// todoError reports missing rows as entities.ErrTodoNotFound, the todos of
+// other users are missing as well
+func todoError(err error) error {
+	if errors.Is(err, repositories.ErrNoRows) {
+		return entities.ErrTodoNotFound
+	}
+	return err
 }
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+)
+
+func TestTodoServicePermissions(t *testing.T) {
+	viewer := newTestUser(2, "viewer@example.com", "secret")
+	viewer.Role = entities.RoleViewer
+	disabled := newTestUser(3, "disabled@example.com", "secret")
+	disabled.Disable()
+
+	tests := []struct {
+		name    string
+		userID  int
+		wantErr error
+	}{
+		{name: "user", userID: 1},
+		{name: "viewer", userID: viewer.ID, wantErr: entities.ErrPermissionDenied},
+		{name: "disabled user", userID: disabled.ID, wantErr: entities.ErrUserDisabled},
+		{name: "unknown user", userID: 4, wantErr: entities.ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			users := newFakeUserRepository(newTestUser(1, "ada@example.com", "secret"), viewer, disabled)
+			todos := newFakeTodoRepository(&entities.Todo{UserID: tt.userID, Title: "existing"})
+			service := NewTodoService(todos, NewAuthorizationService(users))
+
+			_, err := service.CreateTodo(ctx, &command.CreateTodoCommand{UserID: tt.userID, Title: "new"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateTodo() error = %v, want %v", err, tt.wantErr)
+			}
+			_, err = service.UpdateTodo(ctx, &command.UpdateTodoCommand{ID: 1, UserID: tt.userID, Title: "changed"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UpdateTodo() error = %v, want %v", err, tt.wantErr)
+			}
+			err = service.DeleteTodo(ctx, &command.DeleteTodoCommand{ID: 1, UserID: tt.userID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DeleteTodo() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil {
+				if _, ok := todos.todos[1]; ok || len(todos.todos) != 1 {
+					t.Errorf("todos = %v, want only the created one", todos.todos)
+				}
+				return
+			}
+			// a denied change leaves the todos as they were
+			if todo := todos.todos[1]; len(todos.todos) != 1 || todo == nil || todo.Title != "existing" {
+				t.Errorf("todos = %v, want only the existing one unchanged", todos.todos)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/query"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/pkg/security"
+)
+
+type ITwoFactorService interface {
+	GetStatus(ctx context.Context, userID int) (*query.GetTwoFactorStatusQuery, error)
+	BeginEnrollment(ctx context.Context, enrollmentCommand *command.BeginTwoFactorEnrollmentCommand) (*command.BeginTwoFactorEnrollmentCommandResult, error)
+	ConfirmEnrollment(ctx context.Context, codeCommand *command.TwoFactorCodeCommand) (*command.RecoveryCodesCommandResult, error)
+	RegenerateRecoveryCodes(ctx context.Context, codeCommand *command.TwoFactorCodeCommand) (*command.RecoveryCodesCommandResult, error)
+	Disable(ctx context.Context, codeCommand *command.TwoFactorCodeCommand) error
+	StartChallenge(ctx context.Context, challengeCommand *command.StartTwoFactorChallengeCommand) (string, error)
+	GetChallenge(ctx context.Context, token string) (*entities.TwoFactorChallenge, error)
+	CompleteChallenge(ctx context.Context, challenge *entities.TwoFactorChallenge, code string) error
+	TrustDevice(ctx context.Context, deviceCommand *command.TrustDeviceCommand) (*command.TrustDeviceCommandResult, error)
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// TwoFactorOptions configures the TOTP second factor. Issuer is the account
+// label shown by authenticator apps.
+type TwoFactorOptions struct {
+	Issuer           string
+	ChallengeTTL     time.Duration
+	TrustedDeviceTTL time.Duration
+}
+
+const recoveryCodeCount = 10
+
+type TwoFactorService struct {
+	twoFactorRepository          repositories.ITwoFactorRepository
+	twoFactorChallengeRepository repositories.ITwoFactorChallengeRepository
+	trustedDeviceRepository      repositories.ITrustedDeviceRepository
+	cipher                       security.ICipher
+	options                      TwoFactorOptions
+}
+
+func NewTwoFactorService(
+	twoFactorRepository repositories.ITwoFactorRepository,
+	twoFactorChallengeRepository repositories.ITwoFactorChallengeRepository,
+	trustedDeviceRepository repositories.ITrustedDeviceRepository,
+	cipher security.ICipher,
+	options TwoFactorOptions,
+) ITwoFactorService {
+	return &TwoFactorService{
+		twoFactorRepository:          twoFactorRepository,
+		twoFactorChallengeRepository: twoFactorChallengeRepository,
+		trustedDeviceRepository:      trustedDeviceRepository,
+		cipher:                       cipher,
+		options:                      options,
+	}
+}
+
+func (s *TwoFactorService) GetStatus(ctx context.Context, userID int) (*query.GetTwoFactorStatusQuery, error) {
+	twoFactor, err := s.enabledTwoFactor(ctx, userID)
+	if err != nil {
+		if err == entities.ErrTwoFactorNotEnabled {
+			return query.NewGetTwoFactorStatusQuery(false, 0), nil
+		}
+		return nil, err
+	}
+
+	left, err := s.twoFactorRepository.CountRecoveryCodes(ctx, twoFactor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return query.NewGetTwoFactorStatusQuery(true, left), nil
+}
+
+// BeginEnrollment generates a new secret which stays inactive until it is
+// confirmed with a code, starting over replaces the previous pending secret
+func (s *TwoFactorService) BeginEnrollment(ctx context.Context, enrollmentCommand *command.BeginTwoFactorEnrollmentCommand) (*command.BeginTwoFactorEnrollmentCommandResult, error) {
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.cipher.Encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor, err := entities.NewTwoFactor(enrollmentCommand.UserID, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.twoFactorRepository.Save(ctx, twoFactor); err != nil {
+		if err == repositories.ErrNoRows {
+			return nil, entities.ErrTwoFactorAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &command.BeginTwoFactorEnrollmentCommandResult{
+		Secret: secret,
+		URI:    security.TOTPURI(s.options.Issuer, enrollmentCommand.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables the pending secret once the user entered a code
+// it produced and hands out the first set of recovery codes
+func (s *TwoFactorService) ConfirmEnrollment(ctx context.Context, codeCommand *command.TwoFactorCodeCommand) (*command.RecoveryCodesCommandResult, error) {
+	twoFactor, err := s.twoFactorRepository.Get(ctx, codeCommand.UserID)
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return nil, entities.ErrTwoFactorCodeInvalid
+		}
+		return nil, err
+	}
+	if twoFactor.Enabled() {
+		return nil, entities.ErrTwoFactorAlreadyEnabled
+	}
+
+	step, err := s.validateTOTP(twoFactor, codeCommand.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor.LastUsedStep = step
+	twoFactor.Confirm()
+	if err := s.twoFactorRepository.Confirm(ctx, twoFactor, hashes); err != nil {
+		if err == repositories.ErrNoRows {
+			return nil, entities.ErrTwoFactorAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &command.RecoveryCodesCommandResult{RecoveryCodes: codes}, nil
+}
+
+// RegenerateRecoveryCodes invalidates every previous recovery code
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, codeCommand *command.TwoFactorCodeCommand) (*command.RecoveryCodesCommandResult, error) {
+	twoFactor, err := s.enabledTwoFactor(ctx, codeCommand.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyCode(ctx, twoFactor, codeCommand.Code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepository.ReplaceRecoveryCodes(ctx, twoFactor.UserID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &command.RecoveryCodesCommandResult{RecoveryCodes: codes}, nil
+}
+
+// Disable removes the secret, the recovery codes and every trusted device
+func (s *TwoFactorService) Disable(ctx context.Context, codeCommand *command.TwoFactorCodeCommand) error {
+	twoFactor, err := s.enabledTwoFactor(ctx, codeCommand.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyCode(ctx, twoFactor, codeCommand.Code); err != nil {
+		return err
+	}
+
+	if err := s.twoFactorRepository.Delete(ctx, twoFactor.UserID); err != nil {
+		return err
+	}
+	return s.trustedDeviceRepository.DeleteForUser(ctx, twoFactor.UserID)
+}
+
+// StartChallenge is called once the password was verified. It returns the
+// token of a new challenge the client has to complete, or an empty string
+// when the user has no second factor or the device is trusted.
+func (s *TwoFactorService) StartChallenge(ctx context.Context, challengeCommand *command.StartTwoFactorChallengeCommand) (string, error) {
+	user := challengeCommand.User
+	if user == nil {
+		return "", entities.ErrUserIsRequired
+	}
+
+	if _, err := s.enabledTwoFactor(ctx, user.ID); err != nil {
+		if err == entities.ErrTwoFactorNotEnabled {
+			return "", nil
+		}
+		return "", err
+	}
+
+	trusted, err := s.deviceTrusted(ctx, user.ID, challengeCommand.DeviceToken)
+	if err != nil {
+		return "", err
+	}
+	if trusted {
+		return "", nil
+	}
+
+	plainText, err := security.GenerateToken("", 32)
+	if err != nil {
+		return "", err
+	}
+
+	challenge, err := entities.NewTwoFactorChallenge(user, security.HashToken(plainText), challengeCommand.Remember, s.options.ChallengeTTL)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.twoFactorChallengeRepository.Create(ctx, challenge); err != nil {
+		return "", err
+	}
+
+	return plainText, nil
+}
+
+// GetChallenge looks up a pending challenge, unknown and expired tokens fail
+// with entities.ErrTwoFactorChallengeInvalid
+func (s *TwoFactorService) GetChallenge(ctx context.Context, token string) (*entities.TwoFactorChallenge, error) {
+	if token == "" {
+		return nil, entities.ErrTwoFactorChallengeInvalid
+	}
+
+	challenge, err := s.twoFactorChallengeRepository.GetByHashWithUser(ctx, security.HashToken(token))
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return nil, entities.ErrTwoFactorChallengeInvalid
+		}
+		return nil, err
+	}
+	if challenge.Expired() {
+		return nil, entities.ErrTwoFactorChallengeInvalid
+	}
+	return challenge, nil
+}
+
+// CompleteChallenge checks the code and consumes the challenge. A wrong code
+// fails with entities.ErrTwoFactorCodeInvalid until the attempts run out, the
+// challenge is then dropped and entities.ErrTwoFactorChallengeInvalid returned.
+func (s *TwoFactorService) CompleteChallenge(ctx context.Context, challenge *entities.TwoFactorChallenge, code string) error {
+	twoFactor, err := s.enabledTwoFactor(ctx, challenge.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyCode(ctx, twoFactor, code); err != nil {
+		if err != entities.ErrTwoFactorCodeInvalid {
+			return err
+		}
+
+		attempts, err := s.twoFactorChallengeRepository.RecordAttempt(ctx, challenge)
+		if err != nil {
+			if err == repositories.ErrNoRows {
+				return entities.ErrTwoFactorChallengeInvalid
+			}
+			return err
+		}
+		if attempts >= entities.TwoFactorChallengeMaxAttempts {
+			if err := s.twoFactorChallengeRepository.Delete(ctx, challenge); err != nil && err != repositories.ErrNoRows {
+				return err
+			}
+			return entities.ErrTwoFactorChallengeInvalid
+		}
+		return entities.ErrTwoFactorCodeInvalid
+	}
+
+	if err := s.twoFactorChallengeRepository.Delete(ctx, challenge); err != nil {
+		if err == repositories.ErrNoRows {
+			return entities.ErrTwoFactorChallengeInvalid
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *TwoFactorService) TrustDevice(ctx context.Context, deviceCommand *command.TrustDeviceCommand) (*command.TrustDeviceCommandResult, error) {
+	plainText, err := security.GenerateToken("", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := entities.NewTrustedDevice(deviceCommand.UserID, security.HashToken(plainText), deviceCommand.UserAgent, s.options.TrustedDeviceTTL)
+	if err != nil {
+		return nil, err
+	}
+	device, err = s.trustedDeviceRepository.Create(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewTrustDeviceCommandResult(device, plainText), nil
+}
+
+// PurgeExpired deletes abandoned challenges and expired trusted devices
+func (s *TwoFactorService) PurgeExpired(ctx context.Context) (int64, error) {
+	challenges, err := s.twoFactorChallengeRepository.DeleteExpired(ctx)
+	if err != nil {
+		return 0, err
+	}
+	devices, err := s.trustedDeviceRepository.DeleteExpired(ctx)
+	if err != nil {
+		return challenges, err
+	}
+	return challenges + devices, nil
+}
+
+func (s *TwoFactorService) enabledTwoFactor(ctx context.Context, userID int) (*entities.TwoFactor, error) {
+	twoFactor, err := s.twoFactorRepository.Get(ctx, userID)
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return nil, entities.ErrTwoFactorNotEnabled
+		}
+		return nil, err
+	}
+	if !twoFactor.Enabled() {
+		return nil, entities.ErrTwoFactorNotEnabled
+	}
+	return twoFactor, nil
+}
+
+func (s *TwoFactorService) deviceTrusted(ctx context.Context, userID int, token string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	device, err := s.trustedDeviceRepository.GetByHash(ctx, userID, security.HashToken(token))
+	if err != nil {
+		if err == repositories.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return !device.Expired(), nil
+}
+
+// verifyCode accepts a TOTP code that was not used before or an unused
+// recovery code, redeeming it
+func (s *TwoFactorService) verifyCode(ctx context.Context, twoFactor *entities.TwoFactor, code string) error {
+	code = strings.TrimSpace(code)
+
+	if isTOTPCode(code) {
+		step, err := s.validateTOTP(twoFactor, code)
+		if err != nil {
+			return err
+		}
+		if err := s.twoFactorRepository.MarkStepUsed(ctx, twoFactor.UserID, step); err != nil {
+			if err == repositories.ErrNoRows {
+				return entities.ErrTwoFactorCodeInvalid
+			}
+			return err
+		}
+		return nil
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return entities.ErrTwoFactorCodeInvalid
+	}
+	if err := s.twoFactorRepository.UseRecoveryCode(ctx, twoFactor.UserID, security.HashToken(normalized)); err != nil {
+		if err == repositories.ErrNoRows {
+			return entities.ErrTwoFactorCodeInvalid
+		}
+		return err
+	}
+	return nil
+}
+
+// validateTOTP returns the time step the code belongs to, steps up to the last
+// used one are rejected
+func (s *TwoFactorService) validateTOTP(twoFactor *entities.TwoFactor, code string) (int64, error) {
+	secret, err := s.cipher.Decrypt(twoFactor.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt totp secret of user %d: %w", twoFactor.UserID, err)
+	}
+
+	step, ok := security.ValidateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok || step <= twoFactor.LastUsedStep {
+		return 0, entities.ErrTwoFactorCodeInvalid
+	}
+	return step, nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCodes returns the codes for display, formatted as
+// xxxx-xxxx, and their hashes for storage
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, security.HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode makes the dash and letter case optional when a code is
+// typed back in
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/pkg/security"
+)
+
+type twoFactorFixture struct {
+	service    ITwoFactorService
+	twoFactors *fakeTwoFactorRepository
+	challenges *fakeTwoFactorChallengeRepository
+}
+
+func newTwoFactorFixture(t *testing.T) *twoFactorFixture {
+	t.Helper()
+	cipher, err := security.NewAESGCMCipher([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewAESGCMCipher() error = %v", err)
+	}
+	f := &twoFactorFixture{
+		twoFactors: newFakeTwoFactorRepository(),
+		challenges: newFakeTwoFactorChallengeRepository(),
+	}
+	f.service = NewTwoFactorService(f.twoFactors, f.challenges, nil, cipher, TwoFactorOptions{
+		Issuer:       "Starter",
+		ChallengeTTL: 5 * time.Minute,
+	})
+	return f
+}
+
+// totpCode returns the code of the step offset steps away from now
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := security.TOTPCode(secret, security.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("TOTPCode() error = %v", err)
+	}
+	return code
+}
+
+// enroll enables two-factor authentication for the user and returns the
+// secret and the recovery codes
+func (f *twoFactorFixture) enroll(t *testing.T, userID int) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	begun, err := f.service.BeginEnrollment(ctx, &command.BeginTwoFactorEnrollmentCommand{UserID: userID, Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("BeginEnrollment() error = %v", err)
+	}
+	confirmed, err := f.service.ConfirmEnrollment(ctx, &command.TwoFactorCodeCommand{UserID: userID, Code: totpCode(t, begun.Secret, 0)})
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment() error = %v", err)
+	}
+	return begun.Secret, confirmed.RecoveryCodes
+}
+
+// challenge starts a challenge for the user as after a correct password
+func (f *twoFactorFixture) challenge(t *testing.T, user *entities.User) *entities.TwoFactorChallenge {
+	t.Helper()
+	ctx := context.Background()
+	token, err := f.service.StartChallenge(ctx, &command.StartTwoFactorChallengeCommand{User: user})
+	if err != nil {
+		t.Fatalf("StartChallenge() error = %v", err)
+	}
+	challenge, err := f.service.GetChallenge(ctx, token)
+	if err != nil {
+		t.Fatalf("GetChallenge() error = %v", err)
+	}
+	return challenge
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	ctx := context.Background()
+	f := newTwoFactorFixture(t)
+
+	begun, err := f.service.BeginEnrollment(ctx, &command.BeginTwoFactorEnrollmentCommand{UserID: 1, Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("BeginEnrollment() error = %v", err)
+	}
+	if !strings.Contains(begun.URI, "secret="+begun.Secret) || !strings.Contains(begun.URI, "issuer=Starter") {
+		t.Errorf("URI = %q, want the secret and the issuer", begun.URI)
+	}
+	if stored := f.twoFactors.twoFactors[1].Secret; stored == begun.Secret || stored == "" {
+		t.Errorf("stored secret = %q, want it encrypted", stored)
+	}
+
+	// a pending secret does not protect the account yet
+	status, err := f.service.GetStatus(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.Status.Enabled {
+		t.Error("GetStatus() enabled before the confirmation")
+	}
+
+	wrong := &command.TwoFactorCodeCommand{UserID: 1, Code: totpCode(t, begun.Secret, 10)}
+	if _, err := f.service.ConfirmEnrollment(ctx, wrong); !errors.Is(err, entities.ErrTwoFactorCodeInvalid) {
+		t.Fatalf("ConfirmEnrollment() with a code far off error = %v, want ErrTwoFactorCodeInvalid", err)
+	}
+
+	confirmed, err := f.service.ConfirmEnrollment(ctx, &command.TwoFactorCodeCommand{UserID: 1, Code: totpCode(t, begun.Secret, 0)})
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment() error = %v", err)
+	}
+	if len(confirmed.RecoveryCodes) != recoveryCodeCount {
+		t.Errorf("ConfirmEnrollment() returned %d recovery codes, want %d", len(confirmed.RecoveryCodes), recoveryCodeCount)
+	}
+
+	status, err = f.service.GetStatus(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if !status.Status.Enabled || status.Status.RecoveryCodesLeft != recoveryCodeCount {
+		t.Errorf("GetStatus() = %+v, want enabled with %d recovery codes", status.Status, recoveryCodeCount)
+	}
+
+	if _, err := f.service.BeginEnrollment(ctx, &command.BeginTwoFactorEnrollmentCommand{UserID: 1}); !errors.Is(err, entities.ErrTwoFactorAlreadyEnabled) {
+		t.Errorf("BeginEnrollment() when enabled error = %v, want ErrTwoFactorAlreadyEnabled", err)
+	}
+}
+
+func TestTwoFactorChallenge(t *testing.T) {
+	ctx := context.Background()
+	f := newTwoFactorFixture(t)
+	user := newTestUser(1, "ada@example.com", "secret")
+
+	token, err := f.service.StartChallenge(ctx, &command.StartTwoFactorChallengeCommand{User: user})
+	if err != nil || token != "" {
+		t.Fatalf("StartChallenge() without two-factor = %q, %v, want no challenge", token, err)
+	}
+
+	secret, _ := f.enroll(t, user.ID)
+	token, err = f.service.StartChallenge(ctx, &command.StartTwoFactorChallengeCommand{User: user})
+	if err != nil || token == "" {
+		t.Fatalf("StartChallenge() = %q, %v, want a challenge", token, err)
+	}
+	challenge, err := f.service.GetChallenge(ctx, token)
+	if err != nil {
+		t.Fatalf("GetChallenge() error = %v", err)
+	}
+	if _, err := f.service.GetChallenge(ctx, token+"x"); !errors.Is(err, entities.ErrTwoFactorChallengeInvalid) {
+		t.Errorf("GetChallenge() of an unknown token error = %v, want ErrTwoFactorChallengeInvalid", err)
+	}
+
+	if err := f.service.CompleteChallenge(ctx, challenge, totpCode(t, secret, 1)); err != nil {
+		t.Fatalf("CompleteChallenge() error = %v", err)
+	}
+	if _, err := f.service.GetChallenge(ctx, token); !errors.Is(err, entities.ErrTwoFactorChallengeInvalid) {
+		t.Errorf("GetChallenge() of a completed challenge error = %v, want ErrTwoFactorChallengeInvalid", err)
+	}
+}
+
+func TestTwoFactorChallengeAttempts(t *testing.T) {
+	ctx := context.Background()
+	f := newTwoFactorFixture(t)
+	user := newTestUser(1, "ada@example.com", "secret")
+	f.enroll(t, user.ID)
+	challenge := f.challenge(t, user)
+
+	for i := 1; i <= entities.TwoFactorChallengeMaxAttempts; i++ {
+		want := entities.ErrTwoFactorCodeInvalid
+		if i == entities.TwoFactorChallengeMaxAttempts {
+			want = entities.ErrTwoFactorChallengeInvalid
+		}
+		if err := f.service.CompleteChallenge(ctx, challenge, "wrong-code"); !errors.Is(err, want) {
+			t.Fatalf("attempt %d error = %v, want %v", i, err, want)
+		}
+	}
+	if _, ok := f.challenges.challenges[challenge.TokenHash]; ok {
+		t.Error("challenge kept after the last attempt")
+	}
+}
+
+// a code is accepted once, and neither it nor an older one is accepted again
+// within the validity window
+func TestTwoFactorReplay(t *testing.T) {
+	ctx := context.Background()
+	f := newTwoFactorFixture(t)
+	secret, _ := f.enroll(t, 1)
+
+	tests := []struct {
+		name    string
+		offset  int64
+		wantErr error
+	}{
+		{name: "code used for the confirmation", offset: 0, wantErr: entities.ErrTwoFactorCodeInvalid},
+		{name: "previous step", offset: -1, wantErr: entities.ErrTwoFactorCodeInvalid},
+		{name: "next step", offset: 1},
+		{name: "next step again", offset: 1, wantErr: entities.ErrTwoFactorCodeInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.RegenerateRecoveryCodes(ctx, &command.TwoFactorCodeCommand{UserID: 1, Code: totpCode(t, secret, tt.offset)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("RegenerateRecoveryCodes() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	f := newTwoFactorFixture(t)
+	user := newTestUser(1, "ada@example.com", "secret")
+	secret, codes := f.enroll(t, user.ID)
+
+	// codes may be typed back in upper case and without the dash
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+		left    int
+	}{
+		{name: "unknown code", code: "aaaa-aaaa", wantErr: entities.ErrTwoFactorCodeInvalid, left: recoveryCodeCount},
+		{name: "valid code", code: " " + typed + " ", left: recoveryCodeCount - 1},
+		{name: "used code", code: codes[0], wantErr: entities.ErrTwoFactorCodeInvalid, left: recoveryCodeCount - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := f.challenge(t, user)
+			if err := f.service.CompleteChallenge(ctx, challenge, tt.code); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteChallenge() error = %v, want %v", err, tt.wantErr)
+			}
+			status, err := f.service.GetStatus(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("GetStatus() error = %v", err)
+			}
+			if status.Status.RecoveryCodesLeft != tt.left {
+				t.Errorf("RecoveryCodesLeft = %d, want %d", status.Status.RecoveryCodesLeft, tt.left)
+			}
+		})
+	}
+
+	regenerated, err := f.service.RegenerateRecoveryCodes(ctx, &command.TwoFactorCodeCommand{UserID: user.ID, Code: totpCode(t, secret, 1)})
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes() error = %v", err)
+	}
+	if err := f.service.CompleteChallenge(ctx, f.challenge(t, user), codes[1]); !errors.Is(err, entities.ErrTwoFactorCodeInvalid) {
+		t.Errorf("CompleteChallenge() with a replaced code error = %v, want ErrTwoFactorCodeInvalid", err)
+	}
+	if err := f.service.CompleteChallenge(ctx, f.challenge(t, user), regenerated.RecoveryCodes[0]); err != nil {
+		t.Errorf("CompleteChallenge() with a new code error = %v", err)
+	}
+}
@@ -2,56 +2,206 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"go-starter-template/internal/application/command"
 	"go-starter-template/internal/application/query"
 	"go-starter-template/internal/domain/entities"
 	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
 	"go-starter-template/pkg/security"
+	"go-starter-template/pkg/tracing"
 )
 
 type IUserService interface {
 	Login(ctx context.Context, loginCommand *command.CreateLoginCommand) (*command.CreateLoginCommandResult, error)
+	CompleteTwoFactorLogin(ctx context.Context, loginCommand *command.CompleteTwoFactorLoginCommand) (*command.CreateLoginCommandResult, error)
 	Signup(ctx context.Context, signupCommand *command.CreateSignupCommand) (*command.CreateSignupCommandResult, error)
 	GetUserByEmail(ctx context.Context, email string) (*query.GetUserQuery, error)
 }
 
 type UserService struct {
-	userRepository repositories.IUserRepository
-	sessionService ISessionService
-	passwordHasher security.IPasswordHasher
+	userRepository           repositories.IUserRepository
+	sessionService           ISessionService
+	loginThrottleService     ILoginThrottleService
+	emailVerificationService IEmailVerificationService
+	twoFactorService         ITwoFactorService
+	passwordHasher           security.IPasswordHasher
+	passwordPolicy           valueobject.PasswordPolicy
+	// dummyHash is compared against for unknown emails so they take as long
+	// as a wrong password
+	dummyHash string
 }
 
 func NewUserService(
 	userRepository repositories.IUserRepository,
 	passwordHasher security.IPasswordHasher,
+	passwordPolicy valueobject.PasswordPolicy,
 	sessionService ISessionService,
-) IUserService {
-	return &UserService{
-		userRepository: userRepository,
-		passwordHasher: passwordHasher,
-		sessionService: sessionService,
+	loginThrottleService ILoginThrottleService,
+	emailVerificationService IEmailVerificationService,
+	twoFactorService ITwoFactorService,
+) (IUserService, error) {
+	dummyHash, err := passwordHasher.GenerateFromPassword("not-a-real-password")
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the dummy password: %w", err)
 	}
+	return &UserService{
+		userRepository:           userRepository,
+		passwordHasher:           passwordHasher,
+		passwordPolicy:           passwordPolicy,
+		sessionService:           sessionService,
+		loginThrottleService:     loginThrottleService,
+		emailVerificationService: emailVerificationService,
+		twoFactorService:         twoFactorService,
+		dummyHash:                dummyHash,
+	}, nil
 }
 
+// Login fails with entities.ErrInvalidCredentials whether the email is unknown
+// or the password is wrong, and with *entities.LoginThrottledError while the
+// account or client IP is backing off after failed attempts. Unverified users
+// get entities.ErrEmailNotVerified when verification blocks login and disabled
+// users entities.ErrUserDisabled, both only after a correct password. Users with
+// two-factor authentication get a challenge token instead of a session unless
+// the device is trusted, see CompleteTwoFactorLogin.
 func (s *UserService) Login(ctx context.Context, loginCommand *command.CreateLoginCommand) (*command.CreateLoginCommandResult, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Login")
+	defer span.End()
+
+	result, err := s.login(ctx, loginCommand)
+	recordLogin(loginMethodPassword, result, err)
+	span.SetAttributes(tracing.String("login.result", loginResult(result, err)))
+	return result, err
+}
+
+func (s *UserService) login(ctx context.Context, loginCommand *command.CreateLoginCommand) (*command.CreateLoginCommandResult, error) {
+	attempt := &command.LoginAttemptCommand{
+		Email:     loginCommand.Email,
+		IPAddress: loginCommand.IPAddress,
+	}
+	if err := s.loginThrottleService.Check(ctx, attempt); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepository.GetByEmail(ctx, loginCommand.Email)
-	if err != nil {
+	if err != nil && err != repositories.ErrNoRows {
 		return nil, err
 	}
 
+	if user == nil {
+		s.passwordHasher.CompareHashAndPassword(s.dummyHash, loginCommand.Password)
+		return nil, s.loginFailed(ctx, attempt)
+	}
+
 	if err = s.passwordHasher.CompareHashAndPassword(user.Password.ToString(), loginCommand.Password); err != nil {
+		attempt.UserID = &user.ID
+		return nil, s.loginFailed(ctx, attempt)
+	}
+	s.rehashPassword(ctx, user, loginCommand.Password)
+
+	if user.Disabled() {
+		return nil, entities.ErrUserDisabled
+	}
+
+	if s.emailVerificationService.BlocksLogin() && !user.Verified() {
+		return nil, entities.ErrEmailNotVerified
+	}
+
+	challengeToken, err := s.twoFactorService.StartChallenge(ctx, &command.StartTwoFactorChallengeCommand{
+		User:        user,
+		Remember:    loginCommand.Remember,
+		DeviceToken: loginCommand.DeviceToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the failed attempts keep counting until the second factor is entered
+	if challengeToken != "" {
+		result := command.NewLoginUserCommandResult(user)
+		result.TwoFactorToken = challengeToken
+		return result, nil
+	}
+
+	if err = s.loginThrottleService.Reset(ctx, loginCommand.Email); err != nil {
+		return nil, err
+	}
+
+	return s.createLoginSession(ctx, user, loginCommand.Remember, loginCommand.UserAgent, loginCommand.IPAddress)
+}
+
+// CompleteTwoFactorLogin finishes a login started by Login with the code of
+// the second factor. Wrong codes count as failed logins of the account.
+func (s *UserService) CompleteTwoFactorLogin(ctx context.Context, loginCommand *command.CompleteTwoFactorLoginCommand) (*command.CreateLoginCommandResult, error) {
+	ctx, span := tracing.Start(ctx, "UserService.CompleteTwoFactorLogin")
+	defer span.End()
+
+	result, err := s.completeTwoFactorLogin(ctx, loginCommand)
+	recordLogin(loginMethodPassword, result, err)
+	span.SetAttributes(tracing.String("login.result", loginResult(result, err)))
+	return result, err
+}
+
+func (s *UserService) completeTwoFactorLogin(ctx context.Context, loginCommand *command.CompleteTwoFactorLoginCommand) (*command.CreateLoginCommandResult, error) {
+	challenge, err := s.twoFactorService.GetChallenge(ctx, loginCommand.Token)
+	if err != nil {
 		return nil, err
 	}
 
-	var extendSessionExpiryByHour int
-	if loginCommand.Remember {
-		extendSessionExpiryByHour = 30 * 24
+	user := challenge.User
+	// the account may have been disabled while the code was being entered
+	if user.Disabled() {
+		return nil, entities.ErrUserDisabled
 	}
 
+	attempt := &command.LoginAttemptCommand{
+		Email:     user.Email.ToString(),
+		IPAddress: loginCommand.IPAddress,
+		UserID:    &user.ID,
+	}
+	if err := s.loginThrottleService.Check(ctx, attempt); err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorService.CompleteChallenge(ctx, challenge, loginCommand.Code); err != nil {
+		if err == entities.ErrTwoFactorCodeInvalid || err == entities.ErrTwoFactorChallengeInvalid {
+			if recordErr := s.loginThrottleService.RecordFailure(ctx, attempt); recordErr != nil {
+				return nil, recordErr
+			}
+		}
+		return nil, err
+	}
+
+	if err = s.loginThrottleService.Reset(ctx, attempt.Email); err != nil {
+		return nil, err
+	}
+
+	result, err := s.createLoginSession(ctx, user, challenge.Remember, loginCommand.UserAgent, loginCommand.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if loginCommand.RememberDevice {
+		deviceResult, err := s.twoFactorService.TrustDevice(ctx, &command.TrustDeviceCommand{
+			UserID:    user.ID,
+			UserAgent: loginCommand.UserAgent,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.TrustedDevice = deviceResult.Device
+	}
+
+	return result, nil
+}
+
+func (s *UserService) createLoginSession(ctx context.Context, user *entities.User, remember bool, userAgent, ipAddress string) (*command.CreateLoginCommandResult, error) {
 	sessionResult, err := s.sessionService.CreateSession(ctx, &command.CreateSessionCommand{
-		User:         user,
-		ExtendByHour: extendSessionExpiryByHour,
+		User:      user,
+		Remember:  remember,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
 	})
 
 	if err != nil {
@@ -65,6 +215,9 @@ func (s *UserService) Login(ctx context.Context, loginCommand *command.CreateLog
 }
 
 func (s *UserService) Signup(ctx context.Context, signupCommand *command.CreateSignupCommand) (*command.CreateSignupCommandResult, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Signup")
+	defer span.End()
+
 	existingUser, err := s.userRepository.GetByEmail(ctx, signupCommand.Email)
 	if err != nil && err != repositories.ErrNoRows {
 		return nil, err
@@ -73,26 +226,69 @@ func (s *UserService) Signup(ctx context.Context, signupCommand *command.CreateS
 		return nil, entities.ErrUserAlreadyExists
 	}
 
-	user, err := entities.NewUser(signupCommand.Email, signupCommand.Password)
+	user, err := entities.NewUser(signupCommand.Email, signupCommand.Password, s.passwordPolicy)
 	if err != nil {
 		return nil, err
 	}
 
-	hashedPassword, err := s.passwordHasher.GenerateFromPassword(signupCommand.Password, 10)
+	hashedPassword, err := s.passwordHasher.GenerateFromPassword(signupCommand.Password)
 	if err != nil {
 		return nil, err
 	}
 	user.SetPassword(hashedPassword)
 
+	// the unique index catches a signup racing this one past the check above
 	createdUser, err := s.userRepository.Create(ctx, user)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return nil, entities.ErrUserAlreadyExists
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	signupsTotal.Inc(loginMethodPassword)
+
+	// the account exists at this point, a failed email is recovered by
+	// asking for a new link
+	s.emailVerificationService.SendVerification(ctx, &command.SendVerificationCommand{User: createdUser})
+
 	return command.NewSignupUserCommandResult(createdUser), nil
 }
 
+// rehashPassword upgrades a hash made with an outdated algorithm or cost
+// while the plain password is at hand. It is best effort, the old hash keeps
+// working and the next login tries again.
+func (s *UserService) rehashPassword(ctx context.Context, user *entities.User, password string) {
+	if !s.passwordHasher.NeedsRehash(user.Password.ToString()) {
+		return
+	}
+
+	hashedPassword, err := s.passwordHasher.GenerateFromPassword(password)
+	if err != nil {
+		return
+	}
+	// the hash is only replaced while it is the one just verified, a password
+	// changed in the meantime wins
+	rehashed := *user
+	if err := rehashed.SetPassword(hashedPassword); err != nil {
+		return
+	}
+	if err := s.userRepository.UpdatePassword(ctx, &rehashed, user.Password.ToString()); err == nil {
+		*user = rehashed
+	}
+}
+
+func (s *UserService) loginFailed(ctx context.Context, attempt *command.LoginAttemptCommand) error {
+	if err := s.loginThrottleService.RecordFailure(ctx, attempt); err != nil {
+		return err
+	}
+	return entities.ErrInvalidCredentials
+}
+
 func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*query.GetUserQuery, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByEmail")
+	defer span.End()
+
 	user, err := s.userRepository.GetByEmail(ctx, email)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/pkg/security"
+)
+
+// brokenHasher fails like a hasher given an invalid cost
+type brokenHasher struct {
+	security.IPasswordHasher
+}
+
+func (brokenHasher) GenerateFromPassword(password string) (string, error) {
+	return "", errors.New("invalid cost")
+}
+
+func TestNewUserServiceHasherError(t *testing.T) {
+	service, err := NewUserService(newFakeUserRepository(), brokenHasher{}, valueobject.DefaultPasswordPolicy(), &fakeSessionService{}, nil, &fakeEmailVerificationService{}, fakeTwoFactorService{})
+	if err == nil || service != nil {
+		t.Fatalf("NewUserService() = %v, %v, want an error", service, err)
+	}
+}
+
+// upgradingHasher treats the hashes of plainHasher as outdated. onGenerate
+// runs before a new hash is returned, standing in for a concurrent request.
+type upgradingHasher struct {
+	plainHasher
+	onGenerate func()
+}
+
+func (h *upgradingHasher) GenerateFromPassword(password string) (string, error) {
+	if h.onGenerate != nil {
+		h.onGenerate()
+	}
+	return "v2:" + password, nil
+}
+
+func (h *upgradingHasher) CompareHashAndPassword(hashedPassword, password string) error {
+	if hashedPassword == "v2:"+password {
+		return nil
+	}
+	return h.plainHasher.CompareHashAndPassword(hashedPassword, password)
+}
+
+func (h *upgradingHasher) NeedsRehash(hashedPassword string) bool {
+	return !strings.HasPrefix(hashedPassword, "v2:")
+}
+
+func TestLoginRehash(t *testing.T) {
+	tests := []struct {
+		name string
+		// concurrent changes the stored user while the login hashes
+		concurrent func(users *fakeUserRepository)
+		want       string
+	}{
+		{name: "outdated hash", want: "v2:secret"},
+		{
+			name: "password changed meanwhile",
+			concurrent: func(users *fakeUserRepository) {
+				users.users[1].Password = valueobject.NewPasswordHash("hash:changed")
+			},
+			want: "hash:changed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newFakeUserRepository(newTestUser(1, "ada@example.com", "secret"))
+			hasher := &upgradingHasher{}
+			service, err := NewUserService(
+				users,
+				hasher,
+				valueobject.DefaultPasswordPolicy(),
+				&fakeSessionService{},
+				NewLoginThrottleService(newFakeLoginThrottleRepository(), testThrottlePolicy),
+				&fakeEmailVerificationService{},
+				fakeTwoFactorService{},
+			)
+			if err != nil {
+				t.Fatalf("NewUserService() error = %v", err)
+			}
+			if tt.concurrent != nil {
+				hasher.onGenerate = func() { tt.concurrent(users) }
+			}
+
+			_, err = service.Login(context.Background(), &command.CreateLoginCommand{Email: "ada@example.com", Password: "secret"})
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+			if got := users.users[1].Password.ToString(); got != tt.want {
+				t.Errorf("stored password = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// the policy handed to the service decides, not a package default
+func TestSignupPasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  valueobject.PasswordPolicy
+		wantErr bool
+	}{
+		{name: "default policy", policy: valueobject.DefaultPasswordPolicy(), wantErr: true},
+		{name: "passphrase policy", policy: valueobject.PasswordPolicy{MinLength: 15}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := NewUserService(newFakeUserRepository(), plainHasher{}, tt.policy, &fakeSessionService{}, nil, &fakeEmailVerificationService{}, fakeTwoFactorService{})
+			if err != nil {
+				t.Fatalf("NewUserService() error = %v", err)
+			}
+
+			_, err = service.Signup(context.Background(), &command.CreateSignupCommand{Email: "ada@example.com", Password: "correct horse battery staple"})
+			var validationErr *valueobject.PasswordValidationError
+			if got := errors.As(err, &validationErr); got != tt.wantErr {
+				t.Errorf("Signup() error = %v, want a password validation error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,40 +3,71 @@ package bootstrap
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
 	"go-starter-template/internal/infrastructure/config"
 	"go-starter-template/internal/infrastructure/db/postgres"
 	"go-starter-template/internal/infrastructure/factories"
 	"go-starter-template/internal/infrastructure/middlewares"
 	"go-starter-template/internal/interfaces/controllers"
+	"go-starter-template/pkg/health"
 	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/mailer"
+	"go-starter-template/pkg/metrics"
 	"go-starter-template/pkg/renderer"
 	"go-starter-template/pkg/router"
+	"go-starter-template/pkg/security"
+	"go-starter-template/pkg/tracing"
 
 	_ "github.com/lib/pq"
 )
 
 type App struct {
-	Config *config.Config
-	Router router.Router
-	DB     *sql.DB
-	server *http.Server
-	log    *logger.Logger
+	Config           *config.Config
+	Router           router.Router
+	DB               *sql.DB
+	server           *http.Server
+	log              *logger.Logger
+	logOutput        io.Writer
+	tracer           *tracing.Tracer
+	health           *health.Registry
+	sessionStore     repositories.ISessionRepository
+	sessionService   services.ISessionService
+	twoFactorService services.ITwoFactorService
+	passwordHasher   security.IPasswordHasher
+	passwordPolicy   valueobject.PasswordPolicy
+	mailer           mailer.IMailer
+	background       sync.WaitGroup
+	stopBackground   context.CancelFunc
 }
 
-func Init(ctx context.Context) *App {
-	a := new(App)
+func Init(ctx context.Context, sources config.Sources) *App {
+	a := &App{logOutput: os.Stdout}
 
 	// maintain the order to avoid nil pointer exception
+	a.initConfig(sources)
 	a.initLogger()
-	a.initConfig()
+	a.initTracing()
+	a.initPasswords()
 	a.initDB()
+	a.waitForDB(ctx)
+	a.initMigrations(ctx)
+	a.initMetrics()
+	a.initSessionStore()
+	a.initMailer()
+	a.initTwoFactor()
+	a.initHealth()
 
 	// if not using templ package enable this to use std templates
 	// a.initTemplatingEngine()
@@ -45,46 +76,205 @@ func Init(ctx context.Context) *App {
 	a.initFileServer()
 	a.initControllers()
 	a.initApplicationServer()
+	a.initBackgroundJobs(ctx)
 
 	return a
 }
 
+// LoadConfig only loads the configuration and sets up the logger, for the
+// commands of the binary other than serve. They log to stderr so their output
+// on stdout can be piped.
+func LoadConfig(sources config.Sources) *App {
+	a := &App{logOutput: os.Stderr}
+
+	a.initConfig(sources)
+	a.initLogger()
+
+	return a
+}
+
+// InitBase adds the database pool to LoadConfig and waits for the database
+// like the server does
+func InitBase(ctx context.Context, sources config.Sources) *App {
+	a := LoadConfig(sources)
+
+	a.initDB()
+	a.waitForDB(ctx)
+
+	return a
+}
+
+// InitOperator adds the password policy and the session store to InitBase,
+// the user and session commands go through them like the server does
+func InitOperator(ctx context.Context, sources config.Sources) *App {
+	a := InitBase(ctx, sources)
+
+	a.initPasswords()
+	a.initSessionStore()
+
+	return a
+}
+
+// InitRoutes wires the controllers like Init without checking the schema or
+// serving, nothing connects to the database on the way
+func InitRoutes(sources config.Sources) *App {
+	a := LoadConfig(sources)
+
+	a.initDB()
+	a.initPasswords()
+	a.initSessionStore()
+	a.initMailer()
+	a.initTwoFactor()
+	a.initHealth()
+	a.initRouterMux()
+	a.initFileServer()
+	a.initControllers()
+
+	return a
+}
+
+// CheckConfig builds everything that is derived from the configuration, any
+// invalid setting is fatal like it would be on startup
+func CheckConfig(sources config.Sources) *App {
+	a := LoadConfig(sources)
+
+	a.initDB()
+	a.initTracing()
+	a.initPasswords()
+	a.initSessionStore()
+	a.initMailer()
+	a.initTwoFactor()
+
+	return a
+}
+
+func (a *App) OperatorService() services.IOperatorService {
+	return factories.NewOperatorServiceWithPQRepository(a.DB, a.sessionService, a.passwordHasher, a.passwordPolicy)
+}
+
 func (a *App) GetLogger() *logger.Logger {
 	return a.log
 }
 
 func (a *App) initControllers() {
-	controllers.NewHealthController(a.Router, a.Config, a.DB)
+	emailVerificationService := factories.NewEmailVerificationServiceWithPQRepository(a.DB, a.Config, a.mailer)
+	identityService := factories.NewIdentityServiceWithPQRepository(
+		a.DB,
+		a.Config,
+		a.sessionService,
+		emailVerificationService,
+		a.twoFactorService,
+		a.passwordHasher,
+	)
+	authorizationService := factories.NewAuthorizationServiceWithPQRepository(a.DB)
+	userService, err := factories.NewUserServiceWithPQRepository(
+		a.DB,
+		a.sessionService,
+		factories.NewLoginThrottleServiceWithPQRepository(a.DB, a.Config),
+		emailVerificationService,
+		a.twoFactorService,
+		a.passwordHasher,
+		a.passwordPolicy,
+	)
+	if err != nil {
+		a.log.Fatal("failed to initialize the user service", "error", err)
+	}
+
+	controllers.NewHealthController(a.Router, a.Config, a.health)
+	controllers.NewMetricsController(a.Router, a.Config)
 	controllers.NewHomeController(a.Router)
 	controllers.NewTodoController(
 		a.Router,
-		factories.NewTodoServiceWithPQRepository(a.DB),
-		factories.NewSessionServiceWithPQRepository(a.DB),
+		factories.NewTodoServiceWithPQRepository(a.DB, authorizationService),
+		a.sessionService,
+		authorizationService,
+		emailVerificationService,
+		a.Config,
+	)
+	controllers.NewTodoAPIController(
+		a.Router,
+		factories.NewTodoServiceWithPQRepository(a.DB, authorizationService),
+		a.sessionService,
+		authorizationService,
+		factories.NewAPITokenServiceWithPQRepository(a.DB),
+		emailVerificationService,
+		a.Config,
+	)
+	controllers.NewAPITokenController(
+		a.Router,
+		factories.NewAPITokenServiceWithPQRepository(a.DB),
+		a.sessionService,
+		emailVerificationService,
 		a.Config,
 	)
 	controllers.NewAuthController(
 		a.Router,
-		factories.NewUserServiceWithPQRepository(a.DB, a.log),
+		userService,
+		a.sessionService,
+		factories.NewPasswordResetServiceWithPQRepository(a.DB, a.Config, a.sessionService, a.mailer, a.passwordHasher, a.passwordPolicy),
+		identityService,
+		a.Config,
+	)
+	controllers.NewIdentityController(a.Router, identityService, a.sessionService, a.Config)
+	controllers.NewAccountController(
+		a.Router,
+		factories.NewAccountServiceWithPQRepository(a.DB, a.sessionService, emailVerificationService, a.passwordHasher, a.passwordPolicy),
+		a.sessionService,
+		a.twoFactorService,
+		identityService,
+		a.Config,
+	)
+	controllers.NewVerificationController(a.Router, emailVerificationService)
+	controllers.NewAdminController(
+		a.Router,
+		factories.NewAdminServiceWithPQRepository(a.DB, authorizationService, a.sessionService),
+		a.sessionService,
+		authorizationService,
 		a.Config,
 	)
 }
 
 func (a *App) initLogger() {
-	a.log = logger.NewLogger()
-	a.log.Info("logger initialized")
+	a.log = logger.New(a.logOutput, logger.Options{
+		Level:  a.Config.LogConfig.Level,
+		Format: a.Config.LogConfig.Format,
+	})
+	logger.SetDefault(a.log)
+	a.log.Info("logger initialized", "level", a.Config.LogConfig.Level, "format", a.Config.LogConfig.Format)
 }
 
-func (a *App) initConfig() {
-	conf, err := config.NewConfig()
+func (a *App) initConfig(sources config.Sources) {
+	conf, err := config.NewConfig(sources)
 	if err != nil {
-		a.log.Fatal("%v", err)
+		// the logger is configured from it, so report with a plain one
+		log := logger.New(a.logOutput, logger.Options{Format: logger.FormatText})
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			for _, problem := range invalid.Problems {
+				log.Error("invalid configuration", "problem", problem)
+			}
+			log.Fatal("failed to load configuration", "problems", len(invalid.Problems))
+		}
+		log.Fatal("failed to load configuration", "error", err)
 	}
 	a.Config = conf
-	a.log.Info("configuration initialized")
+}
+
+// initPasswords sets up the password policy and the hasher shared by the
+// services
+func (a *App) initPasswords() {
+	policy, err := factories.NewPasswordPolicy(a.Config)
+	if err != nil {
+		a.log.Fatal("failed to initialize password policy", "error", err)
+	}
+	a.passwordPolicy = policy
+
+	a.passwordHasher = factories.NewPasswordHasher(a.Config, a.log)
+	a.log.Info("passwords initialized", "hasher", a.Config.PasswordConfig.Hasher, "breached_passwords", len(policy.Breached))
 }
 
 func (a *App) initApplicationServer() {
-	addr := fmt.Sprintf(":%s", a.Config.Port)
+	addr := fmt.Sprintf(":%d", a.Config.Port)
 	server := http.Server{
 		Addr:    addr,
 		Handler: a.Router,
@@ -95,43 +285,220 @@ func (a *App) initApplicationServer() {
 func (a *App) initFileServer() {
 	fs := http.FileServer(http.Dir("./web"))
 	a.Router.Handle("/web/", http.StripPrefix("/web/", fs))
-	a.log.Info("file server initialized in directory web/ directory")
+	a.log.Info("file server initialized", "dir", "web/")
 }
 
 func (a *App) initRouterMux() {
 	r := router.NewNetServerMux()
 
-	// add middlewares
+	// add middlewares, the first one added is the outermost
+	r.Use(middlewares.RequestID(a.log))
+	r.Use(middlewares.Tracing)
 	r.Use(middlewares.Logger)
+	r.Use(middlewares.Metrics)
+	r.Use(middlewares.EnableCors(a.Config.AllowedOrigins))
 	csrfMiddleware := middlewares.CSRFMiddleware(a.Config.CSRFAuthKey)
 	r.Use(csrfMiddleware)
-	r.Use(middlewares.EnableCors(a.Config.AllowedOrigins))
 
 	a.Router = r
 
 	a.log.Info("router initialized")
 }
 
+func (a *App) initBackgroundJobs(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	a.stopBackground = cancel
+
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		a.runReaper(ctx)
+	}()
+}
+
+// runReaper periodically deletes expired sessions, stale login throttles,
+// spent password reset and email verification tokens and abandoned two-factor
+// challenges and trusted devices until ctx is done
+func (a *App) runReaper(ctx context.Context) {
+	loginThrottleService := factories.NewLoginThrottleServiceWithPQRepository(a.DB, a.Config)
+	passwordResetService := factories.NewPasswordResetServiceWithPQRepository(a.DB, a.Config, a.sessionService, a.mailer, a.passwordHasher, a.passwordPolicy)
+	emailVerificationService := factories.NewEmailVerificationServiceWithPQRepository(a.DB, a.Config, a.mailer)
+	ticker := time.NewTicker(a.Config.SessionConfig.CleanupInterval)
+	defer ticker.Stop()
+
+	a.log.Info("reaper started", "interval", a.Config.SessionConfig.CleanupInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.log.Info("reaper stopped")
+			return
+		case <-ticker.C:
+			purged, err := a.sessionService.PurgeExpiredSessions(ctx)
+			if err != nil {
+				a.log.Error("failed to purge expired sessions", "error", err)
+			} else if purged > 0 {
+				a.log.Info("purged expired sessions", "count", purged)
+			}
+
+			purged, err = loginThrottleService.PurgeStale(ctx)
+			if err != nil {
+				a.log.Error("failed to purge login throttles", "error", err)
+			} else if purged > 0 {
+				a.log.Info("purged stale login throttles", "count", purged)
+			}
+
+			purged, err = passwordResetService.PurgeExpiredTokens(ctx)
+			if err != nil {
+				a.log.Error("failed to purge password reset tokens", "error", err)
+			} else if purged > 0 {
+				a.log.Info("purged password reset tokens", "count", purged)
+			}
+
+			purged, err = emailVerificationService.PurgeExpiredTokens(ctx)
+			if err != nil {
+				a.log.Error("failed to purge email verification tokens", "error", err)
+			} else if purged > 0 {
+				a.log.Info("purged email verification tokens", "count", purged)
+			}
+
+			purged, err = a.twoFactorService.PurgeExpired(ctx)
+			if err != nil {
+				a.log.Error("failed to purge two factor challenges and devices", "error", err)
+			} else if purged > 0 {
+				a.log.Info("purged two factor challenges and trusted devices", "count", purged)
+			}
+		}
+	}
+}
+
 func (a *App) initDB() {
 	db, err := postgres.NewDatabaseConfig(a.Config)
 	if err != nil {
-		a.log.Fatal("failed to open database: %v", err)
+		a.log.Fatal("failed to open database", "error", err)
 	}
 	a.DB = db
 	a.log.Info("database initialized")
 }
 
+// waitForDB blocks until the database accepts connections, so the app and
+// Postgres can be started together
+func (a *App) waitForDB(ctx context.Context) {
+	conf := a.Config.DatabaseConfig
+	err := postgres.WaitForDatabase(ctx, a.DB, conf.WaitTimeout, func(attempt int, delay time.Duration, err error) {
+		a.log.Warn("waiting for the database", "attempt", attempt, "retry_in", delay, "error", err)
+	})
+	if err != nil {
+		a.log.Fatal("failed to connect to the database", "timeout", conf.WaitTimeout, "error", err)
+	}
+	a.log.Info("database connected", "max_open_conns", conf.MaxOpenConns, "statement_timeout", conf.StatementTimeout)
+}
+
+// initMigrations applies the pending migrations when DB_AUTO_MIGRATE is set.
+// The app refuses to start on an outdated schema, the queries would fail
+// later and in less obvious ways.
+func (a *App) initMigrations(ctx context.Context) {
+	// DDL may take longer than the statement timeout meant for requests
+	ctx = postgres.WithStatementTimeout(ctx, 0)
+
+	migrator, err := factories.NewMigrator(a.DB)
+	if err != nil {
+		a.log.Fatal("failed to load migrations", "error", err)
+	}
+
+	if a.Config.DatabaseConfig.AutoMigrate {
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			a.log.Info("migration applied", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			a.log.Fatal("failed to migrate the database", "error", err)
+		}
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		a.log.Fatal("failed to read the schema version", "error", err)
+	}
+	if len(pending) > 0 {
+		a.log.Fatal("database schema is behind, run `migrate up` or set DB_AUTO_MIGRATE=true",
+			"pending", len(pending),
+			"next", pending[0].File,
+		)
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		a.log.Fatal("failed to read the schema version", "error", err)
+	}
+	a.log.Info("database schema is up to date", "version", version)
+}
+
+// initMetrics adds the runtime and connection pool metrics, the HTTP and
+// application metrics register themselves
+func (a *App) initMetrics() {
+	metrics.Default.Register(metrics.NewGoCollector())
+	metrics.Default.Register(metrics.NewDBStatsCollector(a.DB))
+	a.log.Info("metrics initialized")
+}
+
+// initSessionStore creates the single session store and service shared by
+// every controller and the health checks, the in-memory store would
+// otherwise be split between them
+func (a *App) initSessionStore() {
+	a.sessionStore = factories.NewSessionRepository(a.DB, a.Config)
+	a.sessionService = factories.NewSessionService(a.DB, a.sessionStore, a.Config)
+	a.log.Info("session store initialized", "store", a.Config.SessionConfig.Store)
+}
+
+// initTracing sets the default tracer used by the router, the services and
+// the database connections
+func (a *App) initTracing() {
+	a.tracer = factories.NewTracer(a.Config, a.log)
+	tracing.SetDefault(a.tracer)
+	a.log.Info("tracing initialized", "exporter", a.Config.TracingConfig.Exporter, "sample_ratio", a.Config.TracingConfig.SampleRatio)
+}
+
+func (a *App) initMailer() {
+	mail, err := factories.NewMailer(a.Config, a.log)
+	if err != nil {
+		a.log.Fatal("failed to initialize mailer", "error", err)
+	}
+	a.mailer = mail
+	a.log.Info("mailer initialized", "driver", a.Config.MailConfig.Driver)
+}
+
+func (a *App) initTwoFactor() {
+	twoFactorService, err := factories.NewTwoFactorServiceWithPQRepository(a.DB, a.Config)
+	if err != nil {
+		a.log.Fatal("failed to initialize two factor authentication", "error", err)
+	}
+	a.twoFactorService = twoFactorService
+	a.log.Info("two factor authentication initialized")
+}
+
+// initHealth registers the readiness checks, it has to run after everything
+// that is checked has been set up
+func (a *App) initHealth() {
+	registry, err := factories.NewHealthRegistry(a.Config, a.DB, a.sessionStore, a.mailer)
+	if err != nil {
+		a.log.Fatal("failed to initialize health checks", "error", err)
+	}
+	a.health = registry
+	a.log.Info("health checks initialized", "cache_ttl", a.Config.HealthConfig.CacheTTL)
+}
+
 func (a *App) initTemplatingEngine() {
 	err := renderer.InitBaseTemplate(a.log)
 	if err != nil {
-		a.log.Fatal("%v", err)
+		a.log.Fatal("failed to parse templates", "error", err)
 	}
 	renderer.RegisterPageTemplates()
 	a.log.Info("templates parsed")
 }
 
 func (a *App) Serve() error {
-	a.log.Info("server running on port %s\n", a.server.Addr)
+	a.log.Info("server running", "addr", a.server.Addr)
 	return a.server.ListenAndServe()
 }
 
@@ -143,11 +510,26 @@ func (a *App) GracefulShutdown(ctx context.Context) {
 
 	a.log.Info("shutting down server...")
 
+	// fail readiness first and give load balancers time to notice before
+	// connections are refused
+	a.health.Drain()
+	if delay := a.Config.HealthConfig.DrainDelay; delay > 0 {
+		a.log.Info("draining traffic", "delay", delay)
+		time.Sleep(delay)
+	}
+
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := a.server.Shutdown(shutdownCtx); err != nil {
-		a.log.Fatal("failed to shutdown server: %v", err)
+		a.log.Fatal("failed to shutdown server", "error", err)
+	}
+
+	a.stopBackground()
+	a.background.Wait()
+
+	if err := a.tracer.Shutdown(shutdownCtx); err != nil {
+		a.log.Error("failed to flush spans", "error", err)
 	}
 
 	a.log.Info("server shut down gracefully")
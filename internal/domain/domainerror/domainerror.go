@@ -0,0 +1,128 @@
+// Package domainerror classifies the errors of the domain, so the layers
+// above can tell a missing record from a conflict or invalid input without
+// knowing every sentinel error. Errors that are not classified are internal
+// and their message is not meant for users.
+package domainerror
+
+import "errors"
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindTooManyRequests
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindForbidden:
+		return "forbidden"
+	case KindTooManyRequests:
+		return "too_many_requests"
+	default:
+		return "internal"
+	}
+}
+
+// Error is a classified error with a message that is safe to show to users.
+// The package level errors built with it are compared with errors.Is, so
+// they must not be changed after creation.
+type Error struct {
+	kind    Kind
+	message string
+	fields  map[string][]string
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{kind: kind, message: message}
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, message)
+}
+
+// Invalid is a validation error of a single input field, field is empty when
+// the problem is not tied to one
+func Invalid(field, message string) *Error {
+	err := New(KindValidation, message)
+	if field != "" {
+		err.fields = map[string][]string{field: {message}}
+	}
+	return err
+}
+
+func Unauthorized(message string) *Error {
+	return New(KindUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(KindForbidden, message)
+}
+
+func TooManyRequests(message string) *Error {
+	return New(KindTooManyRequests, message)
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) ErrorKind() Kind {
+	return e.kind
+}
+
+func (e *Error) FieldErrors() map[string][]string {
+	return e.fields
+}
+
+// classified is implemented by Error and by the domain errors that carry
+// more data, such as the password validation and login throttling errors
+type classified interface {
+	error
+	ErrorKind() Kind
+}
+
+// KindOf returns the kind of the first classified error in the chain of err
+func KindOf(err error) Kind {
+	var c classified
+	if errors.As(err, &c) {
+		return c.ErrorKind()
+	}
+	return KindInternal
+}
+
+// Message returns the user facing message of the first classified error in
+// the chain of err, or an empty string for internal errors
+func Message(err error) string {
+	var c classified
+	if errors.As(err, &c) && c.ErrorKind() != KindInternal {
+		return c.Error()
+	}
+	return ""
+}
+
+// Fields returns the problems per input field of a validation error
+func Fields(err error) map[string][]string {
+	var f interface{ FieldErrors() map[string][]string }
+	if errors.As(err, &f) {
+		return f.FieldErrors()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"go-starter-template/internal/domain/domainerror"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+var (
+	ErrAPITokenIsRequired     = errors.New("API token is required")
+	ErrAPITokenNameIsRequired = domainerror.Invalid("name", "token name is required")
+	ErrAPITokenInvalid        = domainerror.Unauthorized("API token is invalid or expired")
+	ErrAPITokenNotFound       = domainerror.NotFound("Token not found")
+)
+
+type APIToken struct {
+	ID         int
+	UserID     int
+	Name       string
+	Prefix     string
+	TokenHash  string
+	LastUsedAt *valueobject.Time
+	ExpiresAt  *valueobject.Time
+	CreatedAt  valueobject.Time
+	UpdatedAt  valueobject.Time
+	User       *User
+}
+
+func NewAPIToken(userID int, name, prefix, tokenHash string, expiresAt *valueobject.Time) (*APIToken, error) {
+	if userID == 0 {
+		return nil, ErrOwnerIsRequired
+	}
+	if name == "" {
+		return nil, ErrAPITokenNameIsRequired
+	}
+	currentTime := valueobject.NewCurrentTime()
+	return &APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: currentTime,
+		UpdatedAt: currentTime,
+	}, nil
+}
+
+func (t *APIToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(t.ExpiresAt.ToTime())
+}
@@ -0,0 +1,44 @@
+package entities
+
+import (
+	"time"
+
+	"go-starter-template/internal/domain/domainerror"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+var (
+	ErrEmailVerificationTokenInvalid = domainerror.Invalid("token", "Verification link is invalid or has expired")
+	ErrVerificationEmailRateLimited  = domainerror.TooManyRequests("A verification email was sent recently, please wait a moment before asking for another")
+)
+
+type EmailVerificationToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt valueobject.Time
+	CreatedAt valueobject.Time
+	User      *User
+}
+
+func NewEmailVerificationToken(userID int, tokenHash string, ttl time.Duration) (*EmailVerificationToken, error) {
+	if userID == 0 {
+		return nil, ErrOwnerIsRequired
+	}
+	currentTime := valueobject.NewCurrentTime()
+	return &EmailVerificationToken{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: valueobject.NewTime(currentTime.ToTime().Add(ttl)),
+		CreatedAt: currentTime,
+	}, nil
+}
+
+func (t *EmailVerificationToken) Expired() bool {
+	return time.Now().After(t.ExpiresAt.ToTime())
+}
+
+// SentWithin reports whether the token was issued less than interval ago
+func (t *EmailVerificationToken) SentWithin(interval time.Duration) bool {
+	return time.Since(t.CreatedAt.ToTime()) < interval
+}
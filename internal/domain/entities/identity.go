@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"go-starter-template/internal/domain/domainerror"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+var (
+	ErrIdentityProviderUnknown = domainerror.NotFound("Unknown sign in provider")
+	ErrIdentityLoginFailed     = domainerror.Unauthorized("Signing in with that provider failed, please try again")
+	ErrIdentityEmailMissing    = domainerror.Invalid("email", "The provider did not share an email address")
+	ErrIdentityEmailInUse      = domainerror.Conflict("An account with that email already exists, log in with your password and connect the provider from your account page")
+	ErrIdentityAlreadyLinked   = domainerror.Conflict("Your account is already connected to that provider")
+	ErrIdentityLinkedElsewhere = domainerror.Conflict("That login is already connected to another account")
+)
+
+// Identity links an account at an external provider to a user. Subject is the
+// stable id the provider assigns, Email is only informational as providers
+// let users change it.
+type Identity struct {
+	ID        int
+	UserID    int
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt valueobject.Time
+	UpdatedAt valueobject.Time
+	User      *User
+}
+
+func NewIdentity(user *User, provider, subject, email string) (*Identity, error) {
+	if user == nil {
+		return nil, ErrUserIsRequired
+	}
+	currentTime := valueobject.NewCurrentTime()
+	return &Identity{
+		UserID:    user.ID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: currentTime,
+		UpdatedAt: currentTime,
+		User:      user,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"go-starter-template/internal/domain/valueobject"
+)
+
+var testThrottlePolicy = LoginThrottlePolicy{
+	MaxAccountFailures: 5,
+	MaxIPFailures:      20,
+	FreeFailures:       2,
+	BaseDelay:          time.Second,
+	MaxDelay:           8 * time.Second,
+	LockoutDuration:    15 * time.Minute,
+	Window:             15 * time.Minute,
+}
+
+func TestLoginThrottleRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    string
+		failures int
+		// ago is how long ago the last failure happened
+		ago    time.Duration
+		policy func(*LoginThrottlePolicy)
+		want   time.Duration
+	}{
+		{name: "no failures", scope: LoginThrottleScopeAccount, failures: 0, want: 0},
+		{name: "first failure is free", scope: LoginThrottleScopeAccount, failures: 1, want: 0},
+		{name: "last free failure", scope: LoginThrottleScopeAccount, failures: 2, want: 0},
+		{name: "backoff starts at the base delay", scope: LoginThrottleScopeAccount, failures: 3, want: time.Second},
+		{name: "backoff doubles", scope: LoginThrottleScopeAccount, failures: 4, want: 2 * time.Second},
+		{name: "lockout at the account limit", scope: LoginThrottleScopeAccount, failures: 5, want: 15 * time.Minute},
+		{name: "lockout lasts past the limit", scope: LoginThrottleScopeAccount, failures: 9, want: 15 * time.Minute},
+		{name: "backoff is capped", scope: LoginThrottleScopeIP, failures: 10, want: 8 * time.Second},
+		{name: "huge counts stay capped", scope: LoginThrottleScopeIP, failures: 100, policy: func(p *LoginThrottlePolicy) { p.MaxIPFailures = 200 }, want: 8 * time.Second},
+		{name: "IP has its own limit", scope: LoginThrottleScopeIP, failures: 20, want: 15 * time.Minute},
+		{name: "wait already passed", scope: LoginThrottleScopeAccount, failures: 4, ago: 3 * time.Second, want: 0},
+		{name: "part of the wait passed", scope: LoginThrottleScopeAccount, failures: 5, ago: 5 * time.Minute, want: 10 * time.Minute},
+		{name: "every failure free", scope: LoginThrottleScopeAccount, failures: 4, policy: func(p *LoginThrottlePolicy) { p.FreeFailures = 4 }, want: 0},
+		{name: "no free failures", scope: LoginThrottleScopeAccount, failures: 1, policy: func(p *LoginThrottlePolicy) { p.FreeFailures = 0 }, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testThrottlePolicy
+			if tt.policy != nil {
+				tt.policy(&policy)
+			}
+			throttle := &LoginThrottle{
+				Scope:         tt.scope,
+				Key:           "key",
+				Failures:      tt.failures,
+				LastFailureAt: valueobject.NewTime(time.Now().Add(-tt.ago)),
+			}
+
+			got := throttle.RetryAfter(policy)
+			if got > tt.want || (tt.want > 0 && got <= tt.want-time.Second) {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleLocked(t *testing.T) {
+	tests := []struct {
+		scope      string
+		failures   int
+		locked     bool
+		justLocked bool
+	}{
+		{scope: LoginThrottleScopeAccount, failures: 4},
+		{scope: LoginThrottleScopeAccount, failures: 5, locked: true, justLocked: true},
+		{scope: LoginThrottleScopeAccount, failures: 6, locked: true},
+		{scope: LoginThrottleScopeIP, failures: 5},
+		{scope: LoginThrottleScopeIP, failures: 20, locked: true, justLocked: true},
+	}
+
+	for _, tt := range tests {
+		throttle := &LoginThrottle{Scope: tt.scope, Failures: tt.failures}
+		if got := throttle.Locked(testThrottlePolicy); got != tt.locked {
+			t.Errorf("%s with %d failures Locked() = %v, want %v", tt.scope, tt.failures, got, tt.locked)
+		}
+		if got := throttle.JustLocked(testThrottlePolicy); got != tt.justLocked {
+			t.Errorf("%s with %d failures JustLocked() = %v, want %v", tt.scope, tt.failures, got, tt.justLocked)
+		}
+	}
+}
+
+func TestNewLoginLockout(t *testing.T) {
+	lastFailure := time.Now().Add(-time.Minute)
+	throttle := &LoginThrottle{Scope: LoginThrottleScopeIP, Key: "203.0.113.7", Failures: 20, LastFailureAt: valueobject.NewTime(lastFailure)}
+
+	lockout := NewLoginLockout(throttle, nil, "203.0.113.7", testThrottlePolicy)
+	if want := lastFailure.Add(testThrottlePolicy.LockoutDuration); !lockout.LockedUntil.ToTime().Equal(want) {
+		t.Errorf("LockedUntil = %v, want %v", lockout.LockedUntil, want)
+	}
+	if lockout.Scope != throttle.Scope || lockout.Key != throttle.Key || lockout.Failures != 20 {
+		t.Errorf("lockout = %+v, want the scope, key and failures of the throttle", lockout)
+	}
+}
@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"time"
+
+	"go-starter-template/internal/domain/domainerror"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+var (
+	ErrPasswordResetTokenInvalid = domainerror.Invalid("token", "Password reset link is invalid or has expired")
+)
+
+type PasswordResetToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt valueobject.Time
+	UsedAt    *valueobject.Time
+	CreatedAt valueobject.Time
+	User      *User
+}
+
+func NewPasswordResetToken(userID int, tokenHash string, ttl time.Duration) (*PasswordResetToken, error) {
+	if userID == 0 {
+		return nil, ErrOwnerIsRequired
+	}
+	currentTime := valueobject.NewCurrentTime()
+	return &PasswordResetToken{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: valueobject.NewTime(currentTime.ToTime().Add(ttl)),
+		CreatedAt: currentTime,
+	}, nil
+}
+
+// Usable reports whether the token can still be redeemed
+func (t *PasswordResetToken) Usable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt.ToTime())
+}
@@ -0,0 +1,63 @@
+package entities
+
+import "go-starter-template/internal/domain/domainerror"
+
+var (
+	ErrRoleInvalid      = domainerror.Invalid("role", "Unknown role")
+	ErrPermissionDenied = domainerror.Forbidden("You are not allowed to do that")
+	ErrCannotModifySelf = domainerror.Forbidden("You cannot change the role of or disable your own account")
+	ErrUserDisabled     = domainerror.Forbidden("Your account has been disabled")
+)
+
+// Role groups permissions, every user has exactly one
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleUser   Role = "user"
+	RoleAdmin  Role = "admin"
+)
+
+// Permission names an action as "<resource>:<action>"
+type Permission string
+
+const (
+	PermissionTodosRead   Permission = "todos:read"
+	PermissionTodosWrite  Permission = "todos:write"
+	PermissionTodosDelete Permission = "todos:delete"
+	PermissionUsersRead   Permission = "users:read"
+	PermissionUsersManage Permission = "users:manage"
+)
+
+// Roles lists every role from the least to the most privileged
+var Roles = []Role{RoleViewer, RoleUser, RoleAdmin}
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {PermissionTodosRead},
+	RoleUser:   {PermissionTodosRead, PermissionTodosWrite, PermissionTodosDelete},
+	RoleAdmin: {
+		PermissionTodosRead, PermissionTodosWrite, PermissionTodosDelete,
+		PermissionUsersRead, PermissionUsersManage,
+	},
+}
+
+func NewRole(role string) (Role, error) {
+	r := Role(role)
+	if _, ok := rolePermissions[r]; !ok {
+		return "", ErrRoleInvalid
+	}
+	return r, nil
+}
+
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
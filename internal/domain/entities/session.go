@@ -6,36 +6,129 @@ import (
 
 	"github.com/google/uuid"
 
+	"go-starter-template/internal/domain/domainerror"
 	"go-starter-template/internal/domain/valueobject"
 )
 
 var (
 	ErrSessionIsRequired = errors.New("Session is required")
+	ErrSessionExpired    = domainerror.Unauthorized("Session has expired")
+	ErrSessionRevoked    = domainerror.Unauthorized("Session has been signed out")
 )
 
+const maxUserAgentLength = 512
+
+// SessionPolicy controls how long sessions live. A session expires after
+// IdleTimeout (RememberIdleTimeout for "remember me" logins) without use and
+// never outlives AbsoluteTimeout counted from its creation. Stores keeping a
+// snapshot of the user have it read again once it is UserRefreshInterval old,
+// zero reads it on every request.
+type SessionPolicy struct {
+	IdleTimeout         time.Duration
+	RememberIdleTimeout time.Duration
+	AbsoluteTimeout     time.Duration
+	UserRefreshInterval time.Duration
+}
+
+func (p SessionPolicy) idleTimeout(remember bool) time.Duration {
+	if remember {
+		return p.RememberIdleTimeout
+	}
+	return p.IdleTimeout
+}
+
 type Session struct {
-	ID        uuid.UUID
+	ID uuid.UUID
+	// Token is the opaque value handed to the client. Server side stores use
+	// the session id, stateless stores encode the whole session into it.
+	Token     string
+	Remember  bool
+	UserAgent string
+	IPAddress string
 	ExpiresAt valueobject.Time
 	CreatedAt valueobject.Time
 	UpdatedAt valueobject.Time
-	User      *User
+	// UserCheckedAt is when User was read, stores keeping a snapshot of the
+	// user return the time of the snapshot
+	UserCheckedAt valueobject.Time
+	User          *User
 }
 
-func NewSession(user *User) *Session {
+func NewSession(user *User, remember bool, policy SessionPolicy) *Session {
 	currentTime := valueobject.NewCurrentTime()
-	return &Session{
-		ID:        uuid.New(),
-		ExpiresAt: currentTime.ExtendByHour(1),
-		CreatedAt: currentTime,
-		UpdatedAt: currentTime,
-		User:      user,
+	id := uuid.New()
+	session := &Session{
+		ID:            id,
+		Token:         id.String(),
+		Remember:      remember,
+		CreatedAt:     currentTime,
+		UpdatedAt:     currentTime,
+		UserCheckedAt: currentTime,
+		User:          user,
 	}
+	session.ExpiresAt = session.nextExpiry(currentTime.ToTime(), policy)
+	return session
 }
 
 func (s *Session) Expired() bool {
 	return time.Now().After(s.ExpiresAt.ToTime())
 }
 
+// NeedsRenewal reports whether the session has been used past half of its
+// idle lifetime and can still be extended without crossing the absolute limit
+func (s *Session) NeedsRenewal(policy SessionPolicy) bool {
+	now := time.Now()
+	remaining := s.ExpiresAt.ToTime().Sub(now)
+	if remaining > s.lifetime(policy)/2 {
+		return false
+	}
+	return s.nextExpiry(now, policy).ToTime().After(s.ExpiresAt.ToTime())
+}
+
+// Renew slides the expiry forward by the idle timeout, capped by the absolute
+// timeout
+func (s *Session) Renew(policy SessionPolicy) error {
+	if s == nil {
+		return ErrSessionIsRequired
+	}
+	if s.Expired() {
+		return ErrSessionExpired
+	}
+	return s.SetExpiresAt(s.nextExpiry(time.Now(), policy))
+}
+
+// UserStale reports whether the snapshot of the user is due to be read again
+func (s *Session) UserStale(policy SessionPolicy) bool {
+	return time.Since(s.UserCheckedAt.ToTime()) >= policy.UserRefreshInterval
+}
+
+// RefreshUser replaces the snapshot of the user with the current user
+func (s *Session) RefreshUser(user *User) error {
+	if s == nil {
+		return ErrSessionIsRequired
+	}
+	if user == nil {
+		return ErrUserIsRequired
+	}
+	s.User = user
+	s.UserCheckedAt = valueobject.NewCurrentTime()
+	return nil
+}
+
+func (s *Session) lifetime(policy SessionPolicy) time.Duration {
+	return policy.idleTimeout(s.Remember)
+}
+
+func (s *Session) nextExpiry(from time.Time, policy SessionPolicy) valueobject.Time {
+	expiresAt := from.Add(s.lifetime(policy))
+	if policy.AbsoluteTimeout > 0 {
+		if limit := s.CreatedAt.ToTime().Add(policy.AbsoluteTimeout); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	return valueobject.NewTime(expiresAt)
+}
+
 func (s *Session) SetExpiresAt(expiresAt valueobject.Time) error {
 	if s == nil {
 		return ErrSessionIsRequired
@@ -45,6 +138,19 @@ func (s *Session) SetExpiresAt(expiresAt valueobject.Time) error {
 	return nil
 }
 
+func (s *Session) SetClient(userAgent, ipAddress string) error {
+	if s == nil {
+		return ErrSessionIsRequired
+	}
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	s.UserAgent = userAgent
+	s.IPAddress = ipAddress
+	s.UpdatedAt = valueobject.NewCurrentTime()
+	return nil
+}
+
 func (s *Session) AddUser(user *User) error {
 	if s.User != nil {
 		return ErrSessionIsRequired
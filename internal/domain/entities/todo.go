@@ -3,28 +3,36 @@ package entities
 import (
 	"errors"
 
+	"go-starter-template/internal/domain/domainerror"
 	"go-starter-template/internal/domain/valueobject"
 )
 
 var (
 	ErrTodoIsRequired  = errors.New("todo is required")
-	ErrTitleIsRequired = errors.New("title is required")
+	ErrTitleIsRequired = domainerror.Invalid("title", "title is required")
+	ErrOwnerIsRequired = errors.New("owner is required")
+	ErrTodoNotFound    = domainerror.NotFound("Todo not found")
 )
 
 type Todo struct {
 	ID          int
+	UserID      int
 	Title       string
 	Description string
 	CreatedAt   valueobject.Time
 	UpdatedAt   valueobject.Time
 }
 
-func NewTodo(title, description string) (*Todo, error) {
+func NewTodo(userID int, title, description string) (*Todo, error) {
+	if userID == 0 {
+		return nil, ErrOwnerIsRequired
+	}
 	if title == "" {
 		return nil, ErrTitleIsRequired
 	}
 	currentTime := valueobject.NewCurrentTime()
 	return &Todo{
+		UserID:      userID,
 		Title:       title,
 		Description: description,
 		CreatedAt:   currentTime,
@@ -32,8 +40,8 @@ func NewTodo(title, description string) (*Todo, error) {
 	}, nil
 }
 
-func NewTodoWithID(id int, title, description string) (*Todo, error) {
-	todo, err := NewTodo(title, description)
+func NewTodoWithID(id, userID int, title, description string) (*Todo, error) {
+	todo, err := NewTodo(userID, title, description)
 	if err != nil {
 		return nil, err
 	}
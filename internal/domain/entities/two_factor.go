@@ -0,0 +1,116 @@
+package entities
+
+import (
+	"time"
+
+	"go-starter-template/internal/domain/domainerror"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+var (
+	ErrTwoFactorCodeInvalid      = domainerror.Invalid("code", "Invalid authentication code")
+	ErrTwoFactorChallengeInvalid = domainerror.Unauthorized("Your sign in attempt has expired, please log in again")
+	ErrTwoFactorAlreadyEnabled   = domainerror.Conflict("Two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled       = domainerror.Conflict("Two-factor authentication is not enabled")
+)
+
+// TwoFactorChallengeMaxAttempts is how many codes may be tried against a
+// single challenge before the password has to be entered again
+const TwoFactorChallengeMaxAttempts = 5
+
+// TwoFactor holds the TOTP secret of a user. Secret is encrypted at rest, the
+// setup is only enforced once ConfirmedAt is set, which happens after the user
+// proved their authenticator produces valid codes.
+type TwoFactor struct {
+	UserID int
+	Secret string
+	// LastUsedStep is the last TOTP time step accepted, codes of that step or
+	// earlier are rejected so a code cannot be replayed
+	LastUsedStep int64
+	ConfirmedAt  *valueobject.Time
+	CreatedAt    valueobject.Time
+	UpdatedAt    valueobject.Time
+}
+
+func NewTwoFactor(userID int, encryptedSecret string) (*TwoFactor, error) {
+	if userID == 0 {
+		return nil, ErrOwnerIsRequired
+	}
+	currentTime := valueobject.NewCurrentTime()
+	return &TwoFactor{
+		UserID:    userID,
+		Secret:    encryptedSecret,
+		CreatedAt: currentTime,
+		UpdatedAt: currentTime,
+	}, nil
+}
+
+func (t *TwoFactor) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+func (t *TwoFactor) Confirm() {
+	confirmedAt := valueobject.NewCurrentTime()
+	t.ConfirmedAt = &confirmedAt
+	t.UpdatedAt = confirmedAt
+}
+
+// TwoFactorChallenge is the state between a correct password and a correct
+// second factor. The user gets a session only once it is completed.
+type TwoFactorChallenge struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	Remember  bool
+	Attempts  int
+	ExpiresAt valueobject.Time
+	CreatedAt valueobject.Time
+	User      *User
+}
+
+func NewTwoFactorChallenge(user *User, tokenHash string, remember bool, ttl time.Duration) (*TwoFactorChallenge, error) {
+	if user == nil {
+		return nil, ErrUserIsRequired
+	}
+	currentTime := valueobject.NewCurrentTime()
+	return &TwoFactorChallenge{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		Remember:  remember,
+		ExpiresAt: valueobject.NewTime(currentTime.ToTime().Add(ttl)),
+		CreatedAt: currentTime,
+		User:      user,
+	}, nil
+}
+
+func (c *TwoFactorChallenge) Expired() bool {
+	return time.Now().After(c.ExpiresAt.ToTime())
+}
+
+// TrustedDevice lets a browser skip the second factor until it expires
+type TrustedDevice struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	UserAgent string
+	ExpiresAt valueobject.Time
+	CreatedAt valueobject.Time
+}
+
+func NewTrustedDevice(userID int, tokenHash string, userAgent string, ttl time.Duration) (*TrustedDevice, error) {
+	if userID == 0 {
+		return nil, ErrOwnerIsRequired
+	}
+	currentTime := valueobject.NewCurrentTime()
+	return &TrustedDevice{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		UserAgent: userAgent,
+		ExpiresAt: valueobject.NewTime(currentTime.ToTime().Add(ttl)),
+		CreatedAt: currentTime,
+	}, nil
+}
+
+func (d *TrustedDevice) Expired() bool {
+	return time.Now().After(d.ExpiresAt.ToTime())
+}
@@ -3,30 +3,49 @@ package entities
 import (
 	"errors"
 
+	"github.com/google/uuid"
+
+	"go-starter-template/internal/domain/domainerror"
 	"go-starter-template/internal/domain/valueobject"
 )
 
 var (
 	ErrUserIsRequired    = errors.New("User is required")
-	ErrUserAlreadyExists = errors.New("User already exists")
+	ErrUserAlreadyExists = domainerror.Conflict("User already exists")
+	ErrUserNotFound      = domainerror.NotFound("User not found")
+	ErrEmailNotVerified  = domainerror.Forbidden("Please verify your email address before logging in")
+	ErrPasswordIncorrect = domainerror.Invalid("password", "Current password is incorrect")
+	ErrEmailUnchanged    = domainerror.Invalid("email", "This is already your email address")
+	// ErrPasswordHashIsRequired guards against storing a password unhashed
+	ErrPasswordHashIsRequired = errors.New("Password hash is required")
 )
 
 type User struct {
-	ID        int
-	Email     valueobject.Email
-	Password  valueobject.Password
-	CreatedAt valueobject.Time
-	UpdatedAt valueobject.Time
-	Sessions  []*Session
+	ID       int
+	Email    valueobject.Email
+	Password valueobject.Password
+	Role     Role
+	// VerifiedAt is nil until the user proved they own the email address
+	VerifiedAt *valueobject.Time
+	// DisabledAt is set while an admin has locked the account
+	DisabledAt *valueobject.Time
+	CreatedAt  valueobject.Time
+	UpdatedAt  valueobject.Time
+	Sessions   []*Session
+	// SessionsValidAfter signs out every session created before it but the
+	// one with KeptSessionID. Stateless session stores cannot delete
+	// sessions, this is how they are revoked.
+	SessionsValidAfter *valueobject.Time
+	KeptSessionID      uuid.UUID
 }
 
-func NewUser(email, password string) (*User, error) {
+func NewUser(email, password string, passwordPolicy valueobject.PasswordPolicy) (*User, error) {
 	emailValid, err := valueobject.NewEmail(email)
 	if err != nil {
 		return nil, err
 	}
 
-	passwordValid, err := valueobject.NewPassword(password)
+	passwordValid, err := valueobject.NewPassword(password, passwordPolicy)
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +55,7 @@ func NewUser(email, password string) (*User, error) {
 	return &User{
 		Email:     emailValid,
 		Password:  passwordValid,
+		Role:      RoleUser,
 		CreatedAt: currentTime,
 		UpdatedAt: currentTime,
 		Sessions:  []*Session{},
@@ -58,18 +78,155 @@ func (u *User) SetEmail(email string) error {
 	return nil
 }
 
-func (u *User) SetPassword(password string) error {
+// ChangeEmail sets a new email address the user still has to verify
+func (u *User) ChangeEmail(email string) error {
+	if u == nil {
+		return ErrUserIsRequired
+	}
+
+	emailValid, err := valueobject.NewEmail(email)
+	if err != nil {
+		return err
+	}
+	if emailValid.ToString() == u.Email.ToString() {
+		return ErrEmailUnchanged
+	}
+
+	u.Email = emailValid
+	u.VerifiedAt = nil
+	u.UpdatedAt = valueobject.NewCurrentTime()
+
+	return nil
+}
+
+// SetPassword stores the hash of a new password, the plain password is
+// checked against the policy with valueobject.NewPassword before hashing
+func (u *User) SetPassword(passwordHash string) error {
+	if u == nil {
+		return ErrUserIsRequired
+	}
+	if passwordHash == "" {
+		return ErrPasswordHashIsRequired
+	}
+
+	u.Password = valueobject.NewPasswordHash(passwordHash)
+	u.UpdatedAt = valueobject.NewCurrentTime()
+
+	return nil
+}
+
+func (u *User) Can(permission Permission) bool {
+	return u != nil && !u.Disabled() && u.Role.Can(permission)
+}
+
+func (u *User) SetRole(role string) error {
 	if u == nil {
 		return ErrUserIsRequired
 	}
 
-	pass, err := valueobject.NewPassword(password)
+	r, err := NewRole(role)
 	if err != nil {
 		return err
 	}
 
-	u.Password = pass
+	u.Role = r
+	u.UpdatedAt = valueobject.NewCurrentTime()
+
+	return nil
+}
+
+func (u *User) Disabled() bool {
+	return u != nil && u.DisabledAt != nil
+}
+
+func (u *User) Disable() error {
+	if u == nil {
+		return ErrUserIsRequired
+	}
+	if u.DisabledAt != nil {
+		return nil
+	}
+
+	disabledAt := valueobject.NewCurrentTime()
+	u.DisabledAt = &disabledAt
+	u.UpdatedAt = disabledAt
+
+	return nil
+}
+
+func (u *User) Enable() error {
+	if u == nil {
+		return ErrUserIsRequired
+	}
+
+	u.DisabledAt = nil
 	u.UpdatedAt = valueobject.NewCurrentTime()
 
 	return nil
 }
+
+// RevokeSessions signs the user out of every session created until now,
+// except keep which may be uuid.Nil
+func (u *User) RevokeSessions(keep uuid.UUID) error {
+	if u == nil {
+		return ErrUserIsRequired
+	}
+
+	validAfter := valueobject.NewCurrentTime()
+	u.SessionsValidAfter = &validAfter
+	u.KeptSessionID = keep
+
+	return nil
+}
+
+// SessionRevoked reports whether the session was signed out by RevokeSessions
+func (u *User) SessionRevoked(session *Session) bool {
+	if u == nil || u.SessionsValidAfter == nil || session == nil {
+		return false
+	}
+	if u.KeptSessionID != uuid.Nil && session.ID == u.KeptSessionID {
+		return false
+	}
+	return session.CreatedAt.ToTime().Before(u.SessionsValidAfter.ToTime())
+}
+
+func (u *User) Verified() bool {
+	return u != nil && u.VerifiedAt != nil
+}
+
+func (u *User) MarkVerified() error {
+	if u == nil {
+		return ErrUserIsRequired
+	}
+
+	verifiedAt := valueobject.NewCurrentTime()
+	u.VerifiedAt = &verifiedAt
+	u.UpdatedAt = verifiedAt
+
+	return nil
+}
+
+// NewExternalUser creates a user signing up through an identity provider.
+// passwordHash is the hash of a random secret nobody knows, so the account
+// has no usable password until one is set through a password reset.
+func NewExternalUser(email, passwordHash string) (*User, error) {
+	emailValid, err := valueobject.NewEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if passwordHash == "" {
+		return nil, ErrPasswordHashIsRequired
+	}
+
+	currentTime := valueobject.NewCurrentTime()
+
+	return &User{
+		Email:     emailValid,
+		Password:  valueobject.NewPasswordHash(passwordHash),
+		Role:      RoleUser,
+		CreatedAt: currentTime,
+		UpdatedAt: currentTime,
+		Sessions:  []*Session{},
+	}, nil
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type IAPITokenRepository interface {
+	Create(ctx context.Context, token *entities.APIToken) (*entities.APIToken, error)
+	List(ctx context.Context, userID int) ([]*entities.APIToken, error)
+	Get(ctx context.Context, userID int, id int) (*entities.APIToken, error)
+	GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.APIToken, error)
+	MarkUsed(ctx context.Context, token *entities.APIToken) error
+	Delete(ctx context.Context, token *entities.APIToken) error
+}
@@ -0,0 +1,15 @@
+package repositories
+
+import (
+	"context"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type IEmailVerificationTokenRepository interface {
+	Create(ctx context.Context, token *entities.EmailVerificationToken) (*entities.EmailVerificationToken, error)
+	GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.EmailVerificationToken, error)
+	GetLatestForUser(ctx context.Context, userID int) (*entities.EmailVerificationToken, error)
+	DeleteForUser(ctx context.Context, userID int) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type IIdentityRepository interface {
+	Create(ctx context.Context, identity *entities.Identity) (*entities.Identity, error)
+	GetByProviderSubjectWithUser(ctx context.Context, provider, subject string) (*entities.Identity, error)
+	ListByUser(ctx context.Context, userID int) ([]*entities.Identity, error)
+	// DeleteForUser returns ErrNoRows when the identity does not exist or
+	// belongs to someone else
+	DeleteForUser(ctx context.Context, userID, identityID int) error
+}
@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type ILoginThrottleRepository interface {
+	Get(ctx context.Context, scope, key string) (*entities.LoginThrottle, error)
+	// RecordFailure atomically counts a failed login at the given time,
+	// restarting the count when the previous failure is older than window
+	RecordFailure(ctx context.Context, scope, key string, at time.Time, window time.Duration) (*entities.LoginThrottle, error)
+	Delete(ctx context.Context, scope, key string) error
+	DeleteStale(ctx context.Context, before time.Time) (int64, error)
+	CreateLockout(ctx context.Context, lockout *entities.LoginLockout) (*entities.LoginLockout, error)
+}
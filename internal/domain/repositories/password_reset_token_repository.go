@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type IPasswordResetTokenRepository interface {
+	Create(ctx context.Context, token *entities.PasswordResetToken) (*entities.PasswordResetToken, error)
+	GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.PasswordResetToken, error)
+	// MarkUsed redeems the token, it returns ErrNoRows when the token was
+	// already used so concurrent redemptions cannot both succeed
+	MarkUsed(ctx context.Context, token *entities.PasswordResetToken) error
+	// DeleteForUser removes the outstanding tokens of a user
+	DeleteForUser(ctx context.Context, userID int) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
@@ -2,13 +2,39 @@ package repositories
 
 import (
 	"context"
+	"errors"
 
 	"go-starter-template/internal/domain/entities"
 )
 
+var (
+	ErrNotSupported = errors.New("operation not supported by the session store")
+)
+
+// ISessionRepository looks sessions up by the token the client presents.
+// Stateless stores cannot enumerate the sessions of a user and return
+// ErrNotSupported for those operations, the session service revokes all of
+// their sessions through entities.User.SessionsValidAfter instead.
 type ISessionRepository interface {
 	Create(ctx context.Context, session *entities.Session) (*entities.Session, error)
 	Get(ctx context.Context, sessionId string) (*entities.Session, error)
 	GetWithUser(ctx context.Context, sessionId string) (*entities.Session, error)
+	ListByUser(ctx context.Context, userID int) ([]*entities.Session, error)
+	Update(ctx context.Context, session *entities.Session) (*entities.Session, error)
 	Delete(ctx context.Context, session *entities.Session) error
+	DeleteForUser(ctx context.Context, userID int, sessionId string) error
+	// DeleteAllForUser removes every session of the user except the one with
+	// exceptSessionId, pass an empty string to remove all of them
+	DeleteAllForUser(ctx context.Context, userID int, exceptSessionId string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// ISnapshotSessionRepository is implemented by the stores keeping a copy of
+// the user taken at sign in instead of reading the user with the session.
+// The session service reads the user again once the copy is older than
+// SessionPolicy.UserRefreshInterval and saves the fresh copy with Update, so
+// deleted, disabled and signed out users are noticed within that interval.
+type ISnapshotSessionRepository interface {
+	ISessionRepository
+	SnapshotsUser() bool
 }
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ISessionRevocationRepository lists the signed out sessions of stateless
+// stores, which cannot delete the session the client holds. An entry is kept
+// until the session could not be presented anymore anyway.
+type ISessionRevocationRepository interface {
+	Revoke(ctx context.Context, sessionID uuid.UUID, userID int, until time.Time) error
+	Revoked(ctx context.Context, sessionID uuid.UUID, userID int) (bool, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
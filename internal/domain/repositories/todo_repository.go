@@ -7,8 +7,8 @@ import (
 )
 
 type ITodoRepository interface {
-	List(ctx context.Context) ([]*entities.Todo, error)
-	Get(ctx context.Context, id int) (*entities.Todo, error)
+	List(ctx context.Context, userID int) ([]*entities.Todo, error)
+	Get(ctx context.Context, userID int, id int) (*entities.Todo, error)
 	Create(ctx context.Context, todoDto *entities.Todo) (*entities.Todo, error)
 	Update(ctx context.Context, todoDto *entities.Todo) (*entities.Todo, error)
 	Delete(ctx context.Context, todo *entities.Todo) error
@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type ITrustedDeviceRepository interface {
+	Create(ctx context.Context, device *entities.TrustedDevice) (*entities.TrustedDevice, error)
+	GetByHash(ctx context.Context, userID int, tokenHash string) (*entities.TrustedDevice, error)
+	DeleteForUser(ctx context.Context, userID int) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
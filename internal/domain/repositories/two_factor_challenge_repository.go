@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type ITwoFactorChallengeRepository interface {
+	Create(ctx context.Context, challenge *entities.TwoFactorChallenge) (*entities.TwoFactorChallenge, error)
+	GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.TwoFactorChallenge, error)
+	// RecordAttempt counts a wrong code and returns the new number of attempts
+	RecordAttempt(ctx context.Context, challenge *entities.TwoFactorChallenge) (int, error)
+	// Delete returns ErrNoRows when the challenge is already gone, so only one
+	// of two concurrent completions succeeds
+	Delete(ctx context.Context, challenge *entities.TwoFactorChallenge) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"context"
+
+	"go-starter-template/internal/domain/entities"
+)
+
+type ITwoFactorRepository interface {
+	Get(ctx context.Context, userID int) (*entities.TwoFactor, error)
+	// Save stores a new unconfirmed secret for the user, replacing a previous
+	// unconfirmed one. Confirmed secrets are never overwritten, ErrNoRows is
+	// returned instead.
+	Save(ctx context.Context, twoFactor *entities.TwoFactor) (*entities.TwoFactor, error)
+	// Confirm enables the secret and stores the hashed recovery codes, dropping
+	// any previous ones, in a single transaction
+	Confirm(ctx context.Context, twoFactor *entities.TwoFactor, recoveryCodeHashes []string) error
+	// MarkStepUsed records the TOTP step of an accepted code, it returns
+	// ErrNoRows when that step or a later one was already used
+	MarkStepUsed(ctx context.Context, userID int, step int64) error
+	// Delete removes the secret together with the recovery codes
+	Delete(ctx context.Context, userID int) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error
+	// UseRecoveryCode redeems an unused code, it returns ErrNoRows when the
+	// code does not exist or was already used
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+}
@@ -2,16 +2,40 @@ package repositories
 
 import (
 	"context"
-	"errors"
 
+	"go-starter-template/internal/domain/domainerror"
 	"go-starter-template/internal/domain/entities"
 )
 
 var (
-	ErrNoRows = errors.New("no results found")
+	ErrNoRows = domainerror.NotFound("Not found")
+	// ErrDuplicate wraps the errors of writes that violate a unique constraint
+	ErrDuplicate = domainerror.Conflict("Already exists")
 )
 
 type IUserRepository interface {
 	Create(ctx context.Context, user *entities.User) (*entities.User, error)
+	Get(ctx context.Context, id int) (*entities.User, error)
 	GetByEmail(ctx context.Context, email string) (*entities.User, error)
+	// the updates below only write the fields they are named after, so two
+	// requests changing different fields of the same user do not undo each
+	// other. They return ErrNoRows when the user is gone.
+
+	// UpdatePassword stores the password only while the stored hash is still
+	// previousHash, it returns ErrNoRows when the password changed meanwhile
+	UpdatePassword(ctx context.Context, user *entities.User, previousHash string) error
+	// UpdateEmail stores the email address together with VerifiedAt, which a
+	// new address resets
+	UpdateEmail(ctx context.Context, user *entities.User) error
+	UpdateVerified(ctx context.Context, user *entities.User) error
+	UpdateRole(ctx context.Context, user *entities.User) error
+	UpdateDisabled(ctx context.Context, user *entities.User) error
+	// RevokeSessions stores SessionsValidAfter and KeptSessionID
+	RevokeSessions(ctx context.Context, user *entities.User) error
+	// Delete removes the user together with everything it owns
+	Delete(ctx context.Context, user *entities.User) error
+	// List and Count filter by a case insensitive part of the email address,
+	// an empty search matches every user
+	List(ctx context.Context, search string, limit, offset int) ([]*entities.User, error)
+	Count(ctx context.Context, search string) (int, error)
 }
@@ -1,13 +1,14 @@
 package valueobject
 
 import (
-	"errors"
 	"net/mail"
+
+	"go-starter-template/internal/domain/domainerror"
 )
 
 var (
-	ErrEmailIsRequired = errors.New("Email is required")
-	ErrEmailIsInvalid  = errors.New("Email is invalid")
+	ErrEmailIsRequired = domainerror.Invalid("email", "Email is required")
+	ErrEmailIsInvalid  = domainerror.Invalid("email", "Email is invalid")
 )
 
 type Email struct {
@@ -1,19 +1,37 @@
 package valueobject
 
-import "unicode"
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"go-starter-template/internal/domain/domainerror"
+)
+
+// Password holds either a plain password while it is being validated or the
+// hash stored for the user
 type Password struct {
 	value string
 }
 
-func NewPassword(password string) (Password, error) {
-	errs := IsStrongPassword(password)
+// NewPassword validates a plain password against the password policy
+func NewPassword(password string, policy PasswordPolicy) (Password, error) {
+	errs := policy.Validate(password)
 	if len(errs) > 0 {
 		return Password{}, &PasswordValidationError{Errors: errs}
 	}
 	return Password{value: password}, nil
 }
 
+// NewPasswordHash wraps a stored password hash, hashes are not checked
+// against the policy
+func NewPasswordHash(hash string) Password {
+	return Password{value: hash}
+}
+
 func (p Password) ToString() string {
 	return p.value
 }
@@ -23,13 +41,46 @@ type PasswordValidationError struct {
 }
 
 func (p *PasswordValidationError) Error() string {
-	return "Password must have minimum length of 8 characters and must contain at least 1 uppercase letter, 1 lowercase letter, 1 digit and 1 symbol"
+	return strings.Join(p.Errors, ", ")
+}
+
+func (p *PasswordValidationError) ErrorKind() domainerror.Kind {
+	return domainerror.KindValidation
+}
+
+func (p *PasswordValidationError) FieldErrors() map[string][]string {
+	return map[string][]string{"password": p.Errors}
+}
+
+// PasswordPolicy are the rules new passwords have to follow. MaxLength is
+// counted in bytes as that is where hashers like bcrypt draw the line, zero
+// means no limit.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Breached holds lower cased passwords known from data breaches, they
+	// are refused whatever their character classes
+	Breached map[string]struct{}
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     72,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
 }
 
-func IsStrongPassword(password string) []string {
+func (p PasswordPolicy) Validate(password string) []string {
 	var errs []string
 	var (
-		hasMinLen  = len(password) >= 8
 		hasUpper   = false
 		hasLower   = false
 		hasNumber  = false
@@ -51,20 +102,46 @@ func IsStrongPassword(password string) []string {
 		}
 	}
 
-	if !hasMinLen {
-		errs = append(errs, "Password must be at least 8 characters long")
+	if utf8.RuneCountInString(password) < p.MinLength {
+		errs = append(errs, fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		errs = append(errs, fmt.Sprintf("Password must be at most %d characters long", p.MaxLength))
 	}
-	if !hasUpper {
+	if p.RequireUpper && !hasUpper {
 		errs = append(errs, "Password must have at least 1 uppercase character")
 	}
-	if !hasLower {
+	if p.RequireLower && !hasLower {
 		errs = append(errs, "Password must have at least 1 lowercase character")
 	}
-	if !hasNumber {
+	if p.RequireDigit && !hasNumber {
 		errs = append(errs, "Password must have at least 1 digit")
 	}
-	if !hasSpecial {
+	if p.RequireSymbol && !hasSpecial {
 		errs = append(errs, "Password must have at least 1 special character or symbol")
 	}
+	if _, ok := p.Breached[strings.ToLower(password)]; ok {
+		errs = append(errs, "Password is too common, it appeared in a data breach")
+	}
 	return errs
 }
+
+// ReadBreachedPasswords reads a list with one password per line, as
+// published for known data breaches. Blank lines and lines starting with #
+// are skipped.
+func ReadBreachedPasswords(r io.Reader) (map[string]struct{}, error) {
+	breached := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached passwords: %w", err)
+	}
+	return breached, nil
+}
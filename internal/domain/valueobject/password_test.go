@@ -0,0 +1,78 @@
+package valueobject
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := DefaultPasswordPolicy()
+	strict.Breached = map[string]struct{}{"p@ssw0rd!": {}}
+	lenient := PasswordPolicy{MinLength: 12}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string
+	}{
+		{name: "strong", policy: strict, password: "Tr0ub4dor&3"},
+		{name: "empty", policy: strict, password: "", want: []string{
+			"Password must be at least 8 characters long",
+			"Password must have at least 1 uppercase character",
+			"Password must have at least 1 lowercase character",
+			"Password must have at least 1 digit",
+			"Password must have at least 1 special character or symbol",
+		}},
+		{name: "no uppercase", policy: strict, password: "tr0ub4dor&3", want: []string{"Password must have at least 1 uppercase character"}},
+		{name: "no lowercase", policy: strict, password: "TR0UB4DOR&3", want: []string{"Password must have at least 1 lowercase character"}},
+		{name: "no digit", policy: strict, password: "Troubador&x", want: []string{"Password must have at least 1 digit"}},
+		{name: "no symbol", policy: strict, password: "Tr0ub4dor33", want: []string{"Password must have at least 1 special character or symbol"}},
+		// length counts characters, the limit bytes
+		{name: "multibyte minimum", policy: strict, password: "Äö1!Äö1!"},
+		{name: "too long", policy: strict, password: "Aa1!" + strings.Repeat("x", 69), want: []string{"Password must be at most 72 characters long"}},
+		{name: "too long in bytes", policy: strict, password: "Aa1!" + strings.Repeat("ä", 35), want: []string{"Password must be at most 72 characters long"}},
+		{name: "breached in any case", policy: strict, password: "P@ssW0rd!", want: []string{"Password is too common, it appeared in a data breach"}},
+		{name: "lenient", policy: lenient, password: "correct horse battery"},
+		{name: "lenient too short", policy: lenient, password: "correct", want: []string{"Password must be at least 12 characters long"}},
+		{name: "no maximum", policy: lenient, password: strings.Repeat("x", 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Validate(tt.password); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPassword(t *testing.T) {
+	if _, err := NewPassword("Tr0ub4dor&3", DefaultPasswordPolicy()); err != nil {
+		t.Errorf("NewPassword() error = %v", err)
+	}
+
+	_, err := NewPassword("short", PasswordPolicy{MinLength: 8})
+	var validationErr *PasswordValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("NewPassword() error = %v, want a *PasswordValidationError", err)
+	}
+	if got := validationErr.FieldErrors()["password"]; len(got) != 1 {
+		t.Errorf("FieldErrors() = %v, want one problem for the password field", validationErr.FieldErrors())
+	}
+}
+
+func TestReadBreachedPasswords(t *testing.T) {
+	list := "# top passwords\n123456\n\n  Password1  \nqwerty\n"
+
+	got, err := ReadBreachedPasswords(strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("ReadBreachedPasswords() error = %v", err)
+	}
+	want := map[string]struct{}{"123456": {}, "password1": {}, "qwerty": {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadBreachedPasswords() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package httputil
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	identityFlowCookieName = "identity_flow"
+	// identityFlowTTL bounds how long the user may take at the provider
+	identityFlowTTL = 10 * time.Minute
+)
+
+// IdentityFlow is the state of a login at an external provider kept between
+// the redirect to the provider and the callback. Link is set when a logged in
+// user connects the provider to their account.
+type IdentityFlow struct {
+	Provider string
+	State    string
+	Nonce    string
+	Verifier string
+	Link     bool
+}
+
+// SetIdentityFlowCookie stores the flow for the callback. The cookie is Lax so
+// it is sent along with the top level redirect back from the provider.
+func SetIdentityFlowCookie(w http.ResponseWriter, flow *IdentityFlow, env string) {
+	secure := env != "development"
+	mode := "login"
+	if flow.Link {
+		mode = "link"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     identityFlowCookieName,
+		Value:    strings.Join([]string{flow.Provider, flow.State, flow.Nonce, flow.Verifier, mode}, "|"),
+		Path:     "/auth",
+		Expires:  time.Now().Add(identityFlowTTL),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func RemoveIdentityFlowCookie(w http.ResponseWriter, env string) {
+	secure := env != "development"
+	http.SetCookie(w, &http.Cookie{
+		Name:     identityFlowCookieName,
+		Value:    "",
+		Path:     "/auth",
+		Expires:  time.Now().Add(-24 * time.Hour),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// GetIdentityFlow returns nil when the cookie is missing or malformed
+func GetIdentityFlow(r *http.Request) *IdentityFlow {
+	cookie, err := r.Cookie(identityFlowCookieName)
+	if err != nil {
+		return nil
+	}
+
+	parts := strings.Split(cookie.Value, "|")
+	if len(parts) != 5 {
+		return nil
+	}
+
+	return &IdentityFlow{
+		Provider: parts[0],
+		State:    parts[1],
+		Nonce:    parts[2],
+		Verifier: parts[3],
+		Link:     parts[4] == "link",
+	}
+}
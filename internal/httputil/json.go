@@ -5,12 +5,51 @@ import (
 	"net/http"
 )
 
+const maxJSONBodyBytes = 1 << 20
+
 func WriteJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
 
+// WriteJSONError writes a problem document for errors found by the handler
+// itself, such as a malformed body. Errors returned by services go through
+// WriteErrorJSON.
+func WriteJSONError(w http.ResponseWriter, status int, message string) {
+	WriteProblem(w, NewProblem(status, message))
+}
+
 func ReadJSON(r *http.Request, data any) error {
-	return json.NewDecoder(r.Body).Decode(data)
+	r.Body = http.MaxBytesReader(nil, r.Body, maxJSONBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(data)
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusNotAcceptable:
+		return "not_acceptable"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusUnprocessableEntity:
+		return "validation_failed"
+	case http.StatusTooManyRequests:
+		return "too_many_requests"
+	default:
+		return "internal_error"
+	}
 }
@@ -0,0 +1,84 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-starter-template/internal/domain/domainerror"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+
+	// GenericErrorMessage is shown instead of the message of internal errors
+	GenericErrorMessage = "Something went wrong, please try again"
+)
+
+// Problem is a problem details document (RFC 9457). Code is a stable machine
+// readable name of the status and Errors lists validation problems per field.
+type Problem struct {
+	Type   string              `json:"type"`
+	Title  string              `json:"title"`
+	Status int                 `json:"status"`
+	Detail string              `json:"detail,omitempty"`
+	Code   string              `json:"code"`
+	Errors map[string][]string `json:"errors,omitempty"`
+}
+
+func NewProblem(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   errorCode(status),
+	}
+}
+
+// NewErrorProblem describes err without leaking the message of internal
+// errors
+func NewErrorProblem(err error) Problem {
+	problem := NewProblem(ErrorStatus(err), ErrorMessage(err))
+	problem.Errors = domainerror.Fields(err)
+	return problem
+}
+
+func WriteProblem(w http.ResponseWriter, problem Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// WriteErrorJSON writes the problem document matching the kind of err
+func WriteErrorJSON(w http.ResponseWriter, err error) {
+	WriteProblem(w, NewErrorProblem(err))
+}
+
+// ErrorStatus maps the kind of err to a status code, errors that are not
+// classified are internal server errors
+func ErrorStatus(err error) int {
+	switch domainerror.KindOf(err) {
+	case domainerror.KindValidation:
+		return http.StatusUnprocessableEntity
+	case domainerror.KindNotFound:
+		return http.StatusNotFound
+	case domainerror.KindConflict:
+		return http.StatusConflict
+	case domainerror.KindUnauthorized:
+		return http.StatusUnauthorized
+	case domainerror.KindForbidden:
+		return http.StatusForbidden
+	case domainerror.KindTooManyRequests:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ErrorMessage returns the message of err that is safe to show to users
+func ErrorMessage(err error) string {
+	if message := domainerror.Message(err); message != "" {
+		return message
+	}
+	return GenericErrorMessage
+}
@@ -15,7 +15,7 @@ func SetSessionCookie(w http.ResponseWriter, session *result.SessionResult, env
 	secure := env != "development"
 	http.SetCookie(w, &http.Cookie{
 		Name:     sessionCookieName,
-		Value:    session.ID.String(),
+		Value:    session.Token,
 		Path:     "/",
 		Expires:  session.ExpiresAt,
 		Secure:   secure,
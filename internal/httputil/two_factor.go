@@ -0,0 +1,72 @@
+package httputil
+
+import (
+	"net/http"
+	"time"
+
+	"go-starter-template/internal/application/result"
+)
+
+const (
+	twoFactorChallengeCookieName = "two_factor_challenge"
+	trustedDeviceCookieName      = "trusted_device"
+)
+
+// SetTwoFactorChallengeCookie keeps the pending login between the password
+// and the code form
+func SetTwoFactorChallengeCookie(w http.ResponseWriter, token string, expiresAt time.Time, env string) {
+	secure := env != "development"
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorChallengeCookieName,
+		Value:    token,
+		Path:     "/login",
+		Expires:  expiresAt,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func RemoveTwoFactorChallengeCookie(w http.ResponseWriter, env string) {
+	secure := env != "development"
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorChallengeCookieName,
+		Value:    "",
+		Path:     "/login",
+		Expires:  time.Now().Add(-24 * time.Hour),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func TwoFactorChallengeToken(r *http.Request) string {
+	cookie, err := r.Cookie(twoFactorChallengeCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// SetTrustedDeviceCookie remembers the browser so its next logins skip the
+// second factor. The cookie is only sent to the login form.
+func SetTrustedDeviceCookie(w http.ResponseWriter, device *result.TrustedDeviceResult, env string) {
+	secure := env != "development"
+	http.SetCookie(w, &http.Cookie{
+		Name:     trustedDeviceCookieName,
+		Value:    device.Token,
+		Path:     "/login",
+		Expires:  device.ExpiresAt,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func TrustedDeviceToken(r *http.Request) string {
+	cookie, err := r.Cookie(trustedDeviceCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
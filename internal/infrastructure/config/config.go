@@ -2,19 +2,92 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SessionStorePostgres = "postgres"
+	SessionStoreMemory   = "memory"
+	SessionStoreCookie   = "cookie"
+
+	MailerLog  = "log"
+	MailerFile = "file"
+
+	EmailVerificationOptional = "optional"
+	EmailVerificationRestrict = "restrict"
+	EmailVerificationBlock    = "block"
+
+	PasswordHasherBcrypt   = "bcrypt"
+	PasswordHasherArgon2id = "argon2id"
 
-	"github.com/joho/godotenv"
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+
+	EnvDevelopment = "development"
+
+	// bcryptMaxPasswordLength is where bcrypt stops looking at the password
+	bcryptMaxPasswordLength = 72
 )
 
 type (
 	Config struct {
-		Version        string
-		Env            string
-		Port           string
-		CSRFAuthKey    string
-		DatabaseConfig *DatabaseConfig
-		AllowedOrigins string
+		Version         string
+		Env             string
+		Port            int
+		AppURL          string
+		CSRFAuthKey     string
+		DatabaseConfig  *DatabaseConfig
+		SessionConfig   *SessionConfig
+		LoginConfig     *LoginConfig
+		AccountConfig   *AccountConfig
+		PasswordConfig  *PasswordConfig
+		MailConfig      *MailConfig
+		TwoFactorConfig *TwoFactorConfig
+		IdentityConfig  *IdentityConfig
+		LogConfig       *LogConfig
+		MetricsConfig   *MetricsConfig
+		TracingConfig   *TracingConfig
+		HealthConfig    *HealthConfig
+		AllowedOrigins  []string
+		settings        []Setting
+	}
+
+	LogConfig struct {
+		Level  slog.Level
+		Format string
+	}
+
+	MetricsConfig struct {
+		// Token protects /metrics with a bearer token, empty leaves it open
+		Token string
+	}
+
+	HealthConfig struct {
+		// CacheTTL is how long check results are reused between probes
+		CacheTTL     time.Duration
+		CheckTimeout time.Duration
+		// DrainDelay is how long readiness fails before the server stops
+		// accepting connections on shutdown
+		DrainDelay time.Duration
+	}
+
+	TracingConfig struct {
+		Exporter    string
+		ServiceName string
+		// SampleRatio is the share of new traces recorded, requests carrying
+		// a traceparent follow the decision of the caller
+		SampleRatio  float64
+		OTLPEndpoint string
+		OTLPHeaders  map[string]string
 	}
 
 	DatabaseConfig struct {
@@ -22,31 +95,503 @@ type (
 		Password string
 		Name     string
 		Host     string
-		Port     string
-		SslMode  string
+		Port     int
+		// SslMode is passed to lib/pq as sslmode, disable by default
+		SslMode string
+		// SslRootCert is the CA file verify-ca and verify-full check the
+		// server certificate against
+		SslRootCert string
+		// AutoMigrate applies pending migrations on startup, otherwise the
+		// app refuses to start until `migrate up` has been run
+		AutoMigrate     bool
+		MaxOpenConns    int
+		MaxIdleConns    int
+		ConnMaxLifetime time.Duration
+		ConnMaxIdleTime time.Duration
+		// ConnectTimeout limits a single attempt to open a connection
+		ConnectTimeout time.Duration
+		// WaitTimeout is how long startup waits for the database to accept
+		// connections, zero tries once
+		WaitTimeout time.Duration
+		// StatementTimeout is the deadline of a statement whose context has
+		// none or a later one, zero disables it
+		StatementTimeout time.Duration
+		// ReadRetries is how often a read marked as safe to repeat is
+		// retried on a new connection after a transient connection error
+		ReadRetries  int
+		RetryBackoff time.Duration
+	}
+
+	SessionConfig struct {
+		Store               string
+		CookieHashKey       string
+		CookieBlockKey      string
+		IdleTimeout         time.Duration
+		RememberIdleTimeout time.Duration
+		AbsoluteTimeout     time.Duration
+		CleanupInterval     time.Duration
+		// UserRefreshInterval is how long the memory and cookie stores trust
+		// the copy of the user taken at sign in
+		UserRefreshInterval time.Duration
+	}
+
+	LoginConfig struct {
+		MaxAccountFailures int
+		MaxIPFailures      int
+		FreeFailures       int
+		BackoffBase        time.Duration
+		BackoffMax         time.Duration
+		LockoutDuration    time.Duration
+		FailureWindow      time.Duration
+	}
+
+	AccountConfig struct {
+		PasswordResetTTL time.Duration
+		// EmailVerification is one of optional, restrict (unverified users can
+		// log in but not use the app) or block (unverified users cannot log in)
+		EmailVerification          string
+		EmailVerificationTTL       time.Duration
+		VerificationResendInterval time.Duration
+	}
+
+	PasswordConfig struct {
+		MinLength     int
+		MaxLength     int
+		RequireUpper  bool
+		RequireLower  bool
+		RequireDigit  bool
+		RequireSymbol bool
+		// BreachedListFile is a local file with one known breached password
+		// per line, empty disables the check
+		BreachedListFile string
+		// Hasher hashes new passwords, existing hashes of the other
+		// algorithm keep working and are replaced on the next login
+		Hasher            string
+		BcryptCost        int
+		Argon2Memory      int
+		Argon2Iterations  int
+		Argon2Parallelism int
+	}
+
+	MailConfig struct {
+		Driver  string
+		From    string
+		FileDir string
 	}
-)
 
-func NewConfig() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
-		return nil, fmt.Errorf("failed to load .env file %w", err)
+	TwoFactorConfig struct {
+		// EncryptionKey encrypts the TOTP secrets at rest, changing it makes
+		// every enrolled authenticator unusable
+		EncryptionKey    string
+		Issuer           string
+		ChallengeTTL     time.Duration
+		TrustedDeviceTTL time.Duration
 	}
 
+	IdentityConfig struct {
+		Providers []*OIDCProviderConfig
+	}
+
+	// OIDCProviderConfig is a client registration at an OpenID Connect
+	// provider. The redirect URL to register is APP_URL/auth/<name>/callback.
+	OIDCProviderConfig struct {
+		Name         string
+		DisplayName  string
+		Issuer       string
+		ClientID     string
+		ClientSecret string
+		Scopes       []string
+	}
+)
+
+// exampleTwoFactorEncryptionKey is the placeholder .example.env used to ship
+const exampleTwoFactorEncryptionKey = "change-me-to-32-random-bytes!!!!"
+
+// sslModes are the sslmode values lib/pq understands
+var sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+// NewConfig reads the settings from the layers of sources and validates them,
+// the error is a *ValidationError listing every problem found
+func NewConfig(sources Sources) (*Config, error) {
+	l := newLoader(sources)
+
 	config := &Config{
-		Version:        os.Getenv("VERSION"),
-		Env:            os.Getenv("ENV"),
-		Port:           os.Getenv("PORT"),
-		CSRFAuthKey:    os.Getenv("CSRF_AUTH_KEY"),
-		AllowedOrigins: os.Getenv("ALLOWED_ORIGINS"),
-		DatabaseConfig: &DatabaseConfig{
-			User:     os.Getenv("DB_USER"),
-			Password: os.Getenv("DB_PASSWORD"),
-			Name:     os.Getenv("DB_NAME"),
-			Host:     os.Getenv("DB_HOST"),
-			Port:     os.Getenv("DB_PORT"),
-			SslMode:  os.Getenv("DB_SSL_MODE"),
+		Version:         l.getString("VERSION", ""),
+		Env:             l.getString("ENV", "production"),
+		Port:            l.getInt("PORT", 8000),
+		CSRFAuthKey:     l.getSecret("CSRF_AUTH_KEY"),
+		AllowedOrigins:  l.getList("ALLOWED_ORIGINS", nil),
+		DatabaseConfig:  newDatabaseConfig(l),
+		SessionConfig:   newSessionConfig(l),
+		LoginConfig:     newLoginConfig(l),
+		AccountConfig:   newAccountConfig(l),
+		PasswordConfig:  newPasswordConfig(l),
+		MailConfig:      newMailConfig(l),
+		TwoFactorConfig: newTwoFactorConfig(l),
+		IdentityConfig:  newIdentityConfig(l),
+		LogConfig:       newLogConfig(l),
+		MetricsConfig: &MetricsConfig{
+			Token: l.getSecret("METRICS_TOKEN"),
 		},
+		TracingConfig: newTracingConfig(l),
+		HealthConfig:  newHealthConfig(l),
 	}
 
+	if config.Port < 1 || config.Port > 65535 {
+		l.invalid("PORT must be between 1 and 65535")
+	}
+
+	config.AppURL = strings.TrimSuffix(l.getString("APP_URL", fmt.Sprintf("http://localhost:%d", config.Port)), "/")
+	if u, err := url.Parse(config.AppURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		l.invalid("APP_URL must be an absolute http or https URL")
+	}
+
+	// gorilla/csrf signs its tokens with the key, short keys can be guessed
+	switch {
+	case config.CSRFAuthKey == "":
+		l.invalid("CSRF_AUTH_KEY is required")
+	case len(config.CSRFAuthKey) < 32 && config.Env != EnvDevelopment:
+		l.invalid("CSRF_AUTH_KEY must be at least 32 bytes long outside of development")
+	}
+	// older copies of .example.env shipped a key, it is public so every TOTP
+	// secret encrypted with it could be read
+	if config.TwoFactorConfig.EncryptionKey == exampleTwoFactorEncryptionKey && config.Env != EnvDevelopment {
+		l.invalid("TWO_FACTOR_ENCRYPTION_KEY must not be the example key outside of development")
+	}
+
+	l.unknown()
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+
+	config.settings = l.sortedSettings()
 	return config, nil
 }
+
+// Settings lists the settings the configuration was built from with their
+// source, secrets are redacted so the list is safe to print
+func (c *Config) Settings() []Setting {
+	return c.settings
+}
+
+func newDatabaseConfig(l *loader) *DatabaseConfig {
+	conf := &DatabaseConfig{
+		User:        l.getString("DB_USER", ""),
+		Password:    l.getSecret("DB_PASSWORD"),
+		Name:        l.getString("DB_NAME", ""),
+		Host:        l.getString("DB_HOST", "localhost"),
+		Port:        l.getInt("DB_PORT", 5432),
+		SslMode:     l.getString("DB_SSL_MODE", "disable"),
+		SslRootCert: l.getString("DB_SSL_ROOT_CERT", ""),
+		AutoMigrate: l.getBool("DB_AUTO_MIGRATE", false),
+
+		MaxOpenConns:     l.getInt("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:     l.getInt("DB_MAX_IDLE_CONNS", 10),
+		ConnMaxLifetime:  l.getDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		ConnMaxIdleTime:  l.getDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		ConnectTimeout:   l.getDuration("DB_CONNECT_TIMEOUT", 5*time.Second),
+		WaitTimeout:      l.getDuration("DB_WAIT_TIMEOUT", 30*time.Second),
+		StatementTimeout: l.getDuration("DB_STATEMENT_TIMEOUT", 10*time.Second),
+		ReadRetries:      l.getInt("DB_READ_RETRIES", 2),
+		RetryBackoff:     l.getDuration("DB_RETRY_BACKOFF", 100*time.Millisecond),
+	}
+
+	if conf.User == "" || conf.Name == "" {
+		l.invalid("DB_USER and DB_NAME are required")
+	}
+	if conf.Port < 1 || conf.Port > 65535 {
+		l.invalid("DB_PORT must be between 1 and 65535")
+	}
+	if !slices.Contains(sslModes, conf.SslMode) {
+		l.invalid("unknown DB_SSL_MODE %q, expected %s", conf.SslMode, strings.Join(sslModes, ", "))
+	}
+	if conf.MaxOpenConns < 1 || conf.MaxIdleConns < 0 || conf.MaxIdleConns > conf.MaxOpenConns {
+		l.invalid("DB_MAX_OPEN_CONNS must be positive and DB_MAX_IDLE_CONNS between 0 and DB_MAX_OPEN_CONNS")
+	}
+	if conf.ConnMaxLifetime < 0 || conf.ConnMaxIdleTime < 0 || conf.WaitTimeout < 0 || conf.StatementTimeout < 0 {
+		l.invalid("DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME, DB_WAIT_TIMEOUT and DB_STATEMENT_TIMEOUT must not be negative")
+	}
+	// lib/pq takes whole seconds
+	if conf.ConnectTimeout < time.Second {
+		l.invalid("DB_CONNECT_TIMEOUT must be at least 1s")
+	}
+	if conf.ReadRetries < 0 || conf.RetryBackoff <= 0 {
+		l.invalid("DB_READ_RETRIES must not be negative and DB_RETRY_BACKOFF must be positive")
+	}
+	if conf.SslRootCert != "" {
+		if _, err := os.Stat(conf.SslRootCert); err != nil {
+			l.invalid("DB_SSL_ROOT_CERT: %v", err)
+		}
+	}
+
+	return conf
+}
+
+func newSessionConfig(l *loader) *SessionConfig {
+	conf := &SessionConfig{
+		Store:               l.getString("SESSION_STORE", SessionStorePostgres),
+		CookieHashKey:       l.getSecret("SESSION_COOKIE_HASH_KEY"),
+		CookieBlockKey:      l.getSecret("SESSION_COOKIE_BLOCK_KEY"),
+		IdleTimeout:         l.getDuration("SESSION_IDLE_TIMEOUT", time.Hour),
+		RememberIdleTimeout: l.getDuration("SESSION_REMEMBER_IDLE_TIMEOUT", 30*24*time.Hour),
+		AbsoluteTimeout:     l.getDuration("SESSION_ABSOLUTE_TIMEOUT", 90*24*time.Hour),
+		CleanupInterval:     l.getDuration("SESSION_CLEANUP_INTERVAL", 10*time.Minute),
+		UserRefreshInterval: l.getDuration("SESSION_USER_REFRESH_INTERVAL", 5*time.Minute),
+	}
+
+	if conf.IdleTimeout <= 0 || conf.RememberIdleTimeout <= 0 || conf.CleanupInterval <= 0 {
+		l.invalid("session timeouts and cleanup interval must be positive")
+	}
+	if conf.UserRefreshInterval < 0 {
+		l.invalid("SESSION_USER_REFRESH_INTERVAL must not be negative")
+	}
+
+	switch conf.Store {
+	case SessionStorePostgres, SessionStoreMemory:
+	case SessionStoreCookie:
+		if n := len(conf.CookieHashKey); n != 32 && n != 64 {
+			l.invalid("SESSION_COOKIE_HASH_KEY must be 32 or 64 bytes long")
+		}
+		if n := len(conf.CookieBlockKey); n != 16 && n != 24 && n != 32 {
+			l.invalid("SESSION_COOKIE_BLOCK_KEY must be 16, 24 or 32 bytes long")
+		}
+	default:
+		l.invalid("unknown SESSION_STORE %q, expected postgres, memory or cookie", conf.Store)
+	}
+
+	return conf
+}
+
+func newLoginConfig(l *loader) *LoginConfig {
+	conf := &LoginConfig{
+		MaxAccountFailures: l.getInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		MaxIPFailures:      l.getInt("LOGIN_MAX_IP_FAILURES", 50),
+		FreeFailures:       l.getInt("LOGIN_FREE_FAILURES", 3),
+		BackoffBase:        l.getDuration("LOGIN_BACKOFF_BASE", time.Second),
+		BackoffMax:         l.getDuration("LOGIN_BACKOFF_MAX", 30*time.Second),
+		LockoutDuration:    l.getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		FailureWindow:      l.getDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+	}
+
+	if conf.MaxAccountFailures <= 0 || conf.MaxIPFailures <= 0 {
+		l.invalid("login failure limits must be positive")
+	}
+	if conf.FreeFailures < 0 || conf.FreeFailures >= conf.MaxAccountFailures {
+		l.invalid("LOGIN_FREE_FAILURES must be between 0 and LOGIN_MAX_ACCOUNT_FAILURES - 1")
+	}
+	if conf.BackoffBase < 0 || conf.BackoffMax < conf.BackoffBase || conf.LockoutDuration <= 0 || conf.FailureWindow <= 0 {
+		l.invalid("login backoff, lockout and window durations must be positive")
+	}
+
+	return conf
+}
+
+func newAccountConfig(l *loader) *AccountConfig {
+	conf := &AccountConfig{
+		PasswordResetTTL:           l.getDuration("PASSWORD_RESET_TTL", time.Hour),
+		EmailVerification:          l.getString("EMAIL_VERIFICATION", EmailVerificationRestrict),
+		EmailVerificationTTL:       l.getDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		VerificationResendInterval: l.getDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute),
+	}
+
+	if conf.PasswordResetTTL <= 0 || conf.EmailVerificationTTL <= 0 || conf.VerificationResendInterval < 0 {
+		l.invalid("password reset and email verification durations must be positive")
+	}
+
+	switch conf.EmailVerification {
+	case EmailVerificationOptional, EmailVerificationRestrict, EmailVerificationBlock:
+	default:
+		l.invalid("unknown EMAIL_VERIFICATION %q, expected optional, restrict or block", conf.EmailVerification)
+	}
+
+	return conf
+}
+
+func newPasswordConfig(l *loader) *PasswordConfig {
+	conf := &PasswordConfig{
+		MinLength:         l.getInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:         l.getInt("PASSWORD_MAX_LENGTH", bcryptMaxPasswordLength),
+		RequireUpper:      l.getBool("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:      l.getBool("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:      l.getBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol:     l.getBool("PASSWORD_REQUIRE_SYMBOL", true),
+		BreachedListFile:  l.getString("PASSWORD_BREACHED_LIST_FILE", ""),
+		Hasher:            l.getString("PASSWORD_HASHER", PasswordHasherBcrypt),
+		BcryptCost:        l.getInt("PASSWORD_BCRYPT_COST", 10),
+		Argon2Memory:      l.getInt("PASSWORD_ARGON2_MEMORY", 64*1024),
+		Argon2Iterations:  l.getInt("PASSWORD_ARGON2_ITERATIONS", 3),
+		Argon2Parallelism: l.getInt("PASSWORD_ARGON2_PARALLELISM", 4),
+	}
+
+	if conf.MinLength < 1 || conf.MaxLength < 0 || (conf.MaxLength > 0 && conf.MaxLength < conf.MinLength) {
+		l.invalid("PASSWORD_MIN_LENGTH must be positive and PASSWORD_MAX_LENGTH zero or at least the minimum")
+	}
+
+	switch conf.Hasher {
+	case PasswordHasherBcrypt:
+		if conf.BcryptCost < 4 || conf.BcryptCost > 31 {
+			l.invalid("PASSWORD_BCRYPT_COST must be between 4 and 31")
+		}
+		if conf.MaxLength == 0 || conf.MaxLength > bcryptMaxPasswordLength {
+			l.invalid("PASSWORD_MAX_LENGTH must be between 1 and %d with bcrypt, longer passwords are truncated", bcryptMaxPasswordLength)
+		}
+	case PasswordHasherArgon2id:
+		if conf.Argon2Memory < 8*conf.Argon2Parallelism || conf.Argon2Iterations < 1 || conf.Argon2Parallelism < 1 || conf.Argon2Parallelism > 255 {
+			l.invalid("PASSWORD_ARGON2_* must be positive, with parallelism up to 255 and at least 8 KiB of memory per thread")
+		}
+	default:
+		l.invalid("unknown PASSWORD_HASHER %q, expected bcrypt or argon2id", conf.Hasher)
+	}
+
+	return conf
+}
+
+func newMailConfig(l *loader) *MailConfig {
+	conf := &MailConfig{
+		Driver:  l.getString("MAILER", MailerLog),
+		From:    l.getString("MAIL_FROM", "no-reply@localhost"),
+		FileDir: l.getString("MAIL_FILE_DIR", "tmp/mail"),
+	}
+
+	if conf.Driver != MailerLog && conf.Driver != MailerFile {
+		l.invalid("unknown MAILER %q, expected log or file", conf.Driver)
+	}
+
+	return conf
+}
+
+func newLogConfig(l *loader) *LogConfig {
+	conf := &LogConfig{
+		Format: l.getString("LOG_FORMAT", LogFormatText),
+	}
+
+	if err := conf.Level.UnmarshalText([]byte(l.getString("LOG_LEVEL", "info"))); err != nil {
+		l.invalid("unknown LOG_LEVEL, expected debug, info, warn or error: %v", err)
+	}
+
+	if conf.Format != LogFormatText && conf.Format != LogFormatJSON {
+		l.invalid("unknown LOG_FORMAT %q, expected text or json", conf.Format)
+	}
+
+	return conf
+}
+
+func newTracingConfig(l *loader) *TracingConfig {
+	conf := &TracingConfig{
+		Exporter:     l.getString("TRACING_EXPORTER", TracingExporterNone),
+		ServiceName:  l.getString("TRACING_SERVICE_NAME", "go-starter-template"),
+		SampleRatio:  l.getFloat("TRACING_SAMPLE_RATIO", 1),
+		OTLPEndpoint: l.getString("TRACING_OTLP_ENDPOINT", "http://localhost:4318/v1/traces"),
+		OTLPHeaders:  make(map[string]string),
+	}
+
+	if conf.SampleRatio < 0 || conf.SampleRatio > 1 {
+		l.invalid("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
+	// TRACING_OTLP_HEADERS is a comma separated list of key=value pairs, they
+	// usually carry credentials
+	for _, pair := range strings.Split(l.getSecret("TRACING_OTLP_HEADERS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			l.invalid("invalid TRACING_OTLP_HEADERS entry, expected key=value")
+			continue
+		}
+		conf.OTLPHeaders[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	switch conf.Exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOTLP:
+		if conf.OTLPEndpoint == "" {
+			l.invalid("TRACING_OTLP_ENDPOINT is required with the otlp exporter")
+		}
+	default:
+		l.invalid("unknown TRACING_EXPORTER %q, expected none, stdout or otlp", conf.Exporter)
+	}
+
+	return conf
+}
+
+func newHealthConfig(l *loader) *HealthConfig {
+	conf := &HealthConfig{
+		CacheTTL:     l.getDuration("HEALTH_CACHE_TTL", 5*time.Second),
+		CheckTimeout: l.getDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		DrainDelay:   l.getDuration("HEALTH_DRAIN_DELAY", 0),
+	}
+
+	if conf.CacheTTL < 0 || conf.CheckTimeout <= 0 || conf.DrainDelay < 0 {
+		l.invalid("health check timeout must be positive, cache ttl and drain delay must not be negative")
+	}
+
+	return conf
+}
+
+func newTwoFactorConfig(l *loader) *TwoFactorConfig {
+	conf := &TwoFactorConfig{
+		EncryptionKey:    l.getSecret("TWO_FACTOR_ENCRYPTION_KEY"),
+		Issuer:           l.getString("TWO_FACTOR_ISSUER", "Go Starter Template"),
+		ChallengeTTL:     l.getDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute),
+		TrustedDeviceTTL: l.getDuration("TWO_FACTOR_TRUSTED_DEVICE_TTL", 30*24*time.Hour),
+	}
+
+	if n := len(conf.EncryptionKey); n != 16 && n != 24 && n != 32 {
+		l.invalid("TWO_FACTOR_ENCRYPTION_KEY must be 16, 24 or 32 bytes long")
+	}
+	if conf.ChallengeTTL <= 0 || conf.TrustedDeviceTTL <= 0 {
+		l.invalid("two factor challenge and trusted device durations must be positive")
+	}
+
+	return conf
+}
+
+// newIdentityConfig reads OIDC_PROVIDERS, a list of provider names, and the
+// OIDC_<NAME>_* settings of each of them
+func newIdentityConfig(l *loader) *IdentityConfig {
+	conf := &IdentityConfig{
+		Providers: make([]*OIDCProviderConfig, 0),
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range l.getList("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(name)
+		if !isProviderName(name) {
+			l.invalid("invalid provider name %q in OIDC_PROVIDERS, use letters, digits and dashes", name)
+			continue
+		}
+		if seen[name] {
+			l.invalid("provider %q is listed twice in OIDC_PROVIDERS", name)
+			continue
+		}
+		seen[name] = true
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := &OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  l.getString(prefix+"DISPLAY_NAME", name),
+			Issuer:       l.getString(prefix+"ISSUER", ""),
+			ClientID:     l.getString(prefix+"CLIENT_ID", ""),
+			ClientSecret: l.getSecret(prefix + "CLIENT_SECRET"),
+			Scopes:       l.getList(prefix+"SCOPES", nil),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			l.invalid("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+		}
+
+		conf.Providers = append(conf.Providers, provider)
+	}
+
+	return conf
+}
+
+func isProviderName(name string) bool {
+	for _, char := range name {
+		if (char < 'a' || char > 'z') && (char < '0' || char > '9') && char != '-' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile flattens a YAML or TOML file into the names of the
+// environment variables: nested keys are joined with underscores and upper
+// cased, so db.ssl_mode sets DB_SSL_MODE. Lists are joined with commas.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		_, err = toml.Decode(string(data), &tree)
+	default:
+		return nil, fmt.Errorf("unknown config file format %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(values, "", tree); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(values map[string]string, prefix string, tree map[string]any) error {
+	for key, value := range tree {
+		name := settingName(key)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		if table, ok := value.(map[string]any); ok {
+			if err := flatten(values, name, table); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var flat string
+		if list, ok := value.([]any); ok {
+			items := make([]string, 0, len(list))
+			for _, item := range list {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				items = append(items, s)
+			}
+			flat = strings.Join(items, ",")
+		} else {
+			s, err := scalar(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			flat = s
+		}
+
+		// db.port and db_port end up as the same setting
+		if _, exists := values[name]; exists {
+			return fmt.Errorf("%s is set twice", name)
+		}
+		values[name] = flat
+	}
+	return nil
+}
+
+// scalar formats a value the way it would be written in the environment
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		// the local dates and times of TOML
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("expected a value or a list of values, got %T", value)
+	}
+}
+
+func settingName(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
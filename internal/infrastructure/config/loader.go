@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	SourceDefault = "default"
+	SourceEnvFile = "env file"
+	SourceFile    = "config file"
+	SourceEnv     = "environment"
+	SourceFlag    = "flag"
+
+	redacted = "[redacted]"
+)
+
+// Sources name where settings come from besides the environment. From the
+// lowest to the highest precedence they are the defaults, the .env file, the
+// config file, the environment and the flags.
+type Sources struct {
+	// EnvFile is an optional dotenv file, .env when empty
+	EnvFile string
+	// File is an optional YAML or TOML file, CONFIG_FILE when empty
+	File string
+	// Flags are the KEY=VALUE settings given on the command line
+	Flags map[string]string
+}
+
+// Setting is a value the configuration was built from, secrets are redacted
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// ValidationError lists every problem of the configuration, so they can be
+// fixed in one go instead of one deploy at a time
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+type layer struct {
+	source string
+	values map[string]string
+	// strict layers only hold settings of the app, so keys that are never
+	// read are typos
+	strict bool
+}
+
+// loader reads settings through the layers and collects the problems instead
+// of stopping at the first one
+type loader struct {
+	layers   []layer
+	used     map[string]bool
+	settings map[string]Setting
+	problems []string
+}
+
+func newLoader(sources Sources) *loader {
+	l := &loader{
+		used:     make(map[string]bool),
+		settings: make(map[string]Setting),
+	}
+	l.layers = append(l.layers, layer{source: SourceDefault, values: map[string]string{}})
+
+	envFile := sources.EnvFile
+	if envFile == "" {
+		envFile = ".env"
+	}
+	values, err := godotenv.Read(envFile)
+	switch {
+	case err == nil:
+		l.layers = append(l.layers, layer{source: SourceEnvFile, values: values})
+	case errors.Is(err, os.ErrNotExist) && sources.EnvFile == "":
+		// the default .env is optional, production usually has none
+	default:
+		l.invalid("failed to read env file %s: %v", envFile, err)
+	}
+
+	env := environ()
+	file := sources.File
+	if file == "" {
+		file = env["CONFIG_FILE"]
+	}
+	if file == "" && len(l.layers) > 1 {
+		file = l.layers[1].values["CONFIG_FILE"]
+	}
+	if file != "" {
+		values, err := readConfigFile(file)
+		if err != nil {
+			l.invalid("%v", err)
+		} else {
+			l.layers = append(l.layers, layer{source: SourceFile, values: values, strict: true})
+		}
+	}
+
+	l.layers = append(l.layers, layer{source: SourceEnv, values: env})
+	if len(sources.Flags) > 0 {
+		l.layers = append(l.layers, layer{source: SourceFlag, values: sources.Flags, strict: true})
+	}
+
+	return l
+}
+
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, pair := range os.Environ() {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// lookup returns the value of the layer with the highest precedence. Empty
+// values count as unset, and KEY_FILE reads the value from a file so secrets
+// can come from mounted files instead of the environment.
+func (l *loader) lookup(key string) (value, source string, ok bool) {
+	l.used[key] = true
+	l.used[key+"_FILE"] = true
+
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		layer := l.layers[i]
+		value, file := layer.values[key], layer.values[key+"_FILE"]
+		switch {
+		case value != "" && file != "":
+			l.invalid("%s and %s_FILE are both set in the %s, set only one of them", key, key, layer.source)
+			return "", "", false
+		case value != "":
+			return value, layer.source, true
+		case file != "":
+			data, err := os.ReadFile(file)
+			if err != nil {
+				l.invalid("failed to read %s_FILE: %v", key, err)
+				return "", "", false
+			}
+			return strings.TrimRight(string(data), "\r\n"), layer.source + " " + file, true
+		}
+	}
+	return "", "", false
+}
+
+func (l *loader) get(key string, fallback string, secret bool) string {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		value, source = fallback, SourceDefault
+	}
+
+	shown := value
+	if secret && value != "" {
+		shown = redacted
+	}
+	l.settings[key] = Setting{Key: key, Value: shown, Source: source}
+
+	return value
+}
+
+// invalid records a problem, loading carries on so every problem is reported
+func (l *loader) invalid(format string, args ...any) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// unknown reports the settings of the config file and the flags nothing read
+func (l *loader) unknown() {
+	for _, layer := range l.layers {
+		if !layer.strict {
+			continue
+		}
+		keys := make([]string, 0)
+		for key := range layer.values {
+			if !l.used[key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			l.invalid("unknown setting %s, set by %s", key, layer.source)
+		}
+	}
+}
+
+func (l *loader) err() error {
+	if len(l.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: l.problems}
+}
+
+func (l *loader) sortedSettings() []Setting {
+	settings := make([]Setting, 0, len(l.settings))
+	for _, setting := range l.settings {
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+func (l *loader) getString(key string, fallback string) string {
+	return l.get(key, fallback, false)
+}
+
+// getSecret is getString for values that must never be printed
+func (l *loader) getSecret(key string) string {
+	return l.get(key, "", true)
+}
+
+func (l *loader) getInt(key string, fallback int) int {
+	value := l.get(key, strconv.Itoa(fallback), false)
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		l.invalid("invalid integer for %s: %q", key, value)
+		return fallback
+	}
+	return i
+}
+
+func (l *loader) getBool(key string, fallback bool) bool {
+	value := l.get(key, strconv.FormatBool(fallback), false)
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.invalid("invalid boolean for %s: %q", key, value)
+		return fallback
+	}
+	return b
+}
+
+func (l *loader) getFloat(key string, fallback float64) float64 {
+	value := l.get(key, strconv.FormatFloat(fallback, 'g', -1, 64), false)
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.invalid("invalid number for %s: %q", key, value)
+		return fallback
+	}
+	return f
+}
+
+func (l *loader) getDuration(key string, fallback time.Duration) time.Duration {
+	value := l.get(key, fallback.String(), false)
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.invalid("invalid duration for %s: %q", key, value)
+		return fallback
+	}
+	return d
+}
+
+// getList splits on commas and whitespace, lists from the config file are
+// joined with commas
+func (l *loader) getList(key string, fallback []string) []string {
+	value := l.get(key, strings.Join(fallback, ","), false)
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile creates a file in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// noEnvFile points the loader at an empty env file in a temporary directory,
+// the default .env of the working directory must not leak into the tests
+func noEnvFile(t *testing.T) string {
+	return writeFile(t, ".env", "")
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		envFile    bool
+		file       bool
+		env        bool
+		flag       bool
+		wantValue  string
+		wantSource string
+	}{
+		{name: "default", wantValue: "default", wantSource: SourceDefault},
+		{name: "env file over default", envFile: true, wantValue: "env file", wantSource: SourceEnvFile},
+		{name: "config file over env file", envFile: true, file: true, wantValue: "config file", wantSource: SourceFile},
+		{name: "environment over config file", envFile: true, file: true, env: true, wantValue: "environment", wantSource: SourceEnv},
+		{name: "flag over everything", envFile: true, file: true, env: true, flag: true, wantValue: "flag", wantSource: SourceFlag},
+		{name: "flag over default", flag: true, wantValue: "flag", wantSource: SourceFlag},
+		{name: "environment over env file", envFile: true, env: true, wantValue: "environment", wantSource: SourceEnv},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := Sources{EnvFile: noEnvFile(t)}
+			if tt.envFile {
+				sources.EnvFile = writeFile(t, ".env", "TEST_SETTING=\"env file\"\n")
+			}
+			if tt.file {
+				sources.File = writeFile(t, "config.yaml", "test:\n  setting: config file\n")
+			}
+			if tt.env {
+				t.Setenv("TEST_SETTING", "environment")
+			}
+			if tt.flag {
+				sources.Flags = map[string]string{"TEST_SETTING": "flag"}
+			}
+
+			l := newLoader(sources)
+			if got := l.getString("TEST_SETTING", "default"); got != tt.wantValue {
+				t.Errorf("value = %q, want %q", got, tt.wantValue)
+			}
+			if got := l.settings["TEST_SETTING"].Source; got != tt.wantSource {
+				t.Errorf("source = %q, want %q", got, tt.wantSource)
+			}
+			l.unknown()
+			if err := l.err(); err != nil {
+				t.Errorf("problems: %v", err)
+			}
+		})
+	}
+}
+
+func TestValueFiles(t *testing.T) {
+	secret := writeFile(t, "secret", "from file\n")
+
+	tests := []struct {
+		name        string
+		envFile     string
+		flags       map[string]string
+		wantValue   string
+		wantProblem string
+	}{
+		{name: "KEY_FILE", flags: map[string]string{"TEST_SECRET_FILE": secret}, wantValue: "from file"},
+		{name: "both in one layer", flags: map[string]string{"TEST_SECRET": "plain", "TEST_SECRET_FILE": secret}, wantProblem: "TEST_SECRET and TEST_SECRET_FILE are both set in the flag"},
+		{name: "both in the env file", envFile: "TEST_SECRET=plain\nTEST_SECRET_FILE=" + secret + "\n", wantProblem: "both set in the env file"},
+		{name: "KEY_FILE over a lower KEY", envFile: "TEST_SECRET=plain\n", flags: map[string]string{"TEST_SECRET_FILE": secret}, wantValue: "from file"},
+		{name: "KEY over a lower KEY_FILE", envFile: "TEST_SECRET_FILE=" + secret + "\n", flags: map[string]string{"TEST_SECRET": "plain"}, wantValue: "plain"},
+		{name: "missing file", flags: map[string]string{"TEST_SECRET_FILE": filepath.Join(t.TempDir(), "missing")}, wantProblem: "failed to read TEST_SECRET_FILE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := Sources{EnvFile: noEnvFile(t), Flags: tt.flags}
+			if tt.envFile != "" {
+				sources.EnvFile = writeFile(t, ".env", tt.envFile)
+			}
+
+			l := newLoader(sources)
+			got := l.getSecret("TEST_SECRET")
+			err := l.err()
+
+			if tt.wantProblem != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantProblem) {
+					t.Fatalf("error = %v, want a problem containing %q", err, tt.wantProblem)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if got != tt.wantValue {
+				t.Errorf("value = %q, want %q", got, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestUnknownSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		flags       map[string]string
+		env         map[string]string
+		wantProblem string
+	}{
+		{name: "config file", file: "test_setting: a\ntest_stting: b\n", wantProblem: "unknown setting TEST_STTING, set by config file"},
+		{name: "flag", flags: map[string]string{"TEST_SETTING": "a", "TEST_STTING": "b"}, wantProblem: "unknown setting TEST_STTING, set by flag"},
+		{name: "KEY_FILE counts as read", flags: map[string]string{"TEST_SETTING_FILE": writeFile(t, "value", "a")}},
+		// the environment holds everything else running on the machine
+		{name: "environment is not checked", env: map[string]string{"TEST_STTING": "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := Sources{EnvFile: noEnvFile(t), Flags: tt.flags}
+			if tt.file != "" {
+				sources.File = writeFile(t, "config.yaml", tt.file)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			l := newLoader(sources)
+			l.getString("TEST_SETTING", "")
+			l.unknown()
+			err := l.err()
+
+			if tt.wantProblem == "" {
+				if err != nil {
+					t.Fatalf("error = %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || len(validationErr.Problems) != 1 || validationErr.Problems[0] != tt.wantProblem {
+				t.Fatalf("error = %v, want the single problem %q", err, tt.wantProblem)
+			}
+		})
+	}
+}
+
+func TestConfigFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+env: development   # comments are fine
+port: 8080
+allowed-origins:
+  - https://example.com
+  - "https://admin.example.com"
+db:
+  ssl_mode: verify-full
+  max_open_conns: 25
+  password: "p#ss: word"
+tracing:
+  sample_ratio: 0.25
+session:
+  cookie:
+    secure: true
+`,
+			want: map[string]string{
+				"ENV":                   "development",
+				"PORT":                  "8080",
+				"ALLOWED_ORIGINS":       "https://example.com,https://admin.example.com",
+				"DB_SSL_MODE":           "verify-full",
+				"DB_MAX_OPEN_CONNS":     "25",
+				"DB_PASSWORD":           "p#ss: word",
+				"TRACING_SAMPLE_RATIO":  "0.25",
+				"SESSION_COOKIE_SECURE": "true",
+			},
+		},
+		{
+			name:    "yml",
+			file:    "config.yml",
+			content: "db:\n  name: app\n",
+			want:    map[string]string{"DB_NAME": "app"},
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+env = "development" # comments are fine
+port = 8080
+allowed_origins = ["https://example.com", 'https://admin.example.com']
+
+[db]
+ssl_mode = "verify-full"
+max_open_conns = 25
+password = "p#ss = word"
+
+[tracing]
+sample_ratio = 0.25
+
+[session.cookie]
+secure = true
+`,
+			want: map[string]string{
+				"ENV":                   "development",
+				"PORT":                  "8080",
+				"ALLOWED_ORIGINS":       "https://example.com,https://admin.example.com",
+				"DB_SSL_MODE":           "verify-full",
+				"DB_MAX_OPEN_CONNS":     "25",
+				"DB_PASSWORD":           "p#ss = word",
+				"TRACING_SAMPLE_RATIO":  "0.25",
+				"SESSION_COOKIE_SECURE": "true",
+			},
+		},
+		{
+			name:    "toml dotted keys",
+			file:    "config.toml",
+			content: "db.name = \"app\"\n",
+			want:    map[string]string{"DB_NAME": "app"},
+		},
+		{name: "unknown format", file: "config.json", content: "{}", wantErr: "unknown config file format"},
+		{name: "invalid yaml", file: "config.yaml", content: "db:\n\tname: app\n", wantErr: "failed to parse config file"},
+		{name: "invalid toml", file: "config.toml", content: "[db\nname = \"app\"\n", wantErr: "failed to parse config file"},
+		{name: "same setting twice", file: "config.yaml", content: "db:\n  port: 5432\ndb_port: 5433\n", wantErr: "DB_PORT is set twice"},
+		{name: "list of tables", file: "config.toml", content: "[[db]]\nname = \"app\"\n", wantErr: "expected a value or a list of values"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := readConfigFile(writeFile(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("values = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
+
+// validFlags are the settings a development configuration needs
+func validFlags() map[string]string {
+	return map[string]string{
+		"ENV":                       "development",
+		"DB_USER":                   "app",
+		"DB_NAME":                   "app",
+		"DB_PASSWORD":               "hunter2",
+		"TWO_FACTOR_ENCRYPTION_KEY": "0123456789abcdef",
+	}
+}
+
+func TestSettingsRedacted(t *testing.T) {
+	flags := validFlags()
+	flags["CSRF_AUTH_KEY"] = "a-csrf-key-of-at-least-32-bytes!!"
+	flags["METRICS_TOKEN"] = "metrics-token"
+
+	config, err := NewConfig(Sources{EnvFile: noEnvFile(t), Flags: flags})
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+
+	settings := make(map[string]Setting)
+	for _, setting := range config.Settings() {
+		settings[setting.Key] = setting
+	}
+
+	for _, key := range []string{"DB_PASSWORD", "CSRF_AUTH_KEY", "METRICS_TOKEN"} {
+		if got := settings[key]; got.Value != redacted || got.Source != SourceFlag {
+			t.Errorf("%s = %+v, want a redacted value set by flag", key, got)
+		}
+	}
+	// unset secrets show as empty rather than redacted, so it is visible
+	// they are missing
+	if got := settings["SESSION_COOKIE_HASH_KEY"]; got.Value != "" || got.Source != SourceDefault {
+		t.Errorf("SESSION_COOKIE_HASH_KEY = %+v, want an empty default", got)
+	}
+	if got := settings["DB_USER"]; got.Value != "app" {
+		t.Errorf("DB_USER = %+v, want the plain value", got)
+	}
+
+	for _, setting := range config.Settings() {
+		for _, secret := range []string{"hunter2", "a-csrf-key", "metrics-token"} {
+			if strings.Contains(setting.Value, secret) {
+				t.Errorf("%s shows the secret %q", setting.Key, setting.Value)
+			}
+		}
+	}
+}
+
+func TestValidationCollectsProblems(t *testing.T) {
+	flags := validFlags()
+	flags["PORT"] = "0"
+	flags["DB_SSL_MODE"] = "sometimes"
+	flags["SESSION_STORE"] = "redis"
+
+	_, err := NewConfig(Sources{EnvFile: noEnvFile(t), Flags: flags})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error = %v, want a *ValidationError", err)
+	}
+	for _, want := range []string{"PORT must be between", "unknown DB_SSL_MODE", "unknown SESSION_STORE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v, want a problem containing %q", err, want)
+		}
+	}
+}
+
+func TestExampleTwoFactorKey(t *testing.T) {
+	tests := []struct {
+		env     string
+		wantErr bool
+	}{
+		{env: "development"},
+		{env: "production", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			flags := validFlags()
+			flags["ENV"] = tt.env
+			flags["CSRF_AUTH_KEY"] = "a-csrf-key-of-at-least-32-bytes!!"
+			flags["TWO_FACTOR_ENCRYPTION_KEY"] = exampleTwoFactorEncryptionKey
+
+			_, err := NewConfig(Sources{EnvFile: noEnvFile(t), Flags: flags})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "TWO_FACTOR_ENCRYPTION_KEY must not be the example key") {
+					t.Errorf("error = %v, want the example key rejected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package cookie
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/securecookie"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+// cookieName must match the name of the session cookie, securecookie binds
+// the encoded value to it
+const cookieName = "session_id"
+
+type sessionPayload struct {
+	ID        uuid.UUID
+	Remember  bool
+	UserAgent string
+	IPAddress string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    int
+	UserEmail string
+	UserRole  string
+	// UserVerifiedAt is the zero time for unverified users
+	UserVerifiedAt time.Time
+	// UserDisabledAt is the zero time for enabled users
+	UserDisabledAt time.Time
+	// UserSessionsValidAfter is the zero time for users who never signed out
+	// everywhere
+	UserSessionsValidAfter time.Time
+	UserKeptSessionID      uuid.UUID
+	UserCreatedAt          time.Time
+	UserUpdatedAt          time.Time
+	// UserCheckedAt is when the user fields were read from the database
+	UserCheckedAt time.Time
+}
+
+func newSessionPayload(session *entities.Session) sessionPayload {
+	payload := sessionPayload{
+		ID:            session.ID,
+		Remember:      session.Remember,
+		UserAgent:     session.UserAgent,
+		IPAddress:     session.IPAddress,
+		ExpiresAt:     session.ExpiresAt.ToTime(),
+		CreatedAt:     session.CreatedAt.ToTime(),
+		UpdatedAt:     session.UpdatedAt.ToTime(),
+		UserID:        session.User.ID,
+		UserEmail:     session.User.Email.ToString(),
+		UserRole:      string(session.User.Role),
+		UserCreatedAt: session.User.CreatedAt.ToTime(),
+		UserUpdatedAt: session.User.UpdatedAt.ToTime(),
+		UserCheckedAt: session.UserCheckedAt.ToTime(),
+	}
+	if session.User.VerifiedAt != nil {
+		payload.UserVerifiedAt = session.User.VerifiedAt.ToTime()
+	}
+	if session.User.DisabledAt != nil {
+		payload.UserDisabledAt = session.User.DisabledAt.ToTime()
+	}
+	if session.User.SessionsValidAfter != nil {
+		payload.UserSessionsValidAfter = session.User.SessionsValidAfter.ToTime()
+		payload.UserKeptSessionID = session.User.KeptSessionID
+	}
+	return payload
+}
+
+func (p sessionPayload) toSession(token string) *entities.Session {
+	email, _ := valueobject.NewEmail(p.UserEmail)
+	user := &entities.User{
+		ID:        p.UserID,
+		Email:     email,
+		Role:      entities.Role(p.UserRole),
+		CreatedAt: valueobject.NewTime(p.UserCreatedAt),
+		UpdatedAt: valueobject.NewTime(p.UserUpdatedAt),
+	}
+	if !p.UserVerifiedAt.IsZero() {
+		verifiedAt := valueobject.NewTime(p.UserVerifiedAt)
+		user.VerifiedAt = &verifiedAt
+	}
+	if !p.UserDisabledAt.IsZero() {
+		disabledAt := valueobject.NewTime(p.UserDisabledAt)
+		user.DisabledAt = &disabledAt
+	}
+	if !p.UserSessionsValidAfter.IsZero() {
+		validAfter := valueobject.NewTime(p.UserSessionsValidAfter)
+		user.SessionsValidAfter = &validAfter
+		user.KeptSessionID = p.UserKeptSessionID
+	}
+	return &entities.Session{
+		ID:            p.ID,
+		Token:         token,
+		Remember:      p.Remember,
+		UserAgent:     p.UserAgent,
+		IPAddress:     p.IPAddress,
+		ExpiresAt:     valueobject.NewTime(p.ExpiresAt),
+		CreatedAt:     valueobject.NewTime(p.CreatedAt),
+		UpdatedAt:     valueobject.NewTime(p.UpdatedAt),
+		UserCheckedAt: valueobject.NewTime(p.UserCheckedAt),
+		User:          user,
+	}
+}
+
+// CookieSessionRepository is a stateless store which signs and encrypts the
+// whole session into the cookie value, so authenticating a request never
+// touches the sessions table. The trade off is that sessions cannot be listed,
+// the session service revokes all sessions of a user by the time they were
+// created and single sessions are signed out through a revocation list.
+type CookieSessionRepository struct {
+	codec       *securecookie.SecureCookie
+	maxAge      time.Duration
+	revocations repositories.ISessionRevocationRepository
+}
+
+// NewCookieSessionRepository takes a 32 or 64 byte hash key used to sign the
+// cookie and a 16, 24 or 32 byte block key used to encrypt it. maxAge bounds
+// how long a cookie is accepted after it was last encoded, which is also how
+// long a signed out session stays on the revocation list.
+func NewCookieSessionRepository(hashKey, blockKey []byte, maxAge time.Duration, revocations repositories.ISessionRevocationRepository) repositories.ISessionRepository {
+	codec := securecookie.New(hashKey, blockKey)
+	codec.MaxAge(int(maxAge.Seconds()))
+	return &CookieSessionRepository{codec: codec, maxAge: maxAge, revocations: revocations}
+}
+
+func (c *CookieSessionRepository) Create(ctx context.Context, session *entities.Session) (*entities.Session, error) {
+	if session == nil || session.User == nil {
+		return nil, entities.ErrSessionIsRequired
+	}
+
+	token, err := c.encode(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session for user %s: %w", session.User.Email, err)
+	}
+
+	created := *session
+	created.Token = token
+	return &created, nil
+}
+
+// Get returns the session with the user snapshot, which comes with the cookie
+// anyway and is needed to revoke the session
+func (c *CookieSessionRepository) Get(ctx context.Context, sessionId string) (*entities.Session, error) {
+	return c.GetWithUser(ctx, sessionId)
+}
+
+// GetWithUser decodes the cookie value. Tampered, foreign, too old and signed
+// out values are reported as unknown sessions.
+func (c *CookieSessionRepository) GetWithUser(ctx context.Context, sessionId string) (*entities.Session, error) {
+	var payload sessionPayload
+	if err := c.codec.Decode(cookieName, sessionId, &payload); err != nil {
+		return nil, repositories.ErrNoRows
+	}
+
+	revoked, err := c.revocations.Revoked(ctx, payload.ID, payload.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, repositories.ErrNoRows
+	}
+	return payload.toSession(sessionId), nil
+}
+
+// SnapshotsUser is true, the user is encoded into the cookie at sign in
+func (c *CookieSessionRepository) SnapshotsUser() bool {
+	return true
+}
+
+func (c *CookieSessionRepository) ListByUser(ctx context.Context, userID int) ([]*entities.Session, error) {
+	return nil, repositories.ErrNotSupported
+}
+
+// Update re-encodes the session, the returned session carries the new token
+// which has to be sent back to the client
+func (c *CookieSessionRepository) Update(ctx context.Context, session *entities.Session) (*entities.Session, error) {
+	updated := *session
+	updated.UpdatedAt = valueobject.NewCurrentTime()
+
+	token, err := c.encode(&updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+	updated.Token = token
+	return &updated, nil
+}
+
+// Delete puts the session on the revocation list, a copy of the cookie kept
+// by someone else is rejected from now on
+func (c *CookieSessionRepository) Delete(ctx context.Context, session *entities.Session) error {
+	if session == nil || session.User == nil {
+		return entities.ErrSessionIsRequired
+	}
+	return c.revoke(ctx, session.ID, session.User.ID)
+}
+
+// DeleteForUser takes the session id, not the cookie value. Ids of other users
+// are accepted but never match their sessions.
+func (c *CookieSessionRepository) DeleteForUser(ctx context.Context, userID int, sessionId string) error {
+	id, err := uuid.Parse(sessionId)
+	if err != nil {
+		return repositories.ErrNoRows
+	}
+	return c.revoke(ctx, id, userID)
+}
+
+func (c *CookieSessionRepository) DeleteAllForUser(ctx context.Context, userID int, exceptSessionId string) error {
+	return repositories.ErrNotSupported
+}
+
+// DeleteExpired purges the revocation list, expired sessions themselves are
+// rejected when read
+func (c *CookieSessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	return c.revocations.DeleteExpired(ctx)
+}
+
+// revoke keeps the session on the revocation list until no cookie encoded up
+// to now is accepted anymore
+func (c *CookieSessionRepository) revoke(ctx context.Context, sessionID uuid.UUID, userID int) error {
+	return c.revocations.Revoke(ctx, sessionID, userID, time.Now().Add(c.maxAge))
+}
+
+func (c *CookieSessionRepository) encode(session *entities.Session) (string, error) {
+	return c.codec.Encode(cookieName, newSessionPayload(session))
+}
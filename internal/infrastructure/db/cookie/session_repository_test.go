@@ -0,0 +1,198 @@
+package cookie
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+type revocation struct {
+	sessionID uuid.UUID
+	userID    int
+}
+
+type fakeRevocations struct {
+	until   map[revocation]time.Time
+	purged  int
+	revoked int
+}
+
+func (r *fakeRevocations) Revoke(ctx context.Context, sessionID uuid.UUID, userID int, until time.Time) error {
+	r.until[revocation{sessionID, userID}] = until
+	r.revoked++
+	return nil
+}
+
+func (r *fakeRevocations) Revoked(ctx context.Context, sessionID uuid.UUID, userID int) (bool, error) {
+	_, ok := r.until[revocation{sessionID, userID}]
+	return ok, nil
+}
+
+func (r *fakeRevocations) DeleteExpired(ctx context.Context) (int64, error) {
+	r.purged++
+	return 0, nil
+}
+
+const maxAge = 24 * time.Hour
+
+func newTestRepository() (repositories.ISessionRepository, *fakeRevocations) {
+	revocations := &fakeRevocations{until: make(map[revocation]time.Time)}
+	repository := NewCookieSessionRepository(
+		[]byte("0123456789abcdef0123456789abcdef"),
+		[]byte("0123456789abcdef"),
+		maxAge,
+		revocations,
+	)
+	return repository, revocations
+}
+
+func newTestSession(t *testing.T, repository repositories.ISessionRepository, userID int) *entities.Session {
+	t.Helper()
+	email, _ := valueobject.NewEmail("ada@example.com")
+	user := &entities.User{ID: userID, Email: email, Role: entities.RoleUser}
+	session, err := repository.Create(context.Background(), entities.NewSession(user, false, entities.SessionPolicy{IdleTimeout: time.Hour}))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return session
+}
+
+func TestGetWithUser(t *testing.T) {
+	ctx := context.Background()
+	repository, _ := newTestRepository()
+	session := newTestSession(t, repository, 1)
+
+	got, err := repository.GetWithUser(ctx, session.Token)
+	if err != nil {
+		t.Fatalf("GetWithUser() error = %v", err)
+	}
+	if got.ID != session.ID || got.User.ID != 1 || got.User.Email.ToString() != "ada@example.com" {
+		t.Errorf("GetWithUser() = %+v with user %+v, want session %s of user 1", got, got.User, session.ID)
+	}
+
+	tampered := session.Token[:len(session.Token)-2] + "xx"
+	for name, token := range map[string]string{"tampered": tampered, "empty": ""} {
+		if _, err := repository.GetWithUser(ctx, token); !errors.Is(err, repositories.ErrNoRows) {
+			t.Errorf("GetWithUser(%s) error = %v, want ErrNoRows", name, err)
+		}
+	}
+}
+
+// the fields TouchSession decides on travel with the cookie, so the user is
+// only read again once the snapshot is stale
+func TestSnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repository, _ := newTestRepository()
+
+	email, _ := valueobject.NewEmail("ada@example.com")
+	user := &entities.User{ID: 1, Email: email, Role: entities.RoleAdmin}
+	user.Disable()
+	kept := uuid.New()
+	user.RevokeSessions(kept)
+	session := entities.NewSession(user, true, entities.SessionPolicy{RememberIdleTimeout: time.Hour})
+
+	created, err := repository.Create(ctx, session)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	got, err := repository.GetWithUser(ctx, created.Token)
+	if err != nil {
+		t.Fatalf("GetWithUser() error = %v", err)
+	}
+
+	if !got.User.Disabled() || !got.User.DisabledAt.ToTime().Equal(user.DisabledAt.ToTime()) {
+		t.Errorf("DisabledAt = %v, want %v", got.User.DisabledAt, user.DisabledAt)
+	}
+	if got.User.SessionsValidAfter == nil || !got.User.SessionsValidAfter.ToTime().Equal(user.SessionsValidAfter.ToTime()) {
+		t.Errorf("SessionsValidAfter = %v, want %v", got.User.SessionsValidAfter, user.SessionsValidAfter)
+	}
+	if got.User.KeptSessionID != kept {
+		t.Errorf("KeptSessionID = %v, want %v", got.User.KeptSessionID, kept)
+	}
+	if got.User.Role != entities.RoleAdmin || !got.Remember {
+		t.Errorf("Role = %q, Remember = %v, want admin and true", got.User.Role, got.Remember)
+	}
+	if !got.UserCheckedAt.ToTime().Equal(session.UserCheckedAt.ToTime()) {
+		t.Errorf("UserCheckedAt = %v, want %v", got.UserCheckedAt, session.UserCheckedAt)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	repository, revocations := newTestRepository()
+	current := newTestSession(t, repository, 1)
+	other := newTestSession(t, repository, 1)
+
+	// logging out reads the session first, like SessionService.DeleteSession
+	session, err := repository.Get(ctx, current.Token)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if err := repository.Delete(ctx, session); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := repository.GetWithUser(ctx, current.Token); !errors.Is(err, repositories.ErrNoRows) {
+		t.Errorf("GetWithUser() of the deleted session error = %v, want ErrNoRows", err)
+	}
+	if _, err := repository.GetWithUser(ctx, other.Token); err != nil {
+		t.Errorf("GetWithUser() of the other session error = %v", err)
+	}
+
+	until := revocations.until[revocation{current.ID, 1}]
+	if want := time.Now().Add(maxAge); until.Before(want.Add(-time.Minute)) || until.After(want) {
+		t.Errorf("revoked until %v, want about %v", until, want)
+	}
+}
+
+func TestDeleteForUser(t *testing.T) {
+	ctx := context.Background()
+	repository, revocations := newTestRepository()
+	session := newTestSession(t, repository, 1)
+
+	tests := []struct {
+		name      string
+		userID    int
+		sessionID string
+		wantErr   error
+		revoked   bool
+	}{
+		{name: "malformed id", userID: 1, sessionID: "not-a-uuid", wantErr: repositories.ErrNoRows},
+		{name: "session of another user", userID: 2, sessionID: session.ID.String()},
+		{name: "own session", userID: 1, sessionID: session.ID.String(), revoked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repository.DeleteForUser(ctx, tt.userID, tt.sessionID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteForUser() error = %v, want %v", err, tt.wantErr)
+			}
+			_, err = repository.GetWithUser(ctx, session.Token)
+			if revoked := errors.Is(err, repositories.ErrNoRows); revoked != tt.revoked {
+				t.Errorf("session revoked = %v, want %v", revoked, tt.revoked)
+			}
+		})
+	}
+
+	if revocations.revoked != 2 {
+		t.Errorf("revocations = %d, want 2", revocations.revoked)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	repository, revocations := newTestRepository()
+	if _, err := repository.DeleteExpired(context.Background()); err != nil {
+		t.Fatalf("DeleteExpired() error = %v", err)
+	}
+	if revocations.purged != 1 {
+		t.Errorf("revocation list purged %d times, want 1", revocations.purged)
+	}
+}
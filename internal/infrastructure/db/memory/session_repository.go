@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+// MemorySessionRepository keeps sessions in process memory. It suits tests and
+// single node deployments, sessions are lost on restart. Expired sessions are
+// evicted by DeleteExpired, which the session reaper calls periodically.
+type MemorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[string]*entities.Session
+}
+
+func NewMemorySessionRepository() repositories.ISessionRepository {
+	return &MemorySessionRepository{
+		sessions: make(map[string]*entities.Session),
+	}
+}
+
+func (m *MemorySessionRepository) Create(ctx context.Context, session *entities.Session) (*entities.Session, error) {
+	if session == nil || session.User == nil {
+		return nil, entities.ErrSessionIsRequired
+	}
+
+	stored := *session
+	stored.Token = session.ID.String()
+
+	m.mu.Lock()
+	m.sessions[stored.Token] = &stored
+	m.mu.Unlock()
+
+	created := stored
+	return &created, nil
+}
+
+func (m *MemorySessionRepository) Get(ctx context.Context, sessionId string) (*entities.Session, error) {
+	session, err := m.GetWithUser(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	session.User = nil
+	return session, nil
+}
+
+func (m *MemorySessionRepository) GetWithUser(ctx context.Context, sessionId string) (*entities.Session, error) {
+	m.mu.RLock()
+	stored, ok := m.sessions[sessionId]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, repositories.ErrNoRows
+	}
+
+	session := *stored
+	return &session, nil
+}
+
+// SnapshotsUser is true, the user is stored with the session at sign in
+func (m *MemorySessionRepository) SnapshotsUser() bool {
+	return true
+}
+
+func (m *MemorySessionRepository) ListByUser(ctx context.Context, userID int) ([]*entities.Session, error) {
+	m.mu.RLock()
+	sessions := make([]*entities.Session, 0)
+	for _, stored := range m.sessions {
+		if stored.User.ID != userID || stored.Expired() {
+			continue
+		}
+		session := *stored
+		session.User = nil
+		sessions = append(sessions, &session)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.ToTime().After(sessions[j].CreatedAt.ToTime())
+	})
+	return sessions, nil
+}
+
+func (m *MemorySessionRepository) Update(ctx context.Context, session *entities.Session) (*entities.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.sessions[session.ID.String()]
+	if !ok {
+		return nil, repositories.ErrNoRows
+	}
+	stored.ExpiresAt = session.ExpiresAt
+	if session.User != nil {
+		stored.User = session.User
+		stored.UserCheckedAt = session.UserCheckedAt
+	}
+	stored.UpdatedAt = valueobject.NewCurrentTime()
+
+	updated := *stored
+	return &updated, nil
+}
+
+func (m *MemorySessionRepository) Delete(ctx context.Context, session *entities.Session) error {
+	m.mu.Lock()
+	delete(m.sessions, session.ID.String())
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionRepository) DeleteForUser(ctx context.Context, userID int, sessionId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.sessions[sessionId]
+	if !ok || stored.User.ID != userID {
+		return repositories.ErrNoRows
+	}
+	delete(m.sessions, sessionId)
+	return nil
+}
+
+func (m *MemorySessionRepository) DeleteAllForUser(ctx context.Context, userID int, exceptSessionId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, stored := range m.sessions {
+		if stored.User.ID == userID && id != exceptSessionId {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemorySessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+	for id, stored := range m.sessions {
+		if !now.Before(stored.ExpiresAt.ToTime()) {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+const apiTokenColumns = "id, user_id, name, prefix, token_hash, last_used_at, expires_at, created_at, updated_at"
+
+type APITokenDTO struct {
+	ID         int
+	UserID     int
+	Name       string
+	Prefix     string
+	TokenHash  string
+	LastUsedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (t APITokenDTO) toAPIToken() *entities.APIToken {
+	return &entities.APIToken{
+		ID:         t.ID,
+		UserID:     t.UserID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		TokenHash:  t.TokenHash,
+		LastUsedAt: nullTimeToValue(t.LastUsedAt),
+		ExpiresAt:  nullTimeToValue(t.ExpiresAt),
+		CreatedAt:  valueobject.NewTime(t.CreatedAt),
+		UpdatedAt:  valueobject.NewTime(t.UpdatedAt),
+	}
+}
+
+func (t *APITokenDTO) scanFields() []any {
+	return []any{
+		&t.ID,
+		&t.UserID,
+		&t.Name,
+		&t.Prefix,
+		&t.TokenHash,
+		&t.LastUsedAt,
+		&t.ExpiresAt,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	}
+}
+
+func nullTimeToValue(t sql.NullTime) *valueobject.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := valueobject.NewTime(t.Time)
+	return &v
+}
+
+func valueToNullTime(t *valueobject.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.ToTime(), Valid: true}
+}
+
+type PQAPITokenRepository struct {
+	db *sql.DB
+}
+
+func NewPQAPITokenRepository(db *sql.DB) repositories.IAPITokenRepository {
+	return &PQAPITokenRepository{db}
+}
+
+func (a *PQAPITokenRepository) Create(ctx context.Context, token *entities.APIToken) (*entities.APIToken, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError(err, "failed to begin transaction")
+	}
+
+	var createdToken APITokenDTO
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO api_tokens (user_id, name, prefix, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING "+apiTokenColumns,
+		token.UserID,
+		token.Name,
+		token.Prefix,
+		token.TokenHash,
+		valueToNullTime(token.ExpiresAt),
+	).Scan(createdToken.scanFields()...)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return nil, translateError(err, "failed to create api token")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, translateError(err, "failed to commit transaction")
+	}
+	return createdToken.toAPIToken(), nil
+}
+
+func (a *PQAPITokenRepository) List(ctx context.Context, userID int) ([]*entities.APIToken, error) {
+	rows, err := a.db.QueryContext(ctx,
+		"SELECT "+apiTokenColumns+" FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, translateError(err, "failed to get api tokens")
+	}
+	defer rows.Close()
+
+	tokens := make([]*entities.APIToken, 0)
+	for rows.Next() {
+		var token APITokenDTO
+		if err := rows.Scan(token.scanFields()...); err != nil {
+			return nil, translateError(err, "failed to scan api token")
+		}
+		tokens = append(tokens, token.toAPIToken())
+	}
+	return tokens, nil
+}
+
+func (a *PQAPITokenRepository) Get(ctx context.Context, userID int, id int) (*entities.APIToken, error) {
+	var token APITokenDTO
+	err := a.db.QueryRowContext(ctx,
+		"SELECT "+apiTokenColumns+" FROM api_tokens WHERE id = $1 AND user_id = $2",
+		id,
+		userID,
+	).Scan(token.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to get api token")
+	}
+	return token.toAPIToken(), nil
+}
+
+func (a *PQAPITokenRepository) GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.APIToken, error) {
+	var tokenDTO APITokenDTO
+	var userDTO UserDTO
+
+	query := `
+		SELECT
+			api_tokens.id, api_tokens.user_id, api_tokens.name, api_tokens.prefix, api_tokens.token_hash,
+			api_tokens.last_used_at, api_tokens.expires_at, api_tokens.created_at, api_tokens.updated_at,
+			` + joinedUserColumns + `
+		FROM api_tokens
+		INNER JOIN users ON api_tokens.user_id = users.id
+		WHERE api_tokens.token_hash = $1
+	`
+	fields := append(tokenDTO.scanFields(), userDTO.scanFields()...)
+	if err := a.db.QueryRowContext(retryableRead(ctx), query, tokenHash).Scan(fields...); err != nil {
+		return nil, translateError(err, "failed to get api token")
+	}
+
+	token := tokenDTO.toAPIToken()
+	token.User = userDTO.toUser()
+
+	return token, nil
+}
+
+func (a *PQAPITokenRepository) MarkUsed(ctx context.Context, token *entities.APIToken) error {
+	_, err := a.db.ExecContext(ctx,
+		"UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1",
+		token.ID,
+	)
+	if err != nil {
+		return translateError(err, "failed to update api token")
+	}
+	return nil
+}
+
+func (a *PQAPITokenRepository) Delete(ctx context.Context, token *entities.APIToken) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err, "failed to begin transaction")
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM api_tokens WHERE id = $1 AND user_id = $2", token.ID, token.UserID)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return translateError(err, "failed to delete api token")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return translateError(err, "failed to commit transaction")
+	}
+	return nil
+}
@@ -1,21 +1,91 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/pkg/tracing"
 )
 
+// NewDatabaseConfig opens the pool, every query made through it is traced,
+// gets a statement timeout and marked reads are retried on transient
+// connection errors. Nothing connects until the pool is used, see WaitForDatabase.
 func NewDatabaseConfig(conf *config.Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		conf.DatabaseConfig.Host,
-		conf.DatabaseConfig.Port,
-		conf.DatabaseConfig.User,
-		conf.DatabaseConfig.Password,
-		conf.DatabaseConfig.Name,
-	)
-	return sql.Open("postgres", dsn)
+	dbConf := conf.DatabaseConfig
+	connector, err := pq.NewConnector(dataSourceName(dbConf))
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(tracing.WrapConnector(&resilientConnector{
+		Connector: connector,
+		options: connectorOptions{
+			statementTimeout: dbConf.StatementTimeout,
+			readRetries:      dbConf.ReadRetries,
+			retryBackoff:     dbConf.RetryBackoff,
+		},
+	}, "postgresql"))
+	db.SetMaxOpenConns(dbConf.MaxOpenConns)
+	db.SetMaxIdleConns(dbConf.MaxIdleConns)
+	db.SetConnMaxLifetime(dbConf.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(dbConf.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// WaitForDatabase pings until the database answers or timeout has passed,
+// Postgres often comes up after the app when both start together. onRetry is
+// called before every wait.
+func WaitForDatabase(ctx context.Context, db *sql.DB, timeout time.Duration, onRetry func(attempt int, delay time.Duration, err error)) error {
+	if timeout <= 0 {
+		return db.PingContext(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		delay := backoff(attempt, 250*time.Millisecond)
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) < delay {
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempt, err)
+		}
+		onRetry(attempt, delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempt, err)
+		}
+	}
+}
+
+// dataSourceName quotes every value, passwords may contain spaces and quotes
+func dataSourceName(conf *config.DatabaseConfig) string {
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	params := [][2]string{
+		{"host", conf.Host},
+		{"port", strconv.Itoa(conf.Port)},
+		{"user", conf.User},
+		{"password", conf.Password},
+		{"dbname", conf.Name},
+		{"sslmode", conf.SslMode},
+		{"connect_timeout", strconv.Itoa(int(conf.ConnectTimeout.Seconds()))},
+	}
+	if conf.SslRootCert != "" {
+		params = append(params, [2]string{"sslrootcert", conf.SslRootCert})
+	}
+
+	pairs := make([]string, 0, len(params))
+	for _, param := range params {
+		pairs = append(pairs, param[0]+"='"+quote.Replace(param[1])+"'")
+	}
+	return strings.Join(pairs, " ")
 }
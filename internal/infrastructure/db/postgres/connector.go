@@ -0,0 +1,293 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+
+	"go-starter-template/pkg/metrics"
+	"go-starter-template/pkg/tracing"
+)
+
+var readRetriesTotal = metrics.MustRegister(metrics.NewCounter(
+	"db_read_retries_total",
+	"Reads retried on a new connection after a transient connection error, by result.",
+	"result",
+))
+
+type (
+	statementTimeoutKey struct{}
+	retryableReadKey    struct{}
+)
+
+// WithStatementTimeout overrides the statement timeout for the queries run
+// with the context, zero disables it. Migrations use it as DDL can take
+// longer than any request should.
+func WithStatementTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, timeout)
+}
+
+// retryableRead marks the queries run with the context as reads that may be
+// sent again on a new connection, only statements without side effects may
+// be marked
+func retryableRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableReadKey{}, true)
+}
+
+// connectorOptions are the resilience settings of the connections
+type connectorOptions struct {
+	statementTimeout time.Duration
+	readRetries      int
+	retryBackoff     time.Duration
+}
+
+// resilientConnector gives every statement a deadline and retries the reads
+// marked with retryableRead that fail because the connection broke, such as
+// after a failover or a restart of Postgres, on a new connection
+type resilientConnector struct {
+	driver.Connector
+	options connectorOptions
+}
+
+func (c *resilientConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &resilientConn{Conn: conn, connector: c}, nil
+}
+
+// resilientConn replaces its driver connection when a read is retried.
+// database/sql never uses a connection from two goroutines at once, so it
+// needs no locking.
+type resilientConn struct {
+	driver.Conn
+	connector *resilientConnector
+	inTx      bool
+}
+
+func (c *resilientConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if _, ok := c.Conn.(driver.QueryerContext); !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel := c.withTimeout(ctx)
+
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil && c.retryable(ctx, err) {
+		rows, err = c.retryRead(ctx, query, args, err)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the deadline covers reading the rows as well
+	return &cancelRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (c *resilientConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *resilientConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *resilientConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else if opts.ReadOnly || opts.Isolation != 0 {
+		return nil, errors.New("postgres: driver does not support transaction options")
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.inTx = true
+	return &resilientTx{Tx: tx, conn: c}, nil
+}
+
+func (c *resilientConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *resilientConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *resilientConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// withTimeout applies the statement timeout unless the caller's deadline is
+// sooner
+func (c *resilientConn) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := c.connector.options.statementTimeout
+	if override, ok := ctx.Value(statementTimeoutKey{}).(time.Duration); ok {
+		timeout = override
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// retryable only allows the reads the caller marked outside of transactions.
+// The query text says nothing about side effects, a SELECT may call a
+// function that writes, a transaction is lost with its connection and a
+// write may have been applied before the connection broke.
+func (c *resilientConn) retryable(ctx context.Context, err error) bool {
+	marked, _ := ctx.Value(retryableReadKey{}).(bool)
+	return marked &&
+		c.connector.options.readRetries > 0 &&
+		!c.inTx &&
+		ctx.Err() == nil &&
+		transient(err)
+}
+
+func (c *resilientConn) retryRead(ctx context.Context, query string, args []driver.NamedValue, err error) (driver.Rows, error) {
+	options := c.connector.options
+	span := tracing.SpanFromContext(ctx)
+
+	for attempt := 1; attempt <= options.readRetries; attempt++ {
+		slog.WarnContext(ctx, "retrying read after a connection error", "attempt", attempt, "error", err)
+		span.AddEvent("retry", tracing.Int("attempt", attempt), tracing.String("error", err.Error()))
+
+		if sleepErr := sleep(ctx, backoff(attempt, options.retryBackoff)); sleepErr != nil {
+			break
+		}
+
+		conn, connectErr := c.connector.Connector.Connect(ctx)
+		if connectErr != nil {
+			err = connectErr
+			if !transient(err) {
+				break
+			}
+			continue
+		}
+		c.Conn.Close()
+		c.Conn = conn
+
+		queryer, ok := conn.(driver.QueryerContext)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		rows, queryErr := queryer.QueryContext(ctx, query, args)
+		if queryErr == nil {
+			readRetriesTotal.Inc("success")
+			return rows, nil
+		}
+		err = queryErr
+		if !c.retryable(ctx, err) {
+			break
+		}
+	}
+
+	readRetriesTotal.Inc("failed")
+	return nil, err
+}
+
+// resilientTx tells the connection when its transaction is over
+type resilientTx struct {
+	driver.Tx
+	conn *resilientConn
+}
+
+func (t *resilientTx) Commit() error {
+	t.conn.inTx = false
+	return t.Tx.Commit()
+}
+
+func (t *resilientTx) Rollback() error {
+	t.conn.inTx = false
+	return t.Tx.Rollback()
+}
+
+// cancelRows releases the statement deadline once the rows are closed
+type cancelRows struct {
+	driver.Rows
+	cancel context.CancelFunc
+}
+
+func (r *cancelRows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	return err
+}
+
+// transient reports errors of a connection that broke or of a server that is
+// restarting, anything else would fail again
+func transient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// class 08 is connection_exception, 57P01 to 57P03 are admin_shutdown,
+		// crash_shutdown and cannot_connect_now
+		switch {
+		case pqErr.Code.Class() == "08":
+			return true
+		case pqErr.Code == "57P01", pqErr.Code == "57P02", pqErr.Code == "57P03":
+			return true
+		}
+	}
+	return false
+}
+
+// backoff doubles the delay with every attempt, starting at base
+func backoff(attempt int, base time.Duration) time.Duration {
+	const maxBackoff = 5 * time.Second
+	delay := base << (attempt - 1)
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
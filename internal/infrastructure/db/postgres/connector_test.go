@@ -0,0 +1,238 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// fakeConnector counts the connections and statements and fails the first
+// failures statements with err
+type fakeConnector struct {
+	err         error
+	failures    int
+	connections int
+	queries     int
+	deadline    time.Time
+	hasDeadline bool
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	c.connections++
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver { return nil }
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	connector := c.connector
+	connector.queries++
+	connector.deadline, connector.hasDeadline = ctx.Deadline()
+	if connector.queries <= connector.failures {
+		return nil, connector.err
+	}
+	return fakeRows{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	connector := c.connector
+	connector.queries++
+	connector.deadline, connector.hasDeadline = ctx.Deadline()
+	if connector.queries <= connector.failures {
+		return nil, connector.err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"id"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func openTestDB(t *testing.T, connector *fakeConnector, options connectorOptions) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(&resilientConnector{Connector: connector, options: options})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReadRetries(t *testing.T) {
+	restarting := &pq.Error{Code: "57P01"}
+	missing := &pq.Error{Code: "42P01"}
+	options := connectorOptions{readRetries: 2, retryBackoff: time.Millisecond}
+
+	tests := []struct {
+		name        string
+		err         error
+		failures    int
+		options     connectorOptions
+		unmarked    bool
+		inTx        bool
+		wantErr     error
+		queries     int
+		connections int
+	}{
+		{name: "marked read", err: io.ErrUnexpectedEOF, failures: 1, options: options, queries: 2, connections: 2},
+		{name: "server restarting", err: restarting, failures: 2, options: options, queries: 3, connections: 3},
+		{name: "retries used up", err: restarting, failures: 3, options: options, wantErr: restarting, queries: 3, connections: 3},
+		// a SELECT may have side effects, only the caller knows
+		{name: "unmarked read", err: io.ErrUnexpectedEOF, failures: 1, options: options, unmarked: true, wantErr: io.ErrUnexpectedEOF, queries: 1, connections: 1},
+		{name: "in a transaction", err: io.ErrUnexpectedEOF, failures: 1, options: options, inTx: true, wantErr: io.ErrUnexpectedEOF, queries: 1, connections: 1},
+		{name: "not transient", err: missing, failures: 1, options: options, wantErr: missing, queries: 1, connections: 1},
+		{name: "retries disabled", err: io.ErrUnexpectedEOF, failures: 1, wantErr: io.ErrUnexpectedEOF, queries: 1, connections: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := &fakeConnector{err: tt.err, failures: tt.failures}
+			db := openTestDB(t, connector, tt.options)
+
+			ctx := context.Background()
+			if !tt.unmarked {
+				ctx = retryableRead(ctx)
+			}
+			var err error
+			if tt.inTx {
+				tx, beginErr := db.BeginTx(ctx, nil)
+				if beginErr != nil {
+					t.Fatalf("BeginTx() error = %v", beginErr)
+				}
+				defer tx.Rollback()
+				_, err = tx.QueryContext(ctx, "SELECT id FROM users")
+			} else {
+				_, err = db.QueryContext(ctx, "SELECT id FROM users")
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("QueryContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if connector.queries != tt.queries || connector.connections != tt.connections {
+				t.Errorf("sent %d queries on %d connections, want %d on %d", connector.queries, connector.connections, tt.queries, tt.connections)
+			}
+		})
+	}
+}
+
+// a write is never sent twice, even when marked, it may have been applied
+// before the connection broke
+func TestWritesAreNotRetried(t *testing.T) {
+	connector := &fakeConnector{err: io.ErrUnexpectedEOF, failures: 1}
+	db := openTestDB(t, connector, connectorOptions{readRetries: 2, retryBackoff: time.Millisecond})
+
+	_, err := db.ExecContext(retryableRead(context.Background()), "UPDATE users SET role = $1", "admin")
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ExecContext() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if connector.queries != 1 {
+		t.Errorf("sent %d statements, want 1", connector.queries)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	const timeout = time.Minute
+	soon, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	later, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		wantDeadline bool
+		want         time.Duration
+	}{
+		{name: "no deadline", ctx: context.Background(), wantDeadline: true, want: timeout},
+		{name: "later deadline", ctx: later, wantDeadline: true, want: timeout},
+		{name: "sooner deadline", ctx: soon, wantDeadline: true, want: time.Second},
+		{name: "override", ctx: WithStatementTimeout(context.Background(), time.Hour), wantDeadline: true, want: time.Hour},
+		{name: "disabled", ctx: WithStatementTimeout(context.Background(), 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := &fakeConnector{}
+			db := openTestDB(t, connector, connectorOptions{statementTimeout: timeout})
+
+			if _, err := db.ExecContext(tt.ctx, "DELETE FROM sessions"); err != nil {
+				t.Fatalf("ExecContext() error = %v", err)
+			}
+			if connector.hasDeadline != tt.wantDeadline {
+				t.Fatalf("statement has a deadline = %v, want %v", connector.hasDeadline, tt.wantDeadline)
+			}
+			if !tt.wantDeadline {
+				return
+			}
+			if left := time.Until(connector.deadline); left > tt.want || left < tt.want-time.Second {
+				t.Errorf("statement deadline in %v, want about %v", left, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "connection failure", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "admin shutdown", err: &pq.Error{Code: "57P01"}, want: true},
+		{name: "cannot connect now", err: &pq.Error{Code: "57P03"}, want: true},
+		{name: "query canceled", err: &pq.Error{Code: "57014"}},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}},
+		{name: "deadline", err: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transient(tt.err); got != tt.want {
+				t.Errorf("transient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 100 * time.Millisecond},
+		{attempt: 2, want: 200 * time.Millisecond},
+		{attempt: 4, want: 800 * time.Millisecond},
+		{attempt: 10, want: 5 * time.Second},
+		{attempt: 100, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempt, 100*time.Millisecond); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
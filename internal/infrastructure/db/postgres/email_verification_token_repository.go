@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+const emailVerificationTokenColumns = "id, user_id, token_hash, expires_at, created_at"
+
+type EmailVerificationTokenDTO struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (t EmailVerificationTokenDTO) toEmailVerificationToken() *entities.EmailVerificationToken {
+	return &entities.EmailVerificationToken{
+		ID:        t.ID,
+		UserID:    t.UserID,
+		TokenHash: t.TokenHash,
+		ExpiresAt: valueobject.NewTime(t.ExpiresAt),
+		CreatedAt: valueobject.NewTime(t.CreatedAt),
+	}
+}
+
+func (t *EmailVerificationTokenDTO) scanFields() []any {
+	return []any{
+		&t.ID,
+		&t.UserID,
+		&t.TokenHash,
+		&t.ExpiresAt,
+		&t.CreatedAt,
+	}
+}
+
+type PQEmailVerificationTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPQEmailVerificationTokenRepository(db *sql.DB) repositories.IEmailVerificationTokenRepository {
+	return &PQEmailVerificationTokenRepository{db}
+}
+
+func (e *PQEmailVerificationTokenRepository) Create(ctx context.Context, token *entities.EmailVerificationToken) (*entities.EmailVerificationToken, error) {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError(err, "failed to begin transaction")
+	}
+
+	var createdToken EmailVerificationTokenDTO
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO email_verification_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING "+emailVerificationTokenColumns,
+		token.UserID,
+		token.TokenHash,
+		token.ExpiresAt.ToTime(),
+	).Scan(createdToken.scanFields()...)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return nil, translateError(err, "failed to create email verification token")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, translateError(err, "failed to commit transaction")
+	}
+	return createdToken.toEmailVerificationToken(), nil
+}
+
+func (e *PQEmailVerificationTokenRepository) GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.EmailVerificationToken, error) {
+	var tokenDTO EmailVerificationTokenDTO
+	var userDTO UserDTO
+
+	query := `
+		SELECT
+			email_verification_tokens.id, email_verification_tokens.user_id, email_verification_tokens.token_hash,
+			email_verification_tokens.expires_at, email_verification_tokens.created_at,
+			` + joinedUserColumns + `
+		FROM email_verification_tokens
+		INNER JOIN users ON email_verification_tokens.user_id = users.id
+		WHERE email_verification_tokens.token_hash = $1
+	`
+	fields := append(tokenDTO.scanFields(), userDTO.scanFields()...)
+	if err := e.db.QueryRowContext(ctx, query, tokenHash).Scan(fields...); err != nil {
+		return nil, translateError(err, "failed to get email verification token")
+	}
+
+	token := tokenDTO.toEmailVerificationToken()
+	token.User = userDTO.toUser()
+
+	return token, nil
+}
+
+func (e *PQEmailVerificationTokenRepository) GetLatestForUser(ctx context.Context, userID int) (*entities.EmailVerificationToken, error) {
+	var token EmailVerificationTokenDTO
+	err := e.db.QueryRowContext(ctx,
+		"SELECT "+emailVerificationTokenColumns+" FROM email_verification_tokens WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1",
+		userID,
+	).Scan(token.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to get email verification token")
+	}
+	return token.toEmailVerificationToken(), nil
+}
+
+func (e *PQEmailVerificationTokenRepository) DeleteForUser(ctx context.Context, userID int) error {
+	_, err := e.db.ExecContext(ctx, "DELETE FROM email_verification_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		return translateError(err, "failed to delete email verification tokens")
+	}
+	return nil
+}
+
+func (e *PQEmailVerificationTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := e.db.ExecContext(ctx, "DELETE FROM email_verification_tokens WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, translateError(err, "failed to delete expired email verification tokens")
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"go-starter-template/internal/domain/repositories"
+)
+
+// translateError turns driver errors into the errors of the repositories
+// package, so the layers above never have to know about sql or pq. The
+// driver error stays wrapped for logging.
+func translateError(err error, message string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repositories.ErrNoRows
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation":
+			return fmt.Errorf("%w: %s: %w", repositories.ErrDuplicate, message, err)
+		case "foreign_key_violation":
+			// the row refers to one that does not exist (anymore)
+			return fmt.Errorf("%w: %s: %w", repositories.ErrNoRows, message, err)
+		}
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
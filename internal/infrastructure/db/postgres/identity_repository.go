@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+const identityColumns = "id, user_id, provider, subject, email, created_at, updated_at"
+
+type IdentityDTO struct {
+	ID        int
+	UserID    int
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (i IdentityDTO) toIdentity() *entities.Identity {
+	return &entities.Identity{
+		ID:        i.ID,
+		UserID:    i.UserID,
+		Provider:  i.Provider,
+		Subject:   i.Subject,
+		Email:     i.Email,
+		CreatedAt: valueobject.NewTime(i.CreatedAt),
+		UpdatedAt: valueobject.NewTime(i.UpdatedAt),
+	}
+}
+
+func (i *IdentityDTO) scanFields() []any {
+	return []any{
+		&i.ID,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	}
+}
+
+type PQIdentityRepository struct {
+	db *sql.DB
+}
+
+func NewPQIdentityRepository(db *sql.DB) repositories.IIdentityRepository {
+	return &PQIdentityRepository{db}
+}
+
+func (i *PQIdentityRepository) Create(ctx context.Context, identity *entities.Identity) (*entities.Identity, error) {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError(err, "failed to begin transaction")
+	}
+
+	var createdIdentity IdentityDTO
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4) RETURNING "+identityColumns,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	).Scan(createdIdentity.scanFields()...)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return nil, translateError(err, "failed to create identity")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, translateError(err, "failed to commit transaction")
+	}
+
+	created := createdIdentity.toIdentity()
+	created.User = identity.User
+
+	return created, nil
+}
+
+func (i *PQIdentityRepository) GetByProviderSubjectWithUser(ctx context.Context, provider, subject string) (*entities.Identity, error) {
+	var identityDTO IdentityDTO
+	var userDTO UserDTO
+
+	query := `
+		SELECT
+			identities.id, identities.user_id, identities.provider, identities.subject,
+			identities.email, identities.created_at, identities.updated_at,
+			` + joinedUserColumns + `
+		FROM identities
+		INNER JOIN users ON identities.user_id = users.id
+		WHERE identities.provider = $1 AND identities.subject = $2
+	`
+	fields := append(identityDTO.scanFields(), userDTO.scanFields()...)
+	if err := i.db.QueryRowContext(ctx, query, provider, subject).Scan(fields...); err != nil {
+		return nil, translateError(err, "failed to get identity")
+	}
+
+	identity := identityDTO.toIdentity()
+	identity.User = userDTO.toUser()
+
+	return identity, nil
+}
+
+func (i *PQIdentityRepository) ListByUser(ctx context.Context, userID int) ([]*entities.Identity, error) {
+	rows, err := i.db.QueryContext(ctx,
+		"SELECT "+identityColumns+" FROM identities WHERE user_id = $1 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, translateError(err, "failed to get identities")
+	}
+	defer rows.Close()
+
+	identities := make([]*entities.Identity, 0)
+	for rows.Next() {
+		var identity IdentityDTO
+		if err := rows.Scan(identity.scanFields()...); err != nil {
+			return nil, translateError(err, "failed to scan identity")
+		}
+		identities = append(identities, identity.toIdentity())
+	}
+	return identities, nil
+}
+
+func (i *PQIdentityRepository) DeleteForUser(ctx context.Context, userID, identityID int) error {
+	res, err := i.db.ExecContext(ctx, "DELETE FROM identities WHERE id = $1 AND user_id = $2", identityID, userID)
+	if err != nil {
+		return translateError(err, "failed to delete identity")
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return repositories.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+const (
+	loginThrottleColumns = "scope, key, failures, last_failure_at"
+	loginLockoutColumns  = "id, scope, key, user_id, ip_address, failures, locked_until, created_at"
+)
+
+type LoginThrottleDTO struct {
+	Scope         string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+}
+
+func (t LoginThrottleDTO) toLoginThrottle() *entities.LoginThrottle {
+	return &entities.LoginThrottle{
+		Scope:         t.Scope,
+		Key:           t.Key,
+		Failures:      t.Failures,
+		LastFailureAt: valueobject.NewTime(t.LastFailureAt),
+	}
+}
+
+func (t *LoginThrottleDTO) scanFields() []any {
+	return []any{
+		&t.Scope,
+		&t.Key,
+		&t.Failures,
+		&t.LastFailureAt,
+	}
+}
+
+type LoginLockoutDTO struct {
+	ID          int
+	Scope       string
+	Key         string
+	UserID      sql.NullInt64
+	IPAddress   string
+	Failures    int
+	LockedUntil time.Time
+	CreatedAt   time.Time
+}
+
+func (l LoginLockoutDTO) toLoginLockout() *entities.LoginLockout {
+	lockout := &entities.LoginLockout{
+		ID:          l.ID,
+		Scope:       l.Scope,
+		Key:         l.Key,
+		IPAddress:   l.IPAddress,
+		Failures:    l.Failures,
+		LockedUntil: valueobject.NewTime(l.LockedUntil),
+		CreatedAt:   valueobject.NewTime(l.CreatedAt),
+	}
+	if l.UserID.Valid {
+		userID := int(l.UserID.Int64)
+		lockout.UserID = &userID
+	}
+	return lockout
+}
+
+func (l *LoginLockoutDTO) scanFields() []any {
+	return []any{
+		&l.ID,
+		&l.Scope,
+		&l.Key,
+		&l.UserID,
+		&l.IPAddress,
+		&l.Failures,
+		&l.LockedUntil,
+		&l.CreatedAt,
+	}
+}
+
+type PQLoginThrottleRepository struct {
+	db *sql.DB
+}
+
+func NewPQLoginThrottleRepository(db *sql.DB) repositories.ILoginThrottleRepository {
+	return &PQLoginThrottleRepository{db}
+}
+
+func (l *PQLoginThrottleRepository) Get(ctx context.Context, scope, key string) (*entities.LoginThrottle, error) {
+	var throttle LoginThrottleDTO
+	err := l.db.QueryRowContext(ctx,
+		"SELECT "+loginThrottleColumns+" FROM login_throttles WHERE scope = $1 AND key = $2",
+		scope,
+		key,
+	).Scan(throttle.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to get login throttle")
+	}
+	return throttle.toLoginThrottle(), nil
+}
+
+func (l *PQLoginThrottleRepository) RecordFailure(ctx context.Context, scope, key string, at time.Time, window time.Duration) (*entities.LoginThrottle, error) {
+	var throttle LoginThrottleDTO
+	err := l.db.QueryRowContext(ctx, `
+		INSERT INTO login_throttles (scope, key, failures, last_failure_at) VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < $4 THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING `+loginThrottleColumns,
+		scope,
+		key,
+		at,
+		at.Add(-window),
+	).Scan(throttle.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to record login failure")
+	}
+	return throttle.toLoginThrottle(), nil
+}
+
+func (l *PQLoginThrottleRepository) Delete(ctx context.Context, scope, key string) error {
+	_, err := l.db.ExecContext(ctx, "DELETE FROM login_throttles WHERE scope = $1 AND key = $2", scope, key)
+	if err != nil {
+		return translateError(err, "failed to delete login throttle")
+	}
+	return nil
+}
+
+func (l *PQLoginThrottleRepository) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	res, err := l.db.ExecContext(ctx, "DELETE FROM login_throttles WHERE last_failure_at < $1", before)
+	if err != nil {
+		return 0, translateError(err, "failed to delete stale login throttles")
+	}
+	return res.RowsAffected()
+}
+
+func (l *PQLoginThrottleRepository) CreateLockout(ctx context.Context, lockout *entities.LoginLockout) (*entities.LoginLockout, error) {
+	var userID sql.NullInt64
+	if lockout.UserID != nil {
+		userID = sql.NullInt64{Int64: int64(*lockout.UserID), Valid: true}
+	}
+
+	var created LoginLockoutDTO
+	err := l.db.QueryRowContext(ctx,
+		"INSERT INTO login_lockouts (scope, key, user_id, ip_address, failures, locked_until) VALUES ($1, $2, $3, $4, $5, $6) RETURNING "+loginLockoutColumns,
+		lockout.Scope,
+		lockout.Key,
+		userID,
+		lockout.IPAddress,
+		lockout.Failures,
+		lockout.LockedUntil.ToTime(),
+	).Scan(created.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to create login lockout")
+	}
+	return created.toLoginLockout(), nil
+}
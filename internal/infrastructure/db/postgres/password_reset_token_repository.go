@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+const passwordResetTokenColumns = "id, user_id, token_hash, expires_at, used_at, created_at"
+
+type PasswordResetTokenDTO struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+func (t PasswordResetTokenDTO) toPasswordResetToken() *entities.PasswordResetToken {
+	return &entities.PasswordResetToken{
+		ID:        t.ID,
+		UserID:    t.UserID,
+		TokenHash: t.TokenHash,
+		ExpiresAt: valueobject.NewTime(t.ExpiresAt),
+		UsedAt:    nullTimeToValue(t.UsedAt),
+		CreatedAt: valueobject.NewTime(t.CreatedAt),
+	}
+}
+
+func (t *PasswordResetTokenDTO) scanFields() []any {
+	return []any{
+		&t.ID,
+		&t.UserID,
+		&t.TokenHash,
+		&t.ExpiresAt,
+		&t.UsedAt,
+		&t.CreatedAt,
+	}
+}
+
+type PQPasswordResetTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPQPasswordResetTokenRepository(db *sql.DB) repositories.IPasswordResetTokenRepository {
+	return &PQPasswordResetTokenRepository{db}
+}
+
+func (p *PQPasswordResetTokenRepository) Create(ctx context.Context, token *entities.PasswordResetToken) (*entities.PasswordResetToken, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError(err, "failed to begin transaction")
+	}
+
+	var createdToken PasswordResetTokenDTO
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING "+passwordResetTokenColumns,
+		token.UserID,
+		token.TokenHash,
+		token.ExpiresAt.ToTime(),
+	).Scan(createdToken.scanFields()...)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return nil, translateError(err, "failed to create password reset token")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, translateError(err, "failed to commit transaction")
+	}
+	return createdToken.toPasswordResetToken(), nil
+}
+
+func (p *PQPasswordResetTokenRepository) GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.PasswordResetToken, error) {
+	var tokenDTO PasswordResetTokenDTO
+	var userDTO UserDTO
+
+	query := `
+		SELECT
+			password_reset_tokens.id, password_reset_tokens.user_id, password_reset_tokens.token_hash,
+			password_reset_tokens.expires_at, password_reset_tokens.used_at, password_reset_tokens.created_at,
+			` + joinedUserColumns + `
+		FROM password_reset_tokens
+		INNER JOIN users ON password_reset_tokens.user_id = users.id
+		WHERE password_reset_tokens.token_hash = $1
+	`
+	fields := append(tokenDTO.scanFields(), userDTO.scanFields()...)
+	if err := p.db.QueryRowContext(ctx, query, tokenHash).Scan(fields...); err != nil {
+		return nil, translateError(err, "failed to get password reset token")
+	}
+
+	token := tokenDTO.toPasswordResetToken()
+	token.User = userDTO.toUser()
+
+	return token, nil
+}
+
+func (p *PQPasswordResetTokenRepository) MarkUsed(ctx context.Context, token *entities.PasswordResetToken) error {
+	res, err := p.db.ExecContext(ctx,
+		"UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1 AND used_at IS NULL",
+		token.ID,
+	)
+	if err != nil {
+		return translateError(err, "failed to update password reset token")
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return repositories.ErrNoRows
+	}
+	return nil
+}
+
+func (p *PQPasswordResetTokenRepository) DeleteForUser(ctx context.Context, userID int) error {
+	_, err := p.db.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		return translateError(err, "failed to delete password reset tokens")
+	}
+	return nil
+}
+
+func (p *PQPasswordResetTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := p.db.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE expires_at <= CURRENT_TIMESTAMP OR used_at IS NOT NULL")
+	if err != nil {
+		return 0, translateError(err, "failed to delete expired password reset tokens")
+	}
+	return res.RowsAffected()
+}
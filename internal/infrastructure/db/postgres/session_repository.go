@@ -13,9 +13,14 @@ import (
 	"github.com/google/uuid"
 )
 
+const sessionColumns = "id, user_id, remember, user_agent, ip_address, expires_at, created_at, updated_at"
+
 type SessionDTO struct {
 	ID        uuid.UUID
 	UserID    int
+	Remember  bool
+	UserAgent string
+	IPAddress string
 	ExpiresAt time.Time
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -24,6 +29,10 @@ type SessionDTO struct {
 func (s SessionDTO) toSession() *entities.Session {
 	return &entities.Session{
 		ID:        s.ID,
+		Token:     s.ID.String(),
+		Remember:  s.Remember,
+		UserAgent: s.UserAgent,
+		IPAddress: s.IPAddress,
 		ExpiresAt: valueobject.NewTime(s.ExpiresAt),
 		CreatedAt: valueobject.NewTime(s.CreatedAt),
 		UpdatedAt: valueobject.NewTime(s.UpdatedAt),
@@ -31,6 +40,19 @@ func (s SessionDTO) toSession() *entities.Session {
 	}
 }
 
+func (s *SessionDTO) scanFields() []any {
+	return []any{
+		&s.ID,
+		&s.UserID,
+		&s.Remember,
+		&s.UserAgent,
+		&s.IPAddress,
+		&s.ExpiresAt,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	}
+}
+
 type PQSessionRepository struct {
 	db *sql.DB
 }
@@ -39,26 +61,38 @@ func NewPQSessionRepository(db *sql.DB) repositories.ISessionRepository {
 	return &PQSessionRepository{db}
 }
 
+// HealthCheck makes sure the sessions table can be read, a reachable
+// database missing the table would still fail every request
+func (s *PQSessionRepository) HealthCheck(ctx context.Context) error {
+	var one int
+	err := s.db.QueryRowContext(retryableRead(ctx), "SELECT 1 FROM sessions LIMIT 1").Scan(&one)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
 func (s *PQSessionRepository) Create(ctx context.Context, session *entities.Session) (*entities.Session, error) {
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, translateError(err, "failed to begin transaction")
 	}
 
 	var createdSession SessionDTO
 
+	// created_at comes from the clock of the application like the
+	// sessions_valid_after of the user it is compared with
 	err = tx.QueryRowContext(ctx,
-		"INSERT INTO sessions (id, user_id, expires_at) VALUES ($1, $2, $3) RETURNING *",
+		"INSERT INTO sessions (id, user_id, remember, user_agent, ip_address, expires_at, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING "+sessionColumns,
 		session.ID,
 		session.User.ID,
+		session.Remember,
+		session.UserAgent,
+		session.IPAddress,
 		session.ExpiresAt.ToTime(),
-	).Scan(
-		&createdSession.ID,
-		&createdSession.UserID,
-		&createdSession.ExpiresAt,
-		&createdSession.CreatedAt,
-		&createdSession.UpdatedAt,
-	)
+		session.CreatedAt.ToTime(),
+		session.UpdatedAt.ToTime(),
+	).Scan(createdSession.scanFields()...)
 
 	if err != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
@@ -68,7 +102,7 @@ func (s *PQSessionRepository) Create(ctx context.Context, session *entities.Sess
 	}
 
 	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, translateError(err, "failed to commit transaction")
 	}
 
 	newSession := createdSession.toSession()
@@ -79,15 +113,10 @@ func (s *PQSessionRepository) Create(ctx context.Context, session *entities.Sess
 
 func (s *PQSessionRepository) Get(ctx context.Context, sessionId string) (*entities.Session, error) {
 	var session SessionDTO
-	err := s.db.QueryRowContext(ctx, "SELECT * FROM sessions WHERE id = $1", sessionId).Scan(
-		&session.ID,
-		&session.ExpiresAt,
-		&session.CreatedAt,
-		&session.UpdatedAt,
-	)
+	err := s.db.QueryRowContext(retryableRead(ctx), "SELECT "+sessionColumns+" FROM sessions WHERE id = $1", sessionId).Scan(session.scanFields()...)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
+		return nil, translateError(err, "failed to get session")
 	}
 	return session.toSession(), nil
 }
@@ -98,25 +127,17 @@ func (s *PQSessionRepository) GetWithUser(ctx context.Context, sessionId string)
 
 	query := `
 		SELECT
-			sessions.id, sessions.expires_at, sessions.created_at, sessions.updated_at,
-			users.id as user_id, users.email, users.password, users.created_at, users.updated_at
+			sessions.id, sessions.user_id, sessions.remember, sessions.user_agent, sessions.ip_address,
+			sessions.expires_at, sessions.created_at, sessions.updated_at,
+			` + joinedUserColumns + `
 		FROM sessions
 		INNER JOIN users ON sessions.user_id = users.id
 		WHERE sessions.id = $1
 	`
-	err := s.db.QueryRowContext(ctx, query, sessionId).Scan(
-		&sessionDTO.ID,
-		&sessionDTO.ExpiresAt,
-		&sessionDTO.CreatedAt,
-		&sessionDTO.UpdatedAt,
-		&userDTO.ID,
-		&userDTO.Email,
-		&userDTO.Password,
-		&userDTO.CreatedAt,
-		&userDTO.UpdatedAt,
-	)
+	fields := append(sessionDTO.scanFields(), userDTO.scanFields()...)
+	err := s.db.QueryRowContext(retryableRead(ctx), query, sessionId).Scan(fields...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
+		return nil, translateError(err, "failed to get session")
 	}
 
 	session := sessionDTO.toSession()
@@ -125,10 +146,48 @@ func (s *PQSessionRepository) GetWithUser(ctx context.Context, sessionId string)
 	return session, nil
 }
 
+func (s *PQSessionRepository) ListByUser(ctx context.Context, userID int) ([]*entities.Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND expires_at > CURRENT_TIMESTAMP ORDER BY created_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, translateError(err, "failed to get sessions")
+	}
+	defer rows.Close()
+
+	sessions := make([]*entities.Session, 0)
+	for rows.Next() {
+		var session SessionDTO
+		if err := rows.Scan(session.scanFields()...); err != nil {
+			return nil, translateError(err, "failed to scan session")
+		}
+		sessions = append(sessions, session.toSession())
+	}
+	return sessions, nil
+}
+
+func (s *PQSessionRepository) Update(ctx context.Context, session *entities.Session) (*entities.Session, error) {
+	var updatedSession SessionDTO
+	err := s.db.QueryRowContext(ctx,
+		"UPDATE sessions SET expires_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING "+sessionColumns,
+		session.ExpiresAt.ToTime(),
+		session.ID,
+	).Scan(updatedSession.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to update session")
+	}
+
+	updated := updatedSession.toSession()
+	updated.AddUser(session.User)
+
+	return updated, nil
+}
+
 func (s *PQSessionRepository) Delete(ctx context.Context, session *entities.Session) error {
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return translateError(err, "failed to begin transaction")
 	}
 
 	_, err = tx.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1", session.ID)
@@ -136,12 +195,45 @@ func (s *PQSessionRepository) Delete(ctx context.Context, session *entities.Sess
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
 			return fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
 		}
-		return fmt.Errorf("failed to delete session: %w", err)
+		return translateError(err, "failed to delete session")
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return translateError(err, "failed to commit transaction")
+	}
+
+	return nil
+}
+
+func (s *PQSessionRepository) DeleteForUser(ctx context.Context, userID int, sessionId string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1 AND user_id = $2", sessionId, userID)
+	if err != nil {
+		return translateError(err, "failed to delete session")
 	}
 
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return repositories.ErrNoRows
+	}
 	return nil
 }
+
+func (s *PQSessionRepository) DeleteAllForUser(ctx context.Context, userID int, exceptSessionId string) error {
+	var err error
+	if exceptSessionId == "" {
+		_, err = s.db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1", userID)
+	} else {
+		_, err = s.db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1 AND id <> $2", userID, exceptSessionId)
+	}
+	if err != nil {
+		return translateError(err, "failed to delete sessions")
+	}
+	return nil
+}
+
+func (s *PQSessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, translateError(err, "failed to delete expired sessions")
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-starter-template/internal/domain/repositories"
+)
+
+type PQSessionRevocationRepository struct {
+	db *sql.DB
+}
+
+func NewPQSessionRevocationRepository(db *sql.DB) repositories.ISessionRevocationRepository {
+	return &PQSessionRevocationRepository{db}
+}
+
+func (r *PQSessionRevocationRepository) Revoke(ctx context.Context, sessionID uuid.UUID, userID int, until time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO revoked_sessions (session_id, user_id, expires_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		sessionID,
+		userID,
+		until,
+	)
+	if err != nil {
+		return translateError(err, "failed to revoke session")
+	}
+	return nil
+}
+
+func (r *PQSessionRevocationRepository) Revoked(ctx context.Context, sessionID uuid.UUID, userID int) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM revoked_sessions WHERE session_id = $1 AND user_id = $2)",
+		sessionID,
+		userID,
+	).Scan(&revoked)
+	if err != nil {
+		return false, translateError(err, "failed to check session revocation")
+	}
+	return revoked, nil
+}
+
+func (r *PQSessionRevocationRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM revoked_sessions WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, translateError(err, "failed to delete expired session revocations")
+	}
+	return res.RowsAffected()
+}
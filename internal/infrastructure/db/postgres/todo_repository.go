@@ -11,8 +11,11 @@ import (
 	"go-starter-template/internal/domain/valueobject"
 )
 
+const todoColumns = "id, user_id, title, description, created_at, updated_at"
+
 type TodoDTO struct {
 	ID          int
+	UserID      int
 	Title       string
 	Description string
 	CreatedAt   time.Time
@@ -22,6 +25,7 @@ type TodoDTO struct {
 func (t TodoDTO) toTodo() *entities.Todo {
 	return &entities.Todo{
 		ID:          t.ID,
+		UserID:      t.UserID,
 		Title:       t.Title,
 		Description: t.Description,
 		CreatedAt:   valueobject.NewTime(t.CreatedAt),
@@ -29,6 +33,17 @@ func (t TodoDTO) toTodo() *entities.Todo {
 	}
 }
 
+func (t *TodoDTO) scanFields() []any {
+	return []any{
+		&t.ID,
+		&t.UserID,
+		&t.Title,
+		&t.Description,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	}
+}
+
 type PQTodoRepository struct {
 	db *sql.DB
 }
@@ -37,128 +52,119 @@ func NewPQTodoRepository(db *sql.DB) repositories.ITodoRepository {
 	return &PQTodoRepository{db}
 }
 
-func (t *PQTodoRepository) List(ctx context.Context) ([]*entities.Todo, error) {
-	rows, err := t.db.QueryContext(ctx, "SELECT * FROM todos ORDER BY created_at DESC")
+func (t *PQTodoRepository) List(ctx context.Context, userID int) ([]*entities.Todo, error) {
+	rows, err := t.db.QueryContext(ctx,
+		"SELECT "+todoColumns+" FROM todos WHERE user_id = $1 ORDER BY created_at DESC",
+		userID,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get todos: %w", err)
+		return nil, translateError(err, "failed to get todos")
 	}
 	defer rows.Close()
 
 	todos := make([]*entities.Todo, 0)
 	for rows.Next() {
 		var todo TodoDTO
-		if err := rows.Scan(
-			&todo.ID,
-			&todo.Title,
-			&todo.Description,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan todo: %w", err)
+		if err := rows.Scan(todo.scanFields()...); err != nil {
+			return nil, translateError(err, "failed to scan todo")
 		}
 		todos = append(todos, todo.toTodo())
 	}
 	return todos, nil
 }
 
-func (t *PQTodoRepository) Get(ctx context.Context, id int) (*entities.Todo, error) {
-	row := t.db.QueryRowContext(ctx, "SELECT * FROM todos WHERE id = $1", id)
+func (t *PQTodoRepository) Get(ctx context.Context, userID int, id int) (*entities.Todo, error) {
+	row := t.db.QueryRowContext(ctx,
+		"SELECT "+todoColumns+" FROM todos WHERE id = $1 AND user_id = $2",
+		id,
+		userID,
+	)
 
 	var todo TodoDTO
-	err := row.Scan(
-		&todo.ID,
-		&todo.Title,
-		&todo.Description,
-		&todo.CreatedAt,
-		&todo.UpdatedAt,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan todo: %w", err)
+	if err := row.Scan(todo.scanFields()...); err != nil {
+		return nil, translateError(err, "failed to scan todo")
 	}
 	return todo.toTodo(), nil
 }
 
 func (t *PQTodoRepository) Create(ctx context.Context, todo *entities.Todo) (*entities.Todo, error) {
-	tx, err := t.db.Begin()
+	tx, err := t.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, translateError(err, "failed to begin transaction")
 	}
 
 	var createdTodo TodoDTO
 	err = tx.QueryRowContext(ctx,
-		"INSERT INTO todos (title, description) VALUES ($1, $2) RETURNING *",
+		"INSERT INTO todos (user_id, title, description) VALUES ($1, $2, $3) RETURNING "+todoColumns,
+		todo.UserID,
 		todo.Title,
 		todo.Description,
-	).Scan(
-		&createdTodo.ID,
-		&createdTodo.Title,
-		&createdTodo.Description,
-		&createdTodo.CreatedAt,
-		&createdTodo.UpdatedAt,
-	)
+	).Scan(createdTodo.scanFields()...)
 
 	if err != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
 			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
 		}
-		return nil, fmt.Errorf("failed to create todo: %w", err)
+		return nil, translateError(err, "failed to create todo")
 	}
 
 	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, translateError(err, "failed to commit transaction")
 	}
 	return createdTodo.toTodo(), nil
 }
 
 func (t *PQTodoRepository) Update(ctx context.Context, todo *entities.Todo) (*entities.Todo, error) {
-	tx, err := t.db.Begin()
+	tx, err := t.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, translateError(err, "failed to begin transaction")
 	}
 
 	var updatedTodo TodoDTO
 	err = tx.QueryRowContext(ctx,
-		"UPDATE todos SET title = $1, description = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3 RETURNING *",
+		"UPDATE todos SET title = $1, description = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3 AND user_id = $4 RETURNING "+todoColumns,
 		todo.Title,
 		todo.Description,
 		todo.ID,
-	).Scan(
-		&updatedTodo.ID,
-		&updatedTodo.Title,
-		&updatedTodo.Description,
-		&updatedTodo.CreatedAt,
-		&updatedTodo.UpdatedAt,
-	)
+		todo.UserID,
+	).Scan(updatedTodo.scanFields()...)
 
 	if err != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
 			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
 		}
-		return nil, fmt.Errorf("failed to update todo: %w", err)
+		return nil, translateError(err, "failed to update todo")
 	}
 
 	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, translateError(err, "failed to commit transaction")
 	}
 	return updatedTodo.toTodo(), nil
 }
 
 func (t *PQTodoRepository) Delete(ctx context.Context, todo *entities.Todo) error {
-	tx, err := t.db.Begin()
+	tx, err := t.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return translateError(err, "failed to begin transaction")
 	}
 
-	_, err = tx.ExecContext(ctx, "DELETE FROM todos WHERE id = $1", todo.ID)
+	res, err := tx.ExecContext(ctx, "DELETE FROM todos WHERE id = $1 AND user_id = $2", todo.ID, todo.UserID)
 	if err != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
 			return fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
 		}
-		return fmt.Errorf("failed to delete todo: %w", err)
+		return translateError(err, "failed to delete todo")
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("failed to rollback transaction: %w", rollbackErr)
+		}
+		return repositories.ErrNoRows
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return translateError(err, "failed to commit transaction")
 	}
 	return nil
 }
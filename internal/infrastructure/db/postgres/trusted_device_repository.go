@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+const trustedDeviceColumns = "id, user_id, token_hash, user_agent, expires_at, created_at"
+
+type TrustedDeviceDTO struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	UserAgent string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (d TrustedDeviceDTO) toTrustedDevice() *entities.TrustedDevice {
+	return &entities.TrustedDevice{
+		ID:        d.ID,
+		UserID:    d.UserID,
+		TokenHash: d.TokenHash,
+		UserAgent: d.UserAgent,
+		ExpiresAt: valueobject.NewTime(d.ExpiresAt),
+		CreatedAt: valueobject.NewTime(d.CreatedAt),
+	}
+}
+
+func (d *TrustedDeviceDTO) scanFields() []any {
+	return []any{
+		&d.ID,
+		&d.UserID,
+		&d.TokenHash,
+		&d.UserAgent,
+		&d.ExpiresAt,
+		&d.CreatedAt,
+	}
+}
+
+type PQTrustedDeviceRepository struct {
+	db *sql.DB
+}
+
+func NewPQTrustedDeviceRepository(db *sql.DB) repositories.ITrustedDeviceRepository {
+	return &PQTrustedDeviceRepository{db}
+}
+
+func (t *PQTrustedDeviceRepository) Create(ctx context.Context, device *entities.TrustedDevice) (*entities.TrustedDevice, error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError(err, "failed to begin transaction")
+	}
+
+	var createdDevice TrustedDeviceDTO
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO trusted_devices (user_id, token_hash, user_agent, expires_at) VALUES ($1, $2, $3, $4) RETURNING "+trustedDeviceColumns,
+		device.UserID,
+		device.TokenHash,
+		device.UserAgent,
+		device.ExpiresAt.ToTime(),
+	).Scan(createdDevice.scanFields()...)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return nil, translateError(err, "failed to create trusted device")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, translateError(err, "failed to commit transaction")
+	}
+	return createdDevice.toTrustedDevice(), nil
+}
+
+func (t *PQTrustedDeviceRepository) GetByHash(ctx context.Context, userID int, tokenHash string) (*entities.TrustedDevice, error) {
+	var device TrustedDeviceDTO
+	err := t.db.QueryRowContext(ctx,
+		"SELECT "+trustedDeviceColumns+" FROM trusted_devices WHERE user_id = $1 AND token_hash = $2",
+		userID,
+		tokenHash,
+	).Scan(device.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to get trusted device")
+	}
+	return device.toTrustedDevice(), nil
+}
+
+func (t *PQTrustedDeviceRepository) DeleteForUser(ctx context.Context, userID int) error {
+	_, err := t.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE user_id = $1", userID)
+	if err != nil {
+		return translateError(err, "failed to delete trusted devices")
+	}
+	return nil
+}
+
+func (t *PQTrustedDeviceRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := t.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, translateError(err, "failed to delete expired trusted devices")
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+const twoFactorChallengeColumns = "id, user_id, token_hash, remember, attempts, expires_at, created_at"
+
+type TwoFactorChallengeDTO struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	Remember  bool
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (c TwoFactorChallengeDTO) toTwoFactorChallenge() *entities.TwoFactorChallenge {
+	return &entities.TwoFactorChallenge{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		TokenHash: c.TokenHash,
+		Remember:  c.Remember,
+		Attempts:  c.Attempts,
+		ExpiresAt: valueobject.NewTime(c.ExpiresAt),
+		CreatedAt: valueobject.NewTime(c.CreatedAt),
+	}
+}
+
+func (c *TwoFactorChallengeDTO) scanFields() []any {
+	return []any{
+		&c.ID,
+		&c.UserID,
+		&c.TokenHash,
+		&c.Remember,
+		&c.Attempts,
+		&c.ExpiresAt,
+		&c.CreatedAt,
+	}
+}
+
+type PQTwoFactorChallengeRepository struct {
+	db *sql.DB
+}
+
+func NewPQTwoFactorChallengeRepository(db *sql.DB) repositories.ITwoFactorChallengeRepository {
+	return &PQTwoFactorChallengeRepository{db}
+}
+
+func (t *PQTwoFactorChallengeRepository) Create(ctx context.Context, challenge *entities.TwoFactorChallenge) (*entities.TwoFactorChallenge, error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError(err, "failed to begin transaction")
+	}
+
+	var createdChallenge TwoFactorChallengeDTO
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO two_factor_challenges (user_id, token_hash, remember, expires_at) VALUES ($1, $2, $3, $4) RETURNING "+twoFactorChallengeColumns,
+		challenge.UserID,
+		challenge.TokenHash,
+		challenge.Remember,
+		challenge.ExpiresAt.ToTime(),
+	).Scan(createdChallenge.scanFields()...)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return nil, translateError(err, "failed to create two factor challenge")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, translateError(err, "failed to commit transaction")
+	}
+
+	created := createdChallenge.toTwoFactorChallenge()
+	created.User = challenge.User
+
+	return created, nil
+}
+
+func (t *PQTwoFactorChallengeRepository) GetByHashWithUser(ctx context.Context, tokenHash string) (*entities.TwoFactorChallenge, error) {
+	var challengeDTO TwoFactorChallengeDTO
+	var userDTO UserDTO
+
+	query := `
+		SELECT
+			two_factor_challenges.id, two_factor_challenges.user_id, two_factor_challenges.token_hash,
+			two_factor_challenges.remember, two_factor_challenges.attempts,
+			two_factor_challenges.expires_at, two_factor_challenges.created_at,
+			` + joinedUserColumns + `
+		FROM two_factor_challenges
+		INNER JOIN users ON two_factor_challenges.user_id = users.id
+		WHERE two_factor_challenges.token_hash = $1
+	`
+	fields := append(challengeDTO.scanFields(), userDTO.scanFields()...)
+	if err := t.db.QueryRowContext(ctx, query, tokenHash).Scan(fields...); err != nil {
+		return nil, translateError(err, "failed to get two factor challenge")
+	}
+
+	challenge := challengeDTO.toTwoFactorChallenge()
+	challenge.User = userDTO.toUser()
+
+	return challenge, nil
+}
+
+func (t *PQTwoFactorChallengeRepository) RecordAttempt(ctx context.Context, challenge *entities.TwoFactorChallenge) (int, error) {
+	var attempts int
+	err := t.db.QueryRowContext(ctx,
+		"UPDATE two_factor_challenges SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts",
+		challenge.ID,
+	).Scan(&attempts)
+	if err != nil {
+		return 0, translateError(err, "failed to update two factor challenge")
+	}
+	return attempts, nil
+}
+
+func (t *PQTwoFactorChallengeRepository) Delete(ctx context.Context, challenge *entities.TwoFactorChallenge) error {
+	res, err := t.db.ExecContext(ctx, "DELETE FROM two_factor_challenges WHERE id = $1", challenge.ID)
+	if err != nil {
+		return translateError(err, "failed to delete two factor challenge")
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return repositories.ErrNoRows
+	}
+	return nil
+}
+
+func (t *PQTwoFactorChallengeRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := t.db.ExecContext(ctx, "DELETE FROM two_factor_challenges WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, translateError(err, "failed to delete expired two factor challenges")
+	}
+	return res.RowsAffected()
+}
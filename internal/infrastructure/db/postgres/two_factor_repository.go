@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+)
+
+const twoFactorColumns = "user_id, secret, last_used_step, confirmed_at, created_at, updated_at"
+
+type TwoFactorDTO struct {
+	UserID       int
+	Secret       string
+	LastUsedStep int64
+	ConfirmedAt  sql.NullTime
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (t TwoFactorDTO) toTwoFactor() *entities.TwoFactor {
+	return &entities.TwoFactor{
+		UserID:       t.UserID,
+		Secret:       t.Secret,
+		LastUsedStep: t.LastUsedStep,
+		ConfirmedAt:  nullTimeToValue(t.ConfirmedAt),
+		CreatedAt:    valueobject.NewTime(t.CreatedAt),
+		UpdatedAt:    valueobject.NewTime(t.UpdatedAt),
+	}
+}
+
+func (t *TwoFactorDTO) scanFields() []any {
+	return []any{
+		&t.UserID,
+		&t.Secret,
+		&t.LastUsedStep,
+		&t.ConfirmedAt,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	}
+}
+
+type PQTwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewPQTwoFactorRepository(db *sql.DB) repositories.ITwoFactorRepository {
+	return &PQTwoFactorRepository{db}
+}
+
+func (t *PQTwoFactorRepository) Get(ctx context.Context, userID int) (*entities.TwoFactor, error) {
+	var twoFactor TwoFactorDTO
+	err := t.db.QueryRowContext(ctx,
+		"SELECT "+twoFactorColumns+" FROM user_two_factor WHERE user_id = $1",
+		userID,
+	).Scan(twoFactor.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to get two factor")
+	}
+	return twoFactor.toTwoFactor(), nil
+}
+
+func (t *PQTwoFactorRepository) Save(ctx context.Context, twoFactor *entities.TwoFactor) (*entities.TwoFactor, error) {
+	var saved TwoFactorDTO
+	err := t.db.QueryRowContext(ctx,
+		`INSERT INTO user_two_factor (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			last_used_step = 0,
+			created_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_two_factor.confirmed_at IS NULL
+		RETURNING `+twoFactorColumns,
+		twoFactor.UserID,
+		twoFactor.Secret,
+	).Scan(saved.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to save two factor")
+	}
+	return saved.toTwoFactor(), nil
+}
+
+func (t *PQTwoFactorRepository) Confirm(ctx context.Context, twoFactor *entities.TwoFactor, recoveryCodeHashes []string) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err, "failed to begin transaction")
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE user_two_factor SET confirmed_at = $1, last_used_step = $2, updated_at = CURRENT_TIMESTAMP WHERE user_id = $3 AND confirmed_at IS NULL",
+		valueToNullTime(twoFactor.ConfirmedAt),
+		twoFactor.LastUsedStep,
+		twoFactor.UserID,
+	)
+	if err == nil {
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			err = repositories.ErrNoRows
+		}
+	}
+	if err == nil {
+		err = replaceRecoveryCodes(ctx, tx, twoFactor.UserID, recoveryCodeHashes)
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		if err == repositories.ErrNoRows {
+			return err
+		}
+		return translateError(err, "failed to confirm two factor")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return translateError(err, "failed to commit transaction")
+	}
+	return nil
+}
+
+func (t *PQTwoFactorRepository) MarkStepUsed(ctx context.Context, userID int, step int64) error {
+	res, err := t.db.ExecContext(ctx,
+		"UPDATE user_two_factor SET last_used_step = $1, updated_at = CURRENT_TIMESTAMP WHERE user_id = $2 AND last_used_step < $1",
+		step,
+		userID,
+	)
+	if err != nil {
+		return translateError(err, "failed to update two factor")
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return repositories.ErrNoRows
+	}
+	return nil
+}
+
+func (t *PQTwoFactorRepository) Delete(ctx context.Context, userID int) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err, "failed to begin transaction")
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM two_factor_recovery_codes WHERE user_id = $1", userID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "DELETE FROM user_two_factor WHERE user_id = $1", userID)
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return translateError(err, "failed to delete two factor")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return translateError(err, "failed to commit transaction")
+	}
+	return nil
+}
+
+func (t *PQTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err, "failed to begin transaction")
+	}
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
+		}
+		return translateError(err, "failed to replace recovery codes")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return translateError(err, "failed to commit transaction")
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, recoveryCodeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM two_factor_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO two_factor_recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userID,
+			codeHash,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *PQTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	res, err := t.db.ExecContext(ctx,
+		"UPDATE two_factor_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userID,
+		codeHash,
+	)
+	if err != nil {
+		return translateError(err, "failed to update recovery code")
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return repositories.ErrNoRows
+	}
+	return nil
+}
+
+func (t *PQTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := t.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM two_factor_recovery_codes WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, translateError(err, "failed to count recovery codes")
+	}
+	return count, nil
+}
@@ -4,30 +4,64 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"go-starter-template/internal/domain/entities"
 	"go-starter-template/internal/domain/repositories"
 	"go-starter-template/internal/domain/valueobject"
 )
 
+const userColumns = "id, email, password, role, verified_at, disabled_at, created_at, updated_at, sessions_valid_after, sessions_kept_id"
+
+// joinedUserColumns are the userColumns for queries joining the users table
+const joinedUserColumns = "users.id, users.email, users.password, users.role, users.verified_at, users.disabled_at, users.created_at, users.updated_at, users.sessions_valid_after, users.sessions_kept_id"
+
 type UserDTO struct {
-	ID        int
-	Email     string
-	Password  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID         int
+	Email      string
+	Password   string
+	Role       string
+	VerifiedAt sql.NullTime
+	DisabledAt sql.NullTime
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+
+	SessionsValidAfter sql.NullTime
+	SessionsKeptID     uuid.NullUUID
 }
 
 func (u UserDTO) toUser() *entities.User {
 	email, _ := valueobject.NewEmail(u.Email)
-	password, _ := valueobject.NewPassword(u.Password)
 	return &entities.User{
-		ID:        int(u.ID),
-		Email:     email,
-		Password:  password,
-		CreatedAt: valueobject.NewTime(u.CreatedAt),
-		UpdatedAt: valueobject.NewTime(u.UpdatedAt),
+		ID:         int(u.ID),
+		Email:      email,
+		Password:   valueobject.NewPasswordHash(u.Password),
+		Role:       entities.Role(u.Role),
+		VerifiedAt: nullTimeToValue(u.VerifiedAt),
+		DisabledAt: nullTimeToValue(u.DisabledAt),
+		CreatedAt:  valueobject.NewTime(u.CreatedAt),
+		UpdatedAt:  valueobject.NewTime(u.UpdatedAt),
+
+		SessionsValidAfter: nullTimeToValue(u.SessionsValidAfter),
+		KeptSessionID:      u.SessionsKeptID.UUID,
+	}
+}
+
+func (u *UserDTO) scanFields() []any {
+	return []any{
+		&u.ID,
+		&u.Email,
+		&u.Password,
+		&u.Role,
+		&u.VerifiedAt,
+		&u.DisabledAt,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+		&u.SessionsValidAfter,
+		&u.SessionsKeptID,
 	}
 }
 
@@ -40,51 +74,160 @@ func NewPQUserRepository(db *sql.DB) repositories.IUserRepository {
 }
 
 func (s *PQUserRepository) Create(ctx context.Context, user *entities.User) (*entities.User, error) {
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, translateError(err, "failed to begin transaction")
 	}
 
 	var userDTO UserDTO
 	err = tx.QueryRowContext(ctx,
-		"INSERT INTO users (email, password) VALUES ($1, $2) RETURNING *",
-		user.Email.ToString(), user.Password.ToString(),
-	).Scan(
-		&userDTO.ID,
-		&userDTO.Email,
-		&userDTO.Password,
-		&userDTO.CreatedAt,
-		&userDTO.UpdatedAt,
-	)
+		"INSERT INTO users (email, password, role, verified_at) VALUES ($1, $2, $3, $4) RETURNING "+userColumns,
+		user.Email.ToString(), user.Password.ToString(), string(user.Role), valueToNullTime(user.VerifiedAt),
+	).Scan(userDTO.scanFields()...)
 
 	if err != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
 			return nil, fmt.Errorf("failed to rollback transaction: %w; original error: %w", rollbackErr, err)
 		}
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, translateError(err, "failed to create user")
 	}
 
 	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, translateError(err, "failed to commit transaction")
 	}
 
 	return userDTO.toUser(), nil
 }
 
+func (s *PQUserRepository) Get(ctx context.Context, id int) (*entities.User, error) {
+	var user UserDTO
+	err := s.db.QueryRowContext(retryableRead(ctx), "SELECT "+userColumns+" FROM users WHERE id = $1", id).Scan(user.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to get user")
+	}
+	return user.toUser(), nil
+}
+
 func (s *PQUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
 	var user UserDTO
-	err := s.db.QueryRowContext(ctx, "SELECT * FROM users WHERE email = $1", email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
+	err := s.db.QueryRowContext(retryableRead(ctx), "SELECT "+userColumns+" FROM users WHERE email = $1", email).Scan(user.scanFields()...)
+	if err != nil {
+		return nil, translateError(err, "failed to get user")
+	}
+	return user.toUser(), nil
+}
+
+func (s *PQUserRepository) UpdatePassword(ctx context.Context, user *entities.User, previousHash string) error {
+	return s.update(ctx, "failed to update password",
+		"UPDATE users SET password = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND password = $3",
+		user.Password.ToString(), user.ID, previousHash,
+	)
+}
+
+func (s *PQUserRepository) UpdateEmail(ctx context.Context, user *entities.User) error {
+	return s.update(ctx, "failed to update email",
+		"UPDATE users SET email = $1, verified_at = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3",
+		user.Email.ToString(), valueToNullTime(user.VerifiedAt), user.ID,
 	)
+}
+
+func (s *PQUserRepository) UpdateVerified(ctx context.Context, user *entities.User) error {
+	return s.update(ctx, "failed to update verification",
+		"UPDATE users SET verified_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		valueToNullTime(user.VerifiedAt), user.ID,
+	)
+}
+
+func (s *PQUserRepository) UpdateRole(ctx context.Context, user *entities.User) error {
+	return s.update(ctx, "failed to update role",
+		"UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		string(user.Role), user.ID,
+	)
+}
+
+func (s *PQUserRepository) UpdateDisabled(ctx context.Context, user *entities.User) error {
+	return s.update(ctx, "failed to update disabled state",
+		"UPDATE users SET disabled_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		valueToNullTime(user.DisabledAt), user.ID,
+	)
+}
+
+// RevokeSessions only writes the session cutoff, so it never races with the
+// updates of the other fields
+func (s *PQUserRepository) RevokeSessions(ctx context.Context, user *entities.User) error {
+	keptID := uuid.NullUUID{UUID: user.KeptSessionID, Valid: user.KeptSessionID != uuid.Nil}
+	return s.update(ctx, "failed to revoke sessions",
+		"UPDATE users SET sessions_valid_after = $1, sessions_kept_id = $2 WHERE id = $3",
+		valueToNullTime(user.SessionsValidAfter), keptID, user.ID,
+	)
+}
+
+// update runs a statement changing a single user, ErrNoRows means no row
+// matched
+func (s *PQUserRepository) update(ctx context.Context, message, statement string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, statement, args...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, repositories.ErrNoRows
+		return translateError(err, message)
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return repositories.ErrNoRows
+	}
+	return nil
+}
+
+// Delete relies on the foreign keys of the other tables to cascade, the
+// sessions, todos, tokens and identities of the user go with it
+func (s *PQUserRepository) Delete(ctx context.Context, user *entities.User) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", user.ID)
+	if err != nil {
+		return translateError(err, "failed to delete user")
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return repositories.ErrNoRows
+	}
+	return nil
+}
+
+// List pages through the users ordered by id, search matches part of the
+// email address
+func (s *PQUserRepository) List(ctx context.Context, search string, limit, offset int) ([]*entities.User, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+userColumns+" FROM users WHERE email ILIKE '%' || $1 || '%' ORDER BY id LIMIT $2 OFFSET $3",
+		escapeLike(search),
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, translateError(err, "failed to get users")
+	}
+	defer rows.Close()
+
+	users := make([]*entities.User, 0)
+	for rows.Next() {
+		var user UserDTO
+		if err := rows.Scan(user.scanFields()...); err != nil {
+			return nil, translateError(err, "failed to scan user")
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		users = append(users, user.toUser())
 	}
-	return user.toUser(), nil
+	return users, nil
+}
+
+func (s *PQUserRepository) Count(ctx context.Context, search string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM users WHERE email ILIKE '%' || $1 || '%'",
+		escapeLike(search),
+	).Scan(&count)
+	if err != nil {
+		return 0, translateError(err, "failed to count users")
+	}
+	return count, nil
+}
+
+// escapeLike makes the LIKE wildcards in s match literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
@@ -0,0 +1,27 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/internal/infrastructure/db/postgres"
+	"go-starter-template/pkg/security"
+)
+
+func NewAccountServiceWithPQRepository(
+	db *sql.DB,
+	sessionService services.ISessionService,
+	emailVerificationService services.IEmailVerificationService,
+	passwordHasher security.IPasswordHasher,
+	passwordPolicy valueobject.PasswordPolicy,
+) services.IAccountService {
+	return services.NewAccountService(
+		postgres.NewPQUserRepository(db),
+		postgres.NewPQPasswordResetTokenRepository(db),
+		passwordHasher,
+		passwordPolicy,
+		sessionService,
+		emailVerificationService,
+	)
+}
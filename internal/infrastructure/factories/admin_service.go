@@ -0,0 +1,20 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/infrastructure/db/postgres"
+)
+
+func NewAdminServiceWithPQRepository(
+	db *sql.DB,
+	authorizationService services.IAuthorizationService,
+	sessionService services.ISessionService,
+) services.IAdminService {
+	return services.NewAdminService(
+		postgres.NewPQUserRepository(db),
+		authorizationService,
+		sessionService,
+	)
+}
@@ -0,0 +1,12 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/infrastructure/db/postgres"
+)
+
+func NewAPITokenServiceWithPQRepository(db *sql.DB) services.IAPITokenService {
+	return services.NewAPITokenService(postgres.NewPQAPITokenRepository(db))
+}
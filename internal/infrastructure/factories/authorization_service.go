@@ -0,0 +1,12 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/infrastructure/db/postgres"
+)
+
+func NewAuthorizationServiceWithPQRepository(db *sql.DB) services.IAuthorizationService {
+	return services.NewAuthorizationService(postgres.NewPQUserRepository(db))
+}
@@ -0,0 +1,27 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/db/postgres"
+	"go-starter-template/pkg/mailer"
+)
+
+func NewEmailVerificationServiceWithPQRepository(db *sql.DB, conf *config.Config, mail mailer.IMailer) services.IEmailVerificationService {
+	mode := conf.AccountConfig.EmailVerification
+	return services.NewEmailVerificationService(
+		postgres.NewPQEmailVerificationTokenRepository(db),
+		postgres.NewPQUserRepository(db),
+		mail,
+		services.EmailVerificationOptions{
+			TokenTTL:         conf.AccountConfig.EmailVerificationTTL,
+			ResendInterval:   conf.AccountConfig.VerificationResendInterval,
+			VerifyURL:        conf.AppURL + "/verify",
+			MailFrom:         conf.MailConfig.From,
+			BlockLogin:       mode == config.EmailVerificationBlock,
+			RestrictFeatures: mode != config.EmailVerificationOptional,
+		},
+	)
+}
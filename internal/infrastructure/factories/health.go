@@ -0,0 +1,47 @@
+package factories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/pkg/health"
+	"go-starter-template/pkg/mailer"
+)
+
+// NewHealthRegistry registers the readiness checks of everything a request
+// may need. Session stores and mailers without a HealthCheck live in the
+// process and cannot fail on their own.
+func NewHealthRegistry(conf *config.Config, db *sql.DB, sessionRepository repositories.ISessionRepository, mail mailer.IMailer) (*health.Registry, error) {
+	registry := health.NewRegistry(conf.HealthConfig.CacheTTL)
+	timeout := conf.HealthConfig.CheckTimeout
+
+	registry.Register("database", timeout, db.PingContext)
+
+	if checker, ok := sessionRepository.(health.Checker); ok {
+		registry.Register("session_store", timeout, checker.HealthCheck)
+	}
+
+	if checker, ok := mail.(health.Checker); ok {
+		registry.Register("mailer", timeout, checker.HealthCheck)
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	registry.Register("migrations", timeout, func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d migrations pending, the oldest is %s", len(pending), pending[0].File)
+		}
+		return nil
+	})
+
+	return registry, nil
+}
@@ -0,0 +1,47 @@
+package factories
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/db/postgres"
+	"go-starter-template/internal/infrastructure/identity"
+	"go-starter-template/pkg/oidc"
+	"go-starter-template/pkg/security"
+	"go-starter-template/pkg/tracing"
+)
+
+func NewIdentityServiceWithPQRepository(
+	db *sql.DB,
+	conf *config.Config,
+	sessionService services.ISessionService,
+	emailVerificationService services.IEmailVerificationService,
+	twoFactorService services.ITwoFactorService,
+	passwordHasher security.IPasswordHasher,
+) services.IIdentityService {
+	// calls to the providers are traced like requests to the app
+	httpClient := &http.Client{Timeout: 10 * time.Second, Transport: tracing.NewTransport(nil)}
+	providers := make([]services.IIdentityProvider, 0, len(conf.IdentityConfig.Providers))
+	for _, provider := range conf.IdentityConfig.Providers {
+		providers = append(providers, identity.NewOIDCProvider(provider.Name, provider.DisplayName, oidc.Config{
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  conf.AppURL + "/auth/" + provider.Name + "/callback",
+			Scopes:       provider.Scopes,
+		}, httpClient))
+	}
+
+	return services.NewIdentityService(
+		postgres.NewPQIdentityRepository(db),
+		postgres.NewPQUserRepository(db),
+		passwordHasher,
+		sessionService,
+		emailVerificationService,
+		twoFactorService,
+		providers,
+	)
+}
@@ -0,0 +1,22 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/db/postgres"
+)
+
+func NewLoginThrottleServiceWithPQRepository(db *sql.DB, conf *config.Config) services.ILoginThrottleService {
+	return services.NewLoginThrottleService(postgres.NewPQLoginThrottleRepository(db), entities.LoginThrottlePolicy{
+		MaxAccountFailures: conf.LoginConfig.MaxAccountFailures,
+		MaxIPFailures:      conf.LoginConfig.MaxIPFailures,
+		FreeFailures:       conf.LoginConfig.FreeFailures,
+		BaseDelay:          conf.LoginConfig.BackoffBase,
+		MaxDelay:           conf.LoginConfig.BackoffMax,
+		LockoutDuration:    conf.LoginConfig.LockoutDuration,
+		Window:             conf.LoginConfig.FailureWindow,
+	})
+}
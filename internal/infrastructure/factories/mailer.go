@@ -0,0 +1,15 @@
+package factories
+
+import (
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/mailer"
+)
+
+// NewMailer returns the mailer selected by MAILER
+func NewMailer(conf *config.Config, log *logger.Logger) (mailer.IMailer, error) {
+	if conf.MailConfig.Driver == config.MailerFile {
+		return mailer.NewFileMailer(conf.MailConfig.FileDir)
+	}
+	return mailer.NewLogMailer(log), nil
+}
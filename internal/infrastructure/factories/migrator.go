@@ -0,0 +1,13 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/migrations"
+	"go-starter-template/pkg/migrate"
+)
+
+// NewMigrator returns a migrator for the migrations embedded in the binary
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	return migrate.New(db, migrations.FS)
+}
@@ -0,0 +1,25 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/internal/infrastructure/db/postgres"
+	"go-starter-template/pkg/security"
+)
+
+func NewOperatorServiceWithPQRepository(
+	db *sql.DB,
+	sessionService services.ISessionService,
+	passwordHasher security.IPasswordHasher,
+	passwordPolicy valueobject.PasswordPolicy,
+) services.IOperatorService {
+	return services.NewOperatorService(
+		postgres.NewPQUserRepository(db),
+		postgres.NewPQPasswordResetTokenRepository(db),
+		sessionService,
+		passwordHasher,
+		passwordPolicy,
+	)
+}
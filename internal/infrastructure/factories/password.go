@@ -0,0 +1,52 @@
+package factories
+
+import (
+	"fmt"
+	"os"
+
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/security"
+)
+
+func NewPasswordHasher(conf *config.Config, log *logger.Logger) security.IPasswordHasher {
+	passwordConfig := conf.PasswordConfig
+	if passwordConfig.Hasher == config.PasswordHasherArgon2id {
+		params := security.DefaultArgon2idParams()
+		params.Memory = uint32(passwordConfig.Argon2Memory)
+		params.Iterations = uint32(passwordConfig.Argon2Iterations)
+		params.Parallelism = uint8(passwordConfig.Argon2Parallelism)
+		return security.NewArgon2idPasswordHasher(params, log)
+	}
+	return security.NewBcryptPasswordHasher(passwordConfig.BcryptCost, log)
+}
+
+// NewPasswordPolicy reads the breached password list into memory, so the
+// file is only needed at startup
+func NewPasswordPolicy(conf *config.Config) (valueobject.PasswordPolicy, error) {
+	passwordConfig := conf.PasswordConfig
+	policy := valueobject.PasswordPolicy{
+		MinLength:     passwordConfig.MinLength,
+		MaxLength:     passwordConfig.MaxLength,
+		RequireUpper:  passwordConfig.RequireUpper,
+		RequireLower:  passwordConfig.RequireLower,
+		RequireDigit:  passwordConfig.RequireDigit,
+		RequireSymbol: passwordConfig.RequireSymbol,
+	}
+
+	if passwordConfig.BreachedListFile == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(passwordConfig.BreachedListFile)
+	if err != nil {
+		return policy, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	if policy.Breached, err = valueobject.ReadBreachedPasswords(file); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
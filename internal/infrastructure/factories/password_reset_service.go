@@ -0,0 +1,35 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/db/postgres"
+	"go-starter-template/pkg/mailer"
+	"go-starter-template/pkg/security"
+)
+
+func NewPasswordResetServiceWithPQRepository(
+	db *sql.DB,
+	conf *config.Config,
+	sessionService services.ISessionService,
+	mail mailer.IMailer,
+	passwordHasher security.IPasswordHasher,
+	passwordPolicy valueobject.PasswordPolicy,
+) services.IPasswordResetService {
+	return services.NewPasswordResetService(
+		postgres.NewPQPasswordResetTokenRepository(db),
+		postgres.NewPQUserRepository(db),
+		sessionService,
+		passwordHasher,
+		passwordPolicy,
+		mail,
+		services.PasswordResetOptions{
+			TokenTTL: conf.AccountConfig.PasswordResetTTL,
+			ResetURL: conf.AppURL + "/reset-password",
+			MailFrom: conf.MailConfig.From,
+		},
+	)
+}
@@ -4,9 +4,50 @@ import (
 	"database/sql"
 
 	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/db/cookie"
+	"go-starter-template/internal/infrastructure/db/memory"
 	"go-starter-template/internal/infrastructure/db/postgres"
 )
 
-func NewSessionServiceWithPQRepository(db *sql.DB) services.ISessionService {
-	return services.NewSessionService(postgres.NewPQSessionRepository(db))
+// NewSessionService builds the session service on top of the store returned
+// by NewSessionRepository
+func NewSessionService(db *sql.DB, sessionRepository repositories.ISessionRepository, conf *config.Config) services.ISessionService {
+	return services.NewSessionService(sessionRepository, postgres.NewPQUserRepository(db), newSessionPolicy(conf))
+}
+
+// NewSessionRepository returns the store selected by SESSION_STORE. The memory
+// store lives in the returned repository, so create it once and share it
+// between everything that needs sessions.
+func NewSessionRepository(db *sql.DB, conf *config.Config) repositories.ISessionRepository {
+	sessionConf := conf.SessionConfig
+
+	switch sessionConf.Store {
+	case config.SessionStoreMemory:
+		return memory.NewMemorySessionRepository()
+	case config.SessionStoreCookie:
+		maxAge := sessionConf.AbsoluteTimeout
+		if maxAge <= 0 {
+			maxAge = sessionConf.RememberIdleTimeout
+		}
+		return cookie.NewCookieSessionRepository(
+			[]byte(sessionConf.CookieHashKey),
+			[]byte(sessionConf.CookieBlockKey),
+			maxAge,
+			postgres.NewPQSessionRevocationRepository(db),
+		)
+	default:
+		return postgres.NewPQSessionRepository(db)
+	}
+}
+
+func newSessionPolicy(conf *config.Config) entities.SessionPolicy {
+	return entities.SessionPolicy{
+		IdleTimeout:         conf.SessionConfig.IdleTimeout,
+		RememberIdleTimeout: conf.SessionConfig.RememberIdleTimeout,
+		AbsoluteTimeout:     conf.SessionConfig.AbsoluteTimeout,
+		UserRefreshInterval: conf.SessionConfig.UserRefreshInterval,
+	}
 }
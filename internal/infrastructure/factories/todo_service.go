@@ -7,6 +7,6 @@ import (
 	"go-starter-template/internal/infrastructure/db/postgres"
 )
 
-func NewTodoServiceWithPQRepository(db *sql.DB) services.ITodoService {
-	return services.NewTodoService(postgres.NewPQTodoRepository(db))
+func NewTodoServiceWithPQRepository(db *sql.DB, authorizationService services.IAuthorizationService) services.ITodoService {
+	return services.NewTodoService(postgres.NewPQTodoRepository(db), authorizationService)
 }
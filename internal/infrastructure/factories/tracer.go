@@ -0,0 +1,31 @@
+package factories
+
+import (
+	"os"
+
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/tracing"
+)
+
+// NewTracer returns a tracer exporting to the exporter selected by
+// TRACING_EXPORTER, with none spans still carry trace ids but are not recorded
+func NewTracer(conf *config.Config, log *logger.Logger) *tracing.Tracer {
+	var exporter tracing.Exporter
+	switch conf.TracingConfig.Exporter {
+	case config.TracingExporterStdout:
+		exporter = tracing.NewStdoutExporter(os.Stdout)
+	case config.TracingExporterOTLP:
+		exporter = tracing.NewOTLPExporter(conf.TracingConfig.OTLPEndpoint, conf.TracingConfig.OTLPHeaders)
+	}
+
+	return tracing.New(tracing.Options{
+		ServiceName:    conf.TracingConfig.ServiceName,
+		ServiceVersion: conf.Version,
+		Exporter:       exporter,
+		SampleRatio:    conf.TracingConfig.SampleRatio,
+		OnError: func(err error) {
+			log.Error("failed to export spans", "exporter", conf.TracingConfig.Exporter, "error", err)
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package factories
+
+import (
+	"database/sql"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/db/postgres"
+	"go-starter-template/pkg/security"
+)
+
+func NewTwoFactorServiceWithPQRepository(db *sql.DB, conf *config.Config) (services.ITwoFactorService, error) {
+	cipher, err := security.NewAESGCMCipher([]byte(conf.TwoFactorConfig.EncryptionKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return services.NewTwoFactorService(
+		postgres.NewPQTwoFactorRepository(db),
+		postgres.NewPQTwoFactorChallengeRepository(db),
+		postgres.NewPQTrustedDeviceRepository(db),
+		cipher,
+		services.TwoFactorOptions{
+			Issuer:           conf.TwoFactorConfig.Issuer,
+			ChallengeTTL:     conf.TwoFactorConfig.ChallengeTTL,
+			TrustedDeviceTTL: conf.TwoFactorConfig.TrustedDeviceTTL,
+		},
+	), nil
+}
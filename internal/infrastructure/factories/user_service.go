@@ -4,15 +4,27 @@ import (
 	"database/sql"
 
 	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/valueobject"
 	"go-starter-template/internal/infrastructure/db/postgres"
-	"go-starter-template/pkg/logger"
 	"go-starter-template/pkg/security"
 )
 
-func NewUserServiceWithPQRepository(db *sql.DB, log *logger.Logger) services.IUserService {
+func NewUserServiceWithPQRepository(
+	db *sql.DB,
+	sessionService services.ISessionService,
+	loginThrottleService services.ILoginThrottleService,
+	emailVerificationService services.IEmailVerificationService,
+	twoFactorService services.ITwoFactorService,
+	passwordHasher security.IPasswordHasher,
+	passwordPolicy valueobject.PasswordPolicy,
+) (services.IUserService, error) {
 	return services.NewUserService(
 		postgres.NewPQUserRepository(db),
-		security.NewBcryptPasswordHasher(log),
-		services.NewSessionService(postgres.NewPQSessionRepository(db)),
+		passwordHasher,
+		passwordPolicy,
+		sessionService,
+		loginThrottleService,
+		emailVerificationService,
+		twoFactorService,
 	)
 }
@@ -0,0 +1,54 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/pkg/oidc"
+)
+
+// OIDCProvider adapts an OpenID Connect client to services.IIdentityProvider
+type OIDCProvider struct {
+	name        string
+	displayName string
+	client      *oidc.Client
+}
+
+func NewOIDCProvider(name, displayName string, config oidc.Config, httpClient *http.Client) services.IIdentityProvider {
+	return &OIDCProvider{
+		name:        name,
+		displayName: displayName,
+		client:      oidc.NewClient(config, httpClient),
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+func (p *OIDCProvider) DisplayName() string {
+	return p.displayName
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return p.client.AuthCodeURL(ctx, state, nonce, verifier)
+}
+
+func (p *OIDCProvider) Authenticate(ctx context.Context, code, verifier, nonce string) (*services.ExternalIdentity, error) {
+	token, err := p.client.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := p.client.VerifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &services.ExternalIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+	}, nil
+}
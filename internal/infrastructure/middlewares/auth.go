@@ -2,43 +2,55 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"go-starter-template/internal/application/result"
 	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
 	"go-starter-template/internal/httputil"
+	"go-starter-template/pkg/router"
 )
 
 type contextKey string
 
 const (
-	userContextKey contextKey = "user"
+	userContextKey    contextKey = "user"
+	sessionContextKey contextKey = "session_id"
 )
 
+// AuthMiddleware authenticates browsers through the session cookie, those
+// without a valid session are sent to the login page
 func AuthMiddleware(env string, sessionService services.ISessionService) MiddlewareFunc {
+	return sessionAuth(env, sessionService, unauthorized)
+}
+
+func sessionAuth(env string, sessionService services.ISessionService, unauthorized http.HandlerFunc) MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 
 			cookie, err := r.Cookie("session_id")
 			if err != nil {
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				unauthorized(w, r)
 				return
 			}
 
-			result, err := sessionService.GetSession(ctx, cookie.Value)
+			result, err := sessionService.TouchSession(ctx, cookie.Value)
 			if err != nil {
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				if errors.Is(err, entities.ErrSessionExpired) || errors.Is(err, entities.ErrSessionRevoked) || errors.Is(err, entities.ErrUserDisabled) {
+					httputil.RemoveSessionCookie(w, env)
+				}
+				unauthorized(w, r)
 				return
 			}
 
-			if result.Session.Expired() {
-				sessionService.DeleteSession(ctx, cookie.Value)
-				httputil.RemoveSessionCookie(w, env)
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
-				return
+			if result.Renewed {
+				httputil.SetSessionCookie(w, result.Session, env)
 			}
 
-			ctx = context.WithValue(ctx, userContextKey, result.Session.User)
+			ctx = withUser(ctx, result.Session.User)
+			ctx = context.WithValue(ctx, sessionContextKey, result.Session.ID.String())
 			r = r.WithContext(ctx)
 
 			next.ServeHTTP(w, r)
@@ -46,6 +58,69 @@ func AuthMiddleware(env string, sessionService services.ISessionService) Middlew
 	}
 }
 
-func GetUser(ctx context.Context) any {
-	return ctx.Value(userContextKey)
+// APIAuthMiddleware authenticates machine clients through an
+// "Authorization: Bearer <token>" header and falls back to the session cookie
+// for browser clients. A request carrying a bearer token is never
+// authenticated by its cookie. Failures are always a 401 problem document,
+// whatever the Accept header asks for.
+func APIAuthMiddleware(env string, sessionService services.ISessionService, apiTokenService services.IAPITokenService) MiddlewareFunc {
+	sessionAuth := sessionAuth(env, sessionService, apiUnauthorized)
+
+	return func(next http.Handler) http.Handler {
+		cookieAuthenticated := sessionAuth(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := httputil.BearerToken(r)
+			if !ok {
+				cookieAuthenticated.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := apiTokenService.Authenticate(r.Context(), token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				httputil.WriteJSONError(w, http.StatusUnauthorized, "invalid or expired API token")
+				return
+			}
+
+			ctx := withUser(r.Context(), result.User)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetUser returns the user the request was authenticated as, nil for
+// anonymous requests
+func GetUser(ctx context.Context) *result.UserResult {
+	user, _ := ctx.Value(userContextKey).(*result.UserResult)
+	return user
+}
+
+func withUser(ctx context.Context, user *result.UserResult) context.Context {
+	if user != nil {
+		recordUser(ctx, user.ID)
+	}
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// GetSessionID returns the id of the session that authenticated the request,
+// empty when it was authenticated some other way
+func GetSessionID(ctx context.Context) string {
+	id, _ := ctx.Value(sessionContextKey).(string)
+	return id
+}
+
+// unauthorized redirects browsers to the login page while clients asking
+// for JSON get a 401 error they can act on
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	if router.Negotiate(r, "text/html", "application/json") == "application/json" {
+		apiUnauthorized(w, r)
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func apiUnauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	httputil.WriteJSONError(w, http.StatusUnauthorized, "authentication required")
 }
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-starter-template/internal/httputil"
+)
+
+func TestUnauthenticated(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the handler ran for an anonymous request")
+	})
+	// requests without a cookie or a token never reach the services
+	cookieAuth := AuthMiddleware("production", nil)(next)
+	apiAuth := APIAuthMiddleware("production", nil, nil)(next)
+
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		accept      string
+		status      int
+		contentType string
+	}{
+		{name: "browser", handler: cookieAuth, accept: "text/html", status: http.StatusSeeOther},
+		{name: "browser without Accept", handler: cookieAuth, status: http.StatusSeeOther},
+		{name: "browser asking for JSON", handler: cookieAuth, accept: "application/json", status: http.StatusUnauthorized, contentType: httputil.ProblemContentType},
+		{name: "API without Accept", handler: apiAuth, status: http.StatusUnauthorized, contentType: httputil.ProblemContentType},
+		{name: "API accepting anything", handler: apiAuth, accept: "*/*", status: http.StatusUnauthorized, contentType: httputil.ProblemContentType},
+		{name: "API asking for HTML", handler: apiAuth, accept: "text/html", status: http.StatusUnauthorized, contentType: httputil.ProblemContentType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/todos", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusUnauthorized {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, "Bearer")
+			}
+		})
+	}
+}
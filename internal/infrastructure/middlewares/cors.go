@@ -1,16 +1,12 @@
 package middlewares
 
-import (
-	"net/http"
-	"strings"
-)
+import "net/http"
 
-func EnableCors(allowedOrigins string) MiddlewareFunc {
+func EnableCors(allowedOrigins []string) MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			origins := strings.Split(allowedOrigins, ",")
 			origin := r.Header.Get("Origin")
-			for _, o := range origins {
+			for _, o := range allowedOrigins {
 				if origin == o {
 					w.Header().Add("Access-Control-Allow-Origin", origin)
 					break
@@ -3,12 +3,32 @@ package middlewares
 import (
 	"net/http"
 
+	"go-starter-template/internal/domain/domainerror"
+	"go-starter-template/internal/httputil"
+
 	"github.com/gorilla/csrf"
 )
 
+var errCSRFTokenInvalid = domainerror.Forbidden("Your form has expired, please reload the page and try again")
+
 func CSRFMiddleware(csrfAuthKey string) MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
-		c := csrf.Protect([]byte(csrfAuthKey))
-		return c(next)
+		c := csrf.Protect([]byte(csrfAuthKey), csrf.ErrorHandler(http.HandlerFunc(csrfFailed)))
+		protected := c(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// bearer tokens are not sent by browsers automatically so requests
+			// carrying one can't be forged cross site
+			if _, ok := httputil.BearerToken(r); ok {
+				r = csrf.UnsafeSkipCheck(r)
+			}
+			protected.ServeHTTP(w, r)
+		})
 	}
 }
+
+// csrfFailed answers JSON clients with a 403 problem document instead of the
+// plain text of gorilla/csrf
+func csrfFailed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, errCSRFTokenInvalid)
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-starter-template/internal/httputil"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := CSRFMiddleware("0123456789abcdef0123456789abcdef")(next)
+
+	tests := []struct {
+		name          string
+		accept        string
+		authorization string
+		status        int
+		contentType   string
+	}{
+		{name: "browser", accept: "text/html", status: http.StatusForbidden, contentType: "text/plain; charset=utf-8"},
+		{name: "JSON client", accept: "application/json", status: http.StatusForbidden, contentType: httputil.ProblemContentType},
+		{name: "bearer token", accept: "application/json", authorization: "Bearer token", status: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/todos", nil)
+			req.Header.Set("Accept", tt.accept)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.contentType == "" {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if tt.contentType != httputil.ProblemContentType {
+				return
+			}
+			var problem httputil.Problem
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if problem.Status != http.StatusForbidden || problem.Code != "forbidden" {
+				t.Errorf("problem = %+v, want a 403 forbidden", problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"mime"
+	"net/http"
+
+	"go-starter-template/internal/httputil"
+	"go-starter-template/pkg/router"
+)
+
+const jsonContentType = "application/json"
+
+// JSONOnly rejects requests that can't accept a JSON response with 406 and
+// requests carrying a non JSON body with 415
+func JSONOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if router.Negotiate(r, jsonContentType) == "" {
+			httputil.WriteJSONError(w, http.StatusNotAcceptable, "this endpoint only produces application/json")
+			return
+		}
+
+		if r.ContentLength != 0 {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != jsonContentType {
+				httputil.WriteJSONError(w, http.StatusUnsupportedMediaType, "request body must be application/json")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
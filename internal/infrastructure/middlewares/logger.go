@@ -1,14 +1,19 @@
 package middlewares
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"go-starter-template/internal/httputil"
 	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/router"
 )
 
+const accessLogContextKey contextKey = "access_log"
+
 type CustomResponseWriter struct {
 	http.ResponseWriter
 	statusCode   int
@@ -16,7 +21,9 @@ type CustomResponseWriter struct {
 }
 
 func (crw *CustomResponseWriter) WriteHeader(code int) {
-	crw.statusCode = code
+	if crw.statusCode == 0 {
+		crw.statusCode = code
+	}
 	crw.ResponseWriter.WriteHeader(code)
 }
 
@@ -29,46 +36,72 @@ func (crw *CustomResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Status returns the status sent to the client, handlers that never write
+// answer with 200
+func (crw *CustomResponseWriter) Status() int {
+	if crw.statusCode == 0 {
+		return http.StatusOK
+	}
+	return crw.statusCode
+}
+
+// Unwrap lets http.ResponseController reach the writer of the server
+func (crw *CustomResponseWriter) Unwrap() http.ResponseWriter {
+	return crw.ResponseWriter
+}
+
+// accessLog collects what inner handlers learn about the request, the auth
+// middlewares run further down and put the user into a context the access
+// log never sees
+type accessLog struct {
+	userID int
+}
+
+// Logger writes one record per request once it has been served, with the
+// route pattern rather than the path so requests of a route can be grouped.
+// It has to run after RequestID to log the request id.
 func Logger(next http.Handler) http.Handler {
-	log := logger.NewLogger()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasPrefix(r.URL.Path, "/web/") {
 			next.ServeHTTP(w, r)
 			return
 		}
+
 		start := time.Now()
+		entry := &accessLog{}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogContextKey, entry))
 		crw := &CustomResponseWriter{ResponseWriter: w}
 		next.ServeHTTP(crw, r)
-		duration := time.Since(start)
 
-		scheme := "http"
-		if r.TLS != nil {
-			scheme = "https"
-		}
+		status := crw.Status()
 
-		url := fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
-		if r.URL.RawQuery != "" {
-			url = fmt.Sprintf("%s?%s", url, r.URL.RawQuery)
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", router.RoutePattern(r)),
+			slog.Int("status", status),
+			slog.Int64("bytes", crw.bytesWritten),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", httputil.ClientIP(r)),
+		}
+		if entry.userID != 0 {
+			attrs = append(attrs, slog.Int("user_id", entry.userID))
 		}
 
-		logMessage := fmt.Sprintf(
-			"\"%s %s %s\" from %s - %d %dB completed in %s",
-			r.Method,
-			url,
-			r.Proto,
-			r.RemoteAddr,
-			crw.statusCode,
-			crw.bytesWritten,
-			duration,
-		)
-
+		level := slog.LevelInfo
 		switch {
-		case crw.statusCode >= 400:
-			log.Error(logMessage)
-		case crw.statusCode >= 300:
-			log.Info(logMessage)
-		default:
-			log.Success(logMessage)
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
 		}
+		logger.FromContext(r.Context()).LogAttrs(r.Context(), level, "request completed", attrs...)
 	})
 }
+
+// recordUser notes the authenticated user for the access log
+func recordUser(ctx context.Context, userID int) {
+	if entry, ok := ctx.Value(accessLogContextKey).(*accessLog); ok {
+		entry.userID = userID
+	}
+}
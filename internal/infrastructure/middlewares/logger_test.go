@@ -0,0 +1,123 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/router"
+)
+
+// newLoggedMux serves the routes behind RequestID and Logger as the app does
+// and writes the records as JSON to buf
+func newLoggedMux(buf *bytes.Buffer) *router.NetServerMux {
+	log := logger.New(buf, logger.Options{Level: slog.LevelDebug, Format: logger.FormatJSON})
+	mux := router.NewNetServerMux()
+	mux.Use(RequestID(log), Logger)
+	return mux
+}
+
+// accessRecords decodes the "request completed" records
+func accessRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("record %q is not JSON: %v", line, err)
+		}
+		if record["msg"] == "request completed" {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		level  string
+		route  string
+		status int
+		bytes  int
+		userID int
+	}{
+		{name: "created", path: "/todos/42", level: "INFO", route: "/todos/{id}", status: http.StatusCreated, bytes: 7, userID: 7},
+		{name: "no write", path: "/health", level: "INFO", route: "/health", status: http.StatusOK},
+		{name: "not found", path: "/missing", level: "WARN", status: http.StatusNotFound, bytes: 19},
+		{name: "failed", path: "/fail", level: "ERROR", route: "/fail", status: http.StatusInternalServerError, bytes: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			mux := newLoggedMux(&buf)
+			mux.Post("/todos/{id}", func(w http.ResponseWriter, r *http.Request) {
+				recordUser(r.Context(), 7)
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, "created")
+			})
+			mux.Post("/health", func(w http.ResponseWriter, r *http.Request) {})
+			mux.Post("/fail", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.WriteHeader(http.StatusOK)
+				io.WriteString(w, "oops\n")
+			})
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path+"?secret=1", nil))
+
+			records := accessRecords(t, &buf)
+			if len(records) != 1 {
+				t.Fatalf("wrote %d access records, want 1", len(records))
+			}
+			record := records[0]
+			want := map[string]any{
+				"level":      tt.level,
+				"method":     http.MethodPost,
+				"path":       tt.path,
+				"route":      tt.route,
+				"status":     float64(tt.status),
+				"bytes":      float64(tt.bytes),
+				"ip":         "192.0.2.1",
+				"request_id": rec.Header().Get(RequestIDHeader),
+			}
+			for key, value := range want {
+				if record[key] != value {
+					t.Errorf("%s = %v, want %v", key, record[key], value)
+				}
+			}
+			if latency, ok := record["latency"].(float64); !ok || latency < 0 {
+				t.Errorf("latency = %v, want a duration", record["latency"])
+			}
+			userID, ok := record["user_id"]
+			if tt.userID == 0 && ok {
+				t.Errorf("user_id = %v for an anonymous request", userID)
+			} else if tt.userID != 0 && userID != float64(tt.userID) {
+				t.Errorf("user_id = %v, want %d", userID, tt.userID)
+			}
+		})
+	}
+}
+
+// the static files are not worth a record each
+func TestAccessLogSkipsStaticFiles(t *testing.T) {
+	var buf bytes.Buffer
+	mux := newLoggedMux(&buf)
+	mux.Get("/web/{file...}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "body{}")
+	})
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/web/app.css", nil))
+
+	if buf.Len() != 0 {
+		t.Errorf("logged %q for a static file", buf.String())
+	}
+}
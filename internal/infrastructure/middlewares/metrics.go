@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-starter-template/pkg/metrics"
+	"go-starter-template/pkg/router"
+)
+
+var (
+	httpRequestsTotal = metrics.MustRegister(metrics.NewCounter(
+		"http_requests_total",
+		"HTTP requests by method, route pattern and status.",
+		"method", "route", "status",
+	))
+	httpRequestDuration = metrics.MustRegister(metrics.NewHistogram(
+		"http_request_duration_seconds",
+		"HTTP request latencies by method, route pattern and status.",
+		metrics.DefBuckets,
+		"method", "route", "status",
+	))
+)
+
+// Metrics counts and times requests. They are labelled by the route pattern
+// instead of the path and unknown methods are grouped, so clients cannot
+// create new series by making up URLs.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		crw := &CustomResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(crw, r)
+
+		route := router.RoutePattern(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		labels := []string{metricsMethod(r.Method), route, strconv.Itoa(crw.Status())}
+		httpRequestsTotal.Inc(labels...)
+		httpRequestDuration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
+
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/httputil"
+	"go-starter-template/pkg/router"
+)
+
+// RequirePermission lets the request through when the current role of the
+// user grants permission, the user in the context is replaced by the fresh
+// copy. It has to run after one of the auth middlewares.
+func RequirePermission(authorizationService services.IAuthorizationService, permission entities.Permission) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUser(r.Context())
+			if user == nil {
+				unauthorized(w, r)
+				return
+			}
+
+			res, err := authorizationService.Authorize(r.Context(), user.ID, permission)
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r.WithContext(withUser(r.Context(), res.User)))
+			case errors.Is(err, entities.ErrUserDisabled):
+				unauthorized(w, r)
+			case errors.Is(err, entities.ErrPermissionDenied):
+				forbidden(w, r)
+			default:
+				writeError(w, r, err)
+			}
+		})
+	}
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, entities.ErrPermissionDenied)
+}
+
+// writeError answers with the status matching err, the controllers render
+// richer error pages but the middlewares cannot depend on the views
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if router.Negotiate(r, "text/html", "application/json") == "application/json" {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+	http.Error(w, httputil.ErrorMessage(err), httputil.ErrorStatus(err))
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/security"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDContextKey contextKey = "request_id"
+	maxRequestIDLength             = 128
+)
+
+// RequestID gives every request an id, reusing a well formed X-Request-ID set
+// by a proxy in front of the app. The id is sent back in the same header and
+// added to the logger of the request context, see logger.FromContext.
+func RequestID(log *logger.Logger) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				generated, err := security.GenerateToken("", 12)
+				if err != nil {
+					log.ErrorContext(r.Context(), "failed to generate request id", "error", err)
+				}
+				id = generated
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+			ctx = logger.NewContext(ctx, log.With("request_id", id))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// validRequestID keeps ids from clients short and free of characters that
+// could forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
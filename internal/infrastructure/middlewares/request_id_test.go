@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-starter-template/pkg/logger"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "from the proxy", header: "req-4bf92f35_77b3.4da6", keep: true},
+		{name: "longest allowed", header: strings.Repeat("a", maxRequestIDLength), keep: true},
+		{name: "missing", header: ""},
+		{name: "too long", header: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "forged log line", header: "abc\nlevel=ERROR msg=forged"},
+		{name: "spaces", header: "abc def"},
+		{name: "quotes", header: `abc"`},
+		{name: "non ascii", header: "abcä"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			mux := newLoggedMux(&buf)
+			var fromContext string
+			mux.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				fromContext = GetRequestID(r.Context())
+				logger.FromContext(r.Context()).Info("handled")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, tt.header)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if tt.keep && id != tt.header {
+				t.Errorf("request id = %q, want %q from the header", id, tt.header)
+			}
+			if !tt.keep && (id == tt.header || !validRequestID(id)) {
+				t.Errorf("request id = %q, want a generated one", id)
+			}
+			if fromContext != id {
+				t.Errorf("GetRequestID() = %q, want %q", fromContext, id)
+			}
+
+			// every record of the request carries the id
+			records := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(records) != 2 {
+				t.Fatalf("wrote %d records, want the handler and the access record", len(records))
+			}
+			for _, record := range records {
+				if !strings.Contains(record, `"request_id":"`+id+`"`) {
+					t.Errorf("record %s lacks the request id %q", record, id)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratedRequestIDsDiffer(t *testing.T) {
+	var buf bytes.Buffer
+	mux := newLoggedMux(&buf)
+	mux.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		id := rec.Header().Get(RequestIDHeader)
+		if seen[id] {
+			t.Fatalf("request id %q generated twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/router"
+	"go-starter-template/pkg/tracing"
+)
+
+// Tracing starts the server span of the request, continuing the trace of a
+// caller that sent a traceparent header. The span is named after the route
+// pattern once it is known and the trace id is added to the logger of the
+// request, so it has to run after RequestID.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/web/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.StartServer(ctx, r.Method,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("url.path", r.URL.Path),
+			tracing.String("user_agent.original", r.UserAgent()),
+			tracing.String("http.request.id", GetRequestID(ctx)),
+		)
+		defer span.End()
+
+		log := logger.FromContext(ctx).With("trace_id", span.SpanContext().TraceID.String())
+		r = r.WithContext(logger.NewContext(ctx, log))
+		crw := &CustomResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(crw, r)
+
+		status := crw.Status()
+		if route := router.RoutePattern(r); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(tracing.String("http.route", route))
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		// client errors are the client's fault, only 5xx fail the span
+		if status >= 500 {
+			span.SetStatus(tracing.StatusError, http.StatusText(status))
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package middlewares
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/infrastructure/db/postgres"
+	"go-starter-template/pkg/router"
+	"go-starter-template/pkg/tracing"
+)
+
+// todoConnector stands in for PostgreSQL, the todos query returns no rows or
+// fails with err
+type todoConnector struct {
+	err error
+}
+
+func (c *todoConnector) Connect(context.Context) (driver.Conn, error) {
+	return &todoConn{err: c.err}, nil
+}
+func (c *todoConnector) Driver() driver.Driver { return nil }
+
+type todoConn struct {
+	err error
+}
+
+func (c *todoConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *todoConn) Close() error                        { return nil }
+func (c *todoConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *todoConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, "FROM todos") {
+		return nil, errors.New("unexpected query " + query)
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return []string{"id", "user_id", "title", "description", "created_at", "updated_at"}
+}
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func TestTracingSpanTree(t *testing.T) {
+	const (
+		callerTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+		callerSpan  = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name     string
+		queryErr error
+		status   int
+		want     tracing.StatusCode
+	}{
+		{name: "ok", status: http.StatusOK, want: tracing.StatusUnset},
+		{name: "failed query", queryErr: errors.New("connection reset by peer"), status: http.StatusInternalServerError, want: tracing.StatusError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a ratio of 0 records nothing of its own, the spans below are
+			// recorded because the caller sampled the trace
+			exporter := tracing.NewInMemoryExporter()
+			tracer := tracing.New(tracing.Options{ServiceName: "test", Exporter: exporter})
+			previous := tracing.Default()
+			tracing.SetDefault(tracer)
+			t.Cleanup(func() {
+				tracing.SetDefault(previous)
+				tracer.Shutdown(context.Background())
+			})
+
+			db := sql.OpenDB(tracing.WrapConnector(&todoConnector{err: tt.queryErr}, "postgresql"))
+			defer db.Close()
+			todoService := services.NewTodoService(postgres.NewPQTodoRepository(db), nil)
+
+			mux := router.NewNetServerMux()
+			mux.Use(Tracing)
+			mux.Get("/api/todos", func(w http.ResponseWriter, r *http.Request) {
+				if _, err := todoService.ListTodos(r.Context(), 1); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			})
+
+			req := httptest.NewRequest("GET", "/api/todos", nil)
+			req.Header.Set(tracing.TraceparentHeader, "00-"+callerTrace+"-"+callerSpan+"-01")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			if err := tracer.ForceFlush(context.Background()); err != nil {
+				t.Fatalf("ForceFlush() error = %v", err)
+			}
+			spans := make(map[string]tracing.SpanData)
+			for _, span := range exporter.Spans() {
+				spans[span.Name] = span
+			}
+			if len(spans) != 3 {
+				t.Fatalf("exported %d spans, want the request, the service and the query", len(spans))
+			}
+
+			tree := []struct {
+				name   string
+				parent string
+				kind   tracing.SpanKind
+			}{
+				{name: "GET /api/todos", parent: callerSpan, kind: tracing.SpanKindServer},
+				{name: "TodoService.ListTodos", parent: "GET /api/todos", kind: tracing.SpanKindInternal},
+				{name: "SELECT", parent: "TodoService.ListTodos", kind: tracing.SpanKindClient},
+			}
+			for _, node := range tree {
+				span, ok := spans[node.name]
+				if !ok {
+					t.Fatalf("no span %q in %v", node.name, spans)
+				}
+				parent := node.parent
+				if parentSpan, ok := spans[node.parent]; ok {
+					parent = parentSpan.SpanContext.SpanID.String()
+				}
+				if span.SpanContext.TraceID.String() != callerTrace {
+					t.Errorf("%s is in trace %s, want the trace of the caller", node.name, span.SpanContext.TraceID)
+				}
+				if span.ParentSpanID.String() != parent {
+					t.Errorf("parent of %s = %s, want %s", node.name, span.ParentSpanID, node.parent)
+				}
+				if span.Kind != node.kind {
+					t.Errorf("kind of %s = %s, want %s", node.name, span.Kind, node.kind)
+				}
+			}
+
+			server := spans["GET /api/todos"]
+			if server.Status.Code != tt.want {
+				t.Errorf("request status = %s, want %s", server.Status.Code, tt.want)
+			}
+			if query := spans["SELECT"]; query.Status.Code != tt.want {
+				t.Errorf("query status = %s, want %s", query.Status.Code, tt.want)
+			}
+			for _, attr := range server.Attributes {
+				if attr.Key == "http.route" && attr.Value != "/api/todos" {
+					t.Errorf("http.route = %v, want the pattern", attr.Value)
+				}
+			}
+		})
+	}
+}
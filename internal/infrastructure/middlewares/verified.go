@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/url"
+
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/httputil"
+	"go-starter-template/pkg/router"
+)
+
+// RequireVerifiedEmail keeps unverified users away from the routes it wraps
+// when email verification restricts features. It has to run after one of the
+// auth middlewares.
+func RequireVerifiedEmail(emailVerificationService services.IEmailVerificationService) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUser(r.Context())
+			if !emailVerificationService.RestrictsFeatures() || (user != nil && user.Verified) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the session may hold a copy of the user from before verifying
+			if user != nil {
+				if verified, err := emailVerificationService.IsVerified(r.Context(), user.ID); err == nil && verified {
+					refreshed := *user
+					refreshed.Verified = true
+					ctx := withUser(r.Context(), &refreshed)
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+			}
+
+			if router.Negotiate(r, "text/html", "application/json") == "application/json" {
+				httputil.WriteJSONError(w, http.StatusForbidden, "email address is not verified")
+				return
+			}
+
+			target := "/verify/pending"
+			if user != nil {
+				target += "?email=" + url.QueryEscape(user.Email)
+			}
+			http.Redirect(w, r, target, http.StatusSeeOther)
+		})
+	}
+}
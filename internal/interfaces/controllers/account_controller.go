@@ -0,0 +1,413 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/domain/repositories"
+	"go-starter-template/internal/domain/valueobject"
+	"go-starter-template/internal/httputil"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/middlewares"
+	"go-starter-template/internal/infrastructure/views/components"
+	"go-starter-template/internal/infrastructure/views/pages"
+	"go-starter-template/pkg/csrf"
+	"go-starter-template/pkg/qrcode"
+	"go-starter-template/pkg/router"
+)
+
+// identityLinkMessages turns the identity_error codes set by the identity
+// callback back into messages
+var identityLinkMessages = map[string]string{
+	"already-linked":   entities.ErrIdentityAlreadyLinked.Error(),
+	"linked-elsewhere": entities.ErrIdentityLinkedElsewhere.Error(),
+	"failed":           entities.ErrIdentityLoginFailed.Error(),
+}
+
+type AccountController struct {
+	accountService   services.IAccountService
+	sessionService   services.ISessionService
+	twoFactorService services.ITwoFactorService
+	identityService  services.IIdentityService
+	config           *config.Config
+}
+
+func NewAccountController(
+	r router.Router,
+	accountService services.IAccountService,
+	sessionService services.ISessionService,
+	twoFactorService services.ITwoFactorService,
+	identityService services.IIdentityService,
+	config *config.Config,
+) {
+	controller := &AccountController{
+		accountService:   accountService,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+		identityService:  identityService,
+		config:           config,
+	}
+
+	r.Route("/account", func(r router.Router) {
+		r.Use(middlewares.AuthMiddleware(config.Env, sessionService))
+
+		r.Get("/", controller.Index)
+		r.Post("/email", controller.ChangeEmail)
+		r.Post("/password", controller.ChangePassword)
+		r.Post("/delete", controller.DeleteAccount)
+		r.Delete("/sessions/{id}", controller.RevokeSession)
+		r.Post("/sessions/revoke-others", controller.RevokeOtherSessions)
+		r.Get("/two-factor", controller.TwoFactorView)
+		r.Post("/two-factor/confirm", controller.ConfirmTwoFactor)
+		r.Post("/two-factor/recovery-codes", controller.RegenerateRecoveryCodes)
+		r.Post("/two-factor/disable", controller.DisableTwoFactor)
+		r.Delete("/identities/{id}", controller.UnlinkIdentity)
+	})
+}
+
+func (ac *AccountController) Index(w http.ResponseWriter, r *http.Request) {
+	data := pages.AccountPageData{
+		CSRF:       csrf.GetCSRFField(r),
+		Sessions:   ac.sessionList(r),
+		Identities: ac.identityList(r),
+	}
+	if message, ok := identityLinkMessages[r.URL.Query().Get("identity_error")]; ok {
+		data.Identities.Error = message
+	}
+	data.User = middlewares.GetUser(r.Context())
+	data.PasswordForm = components.NewAccountPasswordFormData(r)
+	data.DeleteForm = components.NewAccountDeleteFormData(r)
+	data.EmailForm = components.NewAccountEmailFormData(r, "")
+	if data.User != nil {
+		data.EmailForm.Email = data.User.Email
+	}
+
+	// the page still works without the status, the section falls back to
+	// offering the setup
+	if res, err := ac.twoFactorService.GetStatus(r.Context(), currentUserID(r)); err == nil {
+		data.TwoFactor = res.Status
+	}
+
+	status := 200
+	if data.Sessions.Error != "" {
+		status = 500
+	}
+	w.WriteHeader(status)
+	pages.Account(data).Render(r.Context(), w)
+}
+
+func (ac *AccountController) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	form := components.NewAccountEmailFormData(r, strings.TrimSpace(r.FormValue("email")))
+
+	res, err := ac.accountService.ChangeEmail(r.Context(), &command.ChangeEmailCommand{
+		UserID:           currentUserID(r),
+		CurrentSessionID: middlewares.GetSessionID(r.Context()),
+		Password:         r.FormValue("password"),
+		Email:            form.Email,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, valueobject.ErrEmailIsRequired), errors.Is(err, valueobject.ErrEmailIsInvalid),
+			errors.Is(err, entities.ErrEmailUnchanged), errors.Is(err, entities.ErrUserAlreadyExists):
+			form.Error.Email = err.Error()
+			w.WriteHeader(400)
+		case errors.Is(err, entities.ErrPasswordIncorrect):
+			form.Error.Password = err.Error()
+			w.WriteHeader(400)
+		default:
+			form.FormError = "Something went wrong, please try again"
+			w.WriteHeader(500)
+		}
+		components.AccountEmailForm(form).Render(r.Context(), w)
+		return
+	}
+
+	form.Success = "We sent a verification link to " + res.User.Email + "."
+	w.WriteHeader(200)
+	components.AccountEmailForm(form).Render(r.Context(), w)
+}
+
+func (ac *AccountController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	form := components.NewAccountPasswordFormData(r)
+
+	err := ac.accountService.ChangePassword(r.Context(), &command.ChangePasswordCommand{
+		UserID:           currentUserID(r),
+		CurrentSessionID: middlewares.GetSessionID(r.Context()),
+		CurrentPassword:  r.FormValue("current_password"),
+		NewPassword:      strings.TrimSpace(r.FormValue("new_password")),
+	})
+	if err != nil {
+		var pve *valueobject.PasswordValidationError
+		switch {
+		case errors.As(err, &pve):
+			form.Error.NewPassword = pve.Errors
+			w.WriteHeader(400)
+		case errors.Is(err, entities.ErrPasswordIncorrect):
+			form.Error.CurrentPassword = err.Error()
+			w.WriteHeader(400)
+		default:
+			form.FormError = "Something went wrong, please try again"
+			w.WriteHeader(500)
+		}
+		components.AccountPasswordForm(form).Render(r.Context(), w)
+		return
+	}
+
+	form.Success = "Your password was changed."
+	w.WriteHeader(200)
+	components.AccountPasswordForm(form).Render(r.Context(), w)
+}
+
+func (ac *AccountController) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	form := components.NewAccountDeleteFormData(r)
+
+	err := ac.accountService.DeleteAccount(r.Context(), &command.DeleteAccountCommand{
+		UserID:   currentUserID(r),
+		Password: r.FormValue("password"),
+	})
+	if err != nil {
+		if errors.Is(err, entities.ErrPasswordIncorrect) {
+			form.Error = err.Error()
+			w.WriteHeader(400)
+		} else {
+			form.Error = "Something went wrong, please try again"
+			w.WriteHeader(500)
+		}
+		components.AccountDeleteForm(form).Render(r.Context(), w)
+		return
+	}
+
+	httputil.RemoveSessionCookie(w, ac.config.Env)
+
+	w.Header().Add("Hx-Location", "/")
+	w.WriteHeader(200)
+}
+
+func (ac *AccountController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(router.GetParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	err = ac.sessionService.RevokeSession(r.Context(), &command.RevokeSessionCommand{
+		UserID:    currentUserID(r),
+		SessionID: sessionID.String(),
+	})
+
+	list := ac.sessionList(r)
+	if err != nil {
+		list.Error = "Session could not be revoked"
+		w.WriteHeader(400)
+		components.SessionList(list).Render(r.Context(), w)
+		return
+	}
+
+	w.WriteHeader(200)
+	components.SessionList(list).Render(r.Context(), w)
+}
+
+func (ac *AccountController) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	err := ac.sessionService.RevokeOtherSessions(r.Context(), &command.RevokeOtherSessionsCommand{
+		UserID:           currentUserID(r),
+		CurrentSessionID: middlewares.GetSessionID(r.Context()),
+	})
+
+	list := ac.sessionList(r)
+	if err != nil {
+		list.Error = "Other sessions could not be revoked"
+		w.WriteHeader(500)
+		components.SessionList(list).Render(r.Context(), w)
+		return
+	}
+
+	w.WriteHeader(200)
+	components.SessionList(list).Render(r.Context(), w)
+}
+
+func (ac *AccountController) sessionList(r *http.Request) *components.SessionListData {
+	list := &components.SessionListData{
+		CurrentSessionID: middlewares.GetSessionID(r.Context()),
+	}
+
+	res, err := ac.sessionService.ListSessions(r.Context(), currentUserID(r))
+	if errors.Is(err, repositories.ErrNotSupported) {
+		list.Error = "The session store cannot list sessions"
+		return list
+	}
+	if err != nil {
+		list.Error = httputil.ErrorMessage(err)
+		return list
+	}
+
+	list.Sessions = res.Sessions
+	return list
+}
+
+func (ac *AccountController) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	identityID, err := strconv.Atoi(router.GetParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	err = ac.identityService.Unlink(r.Context(), &command.UnlinkIdentityCommand{
+		UserID:     currentUserID(r),
+		IdentityID: identityID,
+	})
+
+	list := ac.identityList(r)
+	if err != nil {
+		status := 500
+		list.Error = "Something went wrong, please try again"
+		if errors.Is(err, repositories.ErrNoRows) {
+			status = 404
+			list.Error = "Connected account not found"
+		}
+		w.WriteHeader(status)
+		components.IdentityList(list).Render(r.Context(), w)
+		return
+	}
+
+	w.WriteHeader(200)
+	components.IdentityList(list).Render(r.Context(), w)
+}
+
+func (ac *AccountController) identityList(r *http.Request) *components.IdentityListData {
+	list := &components.IdentityListData{
+		Providers: ac.identityService.Providers(),
+	}
+
+	res, err := ac.identityService.ListIdentities(r.Context(), currentUserID(r))
+	if err != nil {
+		list.Error = "Connected accounts could not be loaded"
+		return list
+	}
+
+	list.Identities = res.Identities
+	return list
+}
+
+// TwoFactorView starts a new enrollment on every visit until the second factor
+// is confirmed, afterwards it offers to manage it
+func (ac *AccountController) TwoFactorView(w http.ResponseWriter, r *http.Request) {
+	data := pages.TwoFactorPageData{
+		ConfirmForm:       components.NewTwoFactorCodeFormData(r, "/account/two-factor/confirm", "Enable"),
+		RecoveryCodesForm: components.NewTwoFactorCodeFormData(r, "/account/two-factor/recovery-codes", "Generate new codes"),
+		DisableForm:       components.NewTwoFactorCodeFormData(r, "/account/two-factor/disable", "Disable"),
+	}
+	data.DisableForm.Danger = true
+
+	res, err := ac.twoFactorService.GetStatus(r.Context(), currentUserID(r))
+	if err != nil {
+		data.Error = "Something went wrong, please try again"
+		w.WriteHeader(500)
+		pages.TwoFactor(data).Render(r.Context(), w)
+		return
+	}
+	data.Status = res.Status
+
+	if !data.Status.Enabled {
+		user := middlewares.GetUser(r.Context())
+		if user == nil {
+			w.WriteHeader(401)
+			return
+		}
+
+		enrollment, err := ac.twoFactorService.BeginEnrollment(r.Context(), &command.BeginTwoFactorEnrollmentCommand{
+			UserID: user.ID,
+			Email:  user.Email,
+		})
+		if err != nil {
+			data.Error = "Something went wrong, please try again"
+			w.WriteHeader(500)
+			pages.TwoFactor(data).Render(r.Context(), w)
+			return
+		}
+
+		data.Secret = enrollment.Secret
+		data.URI = enrollment.URI
+		// the key and URI are shown as well, a missing QR code is not fatal
+		data.QRCode, _ = qrcode.DataURI(enrollment.URI, 256)
+	}
+
+	w.WriteHeader(200)
+	pages.TwoFactor(data).Render(r.Context(), w)
+}
+
+func (ac *AccountController) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	form := components.NewTwoFactorCodeFormData(r, "/account/two-factor/confirm", "Enable")
+
+	res, err := ac.twoFactorService.ConfirmEnrollment(r.Context(), &command.TwoFactorCodeCommand{
+		UserID: currentUserID(r),
+		Code:   r.FormValue("code"),
+	})
+	if err != nil {
+		w.WriteHeader(twoFactorErrorStatus(err, form))
+		components.TwoFactorCodeForm(form).Render(r.Context(), w)
+		return
+	}
+
+	form.Success = "Two-factor authentication is enabled."
+	form.RecoveryCodes = res.RecoveryCodes
+	w.WriteHeader(200)
+	components.TwoFactorCodeForm(form).Render(r.Context(), w)
+}
+
+func (ac *AccountController) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	form := components.NewTwoFactorCodeFormData(r, "/account/two-factor/recovery-codes", "Generate new codes")
+
+	res, err := ac.twoFactorService.RegenerateRecoveryCodes(r.Context(), &command.TwoFactorCodeCommand{
+		UserID: currentUserID(r),
+		Code:   r.FormValue("code"),
+	})
+	if err != nil {
+		w.WriteHeader(twoFactorErrorStatus(err, form))
+		components.TwoFactorCodeForm(form).Render(r.Context(), w)
+		return
+	}
+
+	form.RecoveryCodes = res.RecoveryCodes
+	w.WriteHeader(200)
+	components.TwoFactorCodeForm(form).Render(r.Context(), w)
+}
+
+func (ac *AccountController) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	form := components.NewTwoFactorCodeFormData(r, "/account/two-factor/disable", "Disable")
+	form.Danger = true
+
+	err := ac.twoFactorService.Disable(r.Context(), &command.TwoFactorCodeCommand{
+		UserID: currentUserID(r),
+		Code:   r.FormValue("code"),
+	})
+	if err != nil {
+		w.WriteHeader(twoFactorErrorStatus(err, form))
+		components.TwoFactorCodeForm(form).Render(r.Context(), w)
+		return
+	}
+
+	w.Header().Add("Hx-Location", "/account")
+	w.WriteHeader(200)
+}
+
+// twoFactorErrorStatus sets the form error and returns the matching status
+func twoFactorErrorStatus(err error, form *components.TwoFactorCodeFormData) int {
+	switch {
+	case errors.Is(err, entities.ErrTwoFactorCodeInvalid):
+		form.Error = err.Error()
+		return 400
+	case errors.Is(err, entities.ErrTwoFactorAlreadyEnabled), errors.Is(err, entities.ErrTwoFactorNotEnabled):
+		form.Error = err.Error()
+		return 409
+	default:
+		form.Error = "Something went wrong, please try again"
+		return 500
+	}
+}
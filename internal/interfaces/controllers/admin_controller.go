@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/httputil"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/middlewares"
+	"go-starter-template/internal/infrastructure/views/components"
+	"go-starter-template/internal/infrastructure/views/pages"
+	"go-starter-template/pkg/csrf"
+	"go-starter-template/pkg/router"
+)
+
+type AdminController struct {
+	adminService services.IAdminService
+}
+
+func NewAdminController(
+	r router.Router,
+	adminService services.IAdminService,
+	sessionService services.ISessionService,
+	authorizationService services.IAuthorizationService,
+	config *config.Config,
+) {
+	controller := &AdminController{
+		adminService: adminService,
+	}
+
+	r.Route("/admin", func(r router.Router) {
+		r.Use(middlewares.AuthMiddleware(config.Env, sessionService))
+		r.Use(middlewares.RequirePermission(authorizationService, entities.PermissionUsersRead))
+
+		r.Get("/users", controller.Users)
+		r.Post("/users/{id:[0-9]+}/role", controller.ChangeRole)
+		r.Post("/users/{id:[0-9]+}/disable", controller.Disable)
+		r.Post("/users/{id:[0-9]+}/enable", controller.Enable)
+		r.Post("/users/{id:[0-9]+}/logout", controller.ForceLogout)
+	})
+}
+
+func (ac *AdminController) Users(w http.ResponseWriter, r *http.Request) {
+	data := pages.AdminUsersPageData{
+		CSRF:          csrf.GetCSRFField(r),
+		CurrentUserID: currentUserID(r),
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	res, err := ac.adminService.ListUsers(r.Context(), currentUserID(r), r.URL.Query().Get("q"), page)
+	if err != nil {
+		data.Error = httputil.ErrorMessage(err)
+		w.WriteHeader(httputil.ErrorStatus(err))
+		pages.AdminUsers(data).Render(r.Context(), w)
+		return
+	}
+	data.Users = res
+
+	w.WriteHeader(200)
+	pages.AdminUsers(data).Render(r.Context(), w)
+}
+
+func (ac *AdminController) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(router.GetParam(r, "id"))
+	res, err := ac.adminService.ChangeRole(r.Context(), &command.ChangeUserRoleCommand{
+		ActorID: currentUserID(r),
+		UserID:  userID,
+		Role:    r.FormValue("role"),
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(200)
+	components.AdminUserRow(&components.AdminUserRowData{User: res.User, Success: "Role changed"}).Render(r.Context(), w)
+}
+
+func (ac *AdminController) Disable(w http.ResponseWriter, r *http.Request) {
+	ac.setDisabled(w, r, true)
+}
+
+func (ac *AdminController) Enable(w http.ResponseWriter, r *http.Request) {
+	ac.setDisabled(w, r, false)
+}
+
+func (ac *AdminController) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	userID, _ := strconv.Atoi(router.GetParam(r, "id"))
+	res, err := ac.adminService.SetDisabled(r.Context(), &command.SetUserDisabledCommand{
+		ActorID:  currentUserID(r),
+		UserID:   userID,
+		Disabled: disabled,
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(200)
+	components.AdminUserRow(&components.AdminUserRowData{User: res.User}).Render(r.Context(), w)
+}
+
+func (ac *AdminController) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(router.GetParam(r, "id"))
+	res, err := ac.adminService.ForceLogout(r.Context(), &command.ForceLogoutCommand{
+		ActorID: currentUserID(r),
+		UserID:  userID,
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	row := &components.AdminUserRowData{User: res.User, Success: "Signed out everywhere"}
+
+	w.WriteHeader(200)
+	components.AdminUserRow(row).Render(r.Context(), w)
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/httputil"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/middlewares"
+	"go-starter-template/internal/infrastructure/views/components"
+	"go-starter-template/internal/infrastructure/views/pages"
+	"go-starter-template/pkg/csrf"
+	"go-starter-template/pkg/router"
+	"go-starter-template/pkg/utils"
+)
+
+type APITokenController struct {
+	apiTokenService services.IAPITokenService
+}
+
+type APITokenRequest struct {
+	Name          string `json:"name"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+type APITokenCreatedResponse struct {
+	Token string `json:"token"`
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+}
+
+func NewAPITokenController(
+	r router.Router,
+	apiTokenService services.IAPITokenService,
+	sessionService services.ISessionService,
+	emailVerificationService services.IEmailVerificationService,
+	config *config.Config,
+) {
+	controller := &APITokenController{
+		apiTokenService: apiTokenService,
+	}
+
+	r.Route("/tokens", func(r router.Router) {
+		r.Use(middlewares.AuthMiddleware(config.Env, sessionService))
+		r.Use(middlewares.RequireVerifiedEmail(emailVerificationService))
+
+		r.Get("/", controller.List)
+		r.Post("/", controller.Create)
+		r.Delete("/{id:[0-9]+}", controller.Revoke)
+	})
+
+	r.Route("/api/v1/tokens", func(r router.Router) {
+		r.Use(middlewares.JSONOnly)
+		r.Use(middlewares.APIAuthMiddleware(config.Env, sessionService, apiTokenService))
+		r.Use(middlewares.RequireVerifiedEmail(emailVerificationService))
+
+		r.Get("/", controller.ListJSON)
+		r.Post("/", controller.CreateJSON)
+		r.Delete("/{id:[0-9]+}", controller.RevokeJSON)
+	})
+}
+
+func (ac *APITokenController) List(w http.ResponseWriter, r *http.Request) {
+	data := pages.APITokensPageData{}
+	data.Form = components.NewAPITokenFormData(r)
+
+	res, err := ac.apiTokenService.ListTokens(r.Context(), currentUserID(r))
+	if err != nil {
+		data.Error = httputil.ErrorMessage(err)
+		w.WriteHeader(httputil.ErrorStatus(err))
+		pages.APITokens(data).Render(r.Context(), w)
+		return
+	}
+
+	data.Tokens = res.Tokens
+
+	w.WriteHeader(200)
+	pages.APITokens(data).Render(r.Context(), w)
+}
+
+func (ac *APITokenController) Create(w http.ResponseWriter, r *http.Request) {
+	form := &components.APITokenFormData{
+		CSRF:          csrf.GetCSRFField(r),
+		Name:          strings.TrimSpace(r.FormValue("name")),
+		ExpiresInDays: r.FormValue("expires_in_days"),
+	}
+
+	expiresInDays, err := utils.ParseToInt(form.ExpiresInDays)
+	if err != nil || expiresInDays < 0 {
+		form.Error = "Expiry must be a positive number of days"
+		w.WriteHeader(400)
+		components.APITokenForm(form).Render(r.Context(), w)
+		return
+	}
+
+	result, err := ac.apiTokenService.CreateToken(r.Context(), &command.CreateAPITokenCommand{
+		UserID:        currentUserID(r),
+		Name:          form.Name,
+		ExpiresInDays: expiresInDays,
+	})
+	if err != nil {
+		form.Error = httputil.ErrorMessage(err)
+		w.WriteHeader(httputil.ErrorStatus(err))
+		components.APITokenForm(form).Render(r.Context(), w)
+		return
+	}
+
+	form = components.NewAPITokenFormData(r)
+	form.CreatedToken = result.PlainText
+
+	w.WriteHeader(201)
+	components.APITokenItemOOB(result.Token).Render(r.Context(), w)
+	components.APITokenForm(form).Render(r.Context(), w)
+}
+
+func (ac *APITokenController) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ParseToInt(router.GetParam(r, "id"))
+	if err != nil {
+		renderError(w, r, entities.ErrAPITokenNotFound)
+		return
+	}
+
+	if err := ac.apiTokenService.RevokeToken(r.Context(), &command.RevokeAPITokenCommand{
+		ID:     id,
+		UserID: currentUserID(r),
+	}); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(200)
+}
+
+func (ac *APITokenController) ListJSON(w http.ResponseWriter, r *http.Request) {
+	res, err := ac.apiTokenService.ListTokens(r.Context(), currentUserID(r))
+	if err != nil {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, res.Tokens)
+}
+
+func (ac *APITokenController) CreateJSON(w http.ResponseWriter, r *http.Request) {
+	var body APITokenRequest
+	if err := httputil.ReadJSON(r, &body); err != nil {
+		httputil.WriteJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if body.ExpiresInDays < 0 {
+		httputil.WriteJSONError(w, http.StatusUnprocessableEntity, "expires_in_days must not be negative")
+		return
+	}
+
+	result, err := ac.apiTokenService.CreateToken(r.Context(), &command.CreateAPITokenCommand{
+		UserID:        currentUserID(r),
+		Name:          strings.TrimSpace(body.Name),
+		ExpiresInDays: body.ExpiresInDays,
+	})
+	if err != nil {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusCreated, APITokenCreatedResponse{
+		Token: result.PlainText,
+		ID:    result.Token.ID,
+		Name:  result.Token.Name,
+	})
+}
+
+func (ac *APITokenController) RevokeJSON(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ParseToInt(router.GetParam(r, "id"))
+	if err != nil {
+		httputil.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := ac.apiTokenService.RevokeToken(r.Context(), &command.RevokeAPITokenCommand{
+		ID:     id,
+		UserID: currentUserID(r),
+	}); err != nil {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,11 +3,16 @@ package controllers
 import (
 	"errors"
 	"html/template"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"go-starter-template/internal/application/command"
 	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
 	"go-starter-template/internal/domain/valueobject"
 	"go-starter-template/internal/httputil"
 	"go-starter-template/internal/infrastructure/config"
@@ -18,8 +23,11 @@ import (
 )
 
 type AuthController struct {
-	userService services.IUserService
-	config      *config.Config
+	userService          services.IUserService
+	sessionService       services.ISessionService
+	passwordResetService services.IPasswordResetService
+	identityService      services.IIdentityService
+	config               *config.Config
 }
 
 type LoginForm struct {
@@ -41,22 +49,39 @@ type SignupForm struct {
 	FormError string
 }
 
-func NewAuthController(r router.Router, userService services.IUserService, config *config.Config) {
+func NewAuthController(
+	r router.Router,
+	userService services.IUserService,
+	sessionService services.ISessionService,
+	passwordResetService services.IPasswordResetService,
+	identityService services.IIdentityService,
+	config *config.Config,
+) {
 	controller := &AuthController{
-		userService: userService,
-		config:      config,
+		userService:          userService,
+		sessionService:       sessionService,
+		passwordResetService: passwordResetService,
+		identityService:      identityService,
+		config:               config,
 	}
 
 	r.Get("/login", controller.LoginView)
 	r.Post("/login", controller.Login)
+	r.Get("/login/two-factor", controller.TwoFactorView)
+	r.Post("/login/two-factor", controller.TwoFactor)
 	r.Get("/signup", controller.SignupView)
 	r.Post("/signup", controller.Signup)
+	r.Post("/logout", controller.Logout)
+	r.Get("/forgot-password", controller.ForgotPasswordView)
+	r.Post("/forgot-password", controller.ForgotPassword)
+	r.Get("/reset-password", controller.ResetPasswordView)
+	r.Post("/reset-password", controller.ResetPassword)
 }
 
 func (ac *AuthController) LoginView(w http.ResponseWriter, r *http.Request) {
 	form := components.NewLoginFormData(r)
 	w.WriteHeader(200)
-	pages.Login(form).Render(r.Context(), w)
+	pages.Login(form, ac.identityService.Providers()).Render(r.Context(), w)
 }
 
 func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
@@ -68,28 +93,117 @@ func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	result, err := ac.userService.Login(r.Context(), &command.CreateLoginCommand{
-		Email:    form.Email,
-		Password: form.Password,
-		Remember: form.Remember == "on",
+		Email:       form.Email,
+		Password:    form.Password,
+		Remember:    form.Remember == "on",
+		UserAgent:   r.UserAgent(),
+		IPAddress:   httputil.ClientIP(r),
+		DeviceToken: httputil.TrustedDeviceToken(r),
 	})
 
 	if err != nil {
-		form.Error = err.Error()
-		w.WriteHeader(401)
+		var throttled *entities.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			form.Error = throttled.Error()
+			setRetryAfter(w, throttled)
+			w.WriteHeader(429)
+		case errors.Is(err, entities.ErrEmailNotVerified):
+			form.Error = err.Error()
+			form.Unverified = true
+			w.WriteHeader(403)
+		default:
+			form.Error = httputil.ErrorMessage(err)
+			w.WriteHeader(httputil.ErrorStatus(err))
+		}
 		components.LoginForm(form).Render(r.Context(), w)
 		return
 	}
 
+	if result.TwoFactorToken != "" {
+		expiresAt := time.Now().Add(ac.config.TwoFactorConfig.ChallengeTTL)
+		httputil.SetTwoFactorChallengeCookie(w, result.TwoFactorToken, expiresAt, ac.config.Env)
+
+		w.Header().Add("Hx-Location", "/login/two-factor")
+		w.WriteHeader(200)
+		return
+	}
+
+	httputil.SetSessionCookie(w, result.Session, ac.config.Env)
+
+	w.Header().Add("Hx-Location", "/todos")
+	w.WriteHeader(200)
+}
+
+func (ac *AuthController) TwoFactorView(w http.ResponseWriter, r *http.Request) {
+	if httputil.TwoFactorChallengeToken(r) == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	form := components.NewTwoFactorChallengeFormData(r)
+	w.WriteHeader(200)
+	pages.TwoFactorChallenge(form).Render(r.Context(), w)
+}
+
+func (ac *AuthController) TwoFactor(w http.ResponseWriter, r *http.Request) {
+	form := &components.TwoFactorChallengeFormData{
+		CSRF:           csrf.GetCSRFField(r),
+		RememberDevice: r.FormValue("remember_device"),
+	}
+
+	result, err := ac.userService.CompleteTwoFactorLogin(r.Context(), &command.CompleteTwoFactorLoginCommand{
+		Token:          httputil.TwoFactorChallengeToken(r),
+		Code:           r.FormValue("code"),
+		RememberDevice: form.RememberDevice == "on",
+		UserAgent:      r.UserAgent(),
+		IPAddress:      httputil.ClientIP(r),
+	})
+
+	if err != nil {
+		var throttled *entities.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			form.Error = throttled.Error()
+			setRetryAfter(w, throttled)
+			w.WriteHeader(429)
+		case errors.Is(err, entities.ErrTwoFactorCodeInvalid):
+			form.Error = err.Error()
+			w.WriteHeader(401)
+		case errors.Is(err, entities.ErrTwoFactorChallengeInvalid):
+			form.Error = err.Error()
+			httputil.RemoveTwoFactorChallengeCookie(w, ac.config.Env)
+			w.WriteHeader(401)
+		case errors.Is(err, entities.ErrUserDisabled):
+			form.Error = err.Error()
+			httputil.RemoveTwoFactorChallengeCookie(w, ac.config.Env)
+			w.WriteHeader(403)
+		default:
+			form.Error = httputil.ErrorMessage(err)
+			w.WriteHeader(httputil.ErrorStatus(err))
+		}
+		components.TwoFactorChallengeForm(form).Render(r.Context(), w)
+		return
+	}
+
+	httputil.RemoveTwoFactorChallengeCookie(w, ac.config.Env)
 	httputil.SetSessionCookie(w, result.Session, ac.config.Env)
+	if result.TrustedDevice != nil {
+		httputil.SetTrustedDeviceCookie(w, result.TrustedDevice, ac.config.Env)
+	}
 
 	w.Header().Add("Hx-Location", "/todos")
 	w.WriteHeader(200)
 }
 
+func setRetryAfter(w http.ResponseWriter, throttled *entities.LoginThrottledError) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+}
+
 func (ac *AuthController) SignupView(w http.ResponseWriter, r *http.Request) {
 	form := components.NewSignupFormData(r)
 	w.WriteHeader(200)
-	pages.Signup(form).Render(r.Context(), w)
+	pages.Signup(form, ac.identityService.Providers()).Render(r.Context(), w)
 }
 
 func (ac *AuthController) Signup(w http.ResponseWriter, r *http.Request) {
@@ -119,6 +233,93 @@ func (ac *AuthController) Signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Add("Hx-Location", "/verify/pending?email="+url.QueryEscape(form.Email))
+	w.WriteHeader(200)
+}
+
+func (ac *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie("session_id"); err == nil {
+		// the session may already be gone, the cookie is cleared regardless
+		ac.sessionService.DeleteSession(r.Context(), cookie.Value)
+	}
+
+	httputil.RemoveSessionCookie(w, ac.config.Env)
+
+	w.Header().Add("Hx-Location", "/login")
+	w.WriteHeader(200)
+}
+
+func (ac *AuthController) ForgotPasswordView(w http.ResponseWriter, r *http.Request) {
+	form := components.NewForgotPasswordFormData(r)
+	w.WriteHeader(200)
+	pages.ForgotPassword(form).Render(r.Context(), w)
+}
+
+func (ac *AuthController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	form := &components.ForgotPasswordFormData{
+		CSRF:  csrf.GetCSRFField(r),
+		Email: strings.TrimSpace(r.FormValue("email")),
+	}
+
+	err := ac.passwordResetService.RequestReset(r.Context(), &command.RequestPasswordResetCommand{
+		Email: form.Email,
+	})
+	if err != nil {
+		form.Error = "Something went wrong, please try again"
+		w.WriteHeader(500)
+		components.ForgotPasswordForm(form).Render(r.Context(), w)
+		return
+	}
+
+	form.Email = ""
+	form.Success = "If an account exists for that email, a reset link is on its way."
+	w.WriteHeader(200)
+	components.ForgotPasswordForm(form).Render(r.Context(), w)
+}
+
+func (ac *AuthController) ResetPasswordView(w http.ResponseWriter, r *http.Request) {
+	form := components.NewResetPasswordFormData(r)
+
+	if err := ac.passwordResetService.ValidateToken(r.Context(), form.Token); err != nil {
+		form.Token = ""
+		form.Error = entities.ErrPasswordResetTokenInvalid.Error()
+		w.WriteHeader(400)
+		pages.ResetPassword(form).Render(r.Context(), w)
+		return
+	}
+
+	w.WriteHeader(200)
+	pages.ResetPassword(form).Render(r.Context(), w)
+}
+
+func (ac *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	form := &components.ResetPasswordFormData{
+		CSRF:           csrf.GetCSRFField(r),
+		Token:          r.FormValue("token"),
+		PasswordErrors: make([]string, 0),
+	}
+
+	err := ac.passwordResetService.ResetPassword(r.Context(), &command.ResetPasswordCommand{
+		Token:    form.Token,
+		Password: strings.TrimSpace(r.FormValue("password")),
+	})
+	if err != nil {
+		var pve *valueobject.PasswordValidationError
+		switch {
+		case errors.As(err, &pve):
+			form.PasswordErrors = pve.Errors
+			w.WriteHeader(400)
+		case errors.Is(err, entities.ErrPasswordResetTokenInvalid):
+			form.Error = err.Error()
+			w.WriteHeader(400)
+		default:
+			form.Error = "Something went wrong, please try again"
+			w.WriteHeader(500)
+		}
+		components.ResetPasswordForm(form).Render(r.Context(), w)
+		return
+	}
+
 	w.Header().Add("Hx-Location", "/login")
 	w.WriteHeader(200)
 }
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go-starter-template/internal/httputil"
+	"go-starter-template/internal/infrastructure/views/components"
+	"go-starter-template/internal/infrastructure/views/pages"
+	"go-starter-template/pkg/logger"
+	"go-starter-template/pkg/router"
+)
+
+// renderError answers a failed request with the status matching the kind of
+// err. JSON clients get a problem document, htmx requests fill the error
+// message of the current page and full page loads get an error page. Internal
+// errors are never shown, see httputil.ErrorMessage.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	status := httputil.ErrorStatus(err)
+	message := httputil.ErrorMessage(err)
+	if status >= http.StatusInternalServerError {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "request failed", "error", err)
+	}
+
+	switch {
+	case router.Negotiate(r, "text/html", "application/json") == "application/json":
+		httputil.WriteErrorJSON(w, err)
+	case isPartialRequest(r):
+		// the target of the request keeps its content
+		w.Header().Set("Hx-Reswap", "none")
+		w.WriteHeader(status)
+		components.ErrorMessageOOB(message).Render(r.Context(), w)
+	default:
+		w.WriteHeader(status)
+		pages.Error(pages.ErrorPageData{Status: status, Message: message}).Render(r.Context(), w)
+	}
+}
+
+// isPartialRequest reports whether htmx swaps the response into part of the
+// page, boosted links and forms replace the whole body
+func isPartialRequest(r *http.Request) bool {
+	return r.Header.Get("Hx-Request") == "true" && r.Header.Get("Hx-Boosted") != "true"
+}
@@ -1,25 +1,26 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"runtime"
 	"time"
 
 	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/pkg/health"
 	"go-starter-template/pkg/router"
 )
 
 type HealthHandler struct {
-	conf *config.Config
-	*sql.DB
+	conf   *config.Config
+	health *health.Registry
 }
 
 type HealthStatus struct {
 	Status    string            `json:"status"`
 	Timestamp time.Time         `json:"timestamp"`
 	Version   string            `json:"version"`
+	Draining  bool              `json:"draining,omitempty"`
 	Services  map[string]string `json:"services"`
 	System    SystemInfo        `json:"system"`
 }
@@ -32,23 +33,40 @@ type SystemInfo struct {
 	NumGoroutine int    `json:"num_goroutine"`
 }
 
-func NewHealthController(r router.Router, conf *config.Config, sqlDB *sql.DB) {
+func NewHealthController(r router.Router, conf *config.Config, registry *health.Registry) {
 	controller := &HealthHandler{
-		conf: conf,
-		DB:   sqlDB,
+		conf:   conf,
+		health: registry,
 	}
 
+	r.Get("/livez", controller.Live)
+	r.Get("/readyz", controller.Ready)
 	r.Get("/health", controller.Check)
 }
 
+// Live answers as long as the process serves requests, restarting the app
+// would not fix a failing dependency
+func (hc *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, hc.health.Live(), true)
+}
+
+// Ready fails while a dependency is down or the server is shutting down, so
+// load balancers stop sending traffic
+func (hc *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := hc.health.Ready(r.Context())
+	writeHealth(w, report, report.Up())
+}
+
+// Check is the readiness report with version and runtime details for humans
 func (hc *HealthHandler) Check(w http.ResponseWriter, r *http.Request) {
+	report := hc.health.Ready(r.Context())
+
 	status := HealthStatus{
 		Status:    "healthy",
 		Timestamp: time.Now(),
 		Version:   hc.conf.Version,
-		Services: map[string]string{
-			"database": "up",
-		},
+		Draining:  report.Draining,
+		Services:  make(map[string]string, len(report.Checks)),
 		System: SystemInfo{
 			GoVersion:    runtime.Version(),
 			GOOS:         runtime.GOOS,
@@ -57,13 +75,23 @@ func (hc *HealthHandler) Check(w http.ResponseWriter, r *http.Request) {
 			NumGoroutine: runtime.NumGoroutine(),
 		},
 	}
-
-	if err := hc.DB.PingContext(r.Context()); err != nil {
+	for name, result := range report.Checks {
+		status.Services[name] = result.Status
+	}
+	if !report.Up() {
 		status.Status = "unhealthy"
-		status.Services["database"] = "down"
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(200)
-	json.NewEncoder(w).Encode(status)
+	writeHealth(w, status, report.Up())
+}
+
+func writeHealth(w http.ResponseWriter, body any, up bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if up {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
 }
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/httputil"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/middlewares"
+	"go-starter-template/internal/infrastructure/views/components"
+	"go-starter-template/internal/infrastructure/views/pages"
+	"go-starter-template/pkg/router"
+)
+
+// identityLinkErrors are passed back to the account page as a query parameter
+// so no message text ends up in the URL
+var identityLinkErrors = map[error]string{
+	entities.ErrIdentityAlreadyLinked:   "already-linked",
+	entities.ErrIdentityLinkedElsewhere: "linked-elsewhere",
+	entities.ErrIdentityLoginFailed:     "failed",
+}
+
+type IdentityController struct {
+	identityService services.IIdentityService
+	requireAuth     middlewares.MiddlewareFunc
+	config          *config.Config
+}
+
+func NewIdentityController(
+	r router.Router,
+	identityService services.IIdentityService,
+	sessionService services.ISessionService,
+	config *config.Config,
+) {
+	controller := &IdentityController{
+		identityService: identityService,
+		requireAuth:     middlewares.AuthMiddleware(config.Env, sessionService),
+		config:          config,
+	}
+
+	r.Get("/auth/{provider}", controller.Begin)
+	r.With(controller.requireAuth).Get("/auth/{provider}/link", controller.BeginLink)
+	r.Get("/auth/{provider}/callback", controller.Callback)
+}
+
+func (ic *IdentityController) Begin(w http.ResponseWriter, r *http.Request) {
+	ic.begin(w, r, false)
+}
+
+func (ic *IdentityController) BeginLink(w http.ResponseWriter, r *http.Request) {
+	ic.begin(w, r, true)
+}
+
+func (ic *IdentityController) begin(w http.ResponseWriter, r *http.Request, link bool) {
+	provider := router.GetParam(r, "provider")
+
+	res, err := ic.identityService.BeginLogin(r.Context(), &command.BeginIdentityLoginCommand{
+		Provider: provider,
+	})
+	if err != nil {
+		if errors.Is(err, entities.ErrIdentityProviderUnknown) {
+			w.WriteHeader(404)
+			return
+		}
+		if link {
+			http.Redirect(w, r, "/account?identity_error="+identityLinkErrors[entities.ErrIdentityLoginFailed], http.StatusSeeOther)
+			return
+		}
+		ic.renderLoginError(w, r, entities.ErrIdentityLoginFailed.Error(), 502)
+		return
+	}
+
+	httputil.SetIdentityFlowCookie(w, &httputil.IdentityFlow{
+		Provider: provider,
+		State:    res.State,
+		Nonce:    res.Nonce,
+		Verifier: res.Verifier,
+		Link:     link,
+	}, ic.config.Env)
+
+	http.Redirect(w, r, res.AuthURL, http.StatusFound)
+}
+
+// Callback is the redirect URL registered at every provider, the flow cookie
+// tells whether the user is logging in or connecting the provider
+func (ic *IdentityController) Callback(w http.ResponseWriter, r *http.Request) {
+	flow := httputil.GetIdentityFlow(r)
+	// the flow is single use whatever the outcome
+	httputil.RemoveIdentityFlowCookie(w, ic.config.Env)
+
+	if flow == nil || flow.Provider != router.GetParam(r, "provider") {
+		ic.renderLoginError(w, r, entities.ErrIdentityLoginFailed.Error(), 400)
+		return
+	}
+
+	callback := &command.IdentityCallbackCommand{
+		Provider:      flow.Provider,
+		Code:          r.URL.Query().Get("code"),
+		State:         r.URL.Query().Get("state"),
+		ExpectedState: flow.State,
+		Nonce:         flow.Nonce,
+		Verifier:      flow.Verifier,
+	}
+
+	if flow.Link {
+		ic.requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ic.link(w, r, callback)
+		})).ServeHTTP(w, r)
+		return
+	}
+
+	result, err := ic.identityService.CompleteLogin(r.Context(), &command.CompleteIdentityLoginCommand{
+		Callback:  callback,
+		UserAgent: r.UserAgent(),
+		IPAddress: httputil.ClientIP(r),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrIdentityLoginFailed):
+			ic.renderLoginError(w, r, entities.ErrIdentityLoginFailed.Error(), 401)
+		case errors.Is(err, entities.ErrIdentityEmailInUse),
+			errors.Is(err, entities.ErrIdentityEmailMissing),
+			errors.Is(err, entities.ErrEmailNotVerified),
+			errors.Is(err, entities.ErrUserDisabled):
+			ic.renderLoginError(w, r, err.Error(), 403)
+		default:
+			ic.renderLoginError(w, r, "Something went wrong, please try again", 500)
+		}
+		return
+	}
+
+	if result.TwoFactorToken != "" {
+		expiresAt := time.Now().Add(ic.config.TwoFactorConfig.ChallengeTTL)
+		httputil.SetTwoFactorChallengeCookie(w, result.TwoFactorToken, expiresAt, ic.config.Env)
+		http.Redirect(w, r, "/login/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	httputil.SetSessionCookie(w, result.Session, ic.config.Env)
+	http.Redirect(w, r, "/todos", http.StatusSeeOther)
+}
+
+func (ic *IdentityController) link(w http.ResponseWriter, r *http.Request, callback *command.IdentityCallbackCommand) {
+	err := ic.identityService.Link(r.Context(), &command.LinkIdentityCommand{
+		UserID:   currentUserID(r),
+		Callback: callback,
+	})
+	if err != nil {
+		code := identityLinkErrors[entities.ErrIdentityLoginFailed]
+		for target, c := range identityLinkErrors {
+			if errors.Is(err, target) {
+				code = c
+				break
+			}
+		}
+		http.Redirect(w, r, "/account?identity_error="+code, http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (ic *IdentityController) renderLoginError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	form := components.NewLoginFormData(r)
+	form.Error = message
+	w.WriteHeader(status)
+	pages.Login(form, ic.identityService.Providers()).Render(r.Context(), w)
+}
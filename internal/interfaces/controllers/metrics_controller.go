@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"go-starter-template/internal/httputil"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/pkg/metrics"
+	"go-starter-template/pkg/router"
+)
+
+type MetricsController struct {
+	token   string
+	handler http.Handler
+}
+
+// NewMetricsController serves the Default metrics registry to Prometheus,
+// behind a bearer token when METRICS_TOKEN is set
+func NewMetricsController(r router.Router, conf *config.Config) {
+	controller := &MetricsController{
+		token:   conf.MetricsConfig.Token,
+		handler: metrics.Default.Handler(),
+	}
+
+	r.Get("/metrics", controller.Metrics)
+}
+
+func (mc *MetricsController) Metrics(w http.ResponseWriter, r *http.Request) {
+	if mc.token != "" {
+		token, ok := httputil.BearerToken(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(mc.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	mc.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/httputil"
+	"go-starter-template/internal/infrastructure/config"
+	"go-starter-template/internal/infrastructure/middlewares"
+	"go-starter-template/pkg/router"
+	"go-starter-template/pkg/utils"
+)
+
+type TodoAPIController struct {
+	todoService services.ITodoService
+}
+
+type TodoRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func NewTodoAPIController(
+	r router.Router,
+	todoService services.ITodoService,
+	sessionService services.ISessionService,
+	authorizationService services.IAuthorizationService,
+	apiTokenService services.IAPITokenService,
+	emailVerificationService services.IEmailVerificationService,
+	config *config.Config,
+) {
+	controller := &TodoAPIController{
+		todoService: todoService,
+	}
+
+	authMiddleware := middlewares.APIAuthMiddleware(config.Env, sessionService, apiTokenService)
+
+	r.Route("/api/v1/todos", func(r router.Router) {
+		r.Use(middlewares.JSONOnly)
+		r.Use(authMiddleware)
+		r.Use(middlewares.RequireVerifiedEmail(emailVerificationService))
+
+		canRead := middlewares.RequirePermission(authorizationService, entities.PermissionTodosRead)
+		canWrite := middlewares.RequirePermission(authorizationService, entities.PermissionTodosWrite)
+		canDelete := middlewares.RequirePermission(authorizationService, entities.PermissionTodosDelete)
+
+		r.With(canRead).Get("/", controller.List)
+		r.With(canRead).Get("/{id:[0-9]+}", controller.Get)
+		r.With(canWrite).Post("/", controller.Create)
+		r.With(canWrite).Put("/{id:[0-9]+}", controller.Update)
+		r.With(canDelete).Delete("/{id:[0-9]+}", controller.Delete)
+	})
+}
+
+func (tc *TodoAPIController) List(w http.ResponseWriter, r *http.Request) {
+	res, err := tc.todoService.ListTodos(r.Context(), currentUserID(r))
+	if err != nil {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, res.Todos)
+}
+
+func (tc *TodoAPIController) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ParseToInt(router.GetParam(r, "id"))
+	if err != nil {
+		httputil.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := tc.todoService.GetTodo(r.Context(), currentUserID(r), id)
+	if err != nil {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, res.Todo)
+}
+
+func (tc *TodoAPIController) Create(w http.ResponseWriter, r *http.Request) {
+	var body TodoRequest
+	if err := httputil.ReadJSON(r, &body); err != nil {
+		httputil.WriteJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	res, err := tc.todoService.CreateTodo(r.Context(), &command.CreateTodoCommand{
+		UserID:      currentUserID(r),
+		Title:       strings.TrimSpace(body.Title),
+		Description: strings.TrimSpace(body.Description),
+	})
+	if err != nil {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/todos/"+utils.ParseToString(res.Todo.ID))
+	httputil.WriteJSON(w, http.StatusCreated, res.Todo)
+}
+
+func (tc *TodoAPIController) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ParseToInt(router.GetParam(r, "id"))
+	if err != nil {
+		httputil.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var body TodoRequest
+	if err := httputil.ReadJSON(r, &body); err != nil {
+		httputil.WriteJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	res, err := tc.todoService.UpdateTodo(r.Context(), &command.UpdateTodoCommand{
+		ID:          id,
+		UserID:      currentUserID(r),
+		Title:       strings.TrimSpace(body.Title),
+		Description: strings.TrimSpace(body.Description),
+	})
+	if err != nil {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, res.Todo)
+}
+
+func (tc *TodoAPIController) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ParseToInt(router.GetParam(r, "id"))
+	if err != nil {
+		httputil.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := tc.todoService.DeleteTodo(r.Context(), &command.DeleteTodoCommand{ID: id, UserID: currentUserID(r)}); err != nil {
+		httputil.WriteErrorJSON(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,19 +1,19 @@
 package controllers
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
 	"go-starter-template/internal/application/command"
 	"go-starter-template/internal/application/result"
 	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/httputil"
 	"go-starter-template/internal/infrastructure/config"
 	"go-starter-template/internal/infrastructure/middlewares"
 	"go-starter-template/internal/infrastructure/views/components"
 	"go-starter-template/internal/infrastructure/views/pages"
 	"go-starter-template/pkg/csrf"
-	"go-starter-template/pkg/renderer"
 	"go-starter-template/pkg/router"
 	"go-starter-template/pkg/utils"
 )
@@ -22,34 +22,53 @@ type TodoController struct {
 	todoService services.ITodoService
 }
 
-func NewTodoController(r router.Router, todoService services.ITodoService, sessionService services.ISessionService, config *config.Config) {
+func NewTodoController(
+	r router.Router,
+	todoService services.ITodoService,
+	sessionService services.ISessionService,
+	authorizationService services.IAuthorizationService,
+	emailVerificationService services.IEmailVerificationService,
+	config *config.Config,
+) {
 	controller := &TodoController{
 		todoService: todoService,
 	}
 
-	_ = middlewares.AuthMiddleware(config.Env, sessionService)
+	authMiddleware := middlewares.AuthMiddleware(config.Env, sessionService)
 
 	r.Route("/todos", func(r router.Router) {
 		// INFO: to apply middleware to a group of routes use Use method
-		// r.Use(authMiddleware)
-
-		r.Get("/", controller.List)
-		r.Get("/{id}", controller.Get)
-		r.Post("/", controller.Create)
-		r.Put("/{id}", controller.Update)
-		r.Delete("/{id}", controller.Delete)
+		r.Use(authMiddleware)
+		r.Use(middlewares.RequireVerifiedEmail(emailVerificationService))
+
+		canRead := middlewares.RequirePermission(authorizationService, entities.PermissionTodosRead)
+		canWrite := middlewares.RequirePermission(authorizationService, entities.PermissionTodosWrite)
+		canDelete := middlewares.RequirePermission(authorizationService, entities.PermissionTodosDelete)
+
+		r.With(canRead).Get("/", controller.List)
+		r.With(canRead).Get("/{id}", controller.Get)
+		r.With(canWrite).Post("/", controller.Create)
+		r.With(canWrite).Put("/{id}", controller.Update)
+		r.With(canDelete).Delete("/{id}", controller.Delete)
 	})
 }
 
+func currentUserID(r *http.Request) int {
+	if user := middlewares.GetUser(r.Context()); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
 func (tc *TodoController) List(w http.ResponseWriter, r *http.Request) {
 	data := pages.TodosPageData{}
 	data.Form = components.NewTodoCreateForm(r)
 
-	res, err := tc.todoService.ListTodos(r.Context())
+	res, err := tc.todoService.ListTodos(r.Context(), currentUserID(r))
 
 	if err != nil {
-		data.Error = err.Error()
-		w.WriteHeader(500)
+		data.Error = httputil.ErrorMessage(err)
+		w.WriteHeader(httputil.ErrorStatus(err))
 		pages.Todos(data).Render(r.Context(), w)
 		return
 	}
@@ -63,15 +82,13 @@ func (tc *TodoController) List(w http.ResponseWriter, r *http.Request) {
 func (tc *TodoController) Get(w http.ResponseWriter, r *http.Request) {
 	id, err := utils.ParseToInt(router.GetParam(r, "id"))
 	if err != nil {
-		w.Header().Add("Hx-Location", "/todos")
-		w.WriteHeader(400)
+		renderError(w, r, entities.ErrTodoNotFound)
 		return
 	}
 
-	res, err := tc.todoService.GetTodo(r.Context(), id)
+	res, err := tc.todoService.GetTodo(r.Context(), currentUserID(r), id)
 	if err != nil {
-		w.Header().Add("Hx-Location", "/todos")
-		w.WriteHeader(404)
+		renderError(w, r, err)
 		return
 	}
 
@@ -93,13 +110,14 @@ func (tc *TodoController) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	result, err := tc.todoService.CreateTodo(r.Context(), &command.CreateTodoCommand{
+		UserID:      currentUserID(r),
 		Title:       form.Title,
 		Description: form.Description,
 	})
 
 	if err != nil {
-		form.Error = err.Error()
-		w.WriteHeader(400)
+		form.Error = httputil.ErrorMessage(err)
+		w.WriteHeader(httputil.ErrorStatus(err))
 		components.TodoCreateForm(form).Render(r.Context(), w)
 		return
 	}
@@ -114,8 +132,7 @@ func (tc *TodoController) Create(w http.ResponseWriter, r *http.Request) {
 func (tc *TodoController) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := utils.ParseToInt(router.GetParam(r, "id"))
 	if err != nil {
-		w.Header().Add("Hx-Location", "/todos")
-		w.WriteHeader(400)
+		renderError(w, r, entities.ErrTodoNotFound)
 		return
 	}
 
@@ -127,13 +144,14 @@ func (tc *TodoController) Update(w http.ResponseWriter, r *http.Request) {
 
 	result, err := tc.todoService.UpdateTodo(r.Context(), &command.UpdateTodoCommand{
 		ID:          id,
+		UserID:      currentUserID(r),
 		Title:       editForm.Title,
 		Description: editForm.Description,
 	})
 
 	if err != nil {
-		editForm.Error = err.Error()
-		w.WriteHeader(400)
+		editForm.Error = httputil.ErrorMessage(err)
+		w.WriteHeader(httputil.ErrorStatus(err))
 		components.TodoEditForm(editForm).Render(r.Context(), w)
 		return
 	}
@@ -150,17 +168,12 @@ func (tc *TodoController) Update(w http.ResponseWriter, r *http.Request) {
 func (tc *TodoController) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := utils.ParseToInt(router.GetParam(r, "id"))
 	if err != nil {
-		w.Header().Add("Hx-Location", "/todos")
-		w.WriteHeader(400)
+		renderError(w, r, entities.ErrTodoNotFound)
 		return
 	}
 
-	deleteForm := components.NewTodoDeleteForm(r, id)
-	if err := tc.todoService.DeleteTodo(r.Context(), &command.DeleteTodoCommand{ID: id}); err != nil {
-		w.WriteHeader(400)
-		components.TodoDeleteForm(deleteForm).Render(r.Context(), w)
-		errorString := fmt.Sprintf("<div id=\"error-message\" hx-swap-oob=\"true\"><p style='color: red;'>%s</p></div>", err.Error())
-		renderer.RenderString(w, errorString, nil)
+	if err := tc.todoService.DeleteTodo(r.Context(), &command.DeleteTodoCommand{ID: id, UserID: currentUserID(r)}); err != nil {
+		renderError(w, r, err)
 		return
 	}
 
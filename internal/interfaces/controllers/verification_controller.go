@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-starter-template/internal/application/command"
+	"go-starter-template/internal/application/services"
+	"go-starter-template/internal/domain/entities"
+	"go-starter-template/internal/infrastructure/views/components"
+	"go-starter-template/internal/infrastructure/views/pages"
+	"go-starter-template/pkg/csrf"
+	"go-starter-template/pkg/router"
+)
+
+type VerificationController struct {
+	emailVerificationService services.IEmailVerificationService
+}
+
+func NewVerificationController(r router.Router, emailVerificationService services.IEmailVerificationService) {
+	controller := &VerificationController{
+		emailVerificationService: emailVerificationService,
+	}
+
+	r.Route("/verify", func(r router.Router) {
+		r.Get("/", controller.Verify)
+		r.Get("/pending", controller.PendingView)
+		r.Post("/resend", controller.Resend)
+	})
+}
+
+func (vc *VerificationController) Verify(w http.ResponseWriter, r *http.Request) {
+	data := pages.VerifyEmailPageData{}
+
+	if err := vc.emailVerificationService.Verify(r.Context(), r.URL.Query().Get("token")); err != nil {
+		if errors.Is(err, entities.ErrEmailVerificationTokenInvalid) {
+			data.Error = err.Error()
+			w.WriteHeader(400)
+		} else {
+			data.Error = "Something went wrong, please try again"
+			w.WriteHeader(500)
+		}
+		pages.VerifyEmail(data).Render(r.Context(), w)
+		return
+	}
+
+	w.WriteHeader(200)
+	pages.VerifyEmail(data).Render(r.Context(), w)
+}
+
+func (vc *VerificationController) PendingView(w http.ResponseWriter, r *http.Request) {
+	form := components.NewResendVerificationFormData(r)
+	w.WriteHeader(200)
+	pages.VerifyPending(form).Render(r.Context(), w)
+}
+
+func (vc *VerificationController) Resend(w http.ResponseWriter, r *http.Request) {
+	form := &components.ResendVerificationFormData{
+		CSRF:  csrf.GetCSRFField(r),
+		Email: strings.TrimSpace(r.FormValue("email")),
+	}
+
+	err := vc.emailVerificationService.ResendVerification(r.Context(), &command.ResendVerificationCommand{
+		Email: form.Email,
+	})
+	if err != nil {
+		if errors.Is(err, entities.ErrVerificationEmailRateLimited) {
+			form.Error = err.Error()
+			w.WriteHeader(429)
+		} else {
+			form.Error = "Something went wrong, please try again"
+			w.WriteHeader(500)
+		}
+		components.ResendVerificationForm(form).Render(r.Context(), w)
+		return
+	}
+
+	form.Success = "If this address is waiting for verification, a new link is on its way."
+	w.WriteHeader(200)
+	components.ResendVerificationForm(form).Render(r.Context(), w)
+}
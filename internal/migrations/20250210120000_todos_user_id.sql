@@ -0,0 +1,9 @@
+-- +goose Up
+-- todos created before ownership existed can't be attributed to anyone
+DELETE FROM todos;
+ALTER TABLE todos ADD COLUMN user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE;
+CREATE INDEX todos_user_id_created_at_idx ON todos(user_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS todos_user_id_created_at_idx;
+ALTER TABLE todos DROP COLUMN user_id;
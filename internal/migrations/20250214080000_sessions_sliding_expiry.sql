@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE sessions ADD COLUMN remember BOOLEAN NOT NULL DEFAULT false;
+CREATE INDEX sessions_expires_at_idx ON sessions(expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS sessions_expires_at_idx;
+ALTER TABLE sessions DROP COLUMN remember;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE login_throttles(
+    scope VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+CREATE INDEX login_throttles_last_failure_at_idx ON login_throttles(last_failure_at);
+
+CREATE TABLE login_lockouts(
+    id SERIAL PRIMARY KEY,
+    scope VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    failures INT NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX login_lockouts_user_id_idx ON login_lockouts(user_id);
+
+-- +goose Down
+DROP TABLE login_lockouts;
+DROP TABLE login_throttles;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN verified_at TIMESTAMPTZ;
+-- accounts created before verification existed are trusted as they are
+UPDATE users SET verified_at = created_at;
+
+CREATE TABLE email_verification_tokens(
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX email_verification_tokens_user_id_idx ON email_verification_tokens(user_id);
+
+-- +goose Down
+DROP TABLE email_verification_tokens;
+ALTER TABLE users DROP COLUMN verified_at;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- promote the first admin by hand with
+-- UPDATE users SET role = 'admin' WHERE email = '...';
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'user',
+    ADD COLUMN disabled_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN disabled_at,
+    DROP COLUMN role;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- sessions created before sessions_valid_after are signed out, except the
+-- one in sessions_kept_id. Stateless session stores cannot delete sessions,
+-- this is how they are revoked.
+ALTER TABLE users
+    ADD COLUMN sessions_valid_after TIMESTAMPTZ,
+    ADD COLUMN sessions_kept_id UUID;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN sessions_kept_id,
+    DROP COLUMN sessions_valid_after;
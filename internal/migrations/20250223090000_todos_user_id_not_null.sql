@@ -0,0 +1,21 @@
+-- +goose Up
+-- databases migrated while 20250210120000 kept the todos from before
+-- ownership existed have a nullable user_id. Every new todo needs an owner,
+-- but the owner-less ones stay: the check is NOT VALID so it skips the
+-- existing rows. Give them an owner or delete them by hand, then validate:
+-- UPDATE todos SET user_id = (SELECT id FROM users WHERE email = '...') WHERE user_id IS NULL;
+-- DELETE FROM todos WHERE user_id IS NULL;
+-- ALTER TABLE todos VALIDATE CONSTRAINT todos_user_id_not_null;
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM todos WHERE user_id IS NULL) THEN
+        ALTER TABLE todos ADD CONSTRAINT todos_user_id_not_null CHECK (user_id IS NOT NULL) NOT VALID;
+    ELSE
+        ALTER TABLE todos ALTER COLUMN user_id SET NOT NULL;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+-- +goose Down
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS todos_user_id_not_null;
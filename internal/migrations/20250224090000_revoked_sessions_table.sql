@@ -0,0 +1,13 @@
+-- +goose Up
+-- sessions of the cookie store signed out before they expired, the cookie
+-- itself cannot be taken back. Rows are purged once expires_at has passed.
+CREATE TABLE revoked_sessions(
+    session_id UUID NOT NULL,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (session_id, user_id)
+);
+CREATE INDEX revoked_sessions_expires_at_idx ON revoked_sessions(expires_at);
+
+-- +goose Down
+DROP TABLE revoked_sessions;
@@ -0,0 +1,116 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"go-starter-template/pkg/migrate"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := migrate.Load(FS)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+}
+
+// testDatabase creates an empty database next to the one TEST_DATABASE_URL
+// points to and drops it when the test ends
+func testDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	target, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("TEST_DATABASE_URL: %v", err)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	name := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("failed to create the test database: %v", err)
+	}
+	target.Path = "/" + name
+
+	db, err := sql.Open("postgres", target.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		admin.Exec("DROP DATABASE IF EXISTS " + name)
+	})
+	return db
+}
+
+// todos kept by databases migrated while ownership was optional must survive
+// the migrations that made it mandatory
+func TestNoDataLoss(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+
+	migrator, err := migrate.New(db, FS)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	const notNull = 20250223090000
+	var before int64
+	for _, migration := range migrator.Migrations() {
+		if migration.Version < notNull {
+			before = migration.Version
+		}
+	}
+	if _, err := migrator.UpTo(ctx, before); err != nil {
+		t.Fatalf("UpTo() error = %v", err)
+	}
+
+	// the fixture: an owned todo and two from before ownership existed
+	var userID int
+	err = db.QueryRowContext(ctx, "INSERT INTO users (email, password) VALUES ('owner@example.com', 'x') RETURNING id").Scan(&userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"ALTER TABLE todos ALTER COLUMN user_id DROP NOT NULL",
+		fmt.Sprintf("INSERT INTO todos (title, user_id) VALUES ('owned', %d)", userID),
+		"INSERT INTO todos (title) VALUES ('orphan 1'), ('orphan 2')",
+	} {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	var total, orphans int
+	err = db.QueryRowContext(ctx, "SELECT count(*), count(*) FILTER (WHERE user_id IS NULL) FROM todos").Scan(&total, &orphans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || orphans != 2 {
+		t.Errorf("todos = %d with %d orphans, want 3 with 2", total, orphans)
+	}
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO todos (title) VALUES ('new orphan')"); err == nil {
+		t.Error("a new todo without an owner was accepted")
+	}
+}
@@ -0,0 +1,166 @@
+// Package health keeps the checks deciding whether the app can serve
+// traffic. Results are cached for a short time so frequent probes from load
+// balancers do not turn into a ping storm against the dependencies.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc returns an error when the dependency is unusable, it has to
+// honour the deadline of ctx
+type CheckFunc func(ctx context.Context) error
+
+// Checker is implemented by components that can check themselves
+type Checker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+type Result struct {
+	Status     string    `json:"status"`
+	CheckedAt  time.Time `json:"checked_at"`
+	DurationMS int64     `json:"duration_ms"`
+	err        error
+}
+
+// Err returns the error of a failed check, it is kept out of the JSON as it
+// may name internal hosts
+func (r Result) Err() error {
+	return r.err
+}
+
+type Report struct {
+	Status   string            `json:"status"`
+	Draining bool              `json:"draining,omitempty"`
+	Checks   map[string]Result `json:"checks"`
+}
+
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+
+	// mu is held while the check runs, concurrent probes wait for its
+	// result instead of running it again
+	mu      sync.Mutex
+	result  Result
+	expires time.Time
+}
+
+type Registry struct {
+	cacheTTL time.Duration
+
+	mu       sync.RWMutex
+	checks   []*check
+	draining atomic.Bool
+}
+
+// NewRegistry caches every result for cacheTTL, zero runs the checks on
+// every probe
+func NewRegistry(cacheTTL time.Duration) *Registry {
+	return &Registry{cacheTTL: cacheTTL}
+}
+
+// Register adds a named readiness check which fails when it takes longer
+// than timeout
+func (r *Registry) Register(name string, timeout time.Duration, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, &check{name: name, timeout: timeout, fn: fn})
+}
+
+// Drain makes readiness fail from now on, so load balancers stop sending
+// requests before the server shuts down
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Live reports whether the process is able to answer at all, it never
+// touches the dependencies so a database outage does not restart the app
+func (r *Registry) Live() Report {
+	return Report{Status: StatusUp, Checks: map[string]Result{}}
+}
+
+// Ready runs the checks concurrently and is up when all of them are
+func (r *Registry) Ready(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]*check(nil), r.checks...)
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	if r.Draining() {
+		report.Status = StatusDown
+		report.Draining = true
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, c *check) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(c.expires) {
+		return c.result
+	}
+
+	// the result is shared with other probes, so a cancelled request must
+	// not fail it
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	err := c.fn(checkCtx)
+	if err == nil && checkCtx.Err() != nil {
+		err = checkCtx.Err()
+	}
+
+	result := Result{Status: StatusUp, CheckedAt: now, DurationMS: time.Since(now).Milliseconds(), err: err}
+	if err != nil {
+		result.Status = StatusDown
+	}
+
+	switch {
+	case err != nil && c.result.Status != StatusDown:
+		slog.WarnContext(ctx, "health check failed", "check", c.name, "error", err)
+	case err == nil && c.result.Status == StatusDown:
+		slog.InfoContext(ctx, "health check recovered", "check", c.name)
+	}
+
+	c.result = result
+	c.expires = now.Add(r.cacheTTL)
+	return result
+}
@@ -1,50 +1,76 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"io"
+	"log/slog"
 	"os"
 )
 
 const (
-	ColorReset  = "\033[0m"
-	ColorRed    = "\033[31m"
-	ColorGreen  = "\033[32m"
-	ColorYellow = "\033[33m"
-	ColorBlue   = "\033[34m"
-	ColorCyan   = "\033[36m"
-	ColorWhite  = "\033[37m"
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
+type Options struct {
+	Level slog.Level
+	// Format is text for humans or json for log collectors
+	Format string
+}
+
+// Logger writes structured records through log/slog. Arguments after the
+// message are key value pairs or slog.Attr, as with slog.Logger.
 type Logger struct {
-	logger *log.Logger
+	*slog.Logger
 }
 
+// NewLogger returns a text logger at info level on stdout, it is used until
+// the configuration has been loaded
 func NewLogger() *Logger {
-	return &Logger{
-		logger: log.New(os.Stdout, "", log.Ldate|log.Ltime),
-	}
+	return New(os.Stdout, Options{Level: slog.LevelInfo, Format: FormatText})
 }
 
-func (l *Logger) Info(format string, v ...any) {
-	l.logger.Printf(ColorBlue+"[INFO] "+ColorReset+format, v...)
+func New(w io.Writer, opts Options) *Logger {
+	handlerOptions := &slog.HandlerOptions{Level: opts.Level}
+
+	var handler slog.Handler
+	if opts.Format == FormatJSON {
+		handler = slog.NewJSONHandler(w, handlerOptions)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOptions)
+	}
+	return &Logger{slog.New(handler)}
 }
 
-func (l *Logger) Warn(format string, v ...any) {
-	l.logger.Printf(ColorYellow+"[WARN] "+ColorReset+format, v...)
+// With returns a logger that adds the fields to every record
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{l.Logger.With(args...)}
 }
 
-func (l *Logger) Error(format string, v ...any) {
-	l.logger.Printf(ColorRed+"[ERROR] "+ColorReset+format, v...)
+// Fatal logs at error level and exits the process
+func (l *Logger) Fatal(msg string, args ...any) {
+	l.Logger.Error(msg, args...)
+	os.Exit(1)
 }
 
-func (l *Logger) Success(format string, v ...any) {
-	l.logger.Printf(ColorGreen+"[SUCCESS] "+ColorReset+format, v...)
+// SetDefault makes l the logger of log/slog, the standard log package and
+// FromContext for contexts without a logger
+func SetDefault(l *Logger) {
+	slog.SetDefault(l.Logger)
 }
 
-func (l *Logger) Debug(format string, v ...any) {
-	l.logger.Printf(ColorCyan+"[DEBUG] "+ColorReset+format, v...)
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l, usually a logger with the
+// fields of the current request
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
 }
 
-func (l *Logger) Fatal(format string, v ...any) {
-	l.logger.Fatalf(ColorRed+"[FATAL] "+ColorReset+format, v...)
+// FromContext returns the logger stored by NewContext or the default logger
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return &Logger{slog.Default()}
 }
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("record %q is not JSON: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Options{Level: slog.LevelInfo, Format: FormatJSON})
+
+	log.Debug("hidden")
+	log.With("request_id", "abc").Info("served", "status", 200, slog.String("route", "/todos/{id}"))
+	log.Warn("slow")
+
+	records := decodeLines(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("wrote %d records, want 2 as debug is below the level", len(records))
+	}
+
+	first := records[0]
+	want := map[string]any{"level": "INFO", "msg": "served", "request_id": "abc", "status": float64(200), "route": "/todos/{id}"}
+	for key, value := range want {
+		if first[key] != value {
+			t.Errorf("%s = %v, want %v in %v", key, first[key], value, first)
+		}
+	}
+	if _, ok := first["time"]; !ok {
+		t.Errorf("record %v has no time", first)
+	}
+	// With returns a new logger, the fields do not leak into the parent
+	if _, ok := records[1]["request_id"]; ok || records[1]["level"] != "WARN" {
+		t.Errorf("second record = %v, want a warning without the request id", records[1])
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Options{Level: slog.LevelDebug})
+
+	log.Debug("starting", "port", 8080)
+
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG") || !strings.Contains(got, `msg=starting port=8080`) {
+		t.Errorf("text record = %q, want logfmt with the level, message and fields", got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var defaultBuf, requestBuf bytes.Buffer
+	SetDefault(New(&defaultBuf, Options{Format: FormatJSON}))
+	requestLogger := New(&requestBuf, Options{Format: FormatJSON}).With("request_id", "abc")
+
+	FromContext(context.Background()).Info("background")
+	FromContext(NewContext(context.Background(), requestLogger)).Info("request")
+	// log/slog goes through the default logger as well
+	slog.Info("package level")
+
+	if records := decodeLines(t, &defaultBuf); len(records) != 2 || records[0]["msg"] != "background" || records[1]["msg"] != "package level" {
+		t.Errorf("default logger wrote %v, want the background and package level records", records)
+	}
+	if records := decodeLines(t, &requestBuf); len(records) != 1 || records[0]["request_id"] != "abc" {
+		t.Errorf("request logger wrote %v, want one record with the request id", records)
+	}
+}
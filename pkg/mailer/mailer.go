@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go-starter-template/pkg/logger"
+)
+
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// String renders the message in a minimal RFC 5322 form
+func (m *Message) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(m.Body)
+	return b.String()
+}
+
+// IMailer delivers transactional email. Production deployments plug in an
+// SMTP or provider backed implementation, the ones below are for development.
+type IMailer interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+// LogMailer writes every message to the logger instead of sending it
+type LogMailer struct {
+	log *logger.Logger
+}
+
+func NewLogMailer(log *logger.Logger) IMailer {
+	return &LogMailer{log}
+}
+
+func (m *LogMailer) Send(ctx context.Context, message *Message) error {
+	m.log.InfoContext(ctx, "mail sent to log", "to", message.To, "message", message.String())
+	return nil
+}
+
+// FileMailer writes every message as an .eml file into a directory so it can
+// be opened with a mail client
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) (IMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileMailer{dir}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, message *Message) error {
+	f, err := os.CreateTemp(m.dir, time.Now().Format("20060102150405")+"-*.eml")
+	if err != nil {
+		return fmt.Errorf("failed to create mail file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(message.String()); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+	return nil
+}
+
+// HealthCheck makes sure messages can still be written to the directory
+func (m *FileMailer) HealthCheck(ctx context.Context) error {
+	f, err := os.CreateTemp(m.dir, ".health-*")
+	if err != nil {
+		return fmt.Errorf("mail directory is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
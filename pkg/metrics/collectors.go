@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"database/sql"
+	"runtime"
+)
+
+// goCollector reads the runtime statistics once per scrape, reading the
+// memory statistics briefly stops the world
+type goCollector struct{}
+
+// NewGoCollector exposes goroutines, memory and garbage collection of the
+// runtime
+func NewGoCollector() Collector {
+	return goCollector{}
+}
+
+func (goCollector) Collect() []Family {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return []Family{
+		gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())),
+		gauge("go_threads", "Number of OS threads created.", threads()),
+		{
+			Name:    "go_info",
+			Help:    "Information about the Go environment.",
+			Type:    TypeGauge,
+			Samples: []Sample{{Labels: []Label{{Name: "version", Value: runtime.Version()}}, Value: 1}},
+		},
+		gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(mem.Alloc)),
+		counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(mem.TotalAlloc)),
+		gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(mem.Sys)),
+		gauge("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", float64(mem.HeapAlloc)),
+		gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(mem.HeapInuse)),
+		gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(mem.HeapObjects)),
+		counter("go_memstats_mallocs_total", "Total number of mallocs.", float64(mem.Mallocs)),
+		counter("go_memstats_frees_total", "Total number of frees.", float64(mem.Frees)),
+		counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(mem.NumGC)),
+		counter("go_gc_pause_seconds_total", "Total time spent in GC stop the world pauses.", float64(mem.PauseTotalNs)/1e9),
+		gauge("go_memstats_next_gc_bytes", "Number of heap bytes when next garbage collection will take place.", float64(mem.NextGC)),
+	}
+}
+
+func threads() float64 {
+	n, _ := runtime.ThreadCreateProfile(nil)
+	return float64(n)
+}
+
+// dbStatsCollector exposes the connection pool of a database/sql handle
+type dbStatsCollector struct {
+	db *sql.DB
+}
+
+func NewDBStatsCollector(db *sql.DB) Collector {
+	return dbStatsCollector{db}
+}
+
+func (c dbStatsCollector) Collect() []Family {
+	stats := c.db.Stats()
+
+	return []Family{
+		gauge("db_max_open_connections", "Maximum number of open connections to the database.", float64(stats.MaxOpenConnections)),
+		gauge("db_open_connections", "The number of established connections both in use and idle.", float64(stats.OpenConnections)),
+		gauge("db_in_use_connections", "The number of connections currently in use.", float64(stats.InUse)),
+		gauge("db_idle_connections", "The number of idle connections.", float64(stats.Idle)),
+		counter("db_wait_count_total", "The total number of connections waited for.", float64(stats.WaitCount)),
+		counter("db_wait_duration_seconds_total", "The total time blocked waiting for a new connection.", stats.WaitDuration.Seconds()),
+		counter("db_max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns.", float64(stats.MaxIdleClosed)),
+		counter("db_max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime.", float64(stats.MaxIdleTimeClosed)),
+		counter("db_max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime.", float64(stats.MaxLifetimeClosed)),
+	}
+}
+
+func gauge(name, help string, value float64) Family {
+	return Family{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Value: value}}}
+}
+
+func counter(name, help string, value float64) Family {
+	return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Value: value}}}
+}
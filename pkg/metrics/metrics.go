@@ -0,0 +1,271 @@
+// Package metrics is a small registry of counters, gauges and histograms
+// written in the Prometheus text exposition format. Metrics are registered
+// once, usually as package level variables, and the Default registry is
+// served on /metrics.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefBuckets suit request latencies in seconds
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector returns the current value of one or more metric families
+type Collector interface {
+	Collect() []Family
+}
+
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample is one line of a family, Suffix is appended to the family name as
+// with the _bucket, _sum and _count samples of histograms
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// vec holds one value per combination of label values
+type vec[T any] struct {
+	mu       sync.Mutex
+	labels   []string
+	children map[string]*child[T]
+	newValue func() T
+}
+
+type child[T any] struct {
+	values []string
+	value  T
+}
+
+// newVec starts metrics without labels at their zero value, so they are
+// exposed before the first update
+func newVec[T any](labels []string, newValue func() T) vec[T] {
+	children := make(map[string]*child[T])
+	if len(labels) == 0 {
+		children[""] = &child[T]{value: newValue()}
+	}
+	return vec[T]{labels: labels, children: children, newValue: newValue}
+}
+
+// with runs fn on the value of the label values while holding the lock
+func (v *vec[T]) with(labelValues []string, fn func(*T)) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(labelValues), v.labels))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.children[key]
+	if !ok {
+		c = &child[T]{values: append([]string(nil), labelValues...), value: v.newValue()}
+		v.children[key] = c
+	}
+	fn(&c.value)
+}
+
+// each visits the children ordered by their label values
+func (v *vec[T]) each(fn func(labels []Label, value *T)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		c := v.children[key]
+		labels := make([]Label, len(v.labels))
+		for i, name := range v.labels {
+			labels[i] = Label{Name: name, Value: c.values[i]}
+		}
+		fn(labels, &c.value)
+	}
+}
+
+// Counter only goes up, use NewCounter(name, help) for a counter without
+// labels
+type Counter struct {
+	name string
+	help string
+	vec[float64]
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{name: name, help: help, vec: newVec(labels, func() float64 { return 0 })}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.with(labelValues, func(value *float64) { *value += delta })
+}
+
+func (c *Counter) Collect() []Family {
+	family := Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	c.each(func(labels []Label, value *float64) {
+		family.Samples = append(family.Samples, Sample{Labels: labels, Value: *value})
+	})
+	return []Family{family}
+}
+
+// Gauge goes up and down
+type Gauge struct {
+	name string
+	help string
+	vec[float64]
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{name: name, help: help, vec: newVec(labels, func() float64 { return 0 })}
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.with(labelValues, func(v *float64) { *v = value })
+}
+
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.with(labelValues, func(v *float64) { *v += delta })
+}
+
+func (g *Gauge) Collect() []Family {
+	family := Family{Name: g.name, Help: g.help, Type: TypeGauge}
+	g.each(func(labels []Label, value *float64) {
+		family.Samples = append(family.Samples, Sample{Labels: labels, Value: *value})
+	})
+	return []Family{family}
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	vec[*histogramValue]
+}
+
+type histogramValue struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram uses DefBuckets when buckets is nil
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		vec: newVec(labels, func() *histogramValue {
+			return &histogramValue{counts: make([]uint64, len(buckets))}
+		}),
+	}
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.with(labelValues, func(v **histogramValue) {
+		hv := *v
+		for i, upper := range h.buckets {
+			if value <= upper {
+				hv.counts[i]++
+			}
+		}
+		hv.sum += value
+		hv.count++
+	})
+}
+
+func (h *Histogram) Collect() []Family {
+	family := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	h.each(func(labels []Label, v **histogramValue) {
+		hv := *v
+		for i, upper := range h.buckets {
+			family.Samples = append(family.Samples, Sample{
+				Suffix: "_bucket",
+				Labels: withLabel(labels, "le", formatValue(upper)),
+				Value:  float64(hv.counts[i]),
+			})
+		}
+		family.Samples = append(family.Samples,
+			Sample{Suffix: "_bucket", Labels: withLabel(labels, "le", "+Inf"), Value: float64(hv.count)},
+			Sample{Suffix: "_sum", Labels: labels, Value: hv.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(hv.count)},
+		)
+	})
+	return []Family{family}
+}
+
+func withLabel(labels []Label, name, value string) []Label {
+	return append(append(make([]Label, 0, len(labels)+1), labels...), Label{Name: name, Value: value})
+}
+
+// GaugeFunc reads its value when the metrics are collected
+type GaugeFunc struct {
+	family Family
+	fn     func() float64
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{family: Family{Name: name, Help: help, Type: TypeGauge}, fn: fn}
+}
+
+// NewCounterFunc is a GaugeFunc exposed as a counter, fn has to return a
+// value that never decreases
+func NewCounterFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{family: Family{Name: name, Help: help, Type: TypeCounter}, fn: fn}
+}
+
+func (g *GaugeFunc) Collect() []Family {
+	family := g.family
+	family.Samples = []Sample{{Value: g.fn()}}
+	return []Family{family}
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	registry := NewRegistry()
+
+	latency := NewHistogram("http_request_duration_seconds", "Request latency in seconds.", []float64{1, 0.1, 0.5}, "method")
+	for _, value := range []float64{0.0625, 0.25, 0.25, 2} {
+		latency.Observe(value, "GET")
+	}
+	registry.Register(latency)
+
+	requests := NewCounter("http_requests_total", "Requests by path,\nescaped \\ like this.", "path", "status")
+	requests.Inc(`/todos/"quoted"`, "200")
+	requests.Add(2, "C:\\todos\nnext", "404")
+	registry.Register(requests)
+
+	registry.Register(NewGauge("app_up", ""))
+	registry.Register(NewGaugeFunc("app_build", "Build of the app.", func() float64 { return 1.5 }))
+
+	var out strings.Builder
+	n, err := registry.WriteTo(&out)
+	if err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	// families are ordered by name, HELP comes before TYPE and is left out
+	// when empty, buckets are cumulative and end with +Inf
+	want := `# HELP app_build Build of the app.
+# TYPE app_build gauge
+app_build 1.5
+# TYPE app_up gauge
+app_up 0
+# HELP http_request_duration_seconds Request latency in seconds.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{method="GET",le="0.1"} 1
+http_request_duration_seconds_bucket{method="GET",le="0.5"} 3
+http_request_duration_seconds_bucket{method="GET",le="1"} 3
+http_request_duration_seconds_bucket{method="GET",le="+Inf"} 4
+http_request_duration_seconds_sum{method="GET"} 2.5625
+http_request_duration_seconds_count{method="GET"} 4
+# HELP http_requests_total Requests by path,\nescaped \\ like this.
+# TYPE http_requests_total counter
+http_requests_total{path="/todos/\"quoted\"",status="200"} 1
+http_requests_total{path="C:\\todos\nnext",status="404"} 2
+`
+	if got := out.String(); got != want {
+		t.Errorf("WriteTo() wrote\n%s\nwant\n%s", got, want)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("WriteTo() = %d bytes, want %d", n, len(want))
+	}
+}
+
+func TestHistogramWithoutObservations(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewHistogram("job_duration_seconds", "Job duration.", []float64{1}))
+
+	var out strings.Builder
+	if _, err := registry.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	want := `# HELP job_duration_seconds Job duration.
+# TYPE job_duration_seconds histogram
+job_duration_seconds_bucket{le="1"} 0
+job_duration_seconds_bucket{le="+Inf"} 0
+job_duration_seconds_sum 0
+job_duration_seconds_count 0
+`
+	if got := out.String(); got != want {
+		t.Errorf("WriteTo() wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGatherMergesFamilies(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewCounterFunc("jobs_total", "Jobs.", func() float64 { return 1 }))
+	registry.Register(NewCounterFunc("jobs_total", "Jobs.", func() float64 { return 2 }))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if len(families) != 1 || len(families[0].Samples) != 2 {
+		t.Fatalf("Gather() = %+v, want one family with two samples", families)
+	}
+
+	registry.Register(NewGauge("jobs_total", "Jobs."))
+	if _, err := registry.Gather(); err == nil {
+		t.Error("Gather() accepted a family registered as counter and gauge")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewCounter("jobs_total", "Jobs."))
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ContentType {
+		t.Errorf("Handler() = %d with %q, want 200 with %q", rec.Code, rec.Header().Get("Content-Type"), ContentType)
+	}
+	if want := "# HELP jobs_total Jobs.\n# TYPE jobs_total counter\njobs_total 0\n"; rec.Body.String() != want {
+		t.Errorf("Handler() body = %q, want %q", rec.Body.String(), want)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{name: "decreasing counter", fn: func() { NewCounter("jobs_total", "").Add(-1) }},
+		{name: "missing label value", fn: func() { NewCounter("jobs_total", "", "queue").Inc() }},
+		{name: "extra label value", fn: func() { NewGauge("queue_length", "").Set(1, "mail") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default is the registry served by the app, MustRegister adds to it
+var Default = NewRegistry()
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// MustRegister adds c to the Default registry and returns it, so metrics can
+// be declared in one go:
+//
+//	var loginsTotal = metrics.MustRegister(metrics.NewCounter("app_logins_total", "Logins by result", "result"))
+func MustRegister[T Collector](c T) T {
+	Default.Register(c)
+	return c
+}
+
+// Gather collects every family ordered by name. Families registered twice
+// under the same name are merged, they must agree on the type.
+func (r *Registry) Gather() ([]Family, error) {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	byName := make(map[string]*Family)
+	for _, c := range collectors {
+		for _, family := range c.Collect() {
+			existing, ok := byName[family.Name]
+			if !ok {
+				f := family
+				byName[family.Name] = &f
+				continue
+			}
+			if existing.Type != family.Type {
+				return nil, fmt.Errorf("metrics: %s registered as %s and %s", family.Name, existing.Type, family.Type)
+			}
+			existing.Samples = append(existing.Samples, family.Samples...)
+		}
+	}
+
+	families := make([]Family, 0, len(byName))
+	for _, family := range byName {
+		families = append(families, *family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families, nil
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	families, err := r.Gather()
+	if err != nil {
+		return 0, err
+	}
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, family := range families {
+		if family.Help != "" {
+			fmt.Fprintf(cw, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		}
+		fmt.Fprintf(cw, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			cw.WriteString(family.Name + sample.Suffix)
+			writeLabels(cw, sample.Labels)
+			cw.WriteString(" " + formatValue(sample.Value) + "\n")
+		}
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// Handler serves the registry to Prometheus
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var buf strings.Builder
+		if _, err := r.WriteTo(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		io.WriteString(w, buf.String())
+	})
+}
+
+func writeLabels(w *countingWriter, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	w.WriteString("{")
+	for i, label := range labels {
+		if i > 0 {
+			w.WriteString(",")
+		}
+		w.WriteString(label.Name + `="` + escapeLabelValue(label.Value) + `"`)
+	}
+	w.WriteString("}")
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingWriter) WriteString(s string) {
+	n, _ := c.w.WriteString(s)
+	c.n += int64(n)
+}
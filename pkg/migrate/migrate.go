@@ -0,0 +1,560 @@
+// Package migrate runs goose style SQL migrations against PostgreSQL. It
+// keeps the goose_db_version table of goose, so databases migrated with the
+// goose binary carry on where they were.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	versionTable = "goose_db_version"
+
+	// lockID is the key of the advisory lock held while migrating, so
+	// replicas starting together apply every migration once
+	lockID int64 = 7_262_015_233_640_071_837
+
+	annotationPrefix = "-- +goose "
+)
+
+var ErrNoMigration = errors.New("no migration to roll back")
+
+// Direction tells whether migrations were applied or rolled back
+type Direction string
+
+const (
+	Up   Direction = "up"
+	Down Direction = "down"
+)
+
+// Migration is one file named <version>_<name>.sql with an Up and a Down
+// section
+type Migration struct {
+	Version int64
+	Name    string
+	File    string
+
+	up   section
+	down section
+	// noTransaction is set by -- +goose NO TRANSACTION, for statements such
+	// as CREATE INDEX CONCURRENTLY
+	noTransaction bool
+}
+
+// section is the Up or Down part of a migration. Inside a transaction sql
+// runs as one multi statement query. PostgreSQL runs such a query in an
+// implicit transaction though, so without one the statements run one at a
+// time.
+type section struct {
+	sql        string
+	statements []string
+}
+
+// Status is a migration and when it was applied, AppliedAt is zero for
+// pending migrations
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load reads the migrations in the root of fsys ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	seen := make(map[int64]string)
+	for _, file := range files {
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(path.Base(file), ".sql"), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", file)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, file)
+		}
+		seen[version] = file
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		migration := Migration{Version: version, Name: name, File: file}
+		if err := migration.parse(string(content)); err != nil {
+			return nil, fmt.Errorf("migration %s: %w", file, err)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parse splits the file at the -- +goose Up and -- +goose Down annotations.
+// A statement ends with a line ending in a semicolon, like with goose, and
+// statements containing such lines, as functions do, go between
+// -- +goose StatementBegin and -- +goose StatementEnd. The markers are only
+// needed for NO TRANSACTION migrations, the others run as a whole.
+func (m *Migration) parse(content string) error {
+	var up, down sectionParser
+	var current *sectionParser
+	hasUp := false
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, annotationPrefix) {
+			if current != nil {
+				current.add(line)
+			} else if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return errors.New("statements before -- +goose Up")
+			}
+			continue
+		}
+
+		switch annotation := strings.TrimSpace(strings.TrimPrefix(trimmed, annotationPrefix)); annotation {
+		case "Up":
+			current = &up
+			hasUp = true
+		case "Down":
+			current = &down
+		case "NO TRANSACTION":
+			m.noTransaction = true
+		case "StatementBegin":
+			if current == nil || current.inBlock {
+				return errors.New("unexpected -- +goose StatementBegin")
+			}
+			current.flush()
+			current.inBlock = true
+		case "StatementEnd":
+			if current == nil || !current.inBlock {
+				return errors.New("-- +goose StatementEnd without StatementBegin")
+			}
+			current.inBlock = false
+			current.flush()
+		default:
+			return fmt.Errorf("unknown annotation %q", annotation)
+		}
+	}
+
+	if !hasUp {
+		return errors.New("missing -- +goose Up annotation")
+	}
+	if up.inBlock || down.inBlock {
+		return errors.New("-- +goose StatementBegin without StatementEnd")
+	}
+	m.up = up.section()
+	m.down = down.section()
+	return nil
+}
+
+type sectionParser struct {
+	sql        strings.Builder
+	statements []string
+	statement  strings.Builder
+	// hasSQL is set once the statement has more than comments
+	hasSQL  bool
+	inBlock bool
+}
+
+func (p *sectionParser) add(line string) {
+	p.sql.WriteString(line)
+	p.statement.WriteString(line)
+
+	trimmed := strings.TrimSpace(line)
+	if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+		p.hasSQL = true
+	}
+	if !p.inBlock && strings.HasSuffix(trimmed, ";") {
+		p.flush()
+	}
+}
+
+func (p *sectionParser) flush() {
+	if p.hasSQL {
+		p.statements = append(p.statements, strings.TrimSpace(p.statement.String()))
+	}
+	p.statement.Reset()
+	p.hasSQL = false
+}
+
+func (p *sectionParser) section() section {
+	p.flush()
+	return section{sql: p.sql.String(), statements: p.statements}
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Migrations returns the migrations found in the files
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// queryer is a *sql.DB or the *sql.Conn holding the lock
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// applied returns when each applied version was applied. goose versions
+// before 3.16 add a row for every down instead of deleting the row of the
+// up, so the latest row of a version decides.
+func applied(ctx context.Context, q queryer) (map[int64]time.Time, error) {
+	var exists bool
+	if err := q.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", versionTable).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look for the version table: %w", err)
+	}
+	versions := make(map[int64]time.Time)
+	if !exists {
+		return versions, nil
+	}
+
+	rows, err := q.QueryContext(ctx,
+		"SELECT DISTINCT ON (version_id) version_id, is_applied, COALESCE(tstamp, now()) FROM "+versionTable+" ORDER BY version_id, id DESC",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the version table: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &isApplied, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read the version table: %w", err)
+		}
+		if isApplied && version > 0 {
+			versions[version] = appliedAt
+		}
+	}
+	return versions, rows.Err()
+}
+
+// Status lists every migration found in the files with its state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	versions, err := applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := versions[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+// Version returns the highest applied version, 0 for an empty database
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	versions, err := applied(ctx, m.db)
+	if err != nil {
+		return 0, err
+	}
+	return latest(versions), nil
+}
+
+// Pending returns the migrations not applied yet
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	versions, err := applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	return m.pending(versions, 0), nil
+}
+
+// Up applies every pending migration and returns the ones it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.UpTo(ctx, 0)
+}
+
+// UpTo applies the pending migrations up to and including version, 0 means
+// all of them. Migrations missing below the current version, as after
+// merging branches, are applied as well.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) (err error) {
+		done, err = m.upTo(ctx, conn, version)
+		return err
+	})
+	return done, err
+}
+
+// To applies or rolls back migrations until version is the latest applied
+// one, 0 rolls back everything. It returns the direction it went in, which
+// is only known once it holds the lock.
+func (m *Migrator) To(ctx context.Context, version int64) ([]Migration, Direction, error) {
+	if version != 0 && !m.has(version) {
+		return nil, "", fmt.Errorf("there is no migration with version %d", version)
+	}
+
+	var done []Migration
+	direction := Up
+	// the direction is decided under the lock, another replica may migrate
+	// while this one waits for it
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if version == 0 || version < latest(versions) {
+			direction = Down
+			done, err = m.downTo(ctx, conn, version)
+		} else {
+			done, err = m.upTo(ctx, conn, version)
+		}
+		return err
+	})
+	return done, direction, err
+}
+
+// Down rolls back the latest applied migration
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var done *Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		migration, err := m.latestApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := run(ctx, conn, migration, false); err != nil {
+			return err
+		}
+		done = &migration
+		return nil
+	})
+	return done, err
+}
+
+// DownTo rolls back the applied migrations above version, newest first
+func (m *Migrator) DownTo(ctx context.Context, version int64) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) (err error) {
+		done, err = m.downTo(ctx, conn, version)
+		return err
+	})
+	return done, err
+}
+
+// Redo rolls back the latest applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) (*Migration, error) {
+	var done *Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		migration, err := m.latestApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := run(ctx, conn, migration, false); err != nil {
+			return err
+		}
+		if err := run(ctx, conn, migration, true); err != nil {
+			return err
+		}
+		done = &migration
+		return nil
+	})
+	return done, err
+}
+
+// upTo and downTo run on the connection holding the lock
+
+func (m *Migrator) upTo(ctx context.Context, conn *sql.Conn, version int64) ([]Migration, error) {
+	versions, err := applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	done := make([]Migration, 0)
+	for _, migration := range m.pending(versions, version) {
+		if err := run(ctx, conn, migration, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+func (m *Migrator) downTo(ctx context.Context, conn *sql.Conn, version int64) ([]Migration, error) {
+	done := make([]Migration, 0)
+	for {
+		migration, err := m.latestApplied(ctx, conn)
+		if errors.Is(err, ErrNoMigration) {
+			return done, nil
+		}
+		if err != nil {
+			return done, err
+		}
+		if migration.Version <= version {
+			return done, nil
+		}
+		if err := run(ctx, conn, migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+}
+
+func (m *Migrator) pending(versions map[int64]time.Time, upTo int64) []Migration {
+	pending := make([]Migration, 0)
+	for _, migration := range m.migrations {
+		if upTo > 0 && migration.Version > upTo {
+			break
+		}
+		if _, ok := versions[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+func (m *Migrator) latestApplied(ctx context.Context, q queryer) (Migration, error) {
+	versions, err := applied(ctx, q)
+	if err != nil {
+		return Migration{}, err
+	}
+	version := latest(versions)
+	if version == 0 {
+		return Migration{}, ErrNoMigration
+	}
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, nil
+		}
+	}
+	return Migration{}, fmt.Errorf("version %d is applied but its migration file is missing", version)
+}
+
+func (m *Migrator) has(version int64) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func latest(versions map[int64]time.Time) int64 {
+	var version int64
+	for v := range versions {
+		version = max(version, v)
+	}
+	return version
+}
+
+// withLock runs fn on a single connection holding the advisory lock, other
+// replicas wait until the lock is released
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockID)
+
+	if err := ensureVersionTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureVersionTable(ctx context.Context, q queryer) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", versionTable).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look for the version table: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	tx, err := q.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the same table and first row goose creates
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE `+versionTable+` (
+		id SERIAL PRIMARY KEY,
+		version_id BIGINT NOT NULL,
+		is_applied BOOLEAN NOT NULL,
+		tstamp TIMESTAMP DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("failed to create the version table: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO "+versionTable+" (version_id, is_applied) VALUES (0, true)"); err != nil {
+		return fmt.Errorf("failed to create the version table: %w", err)
+	}
+	return tx.Commit()
+}
+
+// run applies or rolls back one migration together with its version row
+func run(ctx context.Context, q queryer, migration Migration, up bool) error {
+	statements, record, direction := migration.up, "INSERT INTO "+versionTable+" (version_id, is_applied) VALUES ($1, true)", Up
+	if !up {
+		statements, record, direction = migration.down, "DELETE FROM "+versionTable+" WHERE version_id = $1", Down
+	}
+
+	wrap := func(err error) error {
+		return fmt.Errorf("failed to migrate %s %s: %w", direction, migration.File, err)
+	}
+
+	if migration.noTransaction {
+		for _, statement := range statements.statements {
+			if _, err := q.ExecContext(ctx, statement); err != nil {
+				return wrap(err)
+			}
+		}
+		if _, err := q.ExecContext(ctx, record, migration.Version); err != nil {
+			return wrap(err)
+		}
+		return nil
+	}
+
+	tx, err := q.BeginTx(ctx, nil)
+	if err != nil {
+		return wrap(err)
+	}
+	defer tx.Rollback()
+
+	// without arguments the statements go out as one simple query, which
+	// PostgreSQL runs one after another
+	if len(statements.statements) > 0 {
+		if _, err := tx.ExecContext(ctx, statements.sql); err != nil {
+			return wrap(err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, migration.Version); err != nil {
+		return wrap(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return wrap(err)
+	}
+	return nil
+}
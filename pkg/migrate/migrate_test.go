@@ -0,0 +1,353 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeDB stands in for PostgreSQL: it keeps the applied versions, records
+// the migration statements and implements the advisory lock as a channel
+type fakeDB struct {
+	lock    chan struct{}
+	waiting chan struct{}
+
+	mu         sync.Mutex
+	applied    map[int64]bool
+	statements []string
+}
+
+func newFakeDB(applied ...int64) *fakeDB {
+	db := &fakeDB{
+		lock:    make(chan struct{}, 1),
+		waiting: make(chan struct{}, 1),
+		applied: make(map[int64]bool),
+	}
+	for _, version := range applied {
+		db.applied[version] = true
+	}
+	return db
+}
+
+func (db *fakeDB) versions() []int64 {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	versions := make([]int64, 0, len(db.applied))
+	for version := range db.applied {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory_lock"):
+		select {
+		case db.waiting <- struct{}{}:
+		default:
+		}
+		select {
+		case db.lock <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock"):
+		<-db.lock
+	case strings.HasPrefix(query, "INSERT INTO "+versionTable):
+		db.mu.Lock()
+		db.applied[args[0].Value.(int64)] = true
+		db.mu.Unlock()
+	case strings.HasPrefix(query, "DELETE FROM "+versionTable):
+		db.mu.Lock()
+		delete(db.applied, args[0].Value.(int64))
+		db.mu.Unlock()
+	default:
+		db.mu.Lock()
+		db.statements = append(db.statements, strings.TrimSpace(query))
+		db.mu.Unlock()
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT to_regclass"):
+		return &fakeRows{columns: []string{"exists"}, values: [][]driver.Value{{true}}}, nil
+	case strings.Contains(query, "FROM "+versionTable):
+		rows := &fakeRows{columns: []string{"version_id", "is_applied", "tstamp"}}
+		for _, version := range c.db.versions() {
+			rows.values = append(rows.values, []driver.Value{version, true, time.Now()})
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query " + query)
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var testMigrations = fstest.MapFS{
+	"1_users.sql": {Data: []byte("-- +goose Up\nCREATE TABLE users ();\n-- +goose Down\nDROP TABLE users;\n")},
+	"2_todos.sql": {Data: []byte("-- +goose Up\nCREATE TABLE todos ();\n-- +goose Down\nDROP TABLE todos;\n")},
+	"3_tags.sql":  {Data: []byte("-- +goose Up\nCREATE TABLE tags ();\n-- +goose Down\nDROP TABLE tags;\n")},
+}
+
+func newTestMigrator(t *testing.T, db *fakeDB) *Migrator {
+	t.Helper()
+	sqlDB := sql.OpenDB(db)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := New(sqlDB, testMigrations)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return migrator
+}
+
+func TestTo(t *testing.T) {
+	tests := []struct {
+		name       string
+		applied    []int64
+		version    int64
+		want       []int64
+		direction  Direction
+		statements []string
+	}{
+		{name: "up", applied: []int64{1}, version: 3, want: []int64{1, 2, 3}, direction: Up, statements: []string{"CREATE TABLE todos ();", "CREATE TABLE tags ();"}},
+		{name: "down", applied: []int64{1, 2, 3}, version: 1, want: []int64{1}, direction: Down, statements: []string{"DROP TABLE tags;", "DROP TABLE todos;"}},
+		{name: "everything down", applied: []int64{1, 2}, version: 0, want: []int64{}, direction: Down, statements: []string{"DROP TABLE todos;", "DROP TABLE users;"}},
+		{name: "current", applied: []int64{1, 2}, version: 2, want: []int64{1, 2}, direction: Up},
+		{name: "missing below", applied: []int64{1, 3}, version: 3, want: []int64{1, 2, 3}, direction: Up, statements: []string{"CREATE TABLE todos ();"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB(tt.applied...)
+			_, direction, err := newTestMigrator(t, db).To(context.Background(), tt.version)
+			if err != nil {
+				t.Fatalf("To() error = %v", err)
+			}
+			if direction != tt.direction {
+				t.Errorf("direction = %q, want %q", direction, tt.direction)
+			}
+			if got := db.versions(); !slices.Equal(got, tt.want) {
+				t.Errorf("applied = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(db.statements, tt.statements) {
+				t.Errorf("statements = %q, want %q", db.statements, tt.statements)
+			}
+		})
+	}
+}
+
+func TestToUnknownVersion(t *testing.T) {
+	db := newFakeDB(1)
+	if _, _, err := newTestMigrator(t, db).To(context.Background(), 9); err == nil {
+		t.Fatal("To() of a missing version succeeded")
+	}
+	if len(db.statements) != 0 {
+		t.Errorf("statements = %q, want none", db.statements)
+	}
+}
+
+// TestToDecidesUnderLock has another replica migrate up while To waits for
+// the lock, To has to look at the version it finds once it holds the lock
+func TestToDecidesUnderLock(t *testing.T) {
+	db := newFakeDB(1, 2)
+	migrator := newTestMigrator(t, db)
+
+	// the other replica holds the lock
+	db.lock <- struct{}{}
+
+	done := make(chan error, 1)
+	var direction Direction
+	go func() {
+		var err error
+		_, direction, err = migrator.To(context.Background(), 2)
+		done <- err
+	}()
+
+	select {
+	case <-db.waiting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("To() never asked for the lock")
+	}
+	db.mu.Lock()
+	db.applied[3] = true
+	db.mu.Unlock()
+	<-db.lock
+
+	if err := <-done; err != nil {
+		t.Fatalf("To() error = %v", err)
+	}
+	if got := db.versions(); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("applied = %v, want [1 2]", got)
+	}
+	if direction != Down {
+		t.Errorf("direction = %q, want down", direction)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr string
+	}{
+		{name: "bad name", files: fstest.MapFS{"users.sql": {Data: []byte("-- +goose Up\n")}}, wantErr: "is not named"},
+		{name: "same version", files: fstest.MapFS{
+			"1_users.sql": {Data: []byte("-- +goose Up\n")},
+			"1_todos.sql": {Data: []byte("-- +goose Up\n")},
+		}, wantErr: "have the same version"},
+		{name: "no up", files: fstest.MapFS{"1_users.sql": {Data: []byte("-- +goose Down\nDROP TABLE users;\n")}}, wantErr: "missing -- +goose Up"},
+		{name: "statements before up", files: fstest.MapFS{"1_users.sql": {Data: []byte("CREATE TABLE users ();\n-- +goose Up\n")}}, wantErr: "statements before"},
+		{name: "unknown annotation", files: fstest.MapFS{"1_users.sql": {Data: []byte("-- +goose Up\n-- +goose Sideways\n")}}, wantErr: "unknown annotation"},
+		{name: "unfinished block", files: fstest.MapFS{"1_users.sql": {Data: []byte("-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n")}}, wantErr: "without StatementEnd"},
+		{name: "stray block end", files: fstest.MapFS{"1_users.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n-- +goose StatementEnd\n")}}, wantErr: "without StatementBegin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.files)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSections(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"20_index.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY i ON t (c);\n-- +goose Down\nDROP INDEX i;\n")},
+		"3_func.sql":   {Data: []byte("-- a comment\n-- +goose Up\n-- +goose StatementBegin\nCREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\n-- +goose StatementEnd\n")},
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if versions := []int64{migrations[0].Version, migrations[1].Version}; !slices.Equal(versions, []int64{3, 20}) {
+		t.Fatalf("versions = %v, want [3 20]", versions)
+	}
+	if fn := migrations[0]; fn.Name != "func" || strings.TrimSpace(fn.up.sql) != "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;" || fn.down.sql != "" || fn.noTransaction {
+		t.Errorf("func migration = %+v", fn)
+	}
+	if index := migrations[1]; !index.noTransaction || strings.TrimSpace(index.down.sql) != "DROP INDEX i;" {
+		t.Errorf("index migration = %+v", index)
+	}
+}
+
+func TestLoadStatements(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{"1_search.sql": {Data: []byte(`-- +goose Up
+-- trigram search on the titles
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX todos_title_trgm
+    ON todos USING gin (title gin_trgm_ops);
+-- +goose StatementBegin
+CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+-- trailing comment
+
+-- +goose Down
+DROP FUNCTION touch();
+DROP INDEX todos_title_trgm;
+`)}})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	up := []string{
+		"-- trigram search on the titles\nCREATE EXTENSION IF NOT EXISTS pg_trgm;",
+		"CREATE INDEX todos_title_trgm\n    ON todos USING gin (title gin_trgm_ops);",
+		"CREATE FUNCTION touch() RETURNS trigger AS $$\nBEGIN\n    NEW.updated_at = now();\n    RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql;",
+	}
+	if got := migrations[0].up.statements; !slices.Equal(got, up) {
+		t.Errorf("up statements = %q, want %q", got, up)
+	}
+	down := []string{"DROP FUNCTION touch();", "DROP INDEX todos_title_trgm;"}
+	if got := migrations[0].down.statements; !slices.Equal(got, down) {
+		t.Errorf("down statements = %q, want %q", got, down)
+	}
+}
+
+// PostgreSQL runs a multi statement query in an implicit transaction, where
+// CREATE INDEX CONCURRENTLY fails, so NO TRANSACTION migrations send their
+// statements one by one
+func TestNoTransactionStatements(t *testing.T) {
+	db := newFakeDB()
+	sqlDB := sql.OpenDB(db)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := New(sqlDB, fstest.MapFS{
+		"1_index.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY a ON t (a);\nCREATE INDEX CONCURRENTLY b ON t (b);\n-- +goose Down\nDROP INDEX CONCURRENTLY a;\nDROP INDEX CONCURRENTLY b;\n")},
+		"2_todos.sql": {Data: []byte("-- +goose Up\nCREATE TABLE x ();\nCREATE TABLE y ();\n")},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	want := []string{
+		"CREATE INDEX CONCURRENTLY a ON t (a);",
+		"CREATE INDEX CONCURRENTLY b ON t (b);",
+		"CREATE TABLE x ();\nCREATE TABLE y ();",
+	}
+	if !slices.Equal(db.statements, want) {
+		t.Errorf("statements = %q, want %q", db.statements, want)
+	}
+
+	db.statements = nil
+	if _, _, err := migrator.To(context.Background(), 0); err != nil {
+		t.Fatalf("To() error = %v", err)
+	}
+	want = []string{"DROP INDEX CONCURRENTLY a;", "DROP INDEX CONCURRENTLY b;"}
+	if !slices.Equal(db.statements, want) {
+		t.Errorf("statements = %q, want %q", db.statements, want)
+	}
+}
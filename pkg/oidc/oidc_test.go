@@ -0,0 +1,395 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-starter-template/pkg/oidc/oidctest"
+)
+
+const (
+	testClientID     = "app"
+	testClientSecret = "app-secret"
+	testRedirectURL  = "https://app.example.com/auth/test/callback"
+)
+
+// countingTransport counts the requests to every path, so tests can tell when
+// the discovery document and the keys are fetched
+type countingTransport struct {
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	if c.requests == nil {
+		c.requests = make(map[string]int)
+	}
+	c.requests[r.URL.Path]++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (c *countingTransport) count(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests[path]
+}
+
+func newTestClient(t *testing.T) (*oidctest.IdP, *Client, *countingTransport) {
+	t.Helper()
+	idp := oidctest.NewIdP(testClientID, testClientSecret)
+	t.Cleanup(idp.Close)
+
+	transport := &countingTransport{}
+	client := NewClient(Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, &http.Client{Transport: transport})
+	return idp, client, transport
+}
+
+// authorize follows the login URL to the provider and returns the callback
+// parameters it redirects back with
+func authorize(t *testing.T, client *Client, state, nonce, verifier string) url.Values {
+	t.Helper()
+	authURL, err := client.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", authURL, err)
+	}
+	res.Body.Close()
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), testRedirectURL) {
+		t.Fatalf("provider redirected to %q, want the redirect URL", res.Header.Get("Location"))
+	}
+	return location.Query()
+}
+
+// idToken runs a whole login and returns the raw ID token
+func idToken(t *testing.T, client *Client, nonce string) string {
+	t.Helper()
+	callback := authorize(t, client, "state", nonce, "verifier")
+	token, err := client.Exchange(context.Background(), callback.Get("code"), "verifier")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	return token.IDToken
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp, client, transport := newTestClient(t)
+
+	authURL, err := client.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	parsed, _ := url.Parse(authURL)
+	if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != idp.Issuer()+"/authorize" {
+		t.Errorf("endpoint = %q, want the discovered authorization endpoint", got)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for key, value := range want {
+		if got := parsed.Query().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	// the verifier only travels with the code exchange
+	if strings.Contains(authURL, "verifier") {
+		t.Errorf("AuthCodeURL() = %q leaks the verifier", authURL)
+	}
+
+	if _, err := client.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	if got := transport.count("/.well-known/openid-configuration"); got != 1 {
+		t.Errorf("discovery fetched %d times, want 1", got)
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	if got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("CodeChallenge() = %q", got)
+	}
+}
+
+func TestDiscoveryFailed(t *testing.T) {
+	idp := oidctest.NewIdP(testClientID, testClientSecret)
+	defer idp.Close()
+
+	tests := []struct {
+		name   string
+		issuer string
+	}{
+		// the document names the issuer without the slash, which is not the
+		// same issuer
+		{name: "issuer mismatch", issuer: idp.Issuer() + "/"},
+		{name: "no document", issuer: idp.Issuer() + "/tenant"},
+		{name: "unreachable", issuer: "http://127.0.0.1:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(Config{Issuer: tt.issuer, ClientID: testClientID}, nil)
+			_, err := client.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+			if !errors.Is(err, ErrDiscoveryFailed) {
+				t.Fatalf("AuthCodeURL() error = %v, want %v", err, ErrDiscoveryFailed)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	idp, client, transport := newTestClient(t)
+	idp.SetUser(oidctest.User{Subject: "ada", Email: "ada@example.com", EmailVerified: true, Name: "Ada"})
+
+	callback := authorize(t, client, "the-state", "the-nonce", "the-verifier")
+	if got := callback.Get("state"); got != "the-state" {
+		t.Errorf("state = %q, want it sent back unchanged", got)
+	}
+
+	token, err := client.Exchange(context.Background(), callback.Get("code"), "the-verifier")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	claims, err := client.VerifyIDToken(context.Background(), token.IDToken, "the-nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken() error = %v", err)
+	}
+	if claims.Subject != "ada" || claims.Email != "ada@example.com" || !bool(claims.EmailVerified) || claims.Name != "Ada" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// the keys are cached between logins
+	if _, err := client.VerifyIDToken(context.Background(), idToken(t, client, "n"), "n"); err != nil {
+		t.Fatalf("VerifyIDToken() error = %v", err)
+	}
+	if got := transport.count("/jwks"); got != 1 {
+		t.Errorf("keys fetched %d times, want 1", got)
+	}
+}
+
+func TestExchangeFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		reuse    bool
+		secret   string
+	}{
+		{name: "PKCE verifier mismatch", verifier: "another-verifier", secret: testClientSecret},
+		{name: "code used twice", verifier: "verifier", reuse: true, secret: testClientSecret},
+		{name: "wrong client secret", verifier: "verifier", secret: "guess"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client, _ := newTestClient(t)
+			client.config.ClientSecret = tt.secret
+			code := authorize(t, client, "state", "nonce", "verifier").Get("code")
+
+			if tt.reuse {
+				if _, err := client.Exchange(context.Background(), code, tt.verifier); err != nil {
+					t.Fatalf("first Exchange() error = %v", err)
+				}
+			}
+			_, err := client.Exchange(context.Background(), code, tt.verifier)
+			if !errors.Is(err, ErrExchangeFailed) {
+				t.Fatalf("Exchange() error = %v, want %v", err, ErrExchangeFailed)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenClaims(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		nonce   string
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "nonce mismatch", nonce: "another-nonce", wantErr: "nonce mismatch"},
+		{name: "wrong issuer", claims: map[string]any{"iss": "https://evil.example.com"}, wantErr: "unexpected issuer"},
+		{name: "wrong audience", claims: map[string]any{"aud": "another-app"}, wantErr: "not meant for this client"},
+		{name: "audience list", claims: map[string]any{"aud": []string{"another-app", testClientID}, "azp": testClientID}},
+		{name: "audience list for another party", claims: map[string]any{"aud": []string{"another-app", testClientID}, "azp": "another-app"}, wantErr: "issued to another party"},
+		{name: "missing subject", claims: map[string]any{"sub": ""}, wantErr: "missing subject"},
+		{name: "expired", claims: map[string]any{"exp": now.Add(-2 * clockSkew).Unix()}, wantErr: "token expired"},
+		{name: "expired within the clock skew", claims: map[string]any{"exp": now.Add(-clockSkew / 2).Unix()}},
+		{name: "no expiry", claims: map[string]any{"exp": 0}, wantErr: "token expired"},
+		{name: "issued in the future", claims: map[string]any{"iat": now.Add(2 * clockSkew).Unix()}, wantErr: "issued in the future"},
+		{name: "email_verified as a string", claims: map[string]any{"email_verified": "true"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp, client, _ := newTestClient(t)
+			idp.IDTokenClaims = tt.claims
+			raw := idToken(t, client, "nonce")
+
+			nonce := "nonce"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			claims, err := client.VerifyIDToken(context.Background(), raw, nonce)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyIDToken() error = %v", err)
+				}
+				if !bool(claims.EmailVerified) {
+					t.Errorf("email_verified = false, want true")
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidIDToken) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("VerifyIDToken() error = %v, want %v containing %q", err, ErrInvalidIDToken, tt.wantErr)
+			}
+		})
+	}
+}
+
+// forge returns the token with the header or the claims replaced, keeping
+// the original signature
+func forge(t *testing.T, raw string, header map[string]string, claims map[string]any) string {
+	t.Helper()
+	parts := strings.Split(raw, ".")
+	if header != nil {
+		b, _ := json.Marshal(header)
+		parts[0] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	if claims != nil {
+		var original map[string]any
+		if err := decodeSegment(parts[1], &original); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range claims {
+			original[k] = v
+		}
+		b, _ := json.Marshal(original)
+		parts[1] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return strings.Join(parts, ".")
+}
+
+func TestVerifyIDTokenForged(t *testing.T) {
+	tests := []struct {
+		name    string
+		forge   func(t *testing.T, raw string) string
+		wantErr string
+	}{
+		{
+			name:    "changed claims",
+			forge:   func(t *testing.T, raw string) string { return forge(t, raw, nil, map[string]any{"sub": "admin"}) },
+			wantErr: "verification",
+		},
+		{
+			name: "truncated signature",
+			forge: func(t *testing.T, raw string) string {
+				return raw[:strings.LastIndex(raw, ".")+1] + base64.RawURLEncoding.EncodeToString([]byte("short"))
+			},
+			wantErr: "verification",
+		},
+		{
+			name: "unknown key id",
+			forge: func(t *testing.T, raw string) string {
+				return forge(t, raw, map[string]string{"alg": "RS256", "kid": "rotated"}, nil)
+			},
+			wantErr: `unknown signing key "rotated"`,
+		},
+		{
+			name: "alg none",
+			forge: func(t *testing.T, raw string) string {
+				return forge(t, raw, map[string]string{"alg": "none", "kid": "oidctest"}, nil)
+			},
+			wantErr: `unsupported algorithm "none"`,
+		},
+		{
+			name: "symmetric algorithm",
+			forge: func(t *testing.T, raw string) string {
+				return forge(t, raw, map[string]string{"alg": "HS256", "kid": "oidctest"}, nil)
+			},
+			wantErr: `unsupported algorithm "HS256"`,
+		},
+		{
+			name: "key of another type",
+			forge: func(t *testing.T, raw string) string {
+				return forge(t, raw, map[string]string{"alg": "ES256", "kid": "oidctest"}, nil)
+			},
+			wantErr: "key does not match",
+		},
+		{
+			name:    "malformed",
+			forge:   func(t *testing.T, raw string) string { return strings.Replace(raw, ".", "", 1) },
+			wantErr: "malformed token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client, transport := newTestClient(t)
+			raw := idToken(t, client, "nonce")
+			if _, err := client.VerifyIDToken(context.Background(), raw, "nonce"); err != nil {
+				t.Fatalf("VerifyIDToken() of the original error = %v", err)
+			}
+
+			_, err := client.VerifyIDToken(context.Background(), tt.forge(t, raw), "nonce")
+			if !errors.Is(err, ErrInvalidIDToken) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("VerifyIDToken() error = %v, want %v containing %q", err, ErrInvalidIDToken, tt.wantErr)
+			}
+			// forged key ids must not make the client hammer the provider
+			if got := transport.count("/jwks"); got != 1 {
+				t.Errorf("keys fetched %d times, want 1", got)
+			}
+		})
+	}
+}
+
+func TestUnknownKeyRefetch(t *testing.T) {
+	_, client, transport := newTestClient(t)
+	raw := idToken(t, client, "nonce")
+
+	// keys fetched before the provider rotated to the current one
+	client.discover(context.Background())
+	client.keys.keys = map[string]crypto.PublicKey{}
+	client.keys.fetchedAt = time.Now().Add(-2 * keyRefreshInterval)
+
+	if _, err := client.VerifyIDToken(context.Background(), raw, "nonce"); err != nil {
+		t.Fatalf("VerifyIDToken() error = %v", err)
+	}
+	if got := transport.count("/jwks"); got != 1 {
+		t.Errorf("keys fetched %d times, want 1", got)
+	}
+}
@@ -0,0 +1,19 @@
+package qrcode
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// DataURI renders content as a PNG QR code of size by size pixels and returns
+// it as a data URI, ready to be used as the src of an img tag. Everything is
+// generated in process so secrets never leave the server.
+func DataURI(content string, size int) (string, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, size)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode qr code: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
+}
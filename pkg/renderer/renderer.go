@@ -48,12 +48,12 @@ func ParseTemplate(page string, layout ...string) *template.Template {
 
 	tmpl, err := baseTemplate.Clone()
 	if err != nil {
-		rendererLog.Fatal("failed to clone base template: %v", err)
+		rendererLog.Fatal("failed to clone base template", "error", err)
 	}
 
 	tmpl, err = tmpl.ParseFiles(layoutFile, file)
 	if err != nil {
-		rendererLog.Fatal("failed to parse template: %v", err)
+		rendererLog.Fatal("failed to parse template", "page", page, "error", err)
 	}
 
 	return tmpl
@@ -68,7 +68,7 @@ func RenderString(w http.ResponseWriter, html string, data any) error {
 	tmpl, err := template.New("").Parse(html)
 	if err != nil {
 		errorMsg := "failed to parse string"
-		rendererLog.Error("%s\n", errorMsg)
+		rendererLog.Error(errorMsg, "error", err)
 		return fmt.Errorf("%s", errorMsg)
 	}
 	return tmpl.Execute(w, data)
@@ -0,0 +1,91 @@
+package router
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// specificity ranks exact types above type/* above */*
+func (m mediaRange) specificity() int {
+	switch {
+	case m.typ == "*":
+		return 0
+	case m.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (m mediaRange) matches(typ, subtype string) bool {
+	return (m.typ == "*" || m.typ == typ) && (m.subtype == "*" || m.subtype == subtype)
+}
+
+func parseAccept(header string) []mediaRange {
+	ranges := make([]mediaRange, 0)
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// quality returns the q value the client assigned to the media type using the
+// most specific matching range, or 0 if the type is not acceptable
+func quality(ranges []mediaRange, offer string) float64 {
+	typ, subtype, ok := strings.Cut(offer, "/")
+	if !ok {
+		return 0
+	}
+
+	best, q := -1, 0.0
+	for _, m := range ranges {
+		if m.matches(typ, subtype) && m.specificity() > best {
+			best, q = m.specificity(), m.q
+		}
+	}
+	return q
+}
+
+// Negotiate picks the offered media type the client prefers according to its
+// Accept header. A missing Accept header accepts the first offer. It returns
+// an empty string when none of the offers are acceptable.
+func Negotiate(r *http.Request, offers ...string) string {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		if len(offers) > 0 {
+			return offers[0]
+		}
+		return ""
+	}
+
+	ranges := parseAccept(header)
+	chosen, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQ {
+			chosen, bestQ = offer, q
+		}
+	}
+	return chosen
+}
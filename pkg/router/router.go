@@ -3,6 +3,7 @@ package router
 import (
 	"context"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -19,81 +20,118 @@ type Router interface {
 	Patch(pattern string, h http.HandlerFunc)
 	Delete(pattern string, h http.HandlerFunc)
 	Route(pattern string, fn func(r Router)) Router
-	// TODO: implement if grouping of endpoints is needed
+	Group(fn func(r Router)) Router
+	Routes() []Endpoint
 	// refer to chi's doc for more interfaces. https://github.com/go-chi/chi
 }
 
 type contextKey string
 
-const paramsContextKey contextKey = "params"
+const (
+	paramsContextKey contextKey = "params"
+	routeContextKey  contextKey = "route"
+)
+
+// routeInfo is put into the context before the global middlewares run and
+// filled in by dispatch, so those middlewares can see the matched route once
+// the request has been served
+type routeInfo struct {
+	pattern string
+}
 
-type Route struct {
-	handler    http.Handler
-	paramNames []string
-	hasParams  bool
+// Endpoint is a registered route. Method is "*" for routes added with Handle
+// or HandleFunc, they serve every method.
+type Endpoint struct {
+	Method  string
+	Pattern string
 }
 
+// NetServerMux middlewares come in two flavours. Middlewares added with Use
+// on the root mux wrap the whole dispatch, so they also run for unmatched
+// paths. Every other middleware (Use inside Route/Group, With) is inline: it
+// is captured by each route at registration time, so groups never leak
+// middlewares into their parent or siblings.
 type NetServerMux struct {
-	handlers map[string]*Route
-	prefix   string
-	mux      *http.ServeMux
-	mws      []func(http.Handler) http.Handler
+	tree   *node
+	root   *NetServerMux
+	prefix string
+	mws    []func(http.Handler) http.Handler
+	inline []func(http.Handler) http.Handler
+	chain  http.Handler
 }
 
 func NewNetServerMux() *NetServerMux {
-	mux := http.NewServeMux()
 	n := &NetServerMux{
-		prefix:   "",
-		mux:      mux,
-		mws:      make([]func(http.Handler) http.Handler, 0),
-		handlers: make(map[string]*Route),
+		tree:   newNode(""),
+		prefix: "",
+		mws:    make([]func(http.Handler) http.Handler, 0),
 	}
+	n.root = n
+	n.chain = chain(n.mws, http.HandlerFunc(n.dispatch))
 
 	return n
 }
 
 func (n *NetServerMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h := n.applyMiddlewares(n.mux)
-
-	var route *Route
-	key := r.URL.Path
-	if r.Method != "GET" {
-		key = r.Method + " " + r.URL.Path
+	if _, ok := r.Context().Value(routeContextKey).(*routeInfo); !ok {
+		r = r.WithContext(context.WithValue(r.Context(), routeContextKey, &routeInfo{}))
 	}
+	n.root.chain.ServeHTTP(w, r)
+}
 
-	route, exists := n.handlers[key]
-	if !exists {
-		for k, v := range n.handlers {
-			if !v.hasParams {
-				continue
-			}
+func (n *NetServerMux) dispatch(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
 
-			path := k
-			if r.Method != "GET" {
-				if !strings.HasPrefix(k, r.Method+" ") {
-					continue
+	route, params := n.tree.match(r.Method, path)
+	if route == nil {
+		allow := n.tree.allowed(path)
+		if allow == "" {
+			if target, ok := n.slashRedirect(path); ok {
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				// 301 lets clients turn a POST into a GET, 308 keeps the method
+				// and the body
+				status := http.StatusMovedPermanently
+				if r.Method != http.MethodGet && r.Method != http.MethodHead {
+					status = http.StatusPermanentRedirect
 				}
-				path = strings.TrimPrefix(k, r.Method+" ")
+				http.Redirect(w, r, target, status)
+				return
 			}
+			http.NotFound(w, r)
+			return
+		}
 
-			matched, params := matchRoute(path, v.paramNames, r.URL.Path)
-			if matched {
-				ctx := context.WithValue(r.Context(), paramsContextKey, params)
-				r = r.WithContext(ctx)
-				route = v
-				exists = true
-				break
-			}
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
 		}
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
 	}
 
-	if exists {
-		h := n.applyMiddlewares(route.handler)
-		h.ServeHTTP(w, r)
-		return
+	if info, ok := r.Context().Value(routeContextKey).(*routeInfo); ok {
+		info.pattern = route.pattern
 	}
 
-	h.ServeHTTP(w, r)
+	handler := route.handler(r.Method)
+	if len(params) > 0 {
+		values := make(map[string]string, len(params))
+		for _, p := range params {
+			if p.name != "" {
+				values[p.name] = p.value
+			}
+		}
+		ctx := context.WithValue(r.Context(), paramsContextKey, values)
+		r = r.WithContext(ctx)
+	}
+
+	handler.ServeHTTP(w, r)
 }
 
 func (n *NetServerMux) Get(pattern string, handler http.HandlerFunc) {
@@ -122,47 +160,78 @@ func (n *NetServerMux) Delete(pattern string, handler http.HandlerFunc) {
 }
 
 func (n *NetServerMux) HandleFunc(pattern string, handler http.HandlerFunc) {
-	n.mux.HandleFunc(pattern, handler)
+	n.Handle(pattern, handler)
 }
 
+// Handle registers the handler for every method. Like http.ServeMux a pattern
+// ending in a slash matches the whole subtree below it, and the path without
+// the slash is redirected to it.
 func (n *NetServerMux) Handle(pattern string, handler http.Handler) {
-	n.mux.Handle(pattern, handler)
+	pattern = n.prefix + pattern
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "{...}"
+	}
+	n.tree.insert(pattern, methodAny, chain(n.inline, handler))
 }
 
+// Wants reports whether the Accept header of the request allows the media type
 func (n *NetServerMux) Wants(r *http.Request, accept string) bool {
-	a := r.Header.Get("Accept")
-	return a == accept
+	return Negotiate(r, accept) == accept
 }
 
+// Use on the root mux adds global middlewares. On a sub router it adds inline
+// middlewares which only apply to routes registered after the call.
 func (n *NetServerMux) Use(middlewares ...func(http.Handler) http.Handler) {
-	n.mws = append(n.mws, middlewares...)
+	if n.root == n {
+		n.mws = append(n.mws, middlewares...)
+		n.chain = chain(n.mws, http.HandlerFunc(n.dispatch))
+		return
+	}
+	n.inline = append(n.inline, middlewares...)
 }
 
 func (n *NetServerMux) With(middlewares ...func(http.Handler) http.Handler) Router {
-	child := &NetServerMux{
-		prefix:   n.prefix,
-		mux:      n.mux,
-		handlers: n.handlers,
-		mws:      append(n.mws, middlewares...),
-	}
+	child := n.child(n.prefix)
+	child.inline = append(child.inline, middlewares...)
 	return child
 }
 
 func (n *NetServerMux) Route(pattern string, fn func(r Router)) Router {
-	subMux := NewNetServerMux()
-	subMux.prefix = n.prefix + pattern
-	subMux.mws = append(subMux.mws, n.mws...)
+	subMux := n.child(n.prefix + pattern)
+	fn(subMux)
+	return subMux
+}
 
+func (n *NetServerMux) Group(fn func(r Router)) Router {
+	subMux := n.child(n.prefix)
 	fn(subMux)
+	return subMux
+}
 
-	for k, v := range subMux.handlers {
-		if k[len(k)-1] == '/' {
-			k = k[:len(k)-1]
+// Routes lists every registered route in the order of the routing tree, so
+// routes below the same prefix stay together
+func (n *NetServerMux) Routes() []Endpoint {
+	endpoints := make([]Endpoint, 0)
+	n.tree.walk(func(route *node) {
+		methods := make([]string, 0, len(route.handlers))
+		for method := range route.handlers {
+			methods = append(methods, method)
 		}
-		n.handlers[k] = v
-	}
+		sort.Strings(methods)
+		for _, method := range methods {
+			endpoints = append(endpoints, Endpoint{Method: method, Pattern: route.pattern})
+		}
+	})
+	return endpoints
+}
 
-	return subMux
+// RoutePattern returns the pattern of the route serving the request, such as
+// /todos/{id}, or an empty string when no route matched (yet)
+func RoutePattern(r *http.Request) string {
+	if info, ok := r.Context().Value(routeContextKey).(*routeInfo); ok {
+		return info.pattern
+	}
+	return ""
 }
 
 func GetParam(r *http.Request, paramName string) string {
@@ -173,69 +242,51 @@ func GetParam(r *http.Request, paramName string) string {
 	return ""
 }
 
-func (n *NetServerMux) addToRoutes(method string, pattern string, handler http.HandlerFunc) {
-	cleanPattern, paramNames := extractPathParams(pattern)
-	hasParams := len(paramNames) > 0
-
-	key := n.prefix + cleanPattern
-	if method != "GET" {
-		key = method + " " + n.prefix + cleanPattern
+// slashRedirect returns the path with the trailing slash added or removed
+// when only that form exists. Like http.ServeMux, GET /todos/ is redirected
+// to /todos and GET /static to the subtree registered with Handle("/static/").
+func (n *NetServerMux) slashRedirect(path string) (string, bool) {
+	if path == "/" {
+		return "", false
 	}
-
-	n.handlers[key] = &Route{
-		handler:    handler,
-		paramNames: paramNames,
-		hasParams:  hasParams,
+	target := path + "/"
+	if strings.HasSuffix(path, "/") {
+		target = strings.TrimSuffix(path, "/")
 	}
-}
-
-func (n *NetServerMux) applyMiddlewares(handler http.Handler) http.Handler {
-	for _, mw := range n.mws {
-		handler = mw(handler)
+	if n.tree.allowed(target) == "" {
+		return "", false
 	}
-	return handler
+	return target, true
 }
 
-func extractPathParams(pattern string) (cleanPattern string, paramNames []string) {
-	parts := strings.Split(pattern, "/")
-	cleanParts := make([]string, len(parts))
-	paramNames = make([]string, 0)
-
-	for i, part := range parts {
-		if len(part) > 0 && part[0] == '{' && part[len(part)-1] == '}' {
-			paramName := part[1 : len(part)-1]
-			paramNames = append(paramNames, paramName)
-			cleanParts[i] = "*"
-		} else {
-			cleanParts[i] = part
-		}
+// addToRoutes registers the handler for the method. A trailing slash is
+// dropped, so Get("/todos/") serves /todos and /todos/ is redirected to it.
+func (n *NetServerMux) addToRoutes(method string, pattern string, handler http.HandlerFunc) {
+	path := n.prefix + pattern
+	if len(path) > 1 && path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
 	}
 
-	return strings.Join(cleanParts, "/"), paramNames
+	n.tree.insert(path, method, chain(n.inline, handler))
 }
 
-func matchRoute(pattern string, paramNames []string, path string) (bool, map[string]string) {
-	patternParts := strings.Split(pattern, "/")
-	pathParts := strings.Split(path, "/")
-
-	if len(patternParts) != len(pathParts) {
-		return false, nil
+// child creates a sub router sharing the routing tree. The inline slice is
+// copied so appending to it never mutates the parent's backing array.
+func (n *NetServerMux) child(prefix string) *NetServerMux {
+	inline := make([]func(http.Handler) http.Handler, len(n.inline))
+	copy(inline, n.inline)
+	return &NetServerMux{
+		tree:   n.tree,
+		root:   n.root,
+		prefix: prefix,
+		inline: inline,
 	}
+}
 
-	params := make(map[string]string)
-	paramIndex := 0
-
-	// for i := 0; i < len(patternParts); i++ {
-	for i := range patternParts {
-		if patternParts[i] == "*" {
-			if paramIndex < len(paramNames) {
-				params[paramNames[paramIndex]] = pathParts[i]
-				paramIndex++
-			}
-		} else if patternParts[i] != pathParts[i] {
-			return false, nil
-		}
+// chain wraps the handler so the first middleware is the outermost one
+func chain(middlewares []func(http.Handler) http.Handler, handler http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
 	}
-
-	return true, params
+	return handler
 }
@@ -0,0 +1,284 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// text answers with the body, so tests can tell which route served a request
+func text(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}
+}
+
+func serve(t *testing.T, mux http.Handler, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestTrailingSlash(t *testing.T) {
+	mux := NewNetServerMux()
+	mux.Get("/todos", text("todos"))
+	mux.Post("/todos", text("created"))
+	mux.Get("/users/", text("users"))
+	mux.Handle("/static/", http.StripPrefix("/static/", text("static")))
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		status   int
+		location string
+		body     string
+	}{
+		{name: "exact", method: "GET", target: "/todos", status: http.StatusOK, body: "todos"},
+		{name: "extra slash redirects", method: "GET", target: "/todos/", status: http.StatusMovedPermanently, location: "/todos"},
+		{name: "head redirects like get", method: "HEAD", target: "/todos/", status: http.StatusMovedPermanently, location: "/todos"},
+		{name: "post redirect keeps the method", method: "POST", target: "/todos/", status: http.StatusPermanentRedirect, location: "/todos"},
+		{name: "redirect keeps the query", method: "GET", target: "/todos/?page=2", status: http.StatusMovedPermanently, location: "/todos?page=2"},
+		{name: "slash is dropped on registration", method: "GET", target: "/users", status: http.StatusOK, body: "users"},
+		{name: "registered slash redirects", method: "GET", target: "/users/", status: http.StatusMovedPermanently, location: "/users"},
+		{name: "subtree root", method: "GET", target: "/static/", status: http.StatusOK, body: "static"},
+		{name: "subtree", method: "GET", target: "/static/css/app.css", status: http.StatusOK, body: "static"},
+		{name: "missing slash redirects to the subtree", method: "GET", target: "/static", status: http.StatusMovedPermanently, location: "/static/"},
+		{name: "unknown path", method: "GET", target: "/missing/", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, mux, tt.method, tt.target)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+// recorder returns a middleware logging its name into the trace of the request
+func recorder(trace *[]string, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var trace []string
+	mux := NewNetServerMux()
+	mux.Use(recorder(&trace, "global1"))
+
+	mux.Route("/todos", func(r Router) {
+		r.Use(recorder(&trace, "auth"))
+		r.Use(recorder(&trace, "verified"))
+
+		r.With(recorder(&trace, "canRead")).Get("/", func(w http.ResponseWriter, r *http.Request) {
+			trace = append(trace, "handler")
+		})
+		r.Route("/{id}/tags", func(r Router) {
+			r.Use(recorder(&trace, "tags"))
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, "handler")
+			})
+		})
+	})
+	// root middlewares wrap every route, even those registered before them
+	mux.Use(recorder(&trace, "global2"))
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/todos", []string{"global1", "global2", "auth", "verified", "canRead", "handler"}},
+		{"/todos/1/tags", []string{"global1", "global2", "auth", "verified", "tags", "handler"}},
+		// root middlewares also run for paths no route matches
+		{"/missing", []string{"global1", "global2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			trace = nil
+			serve(t, mux, "GET", tt.target)
+			if !slices.Equal(trace, tt.want) {
+				t.Errorf("trace = %v, want %v", trace, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareIsolation(t *testing.T) {
+	var trace []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	}
+
+	mux := NewNetServerMux()
+	mux.Get("/", handler)
+	mux.Route("/todos", func(r Router) {
+		r.Get("/public", handler)
+		r.Use(recorder(&trace, "auth"))
+		r.With(recorder(&trace, "canWrite")).Post("/", handler)
+		r.Get("/", handler)
+		r.Group(func(r Router) {
+			r.Use(recorder(&trace, "admin"))
+			r.Delete("/{id}", handler)
+		})
+		r.Put("/{id}", handler)
+	})
+	mux.Route("/users", func(r Router) {
+		r.Get("/", handler)
+	})
+	mux.Get("/about", handler)
+
+	tests := []struct {
+		method string
+		target string
+		want   []string
+	}{
+		{"GET", "/", []string{"handler"}},
+		{"GET", "/about", []string{"handler"}},
+		{"GET", "/users", []string{"handler"}},
+		// Use only applies to the routes registered after it
+		{"GET", "/todos/public", []string{"handler"}},
+		{"POST", "/todos", []string{"auth", "canWrite", "handler"}},
+		// With does not leak into the routes next to it
+		{"GET", "/todos", []string{"auth", "handler"}},
+		{"DELETE", "/todos/1", []string{"auth", "admin", "handler"}},
+		// nor does Use inside a group
+		{"PUT", "/todos/1", []string{"auth", "handler"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			trace = nil
+			rec := serve(t, mux, tt.method, tt.target)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if !slices.Equal(trace, tt.want) {
+				t.Errorf("trace = %v, want %v", trace, tt.want)
+			}
+		})
+	}
+}
+
+func TestNestedRoutes(t *testing.T) {
+	mux := NewNetServerMux()
+	mux.Route("/api", func(r Router) {
+		r.Route("/v1", func(r Router) {
+			r.Route("/todos/{id}", func(r Router) {
+				r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, "todo "+GetParam(r, "id")+" "+RoutePattern(r))
+				})
+				r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, GetParam(r, "id")+"/"+GetParam(r, "tag")+" "+RoutePattern(r))
+				})
+			})
+		})
+	})
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/api/v1/todos/7", http.StatusOK, "todo 7 /api/v1/todos/{id}"},
+		{"/api/v1/todos/7/tags/home", http.StatusOK, "7/home /api/v1/todos/{id}/tags/{tag}"},
+		{"/api/todos/7", http.StatusNotFound, ""},
+		{"/v1/todos/7", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serve(t, mux, "GET", tt.target)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestMatchPriority(t *testing.T) {
+	mux := NewNetServerMux()
+	// registered from the least to the most specific, so the order of
+	// registration cannot be what decides
+	mux.Get("/files/{path...}", text("catch-all"))
+	mux.Get("/files/{name}", text("param"))
+	mux.Get("/files/{id:[0-9]+}", text("constrained"))
+	mux.Get("/files/new", text("static"))
+	mux.Get("/files/{id:[0-9]+}/raw", text("constrained raw"))
+
+	tests := []struct {
+		target string
+		body   string
+	}{
+		{"/files/new", "static"},
+		{"/files/42", "constrained"},
+		{"/files/report", "param"},
+		{"/files/docs/report.pdf", "catch-all"},
+		{"/files/42/raw", "constrained raw"},
+		// the constrained route has no /edit, so matching backtracks
+		{"/files/42/edit", "catch-all"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serve(t, mux, "GET", tt.target)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestMethods(t *testing.T) {
+	mux := NewNetServerMux()
+	mux.Get("/todos", text("list"))
+	mux.Post("/todos", text("create"))
+	mux.Delete("/todos/{id}", text("delete"))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		allow  string
+	}{
+		{name: "not allowed", method: "PUT", target: "/todos", status: http.StatusMethodNotAllowed, allow: "GET, HEAD, OPTIONS, POST"},
+		{name: "not allowed without GET", method: "GET", target: "/todos/1", status: http.StatusMethodNotAllowed, allow: "DELETE, OPTIONS"},
+		{name: "OPTIONS", method: "OPTIONS", target: "/todos", status: http.StatusNoContent, allow: "GET, HEAD, OPTIONS, POST"},
+		{name: "HEAD falls back to GET", method: "HEAD", target: "/todos", status: http.StatusOK},
+		{name: "unknown path", method: "PUT", target: "/users", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, mux, tt.method, tt.target)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// methodAny is the handler key used by Handle/HandleFunc, matching every method
+const methodAny = "*"
+
+type nodeKind uint8
+
+const (
+	staticNode nodeKind = iota
+	paramNode
+	catchAllNode
+)
+
+type param struct {
+	name  string
+	value string
+}
+
+// node is a single path segment in the routing tree. Children are searched in
+// priority order: static segments first, then constrained params, then plain
+// params and finally the catch-all, so overlapping patterns always resolve
+// the same way regardless of registration order.
+type node struct {
+	raw      string
+	pattern  string
+	name     string
+	re       *regexp.Regexp
+	static   map[string]*node
+	params   []*node
+	catchAll *node
+	handlers map[string]http.Handler
+}
+
+func newNode(raw string) *node {
+	return &node{
+		raw:    raw,
+		static: make(map[string]*node),
+	}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func parseSegment(segment string) (kind nodeKind, name string, re *regexp.Regexp) {
+	if len(segment) < 2 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return staticNode, "", nil
+	}
+
+	inner := segment[1 : len(segment)-1]
+	if strings.HasSuffix(inner, "...") {
+		return catchAllNode, strings.TrimSuffix(inner, "..."), nil
+	}
+
+	name, expr, hasExpr := strings.Cut(inner, ":")
+	if hasExpr {
+		re = regexp.MustCompile("^(?:" + expr + ")$")
+	}
+	return paramNode, name, re
+}
+
+func (n *node) insert(pattern, method string, handler http.Handler) {
+	current := n
+	segments := splitPath(pattern)
+
+	for i, segment := range segments {
+		kind, name, re := parseSegment(segment)
+
+		switch kind {
+		case staticNode:
+			child, ok := current.static[segment]
+			if !ok {
+				child = newNode(segment)
+				current.static[segment] = child
+			}
+			current = child
+		case paramNode:
+			current = current.paramChild(segment, name, re)
+		case catchAllNode:
+			if i != len(segments)-1 {
+				panic(fmt.Sprintf("router: catch-all %q must be the last segment in %q", segment, pattern))
+			}
+			if current.catchAll == nil {
+				current.catchAll = newNode(segment)
+				current.catchAll.name = name
+			} else if current.catchAll.raw != segment {
+				panic(fmt.Sprintf("router: catch-all %q conflicts with %q in %q", segment, current.catchAll.raw, pattern))
+			}
+			current = current.catchAll
+		}
+	}
+
+	if current.handlers == nil {
+		current.handlers = make(map[string]http.Handler)
+	}
+	if _, exists := current.handlers[method]; exists {
+		panic(fmt.Sprintf("router: duplicate route %s %s", method, pattern))
+	}
+	current.handlers[method] = handler
+	current.pattern = pattern
+}
+
+func (n *node) paramChild(raw, name string, re *regexp.Regexp) *node {
+	for _, child := range n.params {
+		if child.raw == raw {
+			return child
+		}
+	}
+
+	child := newNode(raw)
+	child.name = name
+	child.re = re
+	n.params = append(n.params, child)
+
+	// constrained params are more specific so they are tried before plain ones
+	sort.SliceStable(n.params, func(i, j int) bool {
+		return n.params[i].re != nil && n.params[j].re == nil
+	})
+
+	return child
+}
+
+// match walks the tree for the given request path and returns the node that
+// can serve the method together with the captured path parameters
+func (n *node) match(method, path string) (*node, []param) {
+	params := make([]param, 0)
+	found := n.find(splitPath(path), &params, func(route *node) bool {
+		return route.handler(method) != nil
+	})
+	return found, params
+}
+
+// allowed returns the methods served by every route matching the path, or an
+// empty string when the path does not exist at all
+func (n *node) allowed(path string) string {
+	set := make(map[string]struct{})
+	params := make([]param, 0)
+	n.find(splitPath(path), &params, func(route *node) bool {
+		for method := range route.handlers {
+			set[method] = struct{}{}
+		}
+		return false
+	})
+	if len(set) == 0 {
+		return ""
+	}
+
+	if _, ok := set[http.MethodGet]; ok {
+		set[http.MethodHead] = struct{}{}
+	}
+	set[http.MethodOptions] = struct{}{}
+
+	methods := make([]string, 0, len(set))
+	for method := range set {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func (n *node) find(segments []string, params *[]param, accept func(*node) bool) *node {
+	if len(segments) == 0 {
+		if n.handlers != nil && accept(n) {
+			return n
+		}
+		return nil
+	}
+
+	segment, rest := segments[0], segments[1:]
+
+	if child, ok := n.static[segment]; ok {
+		if found := child.find(rest, params, accept); found != nil {
+			return found
+		}
+	}
+
+	if segment != "" {
+		for _, child := range n.params {
+			if child.re != nil && !child.re.MatchString(segment) {
+				continue
+			}
+			mark := len(*params)
+			*params = append(*params, param{name: child.name, value: segment})
+			if found := child.find(rest, params, accept); found != nil {
+				return found
+			}
+			*params = (*params)[:mark]
+		}
+	}
+
+	if n.catchAll != nil && n.catchAll.handlers != nil && accept(n.catchAll) {
+		*params = append(*params, param{name: n.catchAll.name, value: strings.Join(segments, "/")})
+		return n.catchAll
+	}
+
+	return nil
+}
+
+// walk calls fn for every route below the node, static children in
+// alphabetical order first and then the others in the order find tries them
+func (n *node) walk(fn func(route *node)) {
+	if n.handlers != nil {
+		fn(n)
+	}
+
+	segments := make([]string, 0, len(n.static))
+	for segment := range n.static {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+	for _, segment := range segments {
+		n.static[segment].walk(fn)
+	}
+
+	for _, child := range n.params {
+		child.walk(fn)
+	}
+	if n.catchAll != nil {
+		n.catchAll.walk(fn)
+	}
+}
+
+// handler returns the handler registered for the method. HEAD falls back to
+// GET and handlers registered for any method match everything.
+func (n *node) handler(method string) http.Handler {
+	if h, ok := n.handlers[method]; ok {
+		return h
+	}
+	if method == http.MethodHead {
+		if h, ok := n.handlers[http.MethodGet]; ok {
+			return h
+		}
+	}
+	return n.handlers[methodAny]
+}
@@ -0,0 +1,133 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-starter-template/pkg/logger"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var errPasswordMismatch = errors.New("password does not match")
+
+// Argon2idParams tune the cost of a hash, Memory is in KiB. The defaults
+// follow the second recommended option of RFC 9106.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 4,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+type Argon2idPasswordHasher struct {
+	params Argon2idParams
+	log    *logger.Logger
+}
+
+func NewArgon2idPasswordHasher(params Argon2idParams, log *logger.Logger) IPasswordHasher {
+	return &Argon2idPasswordHasher{params, log}
+}
+
+// GenerateFromPassword encodes the hash in the PHC string format also used
+// by the reference implementation,
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+func (a *Argon2idPasswordHasher) GenerateFromPassword(password string) (string, error) {
+	salt := make([]byte, a.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		a.log.Error("failed to generate password salt", "error", err)
+		return "", fmt.Errorf("failed to generate password hash: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		a.params.Memory,
+		a.params.Iterations,
+		a.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2idPasswordHasher) CompareHashAndPassword(hashedPassword, password string) error {
+	return comparePasswordHash(hashedPassword, password)
+}
+
+func (a *Argon2idPasswordHasher) NeedsRehash(hashedPassword string) bool {
+	params, _, key, err := decodeArgon2idHash(hashedPassword)
+	if err != nil {
+		return true
+	}
+	return params.Memory != a.params.Memory ||
+		params.Iterations != a.params.Iterations ||
+		params.Parallelism != a.params.Parallelism ||
+		uint32(len(key)) != a.params.KeyLength
+}
+
+func isArgon2idHash(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$argon2id$")
+}
+
+func compareArgon2idHash(hashedPassword, password string) error {
+	params, salt, key, err := decodeArgon2idHash(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return errPasswordMismatch
+	}
+	return nil
+}
+
+func decodeArgon2idHash(hashedPassword string) (*Argon2idParams, []byte, []byte, error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	params := &Argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, nil, nil, fmt.Errorf("invalid argon2 parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, fmt.Errorf("invalid argon2 key")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
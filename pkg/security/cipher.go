@@ -0,0 +1,61 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var ErrCiphertextInvalid = errors.New("ciphertext is invalid")
+
+// ICipher encrypts small secrets, such as TOTP keys, which have to be read
+// back in plain text and therefore cannot be hashed
+type ICipher interface {
+	Encrypt(plainText string) (string, error)
+	Decrypt(cipherText string) (string, error)
+}
+
+type AESGCMCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMCipher takes a 16, 24 or 32 byte key selecting AES-128, AES-192 or
+// AES-256
+func NewAESGCMCipher(key []byte) (ICipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &AESGCMCipher{aead}, nil
+}
+
+// Encrypt returns the base64 encoded random nonce followed by the sealed text
+func (c *AESGCMCipher) Encrypt(plainText string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plainText), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *AESGCMCipher) Decrypt(cipherText string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrCiphertextInvalid
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plainText, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrCiphertextInvalid
+	}
+	return string(plainText), nil
+}
@@ -0,0 +1,95 @@
+package security
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, key string) ICipher {
+	t.Helper()
+	c, err := NewAESGCMCipher([]byte(key))
+	if err != nil {
+		t.Fatalf("NewAESGCMCipher() error = %v", err)
+	}
+	return c
+}
+
+func TestNewAESGCMCipherKeySize(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		if _, err := NewAESGCMCipher(make([]byte, size)); err != nil {
+			t.Errorf("NewAESGCMCipher() with a %d byte key error = %v", size, err)
+		}
+	}
+	for _, size := range []int{0, 15, 31, 33} {
+		if _, err := NewAESGCMCipher(make([]byte, size)); err == nil {
+			t.Errorf("NewAESGCMCipher() with a %d byte key returned no error", size)
+		}
+	}
+}
+
+func TestAESGCMCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, "0123456789abcdef0123456789abcdef")
+
+	for _, plainText := range []string{"", rfc6238Secret, "ünïcödé"} {
+		cipherText, err := c.Encrypt(plainText)
+		if err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+		got, err := c.Decrypt(cipherText)
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+		if got != plainText {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q", plainText, got)
+		}
+	}
+
+	// the random nonce makes every ciphertext different
+	first, _ := c.Encrypt(rfc6238Secret)
+	second, _ := c.Encrypt(rfc6238Secret)
+	if first == second {
+		t.Error("Encrypt() returned the same ciphertext twice")
+	}
+}
+
+func TestAESGCMCipherTamper(t *testing.T) {
+	c := newTestCipher(t, "0123456789abcdef0123456789abcdef")
+	cipherText, err := c.Encrypt(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(cipherText)
+
+	flip := func(i int) string {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 0x01
+		return base64.StdEncoding.EncodeToString(tampered)
+	}
+
+	tests := []struct {
+		name       string
+		cipherText string
+		cipher     ICipher
+	}{
+		{name: "flipped nonce", cipherText: flip(0)},
+		{name: "flipped text", cipherText: flip(len(sealed) / 2)},
+		{name: "flipped tag", cipherText: flip(len(sealed) - 1)},
+		{name: "truncated", cipherText: base64.StdEncoding.EncodeToString(sealed[:len(sealed)-1])},
+		{name: "shorter than the nonce", cipherText: base64.StdEncoding.EncodeToString(sealed[:4])},
+		{name: "not base64", cipherText: "not base64!"},
+		{name: "other key", cipherText: cipherText, cipher: newTestCipher(t, "fedcba9876543210fedcba9876543210")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decrypter := c
+			if tt.cipher != nil {
+				decrypter = tt.cipher
+			}
+			if _, err := decrypter.Decrypt(tt.cipherText); !errors.Is(err, ErrCiphertextInvalid) {
+				t.Errorf("Decrypt() error = %v, want ErrCiphertextInvalid", err)
+			}
+		})
+	}
+}
@@ -1,38 +1,77 @@
 package security
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"go-starter-template/pkg/logger"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// IPasswordHasher hashes new passwords with one algorithm but verifies the
+// hashes of every supported one, so switching algorithms keeps existing
+// passwords working. NeedsRehash reports hashes made with another algorithm
+// or other parameters than the hasher is configured with, callers replace
+// them once they know the plain password.
 type IPasswordHasher interface {
-	GenerateFromPassword(password string, cost int) (string, error)
+	GenerateFromPassword(password string) (string, error)
 	CompareHashAndPassword(hashedPassword, password string) error
+	NeedsRehash(hashedPassword string) bool
 }
 
 type BcryptPasswordHasher struct {
-	log *logger.Logger
+	cost int
+	log  *logger.Logger
 }
 
-func NewBcryptPasswordHasher(log *logger.Logger) IPasswordHasher {
-	return &BcryptPasswordHasher{log}
+func NewBcryptPasswordHasher(cost int, log *logger.Logger) IPasswordHasher {
+	return &BcryptPasswordHasher{cost, log}
 }
 
-func (b *BcryptPasswordHasher) GenerateFromPassword(password string, cost int) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+func (b *BcryptPasswordHasher) GenerateFromPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
 	if err != nil {
-		b.log.Error("failed to generate password hash: %v", err)
+		b.log.Error("failed to generate password hash", "error", err)
 		return "", fmt.Errorf("failed to generate password hash: %w", err)
 	}
 	return string(hash), nil
 }
 
 func (b *BcryptPasswordHasher) CompareHashAndPassword(hashedPassword, password string) error {
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+	return comparePasswordHash(hashedPassword, password)
+}
+
+func (b *BcryptPasswordHasher) NeedsRehash(hashedPassword string) bool {
+	if !isBcryptHash(hashedPassword) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != b.cost
+}
+
+// comparePasswordHash picks the algorithm from the format of the hash
+func comparePasswordHash(hashedPassword, password string) error {
+	var err error
+	switch {
+	case isBcryptHash(hashedPassword):
+		err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	case isArgon2idHash(hashedPassword):
+		err = compareArgon2idHash(hashedPassword, password)
+	default:
+		err = ErrUnknownPasswordHash
+	}
+	if err != nil {
 		return fmt.Errorf("failed to compare password: %w", err)
 	}
 	return nil
 }
+
+func isBcryptHash(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") ||
+		strings.HasPrefix(hashedPassword, "$2b$") ||
+		strings.HasPrefix(hashedPassword, "$2y$")
+}
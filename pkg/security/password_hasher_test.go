@@ -0,0 +1,191 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2idParams keep the tests fast, they are far too cheap for real use
+var testArgon2idParams = Argon2idParams{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// referenceArgon2idHash is "password" hashed with the salt "somesalt" by the
+// reference implementation, argon2 -id -t 2 -m 16 -p 1 -l 32
+const referenceArgon2idHash = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+func TestArgon2idReferenceHash(t *testing.T) {
+	hasher := NewArgon2idPasswordHasher(testArgon2idParams, nil)
+
+	if err := hasher.CompareHashAndPassword(referenceArgon2idHash, "password"); err != nil {
+		t.Errorf("CompareHashAndPassword() error = %v", err)
+	}
+	if err := hasher.CompareHashAndPassword(referenceArgon2idHash, "Password"); !errors.Is(err, errPasswordMismatch) {
+		t.Errorf("CompareHashAndPassword() with a wrong password error = %v, want a mismatch", err)
+	}
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	hasher := NewArgon2idPasswordHasher(testArgon2idParams, nil)
+
+	hash, err := hasher.GenerateFromPassword("correct horse")
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash = %q, want the PHC format with the configured parameters", hash)
+	}
+
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		t.Fatalf("decodeArgon2idHash() error = %v", err)
+	}
+	if *params != testArgon2idParams || len(salt) != 16 || len(key) != 32 {
+		t.Errorf("decoded %+v with a %d byte salt and a %d byte key, want %+v", *params, len(salt), len(key), testArgon2idParams)
+	}
+
+	if err := hasher.CompareHashAndPassword(hash, "correct horse"); err != nil {
+		t.Errorf("CompareHashAndPassword() error = %v", err)
+	}
+	if err := hasher.CompareHashAndPassword(hash, "correct horse "); err == nil {
+		t.Error("CompareHashAndPassword() accepted a wrong password")
+	}
+
+	other, err := hasher.GenerateFromPassword("correct horse")
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	if other == hash {
+		t.Error("two hashes of the same password are equal, the salt is not random")
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	hasher := NewArgon2idPasswordHasher(testArgon2idParams, nil)
+	current, err := hasher.GenerateFromPassword("secret")
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	shortKeyParams := testArgon2idParams
+	shortKeyParams.KeyLength = 16
+	shortKey, err := NewArgon2idPasswordHasher(shortKeyParams, nil).GenerateFromPassword("secret")
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{name: "current parameters", hash: current},
+		{name: "other memory", hash: strings.Replace(current, "m=64,", "m=128,", 1), want: true},
+		{name: "other iterations", hash: strings.Replace(current, "t=1,", "t=2,", 1), want: true},
+		{name: "other parallelism", hash: strings.Replace(current, "p=1$", "p=2$", 1), want: true},
+		{name: "other key length", hash: shortKey, want: true},
+		{name: "bcrypt", hash: string(bcryptHash), want: true},
+		{name: "malformed", hash: "$argon2id$v=19$m=64", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	hasher := NewBcryptPasswordHasher(bcrypt.MinCost, nil)
+	current, err := hasher.GenerateFromPassword("secret")
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	cheaper, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{name: "current cost", hash: current},
+		{name: "other cost", hash: string(cheaper), want: true},
+		{name: "argon2id", hash: referenceArgon2idHash, want: true},
+		{name: "unknown", hash: "plain", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// either hasher verifies the hashes of the other, so switching algorithms
+// keeps the existing passwords working until they are rehashed
+func TestCompareAcrossAlgorithms(t *testing.T) {
+	argon2id := NewArgon2idPasswordHasher(testArgon2idParams, nil)
+	bcryptHasher := NewBcryptPasswordHasher(bcrypt.MinCost, nil)
+
+	for _, from := range []IPasswordHasher{argon2id, bcryptHasher} {
+		hash, err := from.GenerateFromPassword("secret")
+		if err != nil {
+			t.Fatalf("GenerateFromPassword() error = %v", err)
+		}
+		for _, to := range []IPasswordHasher{argon2id, bcryptHasher} {
+			if err := to.CompareHashAndPassword(hash, "secret"); err != nil {
+				t.Errorf("%T.CompareHashAndPassword(%q) error = %v", to, hash, err)
+			}
+		}
+	}
+}
+
+func TestCompareMalformedHashes(t *testing.T) {
+	hasher := NewArgon2idPasswordHasher(testArgon2idParams, nil)
+
+	tests := []struct {
+		name    string
+		hash    string
+		unknown bool
+	}{
+		{name: "empty", hash: "", unknown: true},
+		{name: "plain text", hash: "password", unknown: true},
+		{name: "argon2i", hash: "$argon2i$v=19$m=64,t=1,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc", unknown: true},
+		{name: "missing key", hash: "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ", unknown: true},
+		{name: "other version", hash: "$argon2id$v=16$m=64,t=1,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{name: "garbled parameters", hash: "$argon2id$v=19$m=x,t=1,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{name: "zero iterations", hash: "$argon2id$v=19$m=64,t=0,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{name: "invalid salt", hash: "$argon2id$v=19$m=64,t=1,p=1$!!!$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{name: "empty key", hash: "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$"},
+		{name: "truncated bcrypt", hash: "$2a$04$short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := hasher.CompareHashAndPassword(tt.hash, "password")
+			if err == nil {
+				t.Fatal("CompareHashAndPassword() accepted a malformed hash")
+			}
+			if errors.Is(err, ErrUnknownPasswordHash) != tt.unknown {
+				t.Errorf("CompareHashAndPassword() error = %v, want ErrUnknownPasswordHash %v", err, tt.unknown)
+			}
+		})
+	}
+}
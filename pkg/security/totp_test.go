@@ -0,0 +1,118 @@
+package security
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors,
+// "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestTOTPCode checks the SHA-1 vectors of RFC 6238 appendix B. The RFC lists
+// 8 digit codes, the 6 digit codes are their last 6 digits.
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		step := TOTPStep(time.Unix(tt.unix, 0))
+		code, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		if code != tt.code {
+			t.Errorf("TOTPCode() at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+
+	// secrets are typed in by hand, so the case does not matter
+	if code, _ := TOTPCode(strings.ToLower(rfc6238Secret), 1); code != "287082" {
+		t.Errorf("TOTPCode() of a lower case secret = %s, want 287082", code)
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode() of an invalid secret returned no error")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+	codeAt := func(step int64) string {
+		code, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: codeAt(current), wantStep: current, wantOK: true},
+		{name: "previous step", code: codeAt(current - 1), wantStep: current - 1, wantOK: true},
+		{name: "next step", code: codeAt(current + 1), wantStep: current + 1, wantOK: true},
+		{name: "outside the skew", code: codeAt(current - 2)},
+		{name: "wrong code", code: "000000"},
+		{name: "too short", code: codeAt(current)[:5]},
+		{name: "too long", code: codeAt(current) + "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("ValidateTOTP() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("GenerateTOTPSecret() = %q, want 160 bits of base32", secret)
+	}
+	if other, _ := GenerateTOTPSecret(); other == secret {
+		t.Error("GenerateTOTPSecret() returned the same secret twice")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Starter App", "ada@example.com", rfc6238Secret))
+	if err != nil {
+		t.Fatalf("TOTPURI() is not a URL: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Starter App:ada@example.com" {
+		t.Errorf("TOTPURI() = %s, want otpauth://totp/Starter App:ada@example.com", uri)
+	}
+	if strings.Contains(uri.RawQuery, "+") {
+		t.Errorf("TOTPURI() query %q encodes spaces as +", uri.RawQuery)
+	}
+
+	query := uri.Query()
+	want := map[string]string{"secret": rfc6238Secret, "issuer": "Starter App", "algorithm": "SHA1", "digits": "6", "period": "30"}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("TOTPURI() %s = %q, want %q", key, got, value)
+		}
+	}
+}
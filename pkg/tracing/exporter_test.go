@@ -0,0 +1,222 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSpans are a server span and the failed query it ran, with fixed ids
+// and times so the encoding can be compared byte for byte
+func testSpans() []SpanData {
+	traceID := TraceID{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c}
+	start := time.Unix(1544712660, 0)
+	resource := []Attribute{String("service.name", "todo"), String("service.version", "1.2.3")}
+
+	return []SpanData{
+		{
+			Name:         "SELECT",
+			Kind:         SpanKindClient,
+			SpanContext:  SpanContext{TraceID: traceID, SpanID: SpanID{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74}, Sampled: true, TraceState: "rojo=00f067aa0ba902b7"},
+			ParentSpanID: SpanID{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x73},
+			Start:        start.Add(10 * time.Millisecond),
+			End:          start.Add(20 * time.Millisecond),
+			Attributes:   []Attribute{String("db.query.text", "SELECT 1"), Int64("db.rows", 1<<53+1), Bool("db.cached", false), {Key: "db.ratio", Value: 0.5}},
+			Events: []Event{{
+				Name:       "exception",
+				Time:       start.Add(15 * time.Millisecond),
+				Attributes: []Attribute{String("exception.message", "relation does not exist")},
+			}},
+			Status:   Status{Code: StatusError, Description: "relation does not exist"},
+			Resource: resource,
+		},
+		{
+			Name:        "GET /todos",
+			Kind:        SpanKindServer,
+			SpanContext: SpanContext{TraceID: traceID, SpanID: SpanID{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x73}, Sampled: true},
+			Start:       start,
+			End:         start.Add(time.Second),
+			Attributes:  []Attribute{Int("http.response.status_code", 200)},
+			Status:      Status{Code: StatusOK},
+			Resource:    resource,
+		},
+	}
+}
+
+// otlpTestRequest is testSpans as the OTLP/HTTP JSON encoding of an
+// ExportTraceServiceRequest: ids are hex rather than base64, enums are
+// numbers, and 64 bit integers including the timestamps are strings
+const otlpTestRequest = `{
+  "resourceSpans": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "todo"}},
+      {"key": "service.version", "value": {"stringValue": "1.2.3"}}
+    ]},
+    "scopeSpans": [{
+      "scope": {"name": "go-starter-template/pkg/tracing"},
+      "spans": [
+        {
+          "traceId": "5b8efff798038103d269b633813fc60c",
+          "spanId": "eee19b7ec3c1b174",
+          "traceState": "rojo=00f067aa0ba902b7",
+          "parentSpanId": "eee19b7ec3c1b173",
+          "name": "SELECT",
+          "kind": 3,
+          "startTimeUnixNano": "1544712660010000000",
+          "endTimeUnixNano": "1544712660020000000",
+          "attributes": [
+            {"key": "db.query.text", "value": {"stringValue": "SELECT 1"}},
+            {"key": "db.rows", "value": {"intValue": "9007199254740993"}},
+            {"key": "db.cached", "value": {"boolValue": false}},
+            {"key": "db.ratio", "value": {"doubleValue": 0.5}}
+          ],
+          "events": [{
+            "timeUnixNano": "1544712660015000000",
+            "name": "exception",
+            "attributes": [{"key": "exception.message", "value": {"stringValue": "relation does not exist"}}]
+          }],
+          "status": {"code": 2, "message": "relation does not exist"}
+        },
+        {
+          "traceId": "5b8efff798038103d269b633813fc60c",
+          "spanId": "eee19b7ec3c1b173",
+          "name": "GET /todos",
+          "kind": 2,
+          "startTimeUnixNano": "1544712660000000000",
+          "endTimeUnixNano": "1544712661000000000",
+          "attributes": [{"key": "http.response.status_code", "value": {"intValue": "200"}}],
+          "status": {"code": 1}
+        }
+      ]
+    }]
+  }]
+}`
+
+// jsonEqual compares the documents as values, ignoring formatting and the
+// order of object keys. Numbers are compared as written so an int64 sent as
+// a JSON number could not pass for the string the mapping asks for.
+func jsonEqual(t *testing.T, got, want []byte) bool {
+	t.Helper()
+	decode := func(b []byte) any {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("invalid JSON %s: %v", b, err)
+		}
+		return v
+	}
+	return reflect.DeepEqual(decode(got), decode(want))
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		method, path, contentType, apiKey string
+		body                              []byte
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		contentType, apiKey = r.Header.Get("Content-Type"), r.Header.Get("X-API-Key")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(collector.URL+"/v1/traces", map[string]string{"X-API-Key": "secret"})
+	if err := exporter.ExportSpans(context.Background(), testSpans()); err != nil {
+		t.Fatalf("ExportSpans() error = %v", err)
+	}
+
+	if method != http.MethodPost || path != "/v1/traces" {
+		t.Errorf("request = %s %s, want POST /v1/traces", method, path)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if apiKey != "secret" {
+		t.Errorf("X-API-Key = %q, want the configured header", apiKey)
+	}
+	if !jsonEqual(t, body, []byte(otlpTestRequest)) {
+		t.Errorf("body = %s\nwant %s", body, otlpTestRequest)
+	}
+}
+
+func TestOTLPExporterFailed(t *testing.T) {
+	requests := 0
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(collector.URL+"/v1/traces", nil)
+	if err := exporter.ExportSpans(context.Background(), testSpans()); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("ExportSpans() error = %v, want the status of the collector", err)
+	}
+	// an empty batch is not worth a request
+	if err := exporter.ExportSpans(context.Background(), nil); err != nil || requests != 1 {
+		t.Errorf("ExportSpans() of no spans error = %v after %d requests, want none sent", err, requests)
+	}
+}
+
+func TestTracerReportsExportErrors(t *testing.T) {
+	var reported []error
+	tracer := New(Options{Exporter: failingExporter{}, SampleRatio: 1, OnError: func(err error) {
+		reported = append(reported, err)
+	}})
+
+	_, span := tracer.Start(context.Background(), "work")
+	span.End()
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	tracer.Shutdown(context.Background())
+
+	if len(reported) != 1 {
+		t.Errorf("reported %v, want the failed export", reported)
+	}
+}
+
+type failingExporter struct{}
+
+func (failingExporter) ExportSpans(context.Context, []SpanData) error {
+	return errors.New("collector unreachable")
+}
+
+func (failingExporter) Shutdown(context.Context) error { return nil }
+
+func TestStdoutExporter(t *testing.T) {
+	var out bytes.Buffer
+	if err := NewStdoutExporter(&out).ExportSpans(context.Background(), testSpans()); err != nil {
+		t.Fatalf("ExportSpans() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %d lines, want one per span", len(lines))
+	}
+	var span map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &span); err != nil {
+		t.Fatalf("line %q: %v", lines[0], err)
+	}
+	want := map[string]any{
+		"name":           "SELECT",
+		"kind":           "client",
+		"trace_id":       "5b8efff798038103d269b633813fc60c",
+		"parent_span_id": "eee19b7ec3c1b173",
+		"duration":       "10ms",
+		"status":         "error",
+	}
+	for key, value := range want {
+		if span[key] != value {
+			t.Errorf("%s = %v, want %v", key, span[key], value)
+		}
+	}
+}
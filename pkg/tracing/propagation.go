@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+
+	traceparentLength = 55
+	flagSampled       = 0x01
+)
+
+// Inject writes the context of the current span into the W3C trace context
+// headers of an outgoing request
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	var flags byte
+	if sc.Sampled {
+		flags = flagSampled
+	}
+	header.Set(TraceparentHeader, fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags))
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	}
+}
+
+// Extract returns a copy of ctx continuing the trace of the incoming request,
+// ctx is returned unchanged when the request carries no valid traceparent
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	sc.TraceState = header.Get(TracestateHeader)
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// ParseTraceparent reads a traceparent header value such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01. Versions above 00
+// are read as far as version 00 goes, as the specification asks.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	if len(value) < traceparentLength || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, false
+	}
+
+	version, ok := parseHexByte(value[0:2])
+	if !ok || version == 0xff {
+		return sc, false
+	}
+	if version == 0 && len(value) != traceparentLength {
+		return sc, false
+	}
+	if len(value) > traceparentLength && value[traceparentLength] != '-' {
+		return sc, false
+	}
+
+	if !isLowerHex(value[3:35]) || !isLowerHex(value[36:52]) {
+		return sc, false
+	}
+	hex.Decode(sc.TraceID[:], []byte(value[3:35]))
+	hex.Decode(sc.SpanID[:], []byte(value[36:52]))
+	if !sc.IsValid() {
+		return sc, false
+	}
+
+	flags, ok := parseHexByte(value[53:55])
+	if !ok {
+		return sc, false
+	}
+	sc.Sampled = flags&flagSampled != 0
+	return sc, true
+}
+
+func parseHexByte(s string) (byte, bool) {
+	if !isLowerHex(s) {
+		return 0, false
+	}
+	var b [1]byte
+	hex.Decode(b[:], []byte(s))
+	return b[0], true
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
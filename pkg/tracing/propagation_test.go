@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPropagationRoundTrip(t *testing.T) {
+	tracer, _ := newTestTracer(t, 0)
+
+	tests := []struct {
+		name        string
+		traceparent string
+		tracestate  string
+		wantFlags   string
+	}{
+		{name: "sampled", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tracestate: "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7", wantFlags: "01"},
+		{name: "not sampled", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", wantFlags: "00"},
+		// flags other than sampled are not passed on
+		{name: "unknown flags", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09", wantFlags: "01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incoming := http.Header{}
+			incoming.Set(TraceparentHeader, tt.traceparent)
+			if tt.tracestate != "" {
+				incoming.Set(TracestateHeader, tt.tracestate)
+			}
+
+			ctx := Extract(context.Background(), incoming)
+			ctx, span := tracer.StartServer(ctx, "server")
+			defer span.End()
+
+			outgoing := http.Header{}
+			Inject(ctx, outgoing)
+
+			want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanContext().SpanID.String() + "-" + tt.wantFlags
+			if got := outgoing.Get(TraceparentHeader); got != want {
+				t.Errorf("traceparent = %q, want %q", got, want)
+			}
+			if got := outgoing.Get(TracestateHeader); got != tt.tracestate {
+				t.Errorf("tracestate = %q, want %q", got, tt.tracestate)
+			}
+		})
+	}
+}
+
+func TestInjectWithoutSpan(t *testing.T) {
+	header := http.Header{}
+	Inject(context.Background(), header)
+	if len(header) != 0 {
+		t.Errorf("header = %v, want nothing injected", header)
+	}
+}
+
+func TestExtractInvalid(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	ctx := context.Background()
+	if Extract(ctx, header) != ctx {
+		t.Error("Extract() of an invalid traceparent changed the context")
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+
+	tests := []struct {
+		name    string
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{name: "sampled", value: "00-" + traceID + "-" + spanID + "-01", valid: true, sampled: true},
+		{name: "not sampled", value: "00-" + traceID + "-" + spanID + "-00", valid: true},
+		{name: "future version", value: "cc-" + traceID + "-" + spanID + "-01-what-the-future-holds", valid: true, sampled: true},
+		{name: "future version without more fields", value: "cc-" + traceID + "-" + spanID + "-01", valid: true, sampled: true},
+		{name: "version ff", value: "ff-" + traceID + "-" + spanID + "-01"},
+		{name: "version 00 with more fields", value: "00-" + traceID + "-" + spanID + "-01-more"},
+		{name: "future version with glued fields", value: "cc-" + traceID + "-" + spanID + "-01what"},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-" + spanID + "-01"},
+		{name: "zero span id", value: "00-" + traceID + "-0000000000000000-01"},
+		{name: "upper case", value: "00-" + strings.ToUpper(traceID) + "-" + spanID + "-01"},
+		{name: "bad flags", value: "00-" + traceID + "-" + spanID + "-0x"},
+		{name: "short trace id", value: "00-" + traceID[1:] + "-" + spanID + "-01"},
+		{name: "empty", value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.value)
+			if ok != tt.valid {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.valid)
+			}
+			if !ok {
+				return
+			}
+			if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != tt.sampled {
+				t.Errorf("span context = %+v", sc)
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	tracer, exporter := newTestTracer(t, 1)
+	previous := Default()
+	SetDefault(tracer)
+	t.Cleanup(func() { SetDefault(previous) })
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(TraceparentHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, parent := tracer.Start(context.Background(), "IdentityService.CompleteLogin")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/token?code=secret", nil)
+	res, err := (&http.Client{Transport: NewTransport(nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	res.Body.Close()
+	parent.End()
+
+	if req.Header.Get(TraceparentHeader) != "" {
+		t.Error("the transport modified the request it was given")
+	}
+	spans := ended(t, tracer, exporter)
+	client := spans["POST"]
+	if client.ParentSpanID != parent.SpanContext().SpanID || client.Kind != SpanKindClient {
+		t.Errorf("client span = %+v, want a child of the caller", client)
+	}
+	if want := "00-" + client.SpanContext.TraceID.String() + "-" + client.SpanContext.SpanID.String() + "-01"; traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+	for _, attr := range client.Attributes {
+		if attr.Key == "url.full" && attr.Value != server.URL+"/token" {
+			t.Errorf("url.full = %v, want it without the query", attr.Value)
+		}
+	}
+	if client.Status.Code != StatusError {
+		t.Errorf("status = %+v, want an error for a 502", client.Status)
+	}
+}
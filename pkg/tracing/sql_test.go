@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+var errRelation = errors.New(`relation "todos" does not exist`)
+
+// fakeConnector answers every query with no rows and fails the queries
+// against the todos table
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query == "SELECT * FROM todos WHERE user_id = $1" {
+		return nil, errRelation
+	}
+	return fakeRows{}, nil
+}
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"id"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func TestWrapConnector(t *testing.T) {
+	tracer, exporter := newTestTracer(t, 1)
+	previous := Default()
+	SetDefault(tracer)
+	t.Cleanup(func() { SetDefault(previous) })
+
+	db := sql.OpenDB(WrapConnector(fakeConnector{}, "postgresql"))
+	defer db.Close()
+
+	ctx, parent := Start(context.Background(), "TodoService.ListTodos")
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users WHERE email = $1", "ada@example.com")
+	if err != nil {
+		t.Fatalf("QueryContext() error = %v", err)
+	}
+	rows.Close()
+	if _, err := db.ExecContext(ctx, "\n\tinsert into todos (title) VALUES ($1)", "secret title"); err != nil {
+		t.Fatalf("ExecContext() error = %v", err)
+	}
+	// the driver error reaches the repository unchanged
+	if _, err := db.QueryContext(ctx, "SELECT * FROM todos WHERE user_id = $1", 1); !errors.Is(err, errRelation) {
+		t.Fatalf("QueryContext() error = %v, want %v", err, errRelation)
+	}
+	parent.End()
+
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	spans := exporter.Spans()
+	if len(spans) != 4 {
+		t.Fatalf("exported %d spans, want 4", len(spans))
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status Status
+	}{
+		{name: "SELECT", query: "SELECT id FROM users WHERE email = $1"},
+		{name: "INSERT", query: "\n\tinsert into todos (title) VALUES ($1)"},
+		{name: "SELECT", query: "SELECT * FROM todos WHERE user_id = $1", status: Status{Code: StatusError, Description: errRelation.Error()}},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name != tt.name || span.Kind != SpanKindClient {
+			t.Errorf("span %d = %s %s, want a client span %s", i, span.Kind, span.Name, tt.name)
+		}
+		if span.ParentSpanID != parent.SpanContext().SpanID {
+			t.Errorf("span %d is not a child of the service span", i)
+		}
+		want := []Attribute{
+			String("db.system.name", "postgresql"),
+			String("db.operation.name", tt.name),
+			String("db.query.text", tt.query),
+		}
+		if len(span.Attributes) != len(want) {
+			t.Errorf("span %d attributes = %v, want %v", i, span.Attributes, want)
+		} else {
+			for j := range want {
+				// the arguments never end up in the span
+				if span.Attributes[j] != want[j] {
+					t.Errorf("span %d attributes = %v, want %v", i, span.Attributes, want)
+					break
+				}
+			}
+		}
+		if span.Status != tt.status {
+			t.Errorf("span %d status = %+v, want %+v", i, span.Status, tt.status)
+		}
+	}
+	if events := spans[2].Events; len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("events of the failed query = %+v, want an exception", events)
+	}
+}
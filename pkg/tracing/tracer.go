@@ -0,0 +1,320 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	queueSize    = 2048
+	batchSize    = 512
+	batchTimeout = 5 * time.Second
+)
+
+type Options struct {
+	ServiceName    string
+	ServiceVersion string
+	// Exporter receives the ended spans, without one nothing is recorded
+	Exporter Exporter
+	// SampleRatio is the share of new traces that are recorded, from 0 to 1.
+	// Requests continuing a trace follow the sampling decision of the caller.
+	SampleRatio float64
+	// OnError is called with failed exports, the default logs them
+	OnError func(error)
+}
+
+// Tracer starts spans and exports the ended ones in batches from a
+// background goroutine, so requests never wait for the exporter
+type Tracer struct {
+	resource  []Attribute
+	exporter  Exporter
+	threshold uint64
+	onError   func(error)
+
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopped  sync.WaitGroup
+	shutdown sync.Once
+}
+
+func New(opts Options) *Tracer {
+	t := &Tracer{
+		resource: []Attribute{String("service.name", opts.ServiceName)},
+		exporter: opts.Exporter,
+		onError:  opts.OnError,
+		done:     make(chan struct{}),
+	}
+	if opts.ServiceVersion != "" {
+		t.resource = append(t.resource, String("service.version", opts.ServiceVersion))
+	}
+	if t.onError == nil {
+		t.onError = func(err error) {
+			slog.Error("failed to export spans", "error", err)
+		}
+	}
+
+	switch {
+	case opts.SampleRatio >= 1:
+		t.threshold = 1 << 63
+	case opts.SampleRatio > 0:
+		t.threshold = uint64(opts.SampleRatio * (1 << 63))
+	}
+
+	if t.exporter != nil {
+		t.queue = make(chan SpanData, queueSize)
+		t.flush = make(chan chan struct{})
+		t.stopped.Add(1)
+		go t.run()
+	}
+	return t
+}
+
+// Start begins an internal span, a child of the span in ctx if there is one.
+// The returned context carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return t.start(ctx, name, SpanKindInternal, attrs)
+}
+
+// StartServer begins the span of an incoming request, usually continuing the
+// trace extracted from its headers
+func (t *Tracer) StartServer(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return t.start(ctx, name, SpanKindServer, attrs)
+}
+
+// StartClient begins the span of a call to another service or the database
+func (t *Tracer) StartClient(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return t.start(ctx, name, SpanKindClient, attrs)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, attrs []Attribute) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.TraceState = parent.TraceState
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+		// the same ratio sampling as OpenTelemetry, decided by the trace id
+		sc.Sampled = binary.BigEndian.Uint64(sc.TraceID[8:])>>1 < t.threshold
+	}
+
+	span := &Span{
+		tracer:    t,
+		recording: sc.Sampled && t.exporter != nil,
+		data:      SpanData{SpanContext: sc},
+	}
+	if span.recording {
+		span.data = SpanData{
+			Name:         name,
+			Kind:         kind,
+			SpanContext:  sc,
+			ParentSpanID: parent.SpanID,
+			Start:        time.Now(),
+			Attributes:   attrs,
+			Resource:     t.resource,
+		}
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case <-t.done:
+	case t.queue <- data:
+	default:
+		// the exporter is falling behind, drop rather than block the request
+	}
+}
+
+func (t *Tracer) run() {
+	defer t.stopped.Done()
+
+	batch := make([]SpanData, 0, batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+			t.onError(err)
+		}
+		batch = make([]SpanData, 0, batchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case data := <-t.queue:
+				batch = append(batch, data)
+				if len(batch) == batchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	ticker := time.NewTicker(batchTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) == batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-t.flush:
+			drain()
+			close(flushed)
+		case <-t.done:
+			drain()
+			return
+		}
+	}
+}
+
+// ForceFlush exports the spans ended so far, tests call it before looking at
+// an InMemoryExporter
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	flushed := make(chan struct{})
+	select {
+	case t.flush <- flushed:
+	case <-t.done:
+		return errors.New("tracing: tracer is shut down")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the queued spans and shuts the exporter down, spans ended
+// afterwards are dropped
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	var err error
+	t.shutdown.Do(func() {
+		close(t.done)
+
+		stopped := make(chan struct{})
+		go func() {
+			t.stopped.Wait()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+
+		err = t.exporter.Shutdown(ctx)
+	})
+	return err
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+type contextKey int
+
+const (
+	spanContextKey contextKey = iota
+	remoteContextKey
+)
+
+// ContextWithSpan returns a copy of ctx carrying span as the current span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey, span)
+}
+
+// SpanFromContext returns the current span or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext makes sc the parent of the next span started
+// from ctx, see Extract
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteContextKey, sc)
+}
+
+// SpanContextFromContext returns the context of the current span, or of the
+// remote parent when no span has been started yet
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteContextKey).(SpanContext)
+	return sc
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(New(Options{}))
+}
+
+// SetDefault makes t the tracer of Start, StartServer and StartClient. Until
+// it is called spans carry ids but nothing is recorded.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// Start begins an internal span with the default tracer
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return Default().Start(ctx, name, attrs...)
+}
+
+// StartServer begins a server span with the default tracer
+func StartServer(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return Default().StartServer(ctx, name, attrs...)
+}
+
+// StartClient begins a client span with the default tracer
+func StartClient(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return Default().StartClient(ctx, name, attrs...)
+}
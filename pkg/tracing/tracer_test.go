@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newTestTracer(t *testing.T, ratio float64) (*Tracer, *InMemoryExporter) {
+	t.Helper()
+	exporter := NewInMemoryExporter()
+	tracer := New(Options{ServiceName: "test", Exporter: exporter, SampleRatio: ratio})
+	t.Cleanup(func() { tracer.Shutdown(context.Background()) })
+	return tracer, exporter
+}
+
+// ended flushes the tracer and returns the spans exported so far by name
+func ended(t *testing.T, tracer *Tracer, exporter *InMemoryExporter) map[string]SpanData {
+	t.Helper()
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	spans := make(map[string]SpanData)
+	for _, span := range exporter.Spans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func TestParentChild(t *testing.T) {
+	tracer, exporter := newTestTracer(t, 1)
+
+	ctx, server := tracer.StartServer(context.Background(), "GET /todos")
+	ctx, service := tracer.Start(ctx, "TodoService.ListTodos", Int("user.id", 1))
+	_, query := tracer.StartClient(ctx, "SELECT")
+	query.End()
+	service.End()
+	server.End()
+
+	spans := ended(t, tracer, exporter)
+	if len(spans) != 3 {
+		t.Fatalf("exported %d spans, want 3", len(spans))
+	}
+
+	root := spans["GET /todos"]
+	if root.ParentSpanID.IsValid() || root.Kind != SpanKindServer {
+		t.Errorf("root = %+v, want a server span without parent", root)
+	}
+	tests := []struct {
+		name   string
+		parent string
+		kind   SpanKind
+	}{
+		{"TodoService.ListTodos", "GET /todos", SpanKindInternal},
+		{"SELECT", "TodoService.ListTodos", SpanKindClient},
+	}
+	for _, tt := range tests {
+		span := spans[tt.name]
+		if span.SpanContext.TraceID != root.SpanContext.TraceID {
+			t.Errorf("%s is in trace %s, want %s", tt.name, span.SpanContext.TraceID, root.SpanContext.TraceID)
+		}
+		if span.ParentSpanID != spans[tt.parent].SpanContext.SpanID {
+			t.Errorf("parent of %s = %s, want %s", tt.name, span.ParentSpanID, tt.parent)
+		}
+		if span.Kind != tt.kind {
+			t.Errorf("kind of %s = %s, want %s", tt.name, span.Kind, tt.kind)
+		}
+	}
+	if got := spans["TodoService.ListTodos"].Attributes; len(got) != 1 || got[0] != Int("user.id", 1) {
+		t.Errorf("attributes = %v, want user.id", got)
+	}
+	if got := root.Resource; len(got) != 1 || got[0] != String("service.name", "test") {
+		t.Errorf("resource = %v, want service.name", got)
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	const traces = 10000
+
+	tests := []struct {
+		ratio    float64
+		min, max int
+	}{
+		{ratio: 0, min: 0, max: 0},
+		{ratio: 0.25, min: traces * 20 / 100, max: traces * 30 / 100},
+		{ratio: 1, min: traces, max: traces},
+		{ratio: 2, min: traces, max: traces},
+	}
+
+	for _, tt := range tests {
+		tracer := New(Options{SampleRatio: tt.ratio, Exporter: NewInMemoryExporter()})
+		sampled := 0
+		for range traces {
+			ctx, span := tracer.Start(context.Background(), "root")
+			// children never decide on their own
+			_, child := tracer.Start(ctx, "child")
+			if child.IsRecording() != span.IsRecording() || child.SpanContext().Sampled != span.SpanContext().Sampled {
+				t.Fatalf("ratio %v: child sampled = %v, root %v", tt.ratio, child.SpanContext().Sampled, span.SpanContext().Sampled)
+			}
+			if !span.SpanContext().IsValid() {
+				t.Fatalf("ratio %v: span context %+v is not valid", tt.ratio, span.SpanContext())
+			}
+			if span.IsRecording() {
+				sampled++
+			}
+		}
+		tracer.Shutdown(context.Background())
+
+		if sampled < tt.min || sampled > tt.max {
+			t.Errorf("ratio %v sampled %d of %d traces, want %d to %d", tt.ratio, sampled, traces, tt.min, tt.max)
+		}
+	}
+}
+
+func TestParentBasedSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		ratio   float64
+		sampled bool
+	}{
+		{name: "sampled caller overrides ratio 0", ratio: 0, sampled: true},
+		{name: "unsampled caller overrides ratio 1", ratio: 1, sampled: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, exporter := newTestTracer(t, tt.ratio)
+			remote := SpanContext{
+				TraceID:    TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+				SpanID:     SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+				Sampled:    tt.sampled,
+				TraceState: "vendor=value",
+			}
+			ctx := ContextWithRemoteSpanContext(context.Background(), remote)
+
+			ctx, server := tracer.StartServer(ctx, "server")
+			_, child := tracer.Start(ctx, "child")
+			child.End()
+			server.End()
+
+			for _, span := range []*Span{server, child} {
+				sc := span.SpanContext()
+				if sc.TraceID != remote.TraceID || sc.Sampled != tt.sampled || sc.TraceState != remote.TraceState {
+					t.Errorf("span context = %+v, want the trace of the caller", sc)
+				}
+				if span.IsRecording() != tt.sampled {
+					t.Errorf("recording = %v, want %v", span.IsRecording(), tt.sampled)
+				}
+			}
+
+			spans := ended(t, tracer, exporter)
+			if !tt.sampled {
+				if len(spans) != 0 {
+					t.Errorf("exported %d spans, want none", len(spans))
+				}
+				return
+			}
+			if got := spans["server"].ParentSpanID; got != remote.SpanID {
+				t.Errorf("parent of the server span = %s, want the caller span %s", got, remote.SpanID)
+			}
+			if got := spans["child"].ParentSpanID; got != server.SpanContext().SpanID {
+				t.Errorf("parent of the child = %s, want the server span", got)
+			}
+		})
+	}
+}
+
+func TestSpanStatus(t *testing.T) {
+	tracer, exporter := newTestTracer(t, 1)
+
+	_, span := tracer.Start(context.Background(), "work")
+	span.RecordError(errors.New("connection refused"))
+	span.SetStatus(StatusOK, "")
+	span.RecordError(nil)
+	span.End()
+	// ending twice exports once
+	span.End()
+
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	exported := exporter.Spans()
+	if len(exported) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(exported))
+	}
+	got := exported[0]
+	if got.Status != (Status{Code: StatusError, Description: "connection refused"}) {
+		t.Errorf("status = %+v, want the error kept", got.Status)
+	}
+	if len(got.Events) != 1 || got.Events[0].Name != "exception" || got.Events[0].Attributes[1] != String("exception.message", "connection refused") {
+		t.Errorf("events = %+v, want one exception", got.Events)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := New(Options{Exporter: exporter, SampleRatio: 1})
+
+	_, span := tracer.Start(context.Background(), "before")
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	_, span = tracer.Start(context.Background(), "after")
+	span.End()
+
+	if spans := exporter.Spans(); len(spans) != 1 || spans[0].Name != "before" {
+		t.Errorf("exported %+v, want only the span ended before Shutdown", spans)
+	}
+	if err := tracer.ForceFlush(context.Background()); err == nil {
+		t.Error("ForceFlush() after Shutdown succeeded")
+	}
+}
+
+func TestWithoutExporter(t *testing.T) {
+	tracer := New(Options{SampleRatio: 1})
+	ctx, span := tracer.Start(context.Background(), "work")
+	defer span.End()
+
+	// ids still flow to downstream services
+	if !span.SpanContext().IsValid() || span.IsRecording() {
+		t.Errorf("span = %+v, want a valid context that records nothing", span.SpanContext())
+	}
+	if SpanFromContext(ctx) != span {
+		t.Error("SpanFromContext() did not return the started span")
+	}
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"net/http"
+)
+
+// Transport starts a client span for every outgoing request and passes the
+// trace on in the traceparent header
+type Transport struct {
+	// Base sends the requests, http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// the query is left out, it may carry codes or tokens
+	url := *req.URL
+	url.User = nil
+	url.RawQuery = ""
+	url.Fragment = ""
+
+	ctx, span := StartClient(req.Context(), req.Method,
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Hostname()),
+		String("url.full", url.String()),
+	)
+	defer span.End()
+
+	// a RoundTripper must not modify the request it was given
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode >= 400 {
+		span.SetStatus(StatusError, res.Status)
+	}
+	return res, nil
+}
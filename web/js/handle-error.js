@@ -3,7 +3,10 @@ document.addEventListener("htmx:beforeSwap", (e) => {
     e.detail.xhr.status === 400 ||
     e.detail.xhr.status === 422 ||
     e.detail.xhr.status === 401 ||
+    e.detail.xhr.status === 403 ||
+    e.detail.xhr.status === 404 ||
     e.detail.xhr.status === 409 ||
+    e.detail.xhr.status === 429 ||
     e.detail.xhr.status === 500
   ) {
     e.detail.shouldSwap = true;